@@ -35,7 +35,7 @@ This appends the contents of localFileOne & Two to example/journal/name.`,
 			}
 			userConfirms(fmt.Sprintf("WARNING: Really append %s to %s? This cannot be undone.", p, name))
 
-			if result := gazetteClient().Put(journal.AppendArgs{
+			if result := journalClient().Put(journal.AppendArgs{
 				Journal: name,
 				Content: file,
 			}); result.Error != nil {
@@ -2,6 +2,8 @@ package cmd
 
 import (
 	"fmt"
+	"sort"
+	"strings"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
@@ -15,30 +17,94 @@ var createCmd = &cobra.Command{
 	Long: `Create one or more new gazette journals.
 
 Example: gazctl create examples/a-journal/one examples/a-journal/two
-This creates journals examples/a-journal/one & examples/a-journal/two.`,
+This creates journals examples/a-journal/one & examples/a-journal/two.
+
+Example: gazctl create examples/a-journal/three --fragment-size 16777216 --retention 720h
+This creates journal examples/a-journal/three with 16MiB fragments, which are
+retained for 30 days. Unspecified options take cluster defaults.
+
+Example: gazctl create examples/a-journal/four --flush-interval 1h
+This creates journal examples/a-journal/four, with fragments which are flushed
+to cloud storage at the top of each hour.
+
+Example: gazctl create examples/a-journal/five --label topic=examples/a-journal
+This creates journal examples/a-journal/five, labeled as a partition of topic
+examples/a-journal. See "gazctl list".`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) == 0 {
 			cmd.Usage()
 			log.Fatal("invalid arguments")
 		}
 
+		if err := createSpec.Validate(); err != nil {
+			log.WithField("err", err).Fatal("invalid journal spec")
+		}
+
 		for i := range args {
 			var name = journal.Name(args[i])
 
 			userConfirms(fmt.Sprintf(
 				"WARNING: Really create %s? This cannot be undone.", name.String()))
 
-			if err := gazetteClient().Create(name); err != nil {
+			if err := journalClient().CreateWithSpec(name, createSpec); err != nil {
 				log.WithField("err", err).Fatal("failed to create journal")
 			} else {
-				log.WithField("name", name).Info("created journal")
+				log.WithFields(log.Fields{"name": name, "spec": createSpec}).Info("created journal")
 			}
 		}
 	},
 }
 
+var createSpec journal.JournalSpec
+
 func init() {
 	rootCmd.AddCommand(createCmd)
 
 	createCmd.Flags().BoolVarP(&defaultYes, "yes", "y", false, "Create without asking for confirmation.")
+	addSpecFlags(createCmd, &createSpec)
+}
+
+// addSpecFlags adds flags to |cmd| for each field of JournalSpec |spec|.
+func addSpecFlags(cmd *cobra.Command, spec *journal.JournalSpec) {
+	cmd.Flags().IntVar(&spec.Replication, "replication", 0,
+		"Number of required journal replicas, in addition to the broker (default cluster replica count)")
+	cmd.Flags().Int64Var(&spec.FragmentSize, "fragment-size", 0,
+		"Target size of journal fragments, in bytes (default 1GiB)")
+	cmd.Flags().DurationVar(&spec.FlushInterval, "flush-interval", 0,
+		"Interval at which journal fragments are flushed, regardless of size (default none)")
+	cmd.Flags().DurationVar(&spec.Retention, "retention", 0,
+		"Duration for which journal content is retained (default forever)")
+	cmd.Flags().Int64Var(&spec.RetentionBytes, "retention-bytes", 0,
+		"Number of trailing journal bytes which are retained (default all)")
+	cmd.Flags().StringVar((*string)(&spec.CompressionCodec), "compression-codec", "",
+		"Codec of persisted journal fragments: none, gzip, snappy, or zstd (default none)")
+	cmd.Flags().Var(labelsValue{&spec.Labels}, "label",
+		"Journal label, as key=value. May be repeated")
+}
+
+// labelsValue is a pflag.Value which parses repeated "key=value" flags into
+// a map of labels.
+type labelsValue struct{ labels *map[string]string }
+
+func (v labelsValue) Set(s string) error {
+	var kv = strings.SplitN(s, "=", 2)
+	if len(kv) != 2 {
+		return fmt.Errorf("expected key=value: %q", s)
+	}
+	if *v.labels == nil {
+		*v.labels = make(map[string]string)
+	}
+	(*v.labels)[kv[0]] = kv[1]
+	return nil
 }
+
+func (v labelsValue) String() string {
+	var kvs []string
+	for key, value := range *v.labels {
+		kvs = append(kvs, key+"="+value)
+	}
+	sort.Strings(kvs)
+	return strings.Join(kvs, ",")
+}
+
+func (v labelsValue) Type() string { return "key=value" }
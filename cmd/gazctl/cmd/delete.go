@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+var deleteCmd = &cobra.Command{
+	Use:   "delete [journal name] [journal name] ...",
+	Short: "Delete gazette journals",
+	Long: `Delete one or more gazette journals. Appends to the journals are fenced,
+and their spooled content is flushed to cloud storage before the journals
+are removed. By default, persisted fragments of deleted journals are kept.
+
+Example: gazctl delete examples/a-journal/one --fragments delete
+This deletes journal examples/a-journal/one and all of its fragments.
+
+Example: gazctl delete examples/a-journal/two --fragments move --destination archive
+This deletes journal examples/a-journal/two, and moves its fragments to
+archive/examples/a-journal/two. They may be read again by creating journal
+archive/examples/a-journal/two.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 0 {
+			cmd.Usage()
+			log.Fatal("invalid arguments")
+		}
+
+		for i := range args {
+			var deleteArgs = journal.DeleteArgs{
+				Journal:     journal.Name(args[i]),
+				Fragments:   journal.FragmentDisposition(deleteFragments),
+				Destination: journal.Name(deleteDestination),
+			}
+			if err := deleteArgs.Validate(); err != nil {
+				log.WithField("err", err).Fatal("invalid arguments")
+			}
+
+			userConfirms(fmt.Sprintf(
+				"WARNING: Really delete %s? This cannot be undone.", deleteArgs.Journal))
+
+			if err := gazetteClient().Delete(deleteArgs); err != nil {
+				log.WithFields(log.Fields{"err": err, "args": deleteArgs}).Fatal("failed to delete journal")
+			} else {
+				log.WithField("args", deleteArgs).Info("deleted journal")
+			}
+		}
+	},
+}
+
+var deleteFragments, deleteDestination string
+
+func init() {
+	rootCmd.AddCommand(deleteCmd)
+
+	deleteCmd.Flags().BoolVarP(&defaultYes, "yes", "y", false, "Delete without asking for confirmation.")
+	deleteCmd.Flags().StringVar(&deleteFragments, "fragments", string(journal.KeepFragments),
+		"Disposition of persisted journal fragments: keep, delete, or move")
+	deleteCmd.Flags().StringVar(&deleteDestination, "destination", "",
+		"Prefix under which fragments are moved, with --fragments move")
+}
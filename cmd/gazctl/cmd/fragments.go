@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+var fragmentsCmd = &cobra.Command{
+	Use:   "fragments",
+	Short: "Commands for working with persisted journal fragments",
+}
+
+var fragmentsVerifyCmd = &cobra.Command{
+	Use:   "verify [journal name prefix]",
+	Short: "Verify the integrity of persisted journal fragments",
+	Long: `Verify walks persisted fragments of journals having a name prefix. Each
+fragment is streamed from cloud storage and its length and SHA1 sum are checked
+against its content name. Fragments of each journal are also checked for
+offset gaps not covered by any fragment. Findings are printed as JSON, and the command exits
+with a non-zero status if there are any.
+
+Example: gazctl fragments verify examples/a-journal/
+This verifies fragments of journals examples/a-journal/one, examples/a-journal/two, etc.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) != 1 {
+			cmd.Usage()
+			log.Fatal("invalid arguments")
+		}
+
+		var out = json.NewEncoder(os.Stdout)
+		var stats, err = journal.Scrub(cloudFS(), args[0], func(finding journal.Finding) {
+			if err := out.Encode(finding); err != nil {
+				log.WithField("err", err).Fatal("failed to write finding")
+			}
+		})
+		if err != nil {
+			log.WithFields(log.Fields{"err": err, "prefix": args[0]}).Fatal("failed to walk fragments")
+		}
+
+		log.WithField("stats", stats).Info("verified fragments")
+		if stats.Findings != 0 {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(fragmentsCmd)
+	fragmentsCmd.AddCommand(fragmentsVerifyCmd)
+}
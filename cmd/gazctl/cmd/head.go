@@ -31,7 +31,7 @@ Example: gazctl head examples/a-journal/one examples/a-journal/two`,
 			}
 
 			var result journal.ReadResult
-			if result, _ = gazetteClient().Head(args); result.Error != nil {
+			if result, _ = journalClient().Head(args); result.Error != nil {
 				log.WithFields(log.Fields{"err": result.Error, "name": args.Journal}).Fatal("failed to HEAD journal")
 			}
 
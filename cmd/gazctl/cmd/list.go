@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list [journal name prefix]",
+	Short: "List gazette journals",
+	Long: `List gazette journals having a name prefix, and optionally having labels.
+Each journal is printed as JSON, with its labels, route and current write head.
+
+Example: gazctl list examples/a-journal/
+This lists journals examples/a-journal/one, examples/a-journal/two, etc.
+
+Example: gazctl list --label topic=examples/a-journal
+This lists all journals labeled as partitions of topic examples/a-journal.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) > 1 {
+			cmd.Usage()
+			log.Fatal("invalid arguments")
+		}
+		var listArgs = journal.ListArgs{Labels: listLabels}
+		if len(args) == 1 {
+			listArgs.Prefix = journal.Name(args[0])
+		}
+
+		var journals, err = gazetteClient().List(listArgs)
+		if err != nil {
+			log.WithFields(log.Fields{"err": err, "args": listArgs}).Fatal("failed to list journals")
+		}
+
+		var out = json.NewEncoder(os.Stdout)
+		for _, listed := range journals {
+			if err := out.Encode(listed); err != nil {
+				log.WithFields(log.Fields{"err": err, "name": listed.Name}).Fatal("failed to write list output")
+			}
+		}
+	},
+}
+
+var listLabels map[string]string
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+
+	listCmd.Flags().Var(labelsValue{&listLabels}, "label",
+		"Select journals having label key=value. May be repeated")
+}
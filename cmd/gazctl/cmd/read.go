@@ -18,14 +18,18 @@ var readCmd = &cobra.Command{
 	Short: "Read the contents of a gazette journal",
 	Long: `Read and output the contents of a gazette journal.
 Reads may be blocking / tailing (--block) and may begin from any non-zero
-offset (--offset), or -1 (which reads from the current journal head).
+offset (--offset), -1 (which reads from the current journal head), or the
+first offset committed at or after a time (--since).
 
 Example: gazctl cat examples/a-journal
 This reads journal content from byte-offset zero, through to the current write head.
 
 Example: gazctl cat examples/a-journal --offset 1234 --block 1m
 This reads journal content from byte-offset 1234, and blocks one minute to read
-new content as it is appended.`,
+new content as it is appended.
+
+Example: gazctl cat examples/a-journal --since 2018-01-02T09:00:00Z
+This reads journal content committed since 09:00 UTC on January 2nd, 2018.`,
 	Run: func(cmd *cobra.Command, args []string) {
 		if len(args) != 1 {
 			cmd.Usage()
@@ -37,12 +41,28 @@ new content as it is appended.`,
 			Offset:  readOffset,
 		}
 
+		if readSince != "" {
+			var since, err = time.Parse(time.RFC3339, readSince)
+			if err != nil {
+				log.WithField("err", err).Fatal("invalid --since time")
+			}
+			var result, _ = journalClient().Head(journal.ReadArgs{Journal: mark.Journal, Timestamp: since})
+
+			if result.Error == journal.ErrNotYetAvailable {
+				mark.Offset = result.WriteHead // Nothing was committed since |since|.
+			} else if result.Error != nil {
+				log.WithFields(log.Fields{"err": result.Error, "since": since}).Fatal("failed to resolve --since time")
+			} else {
+				mark.Offset = result.Offset
+			}
+		}
+
 		var ctx = context.Background()
 		if readTimeout != 0 {
 			ctx, _ = context.WithTimeout(ctx, readTimeout)
 		}
 
-		var reader = journal.NewRetryReaderContext(ctx, mark, gazetteClient())
+		var reader = journal.NewRetryReaderContext(ctx, mark, journalClient())
 		reader.Blocking = (readTimeout != 0)
 
 		var br = bufio.NewReader(reader)
@@ -57,6 +77,7 @@ new content as it is appended.`,
 
 var (
 	readOffset  int64
+	readSince   string
 	readTimeout time.Duration
 )
 
@@ -65,6 +86,8 @@ func init() {
 
 	readCmd.Flags().Int64VarP(&readOffset, "offset", "c", 0,
 		"Byte offset to begin reading from, or -1 for the current write-head")
+	readCmd.Flags().StringVar(&readSince, "since", "",
+		"RFC 3339 time from which to begin reading, instead of --offset")
 	readCmd.Flags().DurationVarP(&readTimeout, "block", "t", 0,
 		"Total duration to block for, reading ongoing journal appends. Zero (default) does not block")
 }
@@ -19,6 +19,7 @@ import (
 
 	"github.com/LiveRamp/gazette/pkg/consumer"
 	"github.com/LiveRamp/gazette/pkg/gazette"
+	"github.com/LiveRamp/gazette/pkg/journal"
 	"github.com/LiveRamp/gazette/pkg/recoverylog"
 )
 
@@ -34,6 +35,9 @@ func Execute() {
 	if lazyWriteService != nil {
 		lazyWriteService.Stop()
 	}
+	if lazyGRPCClient != nil {
+		lazyGRPCClient.Close()
+	}
 }
 
 // rootCmd parents all other commands in the hierarchy.
@@ -105,6 +109,32 @@ func gazetteClient() *gazette.Client {
 	return lazyGazetteClient
 }
 
+// contentClient creates journals, reads their content, and appends to them
+// with Gazette PUTs.
+type contentClient interface {
+	journal.Getter
+	journal.Header
+	CreateWithSpec(name journal.Name, spec journal.JournalSpec) error
+	Put(args journal.AppendArgs) journal.AppendResult
+}
+
+// journalClient returns the client used to create journals, and to read and
+// append journal content. If gazette.grpcEndpoint is configured, it uses the
+// Journal gRPC service. Otherwise, it's the HTTP gazetteClient.
+func journalClient() contentClient {
+	var ep = viper.GetString("gazette.grpcEndpoint")
+	if ep == "" {
+		return gazetteClient()
+	}
+	if lazyGRPCClient == nil {
+		var err error
+		if lazyGRPCClient, err = gazette.NewGRPCClient(ep); err != nil {
+			log.WithField("err", err).Fatal("building gazette gRPC client")
+		}
+	}
+	return lazyGRPCClient
+}
+
 func consumerPlugin() consumer.Consumer {
 	if lazyConsumerPlugin == nil {
 		var path = viper.GetString("consumer.plugin")
@@ -204,6 +234,7 @@ var (
 	lazyConsumerPlugin consumer.Consumer
 	lazyEtcdClient     etcd.Client
 	lazyGazetteClient  *gazette.Client
+	lazyGRPCClient     *gazette.GRPCClient
 	lazyWriteService   *gazette.WriteService
 
 	defaultYes bool
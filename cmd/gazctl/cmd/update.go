@@ -0,0 +1,127 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	etcd "github.com/coreos/etcd/client"
+	log "github.com/sirupsen/logrus"
+	"github.com/spf13/cobra"
+
+	"github.com/LiveRamp/gazette/pkg/gazette"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+var updateCmd = &cobra.Command{
+	Use:   "update [journal name] [journal name] ... [--prefix journal name prefix]",
+	Short: "Update the specification of gazette journals",
+	Long: `Update the specification of one or more existing gazette journals.
+Only provided options are updated: others retain their current values.
+
+Example: gazctl update examples/a-journal/one --fragment-size 16777216
+This updates journal examples/a-journal/one to use 16MiB fragments.
+
+Example: gazctl update examples/a-journal/one --label owner=team-a --label tier=
+This labels journal examples/a-journal/one with owner team-a, and removes its
+tier label.
+
+Example: gazctl update --prefix examples/a-journal/ --retention 168h
+This updates all journals under examples/a-journal/ to retain a week of content.
+Existing journals, as listed by "gazctl list", are updated. --retention and
+--retention-bytes are also stored in Etcd as a retention policy of the prefix,
+which applies to journals created later which don't set their own retention.
+Where policies of several prefixes match a journal, the longest prefix applies.
+Prefixes match on path segment boundaries.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		if updatePrefix != "" {
+			if updateSpec.Retention != 0 || updateSpec.RetentionBytes != 0 {
+				updateRetentionPolicy(journal.Name(updatePrefix), updateSpec)
+			}
+
+			var journals, err = gazetteClient().List(journal.ListArgs{Prefix: journal.Name(updatePrefix)})
+			if err != nil {
+				log.WithFields(log.Fields{"err": err, "prefix": updatePrefix}).Fatal("failed to list journals")
+			}
+			if len(journals) == 0 {
+				log.WithField("prefix", updatePrefix).Warn("no existing journals have prefix")
+			}
+			for _, listed := range journals {
+				args = append(args, listed.Name.String())
+			}
+		}
+		if len(args) == 0 {
+			cmd.Usage()
+			log.Fatal("invalid arguments")
+		}
+		if err := updateSpec.Validate(); err != nil {
+			log.WithField("err", err).Fatal("invalid journal spec")
+		} else if reflect.DeepEqual(updateSpec, journal.JournalSpec{}) {
+			cmd.Usage()
+			log.Fatal("no journal spec options provided")
+		}
+
+		for i := range args {
+			var name = journal.Name(args[i])
+
+			userConfirms(fmt.Sprintf("Really update %s?", name.String()))
+
+			if err := gazetteClient().UpdateSpec(name, updateSpec); err != nil {
+				log.WithFields(log.Fields{"err": err, "name": name}).Fatal("failed to update journal")
+			} else {
+				log.WithFields(log.Fields{"name": name, "spec": updateSpec}).Info("updated journal")
+			}
+		}
+	},
+}
+
+// updateRetentionPolicy merges the retention of |spec| into the stored
+// RetentionPolicy of |prefix|.
+func updateRetentionPolicy(prefix journal.Name, spec journal.JournalSpec) {
+	var keysAPI = etcd.NewKeysAPI(etcdClient())
+	var key = gazette.RetentionPolicyPath(prefix)
+	var policy = gazette.RetentionPolicy{Prefix: prefix}
+
+	// The policy is stored only if unchanged since it was read.
+	var setOptions = &etcd.SetOptions{PrevExist: etcd.PrevNoExist}
+
+	if resp, err := keysAPI.Get(context.Background(), key, nil); err == nil {
+		if err = json.Unmarshal([]byte(resp.Node.Value), &policy); err != nil {
+			log.WithFields(log.Fields{"err": err, "key": key}).Fatal("failed to decode retention policy")
+		}
+		setOptions = &etcd.SetOptions{PrevIndex: resp.Node.ModifiedIndex}
+	} else if etcdErr, _ := err.(etcd.Error); etcdErr.Code != etcd.ErrorCodeKeyNotFound {
+		log.WithFields(log.Fields{"err": err, "key": key}).Fatal("failed to fetch retention policy")
+	}
+
+	if spec.Retention != 0 {
+		policy.Retention = spec.Retention
+	}
+	if spec.RetentionBytes != 0 {
+		policy.RetentionBytes = spec.RetentionBytes
+	}
+	if err := policy.Validate(); err != nil {
+		log.WithField("err", err).Fatal("invalid retention policy")
+	}
+	var value, _ = json.Marshal(policy)
+
+	userConfirms(fmt.Sprintf("Really set retention policy %s of prefix %s?", value, prefix))
+
+	if _, err := keysAPI.Set(context.Background(), key, string(value), setOptions); err != nil {
+		log.WithFields(log.Fields{"err": err, "key": key}).Fatal("failed to store retention policy")
+	}
+	log.WithFields(log.Fields{"prefix": prefix, "policy": string(value)}).Info("updated retention policy")
+}
+
+var updateSpec journal.JournalSpec
+var updatePrefix string
+
+func init() {
+	rootCmd.AddCommand(updateCmd)
+
+	updateCmd.Flags().BoolVarP(&defaultYes, "yes", "y", false, "Update without asking for confirmation.")
+	updateCmd.Flags().StringVar(&updatePrefix, "prefix", "",
+		"Also update all existing journals having this name prefix, and store its retention policy")
+	addSpecFlags(updateCmd, &updateSpec)
+}
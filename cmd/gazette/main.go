@@ -1,9 +1,10 @@
 package main
 
 import (
-	"bytes"
+	"crypto/tls"
 	"errors"
 	"flag"
+	"fmt"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -13,20 +14,25 @@ import (
 	"time"
 
 	etcd "github.com/coreos/etcd/client"
+	"github.com/coreos/etcd/clientv3"
 	"github.com/gorilla/mux"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/context"
 	"golang.org/x/net/trace"
 	"google.golang.org/api/gensupport"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/consensus/etcdv3"
 	"github.com/LiveRamp/gazette/pkg/envflagfactory"
 	"github.com/LiveRamp/gazette/pkg/gazette"
 	"github.com/LiveRamp/gazette/pkg/journal"
 	"github.com/LiveRamp/gazette/pkg/keepalive"
 	"github.com/LiveRamp/gazette/pkg/mainboilerplate"
 	"github.com/LiveRamp/gazette/pkg/metrics"
+	"github.com/LiveRamp/gazette/pkg/tlsconfig"
 )
 
 var (
@@ -34,6 +40,39 @@ var (
 		"Local directory for journal spools")
 
 	replicaCount = flag.Int("replicaCount", 2, "Number of required journal replicas")
+
+	grpcPort = flag.Int("grpcPort", 8082, "Port on which the Journal gRPC service is served")
+
+	streamReplication = flag.Bool("streamReplication", true,
+		"Replicate transactions to peers over long-lived gRPC streams (peers must serve gRPC on grpcPort)")
+
+	scrubInterval = flag.Duration("scrubInterval", 0,
+		"Interval at which new persisted fragments of brokered journals are verified (zero disables)")
+	scrubRate = flag.Int64("scrubRate", 1<<23,
+		"Maximum bytes per second of persisted fragments verified by scrubs (zero is unlimited)")
+
+	tlsCert = flag.String("tlsCert", "",
+		"PEM certificate served by the broker, and presented to replicating peers (enables TLS)")
+	tlsKey = flag.String("tlsKey", "", "PEM private key of tlsCert")
+	tlsCA  = flag.String("tlsCA", "",
+		"PEM CA certificates used to verify peers and clients (enables mutual TLS)")
+
+	authorizeJournals = flag.Bool("authorizeJournals", false,
+		"Authorize journal operations against ACL rules stored in Etcd (peer brokers must be granted read and replicate)")
+
+	limitAppends = flag.Bool("limitAppends", false,
+		"Limit appends of brokered journals to quotas stored in Etcd")
+
+	zone = flag.String("zone", "",
+		"Failure domain of the broker (eg, availability zone or rack), across which journal replicas are spread")
+
+	fragmentCacheDir = flag.String("fragmentCacheDir", "/var/tmp/gazette-fragment-cache",
+		"Local directory in which remote fragments read through the broker are cached")
+	fragmentCacheBytes = flag.Int64("fragmentCacheBytes", 0,
+		"Maximum bytes of remote fragment content cached in fragmentCacheDir (zero disables)")
+
+	etcdV3 = flag.Bool("etcdV3", false,
+		"Coordinate through the Etcd v3 API of etcdEndpoint, rather than the v2 API (all brokers of a cluster must agree)")
 )
 
 // In order for a brokered Journal to be handed off, it must have regular
@@ -53,18 +92,40 @@ func main() {
 	prometheus.MustRegister(metrics.GazetteCollectors()...)
 	gensupport.RegisterHook(traceRequests)
 
+	var tlsConfig *tls.Config
+	if *tlsCert != "" {
+		var err error
+		if tlsConfig, err = tlsconfig.Load(*tlsCert, *tlsKey, *tlsCA); err != nil {
+			log.WithField("err", err).Fatal("failed to load TLS configuration")
+		}
+	}
+
 	var localRoute string
 	if ip, err := routableIP(); err != nil {
 		log.WithField("err", err).Fatal("failed to acquire routable IP")
+	} else if tlsConfig != nil {
+		localRoute = url.QueryEscape("https://" + ip.String() + ":8081")
 	} else {
 		localRoute = url.QueryEscape("http://" + ip.String() + ":8081")
 	}
 
 	log.WithFields(log.Fields{
-		"spoolDir":     *spoolDirectory,
-		"replicaCount": *replicaCount,
-		"etcdEndpoint": *etcdEndpoint,
-		"localRoute":   localRoute,
+		"spoolDir":           *spoolDirectory,
+		"replicaCount":       *replicaCount,
+		"grpcPort":           *grpcPort,
+		"streamReplication":  *streamReplication,
+		"scrubInterval":      *scrubInterval,
+		"scrubRate":          *scrubRate,
+		"tlsCert":            *tlsCert,
+		"tlsCA":              *tlsCA,
+		"authorizeJournals":  *authorizeJournals,
+		"limitAppends":       *limitAppends,
+		"zone":               *zone,
+		"fragmentCacheDir":   *fragmentCacheDir,
+		"fragmentCacheBytes": *fragmentCacheBytes,
+		"etcdEndpoint":       *etcdEndpoint,
+		"etcdV3":             *etcdV3,
+		"localRoute":         localRoute,
 	}).Info("flag configuration")
 
 	// Fail fast if spool directory cannot be created.
@@ -72,12 +133,26 @@ func main() {
 		log.WithField("err", err).Fatal("failed to create spool directory")
 	}
 
-	etcdClient, err := etcd.New(etcd.Config{
-		Endpoints: []string{"http://" + *etcdEndpoint}})
-	if err != nil {
-		log.WithField("err", err).Fatal("failed to init etcd client")
+	var keysAPI etcd.KeysAPI
+	if *etcdV3 {
+		etcdClient, err := clientv3.New(clientv3.Config{
+			Endpoints: []string{"http://" + *etcdEndpoint}})
+		if err != nil {
+			log.WithField("err", err).Fatal("failed to init etcd v3 client")
+		}
+		var v3KeysAPI = etcdv3.NewKeysAPI(etcdClient)
+		// Revoke the lease of our member and item locks on exit, releasing
+		// them immediately rather than after their TTL.
+		defer v3KeysAPI.Close()
+		keysAPI = v3KeysAPI
+	} else {
+		etcdClient, err := etcd.New(etcd.Config{
+			Endpoints: []string{"http://" + *etcdEndpoint}})
+		if err != nil {
+			log.WithField("err", err).Fatal("failed to init etcd client")
+		}
+		keysAPI = etcd.NewKeysAPI(etcdClient)
 	}
-	keysAPI := etcd.NewKeysAPI(etcdClient)
 
 	cfs, err := cloudstore.NewFileSystem(nil, *cloudFSURL)
 	if err != nil {
@@ -87,6 +162,10 @@ func main() {
 	if err != nil {
 		log.WithField("err", err).Fatal("failed to bind listener")
 	}
+	grpcListener, err := net.Listen("tcp", fmt.Sprintf(":%d", *grpcPort))
+	if err != nil {
+		log.WithField("err", err).Fatal("failed to bind gRPC listener")
+	}
 
 	persister := gazette.NewPersister(*spoolDirectory, cfs, keysAPI, localRoute)
 	persister.StartPersisting()
@@ -96,43 +175,115 @@ func main() {
 		persister.Persist(fragment)
 	}
 
+	var producers = gazette.NewProducerStore(keysAPI)
 	var router = gazette.NewRouter(
 		func(n journal.Name) gazette.JournalReplica {
-			return journal.NewReplica(n, *spoolDirectory, persister, cfs)
+			return journal.NewReplica(n, *spoolDirectory, persister, producers, cfs)
 		},
 	)
+	if *streamReplication {
+		router.ReplicateOverStreams(*grpcPort)
+	}
+	if tlsConfig != nil {
+		router.UsePeerTLS(tlsConfig)
+	}
+	if *limitAppends {
+		var limiter = gazette.NewAppendLimiter(keysAPI)
+		if err := limiter.StartWatching(); err != nil {
+			log.WithField("err", err).Fatal("failed to load append quotas")
+		}
+		defer limiter.Stop()
+		router.LimitAppends(limiter)
+	}
 
 	// Run regular broker commit "pulses".
 	go func() {
 		for _ = range time.Tick(brokerPulseInterval) {
-			var emptyBuffer bytes.Buffer
-			var journals = router.BrokeredJournals()
-			var resultCh = make(chan journal.AppendResult, len(journals))
-
-			for _, name := range journals {
-				router.Append(journal.AppendOp{
-					AppendArgs: journal.AppendArgs{
-						Journal: name,
-						Content: &emptyBuffer,
-						Context: context.Background(),
-					},
-					Result: resultCh,
-				})
-			}
-			for _ = range journals {
-				<-resultCh
-			}
+			router.PulseBrokeredJournals()
 		}
 	}()
 
+	// Periodically scrub persisted fragments of journals brokered locally.
+	// Fragments already verified by a prior scrub aren't verified again.
+	if *scrubInterval != 0 {
+		var scrubber = journal.NewScrubber(cfs, *scrubRate)
+
+		go func() {
+			for _ = range time.Tick(*scrubInterval) {
+				var journals = router.BrokeredJournals()
+				scrubber.Retain(journals)
+
+				for _, name := range journals {
+					var stats, err = scrubber.ScrubJournal(name, func(finding journal.Finding) {
+						log.WithField("finding", finding).Error("fragment integrity issue")
+					})
+					if err != nil {
+						log.WithFields(log.Fields{"journal": name, "err": err}).Warn("failed to scrub journal")
+					} else {
+						log.WithFields(log.Fields{"journal": name, "stats": stats}).Debug("scrubbed journal")
+					}
+				}
+			}
+		}()
+	}
+
+	// Write heads of listed journals are fetched from their replicas, using a
+	// Client which is initially routed to this broker.
+	localEndpoint, _ := url.QueryUnescape(localRoute)
+	var localClient *gazette.Client
+	if tlsConfig != nil {
+		localClient, err = gazette.NewClientWithTLS(localEndpoint, tlsConfig)
+	} else {
+		localClient, err = gazette.NewClient(localEndpoint)
+	}
+	if err != nil {
+		log.WithField("err", err).Fatal("failed to init local gazette client")
+	}
+
+	// A nil Authorizer permits all journal operations.
+	var authorizer gazette.Authorizer
+	if *authorizeJournals {
+		var acls = gazette.NewACLAuthorizer(keysAPI)
+		if err := acls.StartWatching(); err != nil {
+			log.WithField("err", err).Fatal("failed to load journal ACLs")
+		}
+		defer acls.Stop()
+		authorizer = acls
+	}
+
+	var fragmentCache *gazette.FragmentCache
+	if *fragmentCacheBytes != 0 {
+		if fragmentCache, err = gazette.NewFragmentCache(*fragmentCacheDir,
+			*fragmentCacheBytes, cfs); err != nil {
+			log.WithField("err", err).Fatal("failed to create fragment cache")
+		}
+	}
+
+	var createAPI = gazette.NewCreateAPI(cfs, keysAPI, *replicaCount).UseAuthorizer(authorizer)
+
+	var runner = gazette.NewRunner(keysAPI, localRoute, *replicaCount, router).
+		UseZone(*zone).UsePersister(persister).UseAuthorizer(authorizer)
+
 	var m = mux.NewRouter()
-	gazette.NewCreateAPI(cfs, keysAPI, *replicaCount).Register(m)
-	gazette.NewReadAPI(router, cfs).Register(m)
-	gazette.NewReplicateAPI(router).Register(m)
-	gazette.NewWriteAPI(router).Register(m)
+	// Serve drains first, as journal APIs match all paths. A drain is also
+	// begun by SIGTERM or SIGINT.
+	runner.Register(m)
+	createAPI.Register(m)
+	gazette.NewDeleteAPI(cfs, keysAPI).UseAuthorizer(authorizer).Register(m)
+	gazette.NewListAPI(keysAPI, localClient).UseAuthorizer(authorizer).Register(m)
+	gazette.NewReadAPI(router, cfs).UseAuthorizer(authorizer).
+		UseFragmentCache(fragmentCache).Register(m)
+	gazette.NewReplicateAPI(router).UseAuthorizer(authorizer).Register(m)
+	gazette.NewSpecAPI(keysAPI, *replicaCount).UseAuthorizer(authorizer).Register(m)
+	gazette.NewWriteAPI(router).UseAuthorizer(authorizer).Register(m)
+
+	var httpListener net.Listener = keepalive.TCPListener{TCPListener: listener.(*net.TCPListener)}
+	if tlsConfig != nil {
+		httpListener = tls.NewListener(httpListener, tlsConfig)
+	}
 
 	go func() {
-		err := http.Serve(keepalive.TCPListener{listener.(*net.TCPListener)}, m)
+		err := http.Serve(httpListener, m)
 
 		if _, ok := err.(net.Error); ok {
 			return // Don't log on listener.Close.
@@ -140,12 +291,30 @@ func main() {
 		log.WithField("err", err).Error("http.Serve failed")
 	}()
 
-	var runner = gazette.NewRunner(etcdClient, localRoute, *replicaCount, router)
+	// Serve the Journal gRPC service alongside the HTTP API.
+	var grpcOpts []grpc.ServerOption
+	if tlsConfig != nil {
+		grpcOpts = append(grpcOpts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	var grpcServer = grpc.NewServer(grpcOpts...)
+	journal.RegisterJournalServer(grpcServer,
+		gazette.NewJournalService(router, cfs, createAPI).UseAuthorizer(authorizer).
+			UseFragmentCache(fragmentCache))
+
+	go func() {
+		if err := grpcServer.Serve(keepalive.TCPListener{TCPListener: grpcListener.(*net.TCPListener)}); err != nil {
+			log.WithField("err", err).Error("grpcServer.Serve failed")
+		}
+	}()
+
 	if err := runner.Run(); err != nil {
 		log.WithField("err", err).Error("runner.Run() failed")
 	}
 	listener.Close()
+	grpcServer.Stop()
 
+	// Run has awaited the shutdown of local replicas, which handed their
+	// spools to the persister. Stop blocks until those are persisted.
 	persister.Stop()
 	log.Info("service stop complete")
 }
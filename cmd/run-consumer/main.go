@@ -9,10 +9,12 @@ import (
 	"strings"
 
 	etcd "github.com/coreos/etcd/client"
+	"github.com/coreos/etcd/clientv3"
 	"github.com/prometheus/client_golang/prometheus"
 	log "github.com/sirupsen/logrus"
 	"github.com/spf13/viper"
 
+	"github.com/LiveRamp/gazette/pkg/consensus/etcdv3"
 	"github.com/LiveRamp/gazette/pkg/consumer"
 	"github.com/LiveRamp/gazette/pkg/gazette"
 	"github.com/LiveRamp/gazette/pkg/metrics"
@@ -29,8 +31,12 @@ type Config struct {
 		RecoveryLogRoot string // Path prefix for the consumer's recovery-log Journals.
 		ShardStandbys   uint8  // Number of warm-standby replicas to allocate for each Consumer shard.
 		Workdir         string // Local directory for ephemeral serving files.
+		Zone            string // Optional failure domain (eg, availability zone) of this consumer instance.
+	}
+	Etcd struct {
+		Endpoint string // Etcd endpoint to use.
+		V3       bool   // Coordinate through the Etcd v3 API of Endpoint, rather than the v2 API.
 	}
-	Etcd    struct{ Endpoint string } // Etcd endpoint to use.
 	Gazette struct{ Endpoint string } // Gazette endpoint to use.
 }
 
@@ -101,6 +107,16 @@ func main() {
 	if err != nil {
 		log.WithField("err", err).Fatal("failed to init etcd client")
 	}
+	var keysAPI etcd.KeysAPI
+	if config.Etcd.V3 {
+		v3Client, err := clientv3.New(clientv3.Config{Endpoints: []string{config.Etcd.Endpoint}})
+		if err != nil {
+			log.WithField("err", err).Fatal("failed to init etcd v3 client")
+		}
+		var v3KeysAPI = etcdv3.NewKeysAPI(v3Client)
+		defer v3KeysAPI.Close()
+		keysAPI = v3KeysAPI
+	}
 	gazClient, err := gazette.NewClient(config.Gazette.Endpoint)
 	if err != nil {
 		log.WithField("err", err).Fatal("failed to init gazette client")
@@ -117,8 +133,10 @@ func main() {
 		LocalRouteKey:   config.Service.LocalRouteKey,
 		RecoveryLogRoot: config.Service.RecoveryLogRoot,
 		ReplicaCount:    int(config.Service.ShardStandbys),
+		LocalZone:       config.Service.Zone,
 
-		Etcd: etcdClient,
+		Etcd:        etcdClient,
+		EtcdKeysAPI: keysAPI,
 		Gazette: struct {
 			*gazette.Client
 			*gazette.WriteService
@@ -0,0 +1,53 @@
+package cloudstore
+
+import (
+	"errors"
+	"fmt"
+)
+
+// AzureEndpoint is a fully-defined Azure Blob Storage endpoint with container
+// and subfolder.
+type AzureEndpoint struct {
+	BaseEndpoint
+
+	AzureAccountName string `json:"account_name"`
+	AzureAccountKey  string `json:"account_key"`
+	AzureContainer   string `json:"container"`
+	AzureSubfolder   string `json:"subfolder"`
+}
+
+// Validate satisfies the model interface.
+func (ep *AzureEndpoint) Validate() error {
+	if ep.AzureAccountName == "" {
+		return errors.New("must specify azure account name")
+	} else if ep.AzureContainer == "" {
+		return errors.New("must specify azure container")
+	}
+	return ep.BaseEndpoint.Validate()
+}
+
+// CheckPermissions satisfies the Endpoint interface.
+func (ep *AzureEndpoint) CheckPermissions() error {
+	return checkPermissions(ep, ep.PermissionTestFilename)
+}
+
+// Connect satisfies the Endpoint interface, returning a usable connection to the
+// underlying Azure Blob Storage filesystem.
+func (ep *AzureEndpoint) Connect(more Properties) (FileSystem, error) {
+	var prop, err = mergeProperties(more, ep.properties())
+	if err != nil {
+		return nil, err
+	}
+	return NewFileSystem(prop, ep.uri())
+}
+
+func (ep *AzureEndpoint) properties() Properties {
+	return MapProperties{
+		AzureAccountName: ep.AzureAccountName,
+		AzureAccountKey:  ep.AzureAccountKey,
+	}
+}
+
+func (ep *AzureEndpoint) uri() string {
+	return fmt.Sprintf("azure://%s/%s", ep.AzureContainer, ep.AzureSubfolder)
+}
@@ -0,0 +1,610 @@
+package cloudstore
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	AzureAccountName = "AzureAccountName"
+	AzureAccountKey  = "AzureAccountKey"
+
+	// Size of blocks staged by azureFile writers. A blob may be composed of
+	// up to 50,000 blocks, making the maximum file size ~195GiB.
+	azureBlockSize = 1024 * 1024 * 4
+)
+
+// Maps Azure Blob Storage into an API compatible with cloudstore.FileSystem.
+// The first component of a path is the blob container.
+type azureFs struct {
+	// Prefix roots all files within this filesystem.
+	prefix string
+	// Authenticated blob storage client.
+	client storage.BlobStorageClient
+	// Whether the client uses HTTPS (it does not for the storage emulator).
+	useHTTPS bool
+}
+
+// Returns an azureFs for the given container and path |prefix|. The storage
+// account and its key are taken from |properties| or, if not set there, from
+// the AZURE_STORAGE_ACCOUNT and AZURE_STORAGE_KEY environment variables. Use
+// of the storage emulator account ("devstoreaccount1") connects to a local
+// emulator (eg, Azurite).
+func newAzureFS(properties Properties, prefix string) (*azureFs, error) {
+	var account, key = os.Getenv("AZURE_STORAGE_ACCOUNT"), os.Getenv("AZURE_STORAGE_KEY")
+	if properties != nil && properties.Get(AzureAccountName) != "" {
+		account, key = properties.Get(AzureAccountName), properties.Get(AzureAccountKey)
+	}
+
+	var client storage.Client
+	var useHTTPS bool
+	var err error
+
+	if account == storage.StorageEmulatorAccountName {
+		client, err = storage.NewEmulatorClient()
+	} else if account == "" {
+		return nil, errors.New("must specify an azure storage account")
+	} else {
+		client, err = storage.NewBasicClient(account, key)
+		useHTTPS = true
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &azureFs{
+		prefix:   prefix,
+		client:   client.GetBlobService(),
+		useHTTPS: useHTTPS,
+	}, nil
+}
+
+// Opens an Azure blob for reading or for writing (O_RDWR is not supported).
+// O_CREATE is enforced, and O_CREATE|O_EXCL is checked prior to open and
+// enforced by a conditional commit of the written blob. Files opened for
+// O_RDONLY are not actually opened for reading by this call (only properties
+// are fetched). Instead, reader opens happen lazily on the first Read call.
+func (fs *azureFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	var container, path = pathToBucketAndSubpath(fs.prefix, name)
+	var isDir = isBucketStoreDir(path)
+	var blob *storage.Blob
+	var exists bool
+
+	// Check the current status of |path| on cloud storage. First determine if
+	// |path| is a regular file by fetching its properties. If not, determine if
+	// |path| should be treated as a directory by quering for subordinate files.
+	if !isDir {
+		blob = fs.blob(container, path)
+
+		if err := blob.GetProperties(nil); isAzureNotFound(err) {
+			exists = false
+		} else if err != nil {
+			return nil, fmt.Errorf("azure get blob properties: %s", err)
+		} else {
+			exists = true
+		}
+
+		if !exists {
+			// |path| doesn't have a '/' suffix, but could still be a directory.
+			// Check by querying with a suffix, and seeing if any files are returned.
+			var resp, err = fs.client.GetContainerReference(container).ListBlobs(
+				storage.ListBlobsParameters{Prefix: path + "/", MaxResults: 1})
+
+			if err != nil {
+				return nil, fmt.Errorf("azure list blobs: %s", err)
+			} else if len(resp.Blobs) != 0 {
+				// Rewrite |path| as a directory.
+				path = path + "/"
+				isDir = true
+			}
+		}
+	}
+
+	// Is this a directory? Opens of directories for reading always succeed.
+	// Otherwise fail.
+	if isDir {
+		if flag != os.O_RDONLY {
+			return nil, errors.New("unsupported directory flags")
+		}
+		return &azureFile{
+			fs:        fs,
+			container: container,
+			path:      path,
+		}, nil
+	}
+
+	// Walk through each supported flag combination, and emulate flag behaviors
+	// by testing against the stat'd status of the file on cloud storage.
+	if flag == os.O_RDONLY && !exists {
+		return nil, os.ErrNotExist // Read which doesn't exist. Map to os error.
+	} else if flag == os.O_RDONLY {
+		// Read which exists. Return a file which will lazily open a reader.
+		return &azureFile{
+			fs:        fs,
+			container: container,
+			path:      path,
+			blob:      blob,
+		}, nil
+	} else if flag == os.O_WRONLY|os.O_TRUNC && !exists {
+		return nil, os.ErrNotExist // Write which doesn't exist. Map to os error.
+	} else if flag == os.O_WRONLY|os.O_CREATE|os.O_EXCL && exists {
+		return nil, os.ErrExist // Exclusive create which exists. Map to os error.
+	} else if flag == os.O_WRONLY|os.O_TRUNC ||
+		flag == os.O_WRONLY|os.O_CREATE|os.O_TRUNC ||
+		flag == os.O_WRONLY|os.O_CREATE|os.O_EXCL {
+
+		return &azureFile{
+			fs:        fs,
+			container: container,
+			path:      path,
+			blob:      blob,
+			writer:    true,
+			exclusive: flag&os.O_EXCL != 0,
+		}, nil
+	} else {
+		return nil, errors.New("unsupported file flags")
+	}
+}
+
+func (fs *azureFs) Open(name string) (http.File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+func (fs *azureFs) MkdirAll(name string, perm os.FileMode) error {
+	// Ensure we can list files under |container| and |path|.
+	var container, path = pathToBucketAndSubpath(fs.prefix, name)
+	path = strings.TrimRight(path, "/")
+
+	var resp, err = fs.client.GetContainerReference(container).ListBlobs(
+		storage.ListBlobsParameters{Prefix: path, MaxResults: 1})
+	if err != nil {
+		return err
+	}
+
+	if len(resp.Blobs) != 0 && resp.Blobs[0].Name == path {
+		// Simulate POSIX rules: that a regular file and directory cannot have the same name.
+		return &os.PathError{Err: os.ErrExist, Path: path}
+	}
+	// A blob which is prefixed by |path| is allowed.
+	return nil
+}
+
+func (fs *azureFs) Remove(name string) error {
+	var container, path = pathToBucketAndSubpath(fs.prefix, name)
+
+	if err := fs.blob(container, path).Delete(nil); isAzureNotFound(err) {
+		return os.ErrNotExist // Map to os error.
+	} else {
+		return err
+	}
+}
+
+// CopyAtomic copies files contents, while being sensitive to the consistency
+// guarantees provided by Azure Blob Storage. Content is staged as uncommitted
+// blocks, and |to| is aborted (its block list is never committed) if a write
+// *or read* error occurs. Uncommitted blocks are garbage-collected by Azure.
+func (fs *azureFs) CopyAtomic(to File, from io.Reader) (int64, error) {
+	return to.(*azureFile).transfer(from)
+}
+
+// For the specified |path|, generates a URL signed with a Shared Access
+// Signature which grants the bearer rights to perform |method| on the blob,
+// for the amount of time specified by |validFor|.
+func (fs *azureFs) ToURL(path, method string, validFor time.Duration) (*url.URL, error) {
+	var container, subPath = pathToBucketAndSubpath(fs.prefix, path)
+
+	var options = storage.BlobSASOptions{
+		SASOptions: storage.SASOptions{
+			Start:    time.Now().Add(-azureClockSkew),
+			Expiry:   time.Now().Add(validFor),
+			UseHTTPS: fs.useHTTPS,
+		},
+	}
+	switch method {
+	case "GET", "HEAD":
+		options.Read = true
+	case "PUT":
+		options.Create, options.Write = true, true
+	case "DELETE":
+		options.Delete = true
+	default:
+		return nil, fmt.Errorf("unsupported method: %s", method)
+	}
+
+	if rawURL, err := fs.blob(container, subPath).GetSASURI(options); err != nil {
+		return nil, fmt.Errorf("azure sas uri: %s", err)
+	} else {
+		return url.Parse(rawURL)
+	}
+}
+
+func (fs *azureFs) ProducesAuthorizedURL() bool {
+	return true
+}
+
+func (fs *azureFs) Close() error {
+	return nil // No-op.
+}
+
+// Emits all files underneath |root| to |walkFn|.
+//
+// Takes advantage of the fact that there are no real directories in Azure
+// Blob Storage, only prefixes. Initiates a blob listing underneath the root
+// (a prefix) but without any Delimiter specified. As a result, all files
+// under the prefix, regardless of directory-depth, are returned in a small
+// number of API calls (subject to pagination if the number of files is large).
+//
+// As this scan doesn't know about even virtual directory boundaries, the
+// |filepath.SkipDir| API allowing walk-functions to skip an entire directory
+// isn't implemented and should not be used.
+func (fs *azureFs) Walk(root string, walkFn filepath.WalkFunc) error {
+	var container, subPath = pathToBucketAndSubpath(fs.prefix, root)
+	var ref = fs.client.GetContainerReference(container)
+	var marker string
+
+	for {
+		// No Delimiter set.
+		var resp, err = ref.ListBlobs(storage.ListBlobsParameters{
+			Prefix: subPath,
+			Marker: marker,
+		})
+		if err != nil {
+			return fmt.Errorf("azure list blobs: %s", err)
+		}
+
+		for i := range resp.Blobs {
+			var blob = &resp.Blobs[i]
+
+			// Sometimes there are invisible files named after the prefix,
+			// these aren't real files so pretend they don't exist.
+			if strings.HasSuffix(blob.Name, "/") {
+				continue
+			}
+			blob.Container = ref
+
+			// Strip the full prefix. |rel| is now relative to |subPath|.
+			var rel, err = filepath.Rel(subPath, blob.Name)
+			if err != nil {
+				return fmt.Errorf("azure relative path: %s", err)
+			}
+
+			var fp = &azureFile{
+				fs:        fs,
+				container: container,
+				path:      blob.Name,
+				blob:      blob,
+			}
+			if werr := walkFn(filepath.Join(root, rel), fp, nil); werr == filepath.SkipDir {
+				return errors.New("SkipDir not implemented for azureFs")
+			} else if werr != nil {
+				// Allow caller to abort Walk operation.
+				return werr
+			}
+		}
+
+		if marker = resp.NextMarker; marker == "" {
+			return nil
+		}
+	}
+}
+
+func (fs *azureFs) blob(container, path string) *storage.Blob {
+	return fs.client.GetContainerReference(container).GetBlobReference(path)
+}
+
+type azureFile struct {
+	fs              *azureFs
+	container, path string
+
+	// Blob of the file, with properties populated if it exists.
+	blob *storage.Blob
+
+	// Used in file read operations.
+	reader     io.ReadCloser
+	readOffset int64
+
+	// Used in directory read operations.
+	results []os.FileInfo
+	listed  bool
+
+	// Used in write operations. Written content is buffered into |spool|
+	// and staged as blocks of the blob, which are committed on Close.
+	writer    bool
+	exclusive bool
+	spool     bytes.Buffer
+	blocks    []storage.Block
+
+	// Global error state for this file.
+	err error
+}
+
+// File interface method.
+func (f *azureFile) Read(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	} else if f.writer || f.blob == nil {
+		return 0, errors.New("not an azureFile reader")
+	}
+
+	if f.reader == nil {
+		if f.readOffset >= f.blob.Properties.ContentLength {
+			return 0, io.EOF
+		} else if f.readOffset == 0 {
+			f.reader, f.err = f.blob.Get(nil)
+		} else {
+			f.reader, f.err = f.blob.GetRange(&storage.GetBlobRangeOptions{
+				Range: &storage.BlobRange{Start: uint64(f.readOffset)},
+			})
+		}
+		if f.err != nil {
+			return 0, f.err
+		}
+	}
+
+	var n int
+	n, f.err = f.reader.Read(p)
+	f.readOffset += int64(n)
+	return n, f.err
+}
+
+// File interface method.
+func (f *azureFile) Write(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	} else if !f.writer {
+		return 0, errors.New("not an azureFile writer")
+	}
+
+	var n, _ = f.spool.Write(p)
+
+	// Stage full blocks of the spool.
+	for f.spool.Len() >= azureBlockSize {
+		if f.err = f.putBlock(f.spool.Next(azureBlockSize)); f.err != nil {
+			return 0, f.err
+		}
+	}
+	return n, nil
+}
+
+// File interface method.
+func (f *azureFile) Close() error {
+	if f.reader != nil {
+		f.err = f.reader.Close()
+		f.reader = nil
+	} else if f.writer && f.err == nil {
+		// Stage the final partial block, and commit the block list.
+		if f.spool.Len() != 0 {
+			f.err = f.putBlock(f.spool.Next(f.spool.Len()))
+		}
+		if f.err == nil {
+			var options = new(storage.PutBlockListOptions)
+			if f.exclusive {
+				options.IfNoneMatch = "*"
+			}
+			if f.err = f.blob.PutBlockList(f.blocks, options); isAzureConditionFailed(f.err) {
+				f.err = os.ErrExist
+			}
+		}
+		f.writer = false
+	}
+	return f.err
+}
+
+// File interface method. Seeks re-open the blob reader at the new offset.
+func (f *azureFile) Seek(offset int64, whence int) (int64, error) {
+	if f.writer {
+		return 0, errors.New("cannot seek an azureFile writer")
+	} else if f.blob == nil {
+		return 0, errors.New("cannot seek an azureFile directory")
+	}
+	// Make |offset| absolute.
+	if whence == io.SeekStart {
+	} else if whence == io.SeekCurrent {
+		offset += f.readOffset
+	} else if whence == io.SeekEnd {
+		offset += f.blob.Properties.ContentLength
+	}
+
+	if offset < 0 {
+		return f.readOffset, errors.New("negative seek offset")
+	} else if f.err != nil && f.err != io.EOF {
+		return f.readOffset, f.err
+	} else if offset == f.readOffset && f.err == nil {
+		return f.readOffset, nil
+	}
+
+	if f.reader != nil {
+		f.reader.Close()
+		f.reader = nil
+	}
+	f.readOffset, f.err = offset, nil
+	return f.readOffset, nil
+}
+
+// File interface method.
+func (f *azureFile) Readdir(count int) ([]os.FileInfo, error) {
+	if !f.IsDir() {
+		return nil, errors.New("not a directory")
+	}
+
+	// Like s3File, list all entries on the first call and then iterate over
+	// the results in-memory.
+	if !f.listed {
+		if f.results, f.err = f.listBlobs(); f.err != nil {
+			return nil, f.err
+		}
+		f.listed = true
+	}
+
+	var toReturn = len(f.results)
+	if toReturn == 0 && count > 0 {
+		return nil, io.EOF
+	} else if count > 0 && count < toReturn {
+		toReturn = count
+	}
+
+	var out = f.results[:toReturn]
+	f.results = f.results[toReturn:]
+	return out, nil
+}
+
+// File interface method.
+func (f *azureFile) ContentSignature() (string, error) {
+	if f.blob == nil || f.blob.Properties.Etag == "" {
+		return "", os.ErrNotExist
+	}
+	return f.blob.Properties.Etag, nil
+}
+
+// An azureFile is also a os.FileInfo.
+func (f *azureFile) Stat() (os.FileInfo, error) {
+	return f, nil
+}
+
+// os.FileInfo interface method.
+func (f *azureFile) Name() string {
+	var name = f.path
+
+	if name == "" {
+		return f.container
+	} else if name[len(name)-1] == '/' {
+		name = name[:len(name)-1]
+	}
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// os.FileInfo interface method.
+func (f *azureFile) Size() int64 {
+	if f.blob != nil {
+		return f.blob.Properties.ContentLength
+	}
+	return 0
+}
+
+// os.FileInfo interface method.
+func (f *azureFile) Mode() os.FileMode {
+	log.Panic("azureFile does not implement Mode()")
+	return 0
+}
+
+// os.FileInfo interface method.
+func (f *azureFile) ModTime() time.Time {
+	if f.blob != nil {
+		return time.Time(f.blob.Properties.LastModified)
+	}
+	return time.Time{}
+}
+
+// os.FileInfo interface method.
+func (f *azureFile) IsDir() bool {
+	return isBucketStoreDir(f.path)
+}
+
+// os.FileInfo interface method.
+func (f *azureFile) Sys() interface{} {
+	return nil
+}
+
+// Stages |chunk| as the next uncommitted block of the blob.
+func (f *azureFile) putBlock(chunk []byte) error {
+	// Block IDs must be base64, and of equal length within a blob.
+	var id = base64.StdEncoding.EncodeToString(
+		[]byte(fmt.Sprintf("%010d", len(f.blocks))))
+
+	if err := f.blob.PutBlock(id, chunk, nil); err != nil {
+		return fmt.Errorf("azure put block: %s", err)
+	}
+	f.blocks = append(f.blocks, storage.Block{ID: id, Status: storage.BlockStatusUncommitted})
+	return nil
+}
+
+func (f *azureFile) listBlobs() ([]os.FileInfo, error) {
+	var ref = f.fs.client.GetContainerReference(f.container)
+	var results []os.FileInfo
+	var marker string
+
+	for {
+		var resp, err = ref.ListBlobs(storage.ListBlobsParameters{
+			Prefix:    f.path,
+			Delimiter: "/",
+			Marker:    marker,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("azure list blobs: %s", err)
+		}
+
+		for _, prefix := range resp.BlobPrefixes {
+			// Synthetic subdirectory prefix "file".
+			results = append(results, &azureFile{
+				fs:        f.fs,
+				container: f.container,
+				path:      prefix,
+			})
+		}
+		for i := range resp.Blobs {
+			var blob = &resp.Blobs[i]
+
+			if blob.Name == f.path {
+				// A directory can also have a regular file with the same path.
+				// Skip it, should one occur.
+				continue
+			}
+			blob.Container = ref
+
+			results = append(results, &azureFile{
+				fs:        f.fs,
+				container: f.container,
+				path:      blob.Name,
+				blob:      blob,
+			})
+		}
+
+		if marker = resp.NextMarker; marker == "" {
+			return results, nil
+		}
+	}
+}
+
+func (f *azureFile) transfer(from io.Reader) (int64, error) {
+	var n int64
+	n, f.err = io.Copy(f, from)
+
+	if f.err != nil {
+		// The transfer failed. Abandon staged blocks, which are never committed.
+		f.writer = false
+		return n, f.err
+	}
+	return n, f.Close()
+}
+
+// Shared Access Signatures begin slightly in the past, to allow for clock
+// skew between the signer and Azure.
+const azureClockSkew = 5 * time.Minute
+
+func isAzureNotFound(err error) bool {
+	return azureStatusCode(err) == http.StatusNotFound
+}
+
+func isAzureConditionFailed(err error) bool {
+	var code = azureStatusCode(err)
+	return code == http.StatusConflict || code == http.StatusPreconditionFailed
+}
+
+func azureStatusCode(err error) int {
+	if e, ok := err.(storage.AzureStorageServiceError); ok {
+		return e.StatusCode
+	}
+	return 0
+}
@@ -0,0 +1,602 @@
+package cloudstore
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+)
+
+const (
+	// Size of plaintext chunks which are independently sealed by an encrypted
+	// FileSystem. Reads of an encrypted file decrypt whole chunks, so seeks
+	// and ReadAt calls require I/O proportional to the chunk size.
+	encryptedChunkSize = 1 << 16
+	// Size of AES-256 data keys.
+	dataKeySize = 32
+)
+
+var (
+	// ErrURLNotSupported is returned by FileSystems which are unable to
+	// produce a URL through which a file may be directly accessed.
+	ErrURLNotSupported = errors.New("url not supported")
+
+	encryptedMagic = []byte("GZE\x01")
+)
+
+// NewEncryptedFileSystem returns a FileSystem which envelope-encrypts file
+// content written to FileSystem |fs|, and decrypts content read from it.
+//
+// Each file is encrypted with AES-256-GCM under a random data key, which is
+// wrapped by |keys| and stored in a header of the file. File content is
+// sealed in independently authenticated chunks, allowing encrypted files to
+// be seeked without decrypting prior content, and truncation, re-ordering or
+// modification of chunks to be detected. Encrypted files are opaque to the
+// underlying FileSystem, and ToURL returns ErrURLNotSupported.
+//
+// FileInfos returned by Stat of a file opened for reading, by Readdir, and
+// by Walk report the plaintext size of the file.
+func NewEncryptedFileSystem(fs FileSystem, keys KeyProvider) FileSystem {
+	return &encryptedFs{FileSystem: fs, keys: keys}
+}
+
+type encryptedFs struct {
+	FileSystem
+	keys KeyProvider
+}
+
+func (fs *encryptedFs) Open(name string) (http.File, error) {
+	return fs.OpenFile(name, os.O_RDONLY, 0)
+}
+
+// Opens a file of the underlying FileSystem. Files opened for writing are
+// assigned a new, wrapped data key. Files opened for reading lazily read and
+// unwrap the data key of the file on first use.
+func (fs *encryptedFs) OpenFile(name string, flag int, perm os.FileMode) (File, error) {
+	var file, err = fs.FileSystem.OpenFile(name, flag, perm)
+	if err != nil {
+		return nil, err
+	}
+	var f = &encryptedFile{File: file, fs: fs, name: name, chunkIndex: -1}
+
+	if flag&(os.O_WRONLY|os.O_RDWR) == 0 {
+		return f, nil
+	}
+
+	if f.cipher, err = fs.newCipher(); err != nil {
+		file.Close()
+		return nil, err
+	}
+	f.writer = true
+	return f, nil
+}
+
+// CopyAtomic encrypts content of |from| as it's copied by the underlying
+// FileSystem, preserving its all-or-nothing semantics. The returned count is
+// of plaintext bytes read from |from|.
+func (fs *encryptedFs) CopyAtomic(to File, from io.Reader) (int64, error) {
+	var f, ok = to.(*encryptedFile)
+	if !ok {
+		to.Close()
+		return 0, errors.New("file was not opened by the encrypted FileSystem")
+	} else if f.headerWritten {
+		return 0, errors.New("encrypted file has already been written to")
+	}
+	var r = &sealingReader{cipher: f.cipher, from: from}
+	r.pending = append(r.pending, f.cipher.header...)
+
+	var _, err = fs.FileSystem.CopyAtomic(f.File, r)
+	f.writer = false
+	return r.n, err
+}
+
+// Walk calls |walkFn| with FileInfos of the underlying FileSystem which
+// report the plaintext size of each file. Where the underlying FileSystem
+// passes its Files as FileInfos (as cloud FileSystems do), they're wrapped
+// to decrypt on Read. Determining the plaintext size requires reading the
+// file header. If it can't be determined (eg, the file is not encrypted),
+// |walkFn| is called with the underlying FileInfo and the error.
+func (fs *encryptedFs) Walk(root string, walkFn filepath.WalkFunc) error {
+	return fs.FileSystem.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return walkFn(name, info, err)
+		}
+
+		if file, ok := info.(File); ok {
+			var f = &encryptedFile{File: file, fs: fs, name: name, chunkIndex: -1}
+
+			if err = f.loadSize(); err != nil {
+				return walkFn(name, info, err)
+			}
+			return walkFn(name, &walkedEncryptedFile{
+				encryptedFile:     f,
+				encryptedFileInfo: &encryptedFileInfo{FileInfo: info, size: f.size},
+			}, nil)
+		}
+
+		var size int64
+		if size, err = fs.plaintextSize(name); err != nil {
+			return walkFn(name, info, err)
+		}
+		return walkFn(name, &encryptedFileInfo{FileInfo: info, size: size}, nil)
+	})
+}
+
+// plaintextSize opens encrypted file |name| and returns its plaintext size.
+func (fs *encryptedFs) plaintextSize(name string) (int64, error) {
+	var file, err = fs.Open(name)
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	var f = file.(*encryptedFile)
+	if err = f.loadSize(); err != nil {
+		return 0, err
+	}
+	return f.size, nil
+}
+
+// ToURL returns ErrURLNotSupported, as a bearer of a direct URL would be
+// unable to decrypt file content.
+func (fs *encryptedFs) ToURL(name, method string, validFor time.Duration) (*url.URL, error) {
+	return nil, ErrURLNotSupported
+}
+
+func (fs *encryptedFs) ProducesAuthorizedURL() bool {
+	return false
+}
+
+// Returns a chunkCipher and header for a new file, having a new data key.
+func (fs *encryptedFs) newCipher() (*chunkCipher, error) {
+	var dataKey = make([]byte, dataKeySize)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, err
+	}
+	var keyID, wrapped, err = fs.keys.WrapKey(dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("wrapping data key: %s", err)
+	} else if len(keyID) > 0xffff || len(wrapped) > 0xffff {
+		return nil, errors.New("wrapped data key is too large")
+	}
+
+	// Header is: magic, chunk size, key ID, and wrapped key.
+	var header = new(bytes.Buffer)
+	header.Write(encryptedMagic)
+	binary.Write(header, binary.BigEndian, uint32(encryptedChunkSize))
+	binary.Write(header, binary.BigEndian, uint16(len(keyID)))
+	header.WriteString(keyID)
+	binary.Write(header, binary.BigEndian, uint16(len(wrapped)))
+	header.Write(wrapped)
+
+	return newChunkCipher(dataKey, header.Bytes(), encryptedChunkSize)
+}
+
+// Reads and parses a file header from |r|, returning its chunkCipher.
+func (fs *encryptedFs) readCipher(r io.Reader) (*chunkCipher, error) {
+	var header = new(bytes.Buffer)
+	var tr = io.TeeReader(r, header)
+
+	var fixed struct {
+		Magic     [4]byte
+		ChunkSize uint32
+		KeyIDLen  uint16
+	}
+	if err := binary.Read(tr, binary.BigEndian, &fixed); err != nil {
+		return nil, fmt.Errorf("reading encryption header: %s", err)
+	} else if !bytes.Equal(fixed.Magic[:], encryptedMagic) {
+		return nil, errors.New("not an encrypted file")
+	} else if fixed.ChunkSize == 0 || fixed.ChunkSize > 1<<24 {
+		return nil, fmt.Errorf("invalid chunk size: %d", fixed.ChunkSize)
+	}
+
+	var keyID = make([]byte, fixed.KeyIDLen)
+	var wrappedLen uint16
+
+	if _, err := io.ReadFull(tr, keyID); err != nil {
+		return nil, fmt.Errorf("reading encryption header: %s", err)
+	} else if err = binary.Read(tr, binary.BigEndian, &wrappedLen); err != nil {
+		return nil, fmt.Errorf("reading encryption header: %s", err)
+	}
+	var wrapped = make([]byte, wrappedLen)
+
+	if _, err := io.ReadFull(tr, wrapped); err != nil {
+		return nil, fmt.Errorf("reading encryption header: %s", err)
+	}
+
+	var dataKey, err = fs.keys.UnwrapKey(string(keyID), wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("unwrapping data key: %s", err)
+	}
+	return newChunkCipher(dataKey, header.Bytes(), int(fixed.ChunkSize))
+}
+
+// chunkCipher seals and opens the chunks of an encrypted file. Chunks are
+// sealed with a nonce of their index, and with additional data of the file
+// header and whether the chunk is the final one of the file. Every file has
+// a final chunk, which holds less than |chunkSize| bytes (and may be empty).
+type chunkCipher struct {
+	gcm       cipher.AEAD
+	header    []byte
+	chunkSize int
+}
+
+func newChunkCipher(dataKey, header []byte, chunkSize int) (*chunkCipher, error) {
+	var gcm, err = newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	return &chunkCipher{gcm: gcm, header: header, chunkSize: chunkSize}, nil
+}
+
+// sealedSize is the size of a sealed, full chunk.
+func (c *chunkCipher) sealedSize() int { return c.chunkSize + c.gcm.Overhead() }
+
+func (c *chunkCipher) seal(dst []byte, index int64, chunk []byte, final bool) []byte {
+	var nonce, ad = c.nonceAndAD(index, final)
+	return c.gcm.Seal(dst, nonce, chunk, ad)
+}
+
+func (c *chunkCipher) open(dst []byte, index int64, sealed []byte, final bool) ([]byte, error) {
+	var nonce, ad = c.nonceAndAD(index, final)
+	var out, err = c.gcm.Open(dst, nonce, sealed, ad)
+	if err != nil {
+		return nil, fmt.Errorf("decrypting chunk %d: %s", index, err)
+	}
+	return out, nil
+}
+
+func (c *chunkCipher) nonceAndAD(index int64, final bool) (nonce, ad []byte) {
+	nonce = make([]byte, c.gcm.NonceSize())
+	binary.BigEndian.PutUint64(nonce[len(nonce)-8:], uint64(index))
+
+	ad = append(ad, c.header...)
+	if final {
+		ad = append(ad, 1)
+	} else {
+		ad = append(ad, 0)
+	}
+	return
+}
+
+// plaintextSize maps the |stored| size of an encrypted file to the size of
+// its plaintext, or returns -1 if |stored| is not a valid size.
+func (c *chunkCipher) plaintextSize(stored int64) int64 {
+	var body = stored - int64(len(c.header))
+	var full, rem = body / int64(c.sealedSize()), body % int64(c.sealedSize())
+
+	if body < 0 || rem < int64(c.gcm.Overhead()) {
+		return -1
+	}
+	return full*int64(c.chunkSize) + rem - int64(c.gcm.Overhead())
+}
+
+// sealingReader is an io.Reader which encrypts content read from |from|.
+type sealingReader struct {
+	cipher  *chunkCipher
+	from    io.Reader
+	n       int64  // Plaintext bytes read from |from|.
+	index   int64  // Index of the next chunk.
+	chunk   []byte // Buffer for plaintext chunks.
+	pending []byte // Sealed content not yet read.
+	done    bool   // Whether the final chunk was sealed.
+}
+
+func (r *sealingReader) Read(p []byte) (int, error) {
+	for len(r.pending) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+
+		if r.chunk == nil {
+			r.chunk = make([]byte, r.cipher.chunkSize)
+		}
+		var n, err = io.ReadFull(r.from, r.chunk)
+		r.n += int64(n)
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			r.done = true
+		} else if err != nil {
+			return 0, err
+		}
+		r.pending = r.cipher.seal(r.pending[:0], r.index, r.chunk[:n], r.done)
+		r.index++
+	}
+
+	var n = copy(p, r.pending)
+	r.pending = r.pending[n:]
+	return n, nil
+}
+
+// encryptedFile is a File of an encryptedFs.
+type encryptedFile struct {
+	File
+	fs     *encryptedFs
+	cipher *chunkCipher
+	name   string
+
+	// Used in write operations.
+	writer        bool
+	headerWritten bool
+	spool         []byte
+	writeIndex    int64
+
+	// Used in read operations.
+	offset     int64  // Plaintext offset of the next Read.
+	size       int64  // Plaintext size of the file, or -1 if unknown.
+	chunk      []byte // Plaintext of chunk |chunkIndex|.
+	chunkIndex int64
+	nextIndex  int64 // Index of the chunk at the underlying File's offset.
+
+	err error
+}
+
+// File interface method. Content is sealed and written to the underlying
+// File as each chunk fills.
+func (f *encryptedFile) Write(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	} else if !f.writer {
+		return 0, errors.New("not an encrypted file writer")
+	}
+
+	if f.spool == nil {
+		f.spool = make([]byte, 0, f.cipher.chunkSize)
+	}
+
+	var n = len(p)
+	for len(p) != 0 {
+		var m = copy(f.spool[len(f.spool):cap(f.spool)], p)
+		f.spool, p = f.spool[:len(f.spool)+m], p[m:]
+
+		if len(f.spool) == f.cipher.chunkSize {
+			if f.err = f.writeChunk(false); f.err != nil {
+				return 0, f.err
+			}
+		}
+	}
+	return n, nil
+}
+
+// File interface method. Writers seal and write the final chunk of the file
+// before closing the underlying File.
+func (f *encryptedFile) Close() error {
+	if f.writer {
+		f.writer = false
+
+		if f.err == nil {
+			f.err = f.writeChunk(true)
+		}
+		if err := f.File.Close(); f.err == nil {
+			f.err = err
+		}
+		return f.err
+	}
+	return f.File.Close()
+}
+
+// File interface method. Readers report the plaintext size of the file.
+func (f *encryptedFile) Stat() (os.FileInfo, error) {
+	var info, err = f.File.Stat()
+	if err != nil || f.writer || info.IsDir() {
+		return info, err
+	} else if err = f.load(); err != nil {
+		return nil, err
+	}
+	return &encryptedFileInfo{FileInfo: info, size: f.size}, nil
+}
+
+// File interface method. FileInfos of files report their plaintext size,
+// which requires reading the header of each file. An error is returned if
+// the size of a file can't be determined (eg, it is not encrypted).
+func (f *encryptedFile) Readdir(count int) ([]os.FileInfo, error) {
+	var infos, err = f.File.Readdir(count)
+
+	for i, info := range infos {
+		if info.IsDir() {
+			continue
+		}
+		var size, sizeErr = f.fs.plaintextSize(path.Join(f.name, info.Name()))
+		if sizeErr != nil {
+			return infos[:i], fmt.Errorf("%s: %s", info.Name(), sizeErr)
+		}
+		infos[i] = &encryptedFileInfo{FileInfo: info, size: size}
+	}
+	return infos, err
+}
+
+// File interface method.
+func (f *encryptedFile) Read(p []byte) (int, error) {
+	if f.err != nil {
+		return 0, f.err
+	} else if f.writer {
+		return 0, errors.New("not an encrypted file reader")
+	} else if f.err = f.load(); f.err != nil {
+		return 0, f.err
+	}
+
+	if f.size != -1 && f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	var index = f.offset / int64(f.cipher.chunkSize)
+	if index != f.chunkIndex {
+		if f.chunk, f.err = f.readChunk(index); f.err != nil {
+			return 0, f.err
+		}
+		f.chunkIndex = index
+	}
+
+	var within = int(f.offset % int64(f.cipher.chunkSize))
+	if within >= len(f.chunk) {
+		return 0, io.EOF // Final chunk.
+	}
+	var n = copy(p, f.chunk[within:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+// File interface method. Seeks are lazy: decryption of the chunk at the
+// seeked offset happens on the next Read.
+func (f *encryptedFile) Seek(offset int64, whence int) (int64, error) {
+	if f.writer {
+		return 0, errors.New("cannot seek an encrypted file writer")
+	} else if f.err != nil {
+		return f.offset, f.err
+	} else if err := f.load(); err != nil {
+		return f.offset, err
+	}
+
+	// Make |offset| absolute.
+	if whence == io.SeekCurrent {
+		offset += f.offset
+	} else if whence == io.SeekEnd {
+		if f.size == -1 {
+			return f.offset, errors.New("encrypted file size is unknown")
+		}
+		offset += f.size
+	}
+	if offset < 0 {
+		return f.offset, errors.New("negative seek offset")
+	}
+	f.offset = offset
+	return f.offset, nil
+}
+
+// ReadAt reads decrypted content at plaintext offset |off|, without altering
+// the offset of Read. It requires that the underlying File is an io.ReaderAt.
+func (f *encryptedFile) ReadAt(p []byte, off int64) (int, error) {
+	var ra, ok = f.File.(io.ReaderAt)
+	if !ok {
+		return 0, errors.New("underlying file is not an io.ReaderAt")
+	} else if f.writer {
+		return 0, errors.New("not an encrypted file reader")
+	} else if err := f.load(); err != nil {
+		return 0, err
+	}
+
+	var n int
+	var sealed = make([]byte, f.cipher.sealedSize())
+	var chunk []byte
+
+	for n != len(p) {
+		var index = (off + int64(n)) / int64(f.cipher.chunkSize)
+		var within = int((off + int64(n)) % int64(f.cipher.chunkSize))
+
+		var m, err = ra.ReadAt(sealed, f.chunkOffset(index))
+		if err != nil && err != io.EOF {
+			return n, err
+		} else if m == 0 {
+			return n, io.EOF // |off| is beyond the final chunk.
+		}
+		var final = m < len(sealed)
+
+		if chunk, err = f.cipher.open(chunk[:0], index, sealed[:m], final); err != nil {
+			return n, err
+		} else if within >= len(chunk) {
+			return n, io.EOF
+		}
+		n += copy(p[n:], chunk[within:])
+
+		if final && n != len(p) {
+			return n, io.EOF
+		}
+	}
+	return n, nil
+}
+
+// Reads the file header and determines the plaintext size of the file, if
+// not already loaded.
+func (f *encryptedFile) load() error {
+	if f.cipher != nil {
+		return nil
+	}
+	var cipher, err = f.fs.readCipher(f.File)
+	if err != nil {
+		return err
+	}
+	f.cipher, f.size = cipher, -1
+
+	if info, err := f.File.Stat(); err == nil && !info.IsDir() {
+		f.size = cipher.plaintextSize(info.Size())
+	}
+	return nil
+}
+
+// Loads the file, and returns an error if its plaintext size is unknown.
+func (f *encryptedFile) loadSize() error {
+	if err := f.load(); err != nil {
+		return err
+	} else if f.size == -1 {
+		return errors.New("encrypted file size is invalid")
+	}
+	return nil
+}
+
+// Reads and decrypts chunk |index|, seeking the underlying File if required.
+func (f *encryptedFile) readChunk(index int64) ([]byte, error) {
+	if index != f.nextIndex {
+		if _, err := f.File.Seek(f.chunkOffset(index), io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	var sealed = make([]byte, f.cipher.sealedSize())
+
+	var n, err = io.ReadFull(f.File, sealed)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF // Final chunk is missing.
+	} else if err != nil && err != io.ErrUnexpectedEOF {
+		return nil, err
+	}
+	f.nextIndex = index + 1
+
+	return f.cipher.open(f.chunk[:0], index, sealed[:n], n < len(sealed))
+}
+
+// Seals and writes the spooled chunk, preceded by the file header if this is
+// the first written chunk.
+func (f *encryptedFile) writeChunk(final bool) error {
+	var out []byte
+	if !f.headerWritten {
+		out = append(out, f.cipher.header...)
+		f.headerWritten = true
+	}
+	out = f.cipher.seal(out, f.writeIndex, f.spool, final)
+
+	f.spool = f.spool[:0]
+	f.writeIndex++
+
+	var _, err = f.File.Write(out)
+	return err
+}
+
+// encryptedFileInfo is an os.FileInfo of an encrypted file, which reports
+// its plaintext |size|.
+type encryptedFileInfo struct {
+	os.FileInfo
+	size int64
+}
+
+// os.FileInfo interface method.
+func (i *encryptedFileInfo) Size() int64 { return i.size }
+
+// walkedEncryptedFile is passed by Walk as the FileInfo of an underlying
+// File which is also an os.FileInfo. It decrypts on Read.
+type walkedEncryptedFile struct {
+	*encryptedFile
+	*encryptedFileInfo
+}
+
+// Offset of chunk |index| within the underlying File.
+func (f *encryptedFile) chunkOffset(index int64) int64 {
+	return int64(len(f.cipher.header)) + index*int64(f.cipher.sealedSize())
+}
@@ -0,0 +1,423 @@
+package cloudstore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing/iotest"
+
+	gc "github.com/go-check/check"
+)
+
+type EncryptedFSSuite struct {
+	dir  string
+	base FileSystem
+	keys *KeyfileProvider
+	cfs  FileSystem
+}
+
+func (s *EncryptedFSSuite) SetUpTest(c *gc.C) {
+	var err error
+	s.dir = c.MkDir()
+	s.base = NewTmpFileSystem()
+
+	s.keys, err = NewKeyfileProvider(s.writeKeyfile(c, "keys", keyfileFixture))
+	c.Assert(err, gc.IsNil)
+	s.cfs = NewEncryptedFileSystem(s.base, s.keys)
+}
+
+func (s *EncryptedFSSuite) TearDownTest(c *gc.C) {
+	c.Check(s.base.Close(), gc.IsNil)
+}
+
+func (s *EncryptedFSSuite) TestWriteAndReadRoundTrip(c *gc.C) {
+	var content = randomContent(encryptedChunkSize*3 + 1234)
+	c.Assert(s.cfs.MkdirAll("a/", 0750), gc.IsNil)
+
+	var f, err = s.cfs.OpenFile("a/file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	c.Assert(err, gc.IsNil)
+
+	// Write in sizes which don't align with chunks.
+	for rem := content; len(rem) != 0; {
+		var n = 7919
+		if n > len(rem) {
+			n = len(rem)
+		}
+		_, err = f.Write(rem[:n])
+		c.Assert(err, gc.IsNil)
+		rem = rem[n:]
+	}
+	c.Assert(f.Close(), gc.IsNil)
+
+	s.verifyContent(c, "a/file", content)
+
+	// Stored content is not plaintext.
+	var stored = s.readStored(c, "a/file")
+	c.Check(bytes.Contains(stored, content[:64]), gc.Equals, false)
+	c.Check(len(stored) > len(content), gc.Equals, true)
+}
+
+func (s *EncryptedFSSuite) TestCopyAtomicRoundTrip(c *gc.C) {
+	for _, size := range []int{0, 1, encryptedChunkSize - 1, encryptedChunkSize, encryptedChunkSize*2 + 1} {
+		var content = randomContent(size)
+
+		var f, err = s.cfs.OpenFile("file", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+		c.Assert(err, gc.IsNil)
+
+		n, err := s.cfs.CopyAtomic(f, iotest.HalfReader(bytes.NewReader(content)))
+		c.Check(err, gc.IsNil)
+		c.Check(n, gc.Equals, int64(size))
+
+		s.verifyContent(c, "file", content)
+	}
+}
+
+func (s *EncryptedFSSuite) TestPartialCopyIsRemoved(c *gc.C) {
+	var f, err = s.cfs.OpenFile("file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	c.Assert(err, gc.IsNil)
+
+	var r = io.MultiReader(bytes.NewReader(randomContent(encryptedChunkSize+10)), errReader{})
+
+	_, err = s.cfs.CopyAtomic(f, r)
+	c.Check(err, gc.ErrorMatches, "read error")
+
+	_, err = s.cfs.Open("file")
+	c.Check(os.IsNotExist(err), gc.Equals, true)
+}
+
+func (s *EncryptedFSSuite) TestCopyAtomicRequiresEncryptedFile(c *gc.C) {
+	var f, err = s.base.OpenFile("file", os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	c.Assert(err, gc.IsNil)
+
+	_, err = s.cfs.CopyAtomic(f, bytes.NewReader(randomContent(10)))
+	c.Check(err, gc.ErrorMatches, "file was not opened by the encrypted FileSystem")
+}
+
+func (s *EncryptedFSSuite) TestStatReaddirAndWalkReportPlaintextSizes(c *gc.C) {
+	var contents = map[string][]byte{
+		"a/empty": randomContent(0),
+		"a/large": randomContent(encryptedChunkSize + 10),
+	}
+	c.Assert(s.cfs.MkdirAll("a/", 0750), gc.IsNil)
+	for name, content := range contents {
+		s.copyContent(c, name, content)
+
+		var f, err = s.cfs.Open(name)
+		c.Assert(err, gc.IsNil)
+		info, err := f.Stat()
+		c.Check(err, gc.IsNil)
+		c.Check(info.Size(), gc.Equals, int64(len(content)))
+		c.Check(f.Close(), gc.IsNil)
+	}
+
+	var dir, err = s.cfs.Open("a/")
+	c.Assert(err, gc.IsNil)
+	infos, err := dir.Readdir(-1)
+	c.Check(err, gc.IsNil)
+	c.Check(dir.Close(), gc.IsNil)
+
+	var sizes = make(map[string]int)
+	for _, info := range infos {
+		sizes[info.Name()] = int(info.Size())
+	}
+	c.Check(sizes, gc.DeepEquals, map[string]int{
+		"empty": 0,
+		"large": encryptedChunkSize + 10,
+	})
+
+	// Walk FileSystems which do and do not pass Files as FileInfos.
+	for _, cfs := range []FileSystem{s.cfs, NewEncryptedFileSystem(filesAsInfosFS{s.base}, s.keys)} {
+		var sizes = make(map[string]int)
+		var files int
+
+		c.Check(cfs.Walk("a", func(name string, info os.FileInfo, err error) error {
+			c.Assert(err, gc.IsNil)
+			sizes[name] = int(info.Size())
+
+			// Walked Files decrypt content.
+			if f, ok := info.(File); ok {
+				var read, err = ioutil.ReadAll(f)
+				c.Check(err, gc.IsNil)
+				c.Check(read, gc.DeepEquals, contents[name])
+				files++
+			}
+			return nil
+		}), gc.IsNil)
+
+		c.Check(sizes, gc.DeepEquals, map[string]int{
+			"a/empty": 0,
+			"a/large": encryptedChunkSize + 10,
+		})
+		if cfs == s.cfs {
+			c.Check(files, gc.Equals, 0)
+		} else {
+			c.Check(files, gc.Equals, 2)
+		}
+	}
+}
+
+func (s *EncryptedFSSuite) TestUnknownSizesAreErrors(c *gc.C) {
+	c.Assert(s.cfs.MkdirAll("a/", 0750), gc.IsNil)
+	s.copyContent(c, "a/encrypted", randomContent(10))
+
+	// Write a file which is not encrypted.
+	var f, err = s.base.OpenFile("a/plain", os.O_WRONLY|os.O_CREATE, 0640)
+	c.Assert(err, gc.IsNil)
+	_, err = s.base.CopyAtomic(f, bytes.NewReader([]byte("plaintext content")))
+	c.Assert(err, gc.IsNil)
+
+	dir, err := s.cfs.Open("a/")
+	c.Assert(err, gc.IsNil)
+	_, err = dir.Readdir(-1)
+	c.Check(err, gc.ErrorMatches, "plain: not an encrypted file")
+	c.Check(dir.Close(), gc.IsNil)
+
+	// Walk passes the error of the foreign file, with its stored FileInfo.
+	for _, cfs := range []FileSystem{s.cfs, NewEncryptedFileSystem(filesAsInfosFS{s.base}, s.keys)} {
+		var walked = make(map[string]string)
+
+		c.Check(cfs.Walk("a", func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				walked[name] = err.Error()
+				c.Check(info.Size(), gc.Equals, int64(len("plaintext content")))
+			} else {
+				walked[name] = "ok"
+			}
+			return nil
+		}), gc.IsNil)
+
+		c.Check(walked, gc.DeepEquals, map[string]string{
+			"a/encrypted": "ok",
+			"a/plain":     "not an encrypted file",
+		})
+	}
+}
+
+func (s *EncryptedFSSuite) TestModificationAndTruncationAreDetected(c *gc.C) {
+	var content = randomContent(encryptedChunkSize * 2)
+	s.copyContent(c, "file", content)
+	var stored = s.readStored(c, "file")
+
+	// Flip a bit of the second chunk. The first chunk remains readable.
+	var modified = append([]byte(nil), stored...)
+	modified[len(modified)-encryptedChunkSize] ^= 0x01
+	s.writeStored(c, "file", modified)
+
+	var f, err = s.cfs.Open("file")
+	c.Assert(err, gc.IsNil)
+
+	var buf = make([]byte, encryptedChunkSize)
+	_, err = io.ReadFull(f, buf)
+	c.Check(err, gc.IsNil)
+	c.Check(buf, gc.DeepEquals, content[:encryptedChunkSize])
+
+	_, err = f.Read(buf)
+	c.Check(err, gc.ErrorMatches, "decrypting chunk 1: .*")
+	f.Close()
+
+	// Drop the final (empty) chunk. Remaining chunks are intact.
+	s.writeStored(c, "file", stored[:len(stored)-16])
+
+	f, err = s.cfs.Open("file")
+	c.Assert(err, gc.IsNil)
+	_, err = ioutil.ReadAll(f)
+	c.Check(err, gc.Equals, io.ErrUnexpectedEOF)
+	f.Close()
+
+	// Drop the final chunk, and a portion of the one before it.
+	s.writeStored(c, "file", stored[:len(stored)-100])
+
+	f, err = s.cfs.Open("file")
+	c.Assert(err, gc.IsNil)
+	_, err = ioutil.ReadAll(f)
+	c.Check(err, gc.ErrorMatches, "decrypting chunk 1: .*")
+	f.Close()
+}
+
+func (s *EncryptedFSSuite) TestSeekAndReadAt(c *gc.C) {
+	var content = randomContent(encryptedChunkSize*4 + 321)
+	s.copyContent(c, "file", content)
+
+	var f, err = s.cfs.Open("file")
+	c.Assert(err, gc.IsNil)
+	defer f.Close()
+
+	var buf = make([]byte, 1000)
+	for _, offset := range []int64{
+		encryptedChunkSize*2 + 17, // Forward seek.
+		10,                        // Backward seek.
+		encryptedChunkSize - 500,  // Spans a chunk boundary.
+		int64(len(content)) - 100, // Partial read of final chunk.
+	} {
+		n, err := f.Seek(offset, io.SeekStart)
+		c.Check(err, gc.IsNil)
+		c.Check(n, gc.Equals, offset)
+
+		var expect = content[offset:]
+		if len(expect) > len(buf) {
+			expect = expect[:len(buf)]
+		}
+		nn, err := io.ReadFull(f, buf[:len(expect)])
+		c.Check(err, gc.IsNil)
+		c.Check(buf[:nn], gc.DeepEquals, expect)
+
+		// ReadAt produces the same content.
+		nn, err = f.(io.ReaderAt).ReadAt(buf[:len(expect)], offset)
+		c.Check(err, gc.IsNil)
+		c.Check(buf[:nn], gc.DeepEquals, expect)
+	}
+
+	// Seeks relative to the file end are supported.
+	n, err := f.Seek(-10, io.SeekEnd)
+	c.Check(err, gc.IsNil)
+	c.Check(n, gc.Equals, int64(len(content)-10))
+
+	rest, err := ioutil.ReadAll(f)
+	c.Check(err, gc.IsNil)
+	c.Check(rest, gc.DeepEquals, content[len(content)-10:])
+
+	// ReadAt which extends beyond the file end returns io.EOF.
+	nn, err := f.(io.ReaderAt).ReadAt(buf, int64(len(content)-10))
+	c.Check(err, gc.Equals, io.EOF)
+	c.Check(buf[:nn], gc.DeepEquals, content[len(content)-10:])
+}
+
+func (s *EncryptedFSSuite) TestKeyRotation(c *gc.C) {
+	var content = randomContent(100)
+	s.copyContent(c, "file", content)
+
+	// A provider having a newly prepended key reads files of the prior key.
+	var keys, err = NewKeyfileProvider(s.writeKeyfile(c, "rotated",
+		"new-key "+keyFixtureC+"\n"+keyfileFixture))
+	c.Assert(err, gc.IsNil)
+	s.cfs = NewEncryptedFileSystem(s.base, keys)
+
+	s.verifyContent(c, "file", content)
+
+	// New files are wrapped with the new key.
+	s.copyContent(c, "other", content)
+	c.Check(bytes.Contains(s.readStored(c, "other"), []byte("new-key")), gc.Equals, true)
+
+	// A provider without the key is unable to read the file.
+	keys, err = NewKeyfileProvider(s.writeKeyfile(c, "other", "new-key "+keyFixtureC))
+	c.Assert(err, gc.IsNil)
+	s.cfs = NewEncryptedFileSystem(s.base, keys)
+
+	f, err := s.cfs.Open("file")
+	c.Assert(err, gc.IsNil)
+	_, err = ioutil.ReadAll(f)
+	c.Check(err, gc.ErrorMatches, "unwrapping data key: unknown key ID: key-a")
+}
+
+func (s *EncryptedFSSuite) TestKeyfileParsing(c *gc.C) {
+	for _, tc := range []struct{ content, err string }{
+		{"# Only a comment.\n", ".*: no keys found"},
+		{"key-a", ".*:1: expected key ID and key"},
+		{"\nkey-a not-base64!", ".*:2: illegal base64 .*"},
+		{"key-a " + keyFixtureA[:8], ".*:1: expected a 32-byte key \\(got 6 bytes\\)"},
+		{"key-a " + keyFixtureA + "\nkey-a " + keyFixtureB, ".*:2: duplicate key ID key-a"},
+	} {
+		var _, err = NewKeyfileProvider(s.writeKeyfile(c, "invalid", tc.content))
+		c.Check(err, gc.ErrorMatches, tc.err)
+	}
+
+	var _, err = NewKeyfileProvider(filepath.Join(s.dir, "does-not-exist"))
+	c.Check(os.IsNotExist(err), gc.Equals, true)
+}
+
+func (s *EncryptedFSSuite) TestURLsAreNotProduced(c *gc.C) {
+	var _, err = s.cfs.ToURL("file", "GET", 0)
+	c.Check(err, gc.Equals, ErrURLNotSupported)
+	c.Check(s.cfs.ProducesAuthorizedURL(), gc.Equals, false)
+}
+
+func (s *EncryptedFSSuite) verifyContent(c *gc.C, name string, content []byte) {
+	var f, err = s.cfs.Open(name)
+	c.Assert(err, gc.IsNil)
+	defer f.Close()
+
+	read, err := ioutil.ReadAll(f)
+	c.Check(err, gc.IsNil)
+	c.Check(read, gc.DeepEquals, content)
+}
+
+func (s *EncryptedFSSuite) copyContent(c *gc.C, name string, content []byte) {
+	var f, err = s.cfs.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	c.Assert(err, gc.IsNil)
+	_, err = s.cfs.CopyAtomic(f, bytes.NewReader(content))
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *EncryptedFSSuite) readStored(c *gc.C, name string) []byte {
+	var f, err = s.base.Open(name)
+	c.Assert(err, gc.IsNil)
+	defer f.Close()
+
+	b, err := ioutil.ReadAll(f)
+	c.Assert(err, gc.IsNil)
+	return b
+}
+
+func (s *EncryptedFSSuite) writeStored(c *gc.C, name string, content []byte) {
+	var f, err = s.base.OpenFile(name, os.O_WRONLY|os.O_TRUNC, 0640)
+	c.Assert(err, gc.IsNil)
+	_, err = s.base.CopyAtomic(f, bytes.NewReader(content))
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *EncryptedFSSuite) writeKeyfile(c *gc.C, name, content string) string {
+	var path = filepath.Join(s.dir, name)
+	c.Assert(ioutil.WriteFile(path, []byte(content), 0600), gc.IsNil)
+	return path
+}
+
+func randomContent(size int) []byte {
+	var b = make([]byte, size)
+	rand.Read(b)
+	return b
+}
+
+// filesAsInfosFS is a FileSystem which, like cloud FileSystems, passes its
+// Files as FileInfos to Walk functions.
+type filesAsInfosFS struct{ FileSystem }
+
+func (fs filesAsInfosFS) Walk(root string, walkFn filepath.WalkFunc) error {
+	return fs.FileSystem.Walk(root, func(name string, info os.FileInfo, err error) error {
+		if err != nil {
+			return walkFn(name, info, err)
+		}
+		file, err := fs.OpenFile(name, os.O_RDONLY, 0)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		return walkFn(name, struct {
+			File
+			os.FileInfo
+		}{file, info}, nil)
+	})
+}
+
+// errReader is an io.Reader which fails with an error.
+type errReader struct{}
+
+func (errReader) Read([]byte) (int, error) { return 0, errors.New("read error") }
+
+const (
+	keyFixtureA = "hcbWBBaClPwCCtkLXzMJ9n2qKm8Lm7C4z9uT+z8lAZc="
+	keyFixtureB = "2sJbZl7Qm9r9wJ3oAvq4uWf0aYUyTbKXbq3kYVw1cO8="
+	keyFixtureC = "Xo9B3cFmYb1wQ2s5H0n5dJ7h2yL4m3uJ8cZp6kR1tEs="
+
+	keyfileFixture = `
+# Keys of the test suite.
+key-a ` + keyFixtureA + `
+key-b ` + keyFixtureB + `
+`
+)
+
+var _ = gc.Suite(&EncryptedFSSuite{})
@@ -37,6 +37,8 @@ func UnmarshalEndpoint(data []byte) (ep Endpoint, err error) {
 		ep = new(SFTPEndpoint)
 	case "gcs":
 		ep = new(GCSEndpoint)
+	case "azure":
+		ep = new(AzureEndpoint)
 	default:
 		panic(fmt.Sprintf("unknown endpoint type: %s", base.Type))
 	}
@@ -31,6 +31,65 @@ func (s *EndpointSuite) TestUnmarshalEndpoint(c *gc.C) {
 		c.Fail()
 	}
 	c.Check(gcsep.GCSBucket, gc.Equals, "testbucket")
+
+	data = `{"name": "testep", "type": "azure", "account_name": "account", "container": "testcontainer"}`
+	ep, err = UnmarshalEndpoint([]byte(data))
+	c.Check(err, gc.IsNil)
+	azureep, ok := ep.(*AzureEndpoint)
+	if !ok {
+		c.Fail()
+	}
+	c.Check(azureep.AzureAccountName, gc.Equals, "account")
+	c.Check(azureep.AzureContainer, gc.Equals, "testcontainer")
+}
+
+func (s *EndpointSuite) TestAzureEndpointValidation(c *gc.C) {
+	var ep = AzureEndpoint{
+		BaseEndpoint:     BaseEndpoint{Name: "testep", Type: "azure"},
+		AzureAccountName: "account",
+		AzureContainer:   "testcontainer",
+	}
+	c.Check(ep.Validate(), gc.IsNil)
+
+	ep.AzureContainer = ""
+	c.Check(ep.Validate(), gc.ErrorMatches, "must specify azure container")
+	ep.AzureAccountName, ep.AzureContainer = "", "testcontainer"
+	c.Check(ep.Validate(), gc.ErrorMatches, "must specify azure account name")
+	ep.AzureAccountName, ep.Name = "account", ""
+	c.Check(ep.Validate(), gc.ErrorMatches, "must specify an endpoint name")
+}
+
+func (s *EndpointSuite) TestAzureEndpointConnect(c *gc.C) {
+	var data = `{"name": "testep", "type": "azure", "account_name": "devstoreaccount1",
+		"container": "testcontainer", "subfolder": "sub/folder"}`
+	var ep, err = UnmarshalEndpoint([]byte(data))
+	c.Assert(err, gc.IsNil)
+	c.Check(ep.Validate(), gc.IsNil)
+
+	var azureep = ep.(*AzureEndpoint)
+	c.Check(azureep.uri(), gc.Equals, "azure://testcontainer/sub/folder")
+	c.Check(azureep.properties(), gc.DeepEquals, MapProperties{
+		AzureAccountName: "devstoreaccount1",
+		AzureAccountKey:  "",
+	})
+
+	// The storage emulator account connects to a local emulator, without a
+	// network round-trip.
+	fs, err := ep.Connect(MapProperties{})
+	c.Assert(err, gc.IsNil)
+	var afs = fs.(*azureFs)
+	c.Check(afs.prefix, gc.Equals, "testcontainer/sub/folder")
+	c.Check(afs.useHTTPS, gc.Equals, false)
+
+	// Other accounts require a base64 account key.
+	azureep.AzureAccountName, azureep.AzureAccountKey = "account", "not base64!"
+	_, err = ep.Connect(MapProperties{})
+	c.Check(err, gc.NotNil)
+
+	azureep.AzureAccountKey = "a2V5"
+	fs, err = ep.Connect(MapProperties{})
+	c.Assert(err, gc.IsNil)
+	c.Check(fs.(*azureFs).useHTTPS, gc.Equals, true)
 }
 
 var _ = gc.Suite(new(EndpointSuite))
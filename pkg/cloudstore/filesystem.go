@@ -75,13 +75,32 @@ type FileSystem interface {
 
 // Selects a FileSystem implementation from |rawURL|. Implementations are
 // determined by URL scheme, and the path roots the resulting FileSystem.
-// Depending on provider, options are passed as URL query arguments.
+// Depending on provider, options are passed as URL query arguments. Any
+// provider may also be encrypted with keys of a KeyfileProvider, by passing
+// its path as query argument "encryptionKeyfile".
 func NewFileSystem(properties Properties, rawURL string) (FileSystem, error) {
 	url, err := url.Parse(rawURL)
 	if err != nil {
 		return nil, err
 	}
 
+	// Optionally, encrypt files of the FileSystem with keys of a local keyfile.
+	if keyfile := url.Query().Get("encryptionKeyfile"); keyfile != "" {
+		var keys, err = NewKeyfileProvider(keyfile)
+		if err != nil {
+			return nil, err
+		}
+		var query = url.Query()
+		query.Del("encryptionKeyfile")
+		url.RawQuery = query.Encode()
+
+		fs, err := NewFileSystem(properties, url.String())
+		if err != nil {
+			return nil, err
+		}
+		return NewEncryptedFileSystem(fs, keys), nil
+	}
+
 	if url.Scheme == "file" {
 		return localFs{http.Dir(url.Path), false}, nil
 	} else if url.Scheme == "gs" {
@@ -101,6 +120,8 @@ func NewFileSystem(properties Properties, rawURL string) (FileSystem, error) {
 		return newS3FS(sp, url.Host+url.Path, compress)
 	} else if url.Scheme == "sftp" {
 		return newSFTPFs(properties, url.Host, url.Path, url.User)
+	} else if url.Scheme == "azure" {
+		return newAzureFS(properties, url.Host+url.Path)
 	} else {
 		return nil, errors.New("filesystem not supported: " + rawURL)
 	}
@@ -3,6 +3,13 @@ package cloudstore
 // How to test individual cloudstore.Filesystem implementations:
 //  * Local: go test -v github.com/LiveRamp/gazette/pkg/cloudstore -check.vv -cloudFS file:///tmp/path
 //  * GCS:   go test -v github.com/LiveRamp/gazette/pkg/cloudstore -check.vv -cloudFS "gs://pippio-uploads/test?compress" -gcpServiceAccount /path/to/account/credentials.json
+//  * S3:    AWS_ENDPOINT=http://localhost:9000 AWS_DISABLE_SSL=1 AWS_REGION=us-east-1 go test -v github.com/LiveRamp/gazette/pkg/cloudstore -check.vv -cloudFS s3://examples/test
+//    (against a local minio server, as deployed by test/minio-values.yaml, with AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY of the server).
+//  * Azure: AZURE_STORAGE_ACCOUNT=devstoreaccount1 go test -v github.com/LiveRamp/gazette/pkg/cloudstore -check.vv -cloudFS azure://test/path
+//    (against a local Azurite emulator, having blob container "test").
+//    Alternatively, set AZURE_TEST_CONTAINER=test to run a second instance of the suite
+//    against that container alongside -cloudFS. It's skipped if the variable is unset.
+//  * Encrypted: append query argument "encryptionKeyfile=/path/to/keyfile" to any of the above.
 
 import (
 	"bytes"
@@ -37,6 +44,9 @@ var (
 
 type FileSystemSuite struct {
 	cfs FileSystem
+	// If |azure|, the suite runs against the Azure blob container named by
+	// AZURE_TEST_CONTAINER rather than -cloudFS, and is skipped if it's unset.
+	azure bool
 }
 
 func (s *FileSystemSuite) SetUpSuite(c *gc.C) {
@@ -46,8 +56,17 @@ func (s *FileSystemSuite) SetUpSuite(c *gc.C) {
 	// Prepare random seed for large file test.
 	rand.Seed(time.Now().Unix())
 
+	var url = *cloudFSURL
+	if s.azure {
+		var container = os.Getenv("AZURE_TEST_CONTAINER")
+		if container == "" {
+			c.Skip("AZURE_TEST_CONTAINER not set")
+		}
+		url = "azure://" + container + "/cloudstore-test"
+	}
+
 	var err error
-	s.cfs, err = NewFileSystem(nil, *cloudFSURL)
+	s.cfs, err = NewFileSystem(nil, url)
 	if err != nil {
 		c.Fatal("Using temp filesystem: failed to initialize DefaultFilesystem: ", err)
 	}
@@ -67,6 +86,9 @@ func (s *FileSystemSuite) SetUpTest(c *gc.C) {
 }
 
 func (s *FileSystemSuite) TearDownSuite(c *gc.C) {
+	if s.cfs == nil {
+		return // Skipped.
+	}
 	s.cfs.Remove("path/to/fixture")
 	s.cfs.Close()
 }
@@ -390,8 +412,50 @@ func (s *FileSystemSuite) TestSeek(c *gc.C) {
 	c.Check(string(buffer[:15]), gc.Equals, quote[:15])
 }
 
+func (s *FileSystemSuite) TestSeekWithinLargeFile(c *gc.C) {
+	var content = make([]byte, 3*megabyte+123)
+	rand.Read(content)
+
+	defer s.cfs.Remove("path/to/large-file")
+	file, err := s.cfs.OpenFile("path/to/large-file", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	c.Assert(err, gc.IsNil)
+	_, err = s.cfs.CopyAtomic(file, bytes.NewReader(content))
+	c.Assert(err, gc.IsNil)
+
+	rdFile, err := s.cfs.Open("path/to/large-file")
+	c.Assert(err, gc.IsNil)
+	defer rdFile.Close()
+
+	var buffer [1000]byte
+	for _, offset := range []int64{
+		2*megabyte + 17,           // Long forward seek.
+		2*megabyte + 5000,         // Short forward seek.
+		10,                        // Backward seek.
+		int64(len(content)) - 500, // Read through the file end.
+	} {
+		n, err := rdFile.Seek(offset, io.SeekStart)
+		c.Check(err, gc.IsNil)
+		c.Check(n, gc.Equals, offset)
+
+		var expect = content[offset:]
+		if len(expect) > len(buffer) {
+			expect = expect[:len(buffer)]
+		}
+		nn, err := io.ReadFull(rdFile, buffer[:])
+		if len(expect) < len(buffer) {
+			c.Check(err, gc.Equals, io.ErrUnexpectedEOF)
+		} else {
+			c.Check(err, gc.IsNil)
+		}
+		c.Check(buffer[:nn], gc.DeepEquals, expect)
+	}
+}
+
 func (s *FileSystemSuite) TestToURL(c *gc.C) {
 	url, err := s.cfs.ToURL("path/to/fixture", "GET", time.Minute)
+	if err == ErrURLNotSupported {
+		c.Skip("filesystem does not produce URLs")
+	}
 	c.Check(err, gc.IsNil)
 
 	// Build an http transport which can also serve from the file local system.
@@ -481,5 +545,6 @@ func (s *FileSystemSuite) TestWalk(c *gc.C) {
 func boxInt64(n int64) *int64 { return &n }
 
 var _ = gc.Suite(&FileSystemSuite{})
+var _ = gc.Suite(&FileSystemSuite{azure: true})
 
 func Test(t *testing.T) { gc.TestingT(t) }
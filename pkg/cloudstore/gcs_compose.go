@@ -0,0 +1,225 @@
+package cloudstore
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"cloud.google.com/go/storage"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Size of chunks uploaded concurrently by a gcsComposeWriter. Files smaller
+	// than a single chunk are uploaded with one request.
+	gcsComposeChunkSize = 1024 * 1024 * 32
+	// Maximum number of source objects of a single GCS compose request.
+	gcsMaxComposeSources = 32
+	// Bucket-relative prefix under which chunks are staged prior to composition.
+	// Objects under this prefix are removed as the upload completes or fails,
+	// but may leak should the process crash: buckets should apply a lifecycle
+	// rule which deletes objects under this prefix after a day or so. The
+	// prefix is hidden from Walk and Readdir of a gcsFs rooted at the bucket.
+	gcsComposePrefix = ".gazette-compose/"
+)
+
+// gcsWriter is the interface of a gcsFile writer. It's implemented by
+// *storage.Writer and *gcsComposeWriter.
+type gcsWriter interface {
+	io.WriteCloser
+	// CloseWithError aborts the write, such that the object is not created.
+	CloseWithError(err error) error
+}
+
+// gcsComposeWriter writes an object by concurrently uploading chunks of its
+// content as temporary objects, and then composing the chunks into the target
+// object. A chunk which fails to upload is retried without re-uploading other
+// chunks. As with a *storage.Writer, the object becomes visible only upon a
+// successful Close.
+//
+// Chunks are arbitrary byte ranges of the object. If the content is encoded
+// (eg, is a gzip stream written through a compressor), |contentEncoding| is
+// applied only to the composed object.
+type gcsComposeWriter struct {
+	bucket          *storage.BucketHandle
+	dst             *storage.ObjectHandle
+	contentEncoding string
+	// Unique staging prefix of chunks of this upload.
+	stagePrefix string
+
+	spool   bytes.Buffer
+	parts   int
+	uploads *partUploader
+	// Objects which must be deleted once the upload completes or fails.
+	// Guarded by |mu|.
+	staged []string
+	mu     sync.Mutex
+}
+
+func newGCSComposeWriter(client *storage.Client, bucket, path, contentEncoding string) (*gcsComposeWriter, error) {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, err
+	}
+	var w = &gcsComposeWriter{
+		bucket:          client.Bucket(bucket),
+		dst:             client.Bucket(bucket).Object(path),
+		contentEncoding: contentEncoding,
+		stagePrefix:     gcsComposePrefix + hex.EncodeToString(id[:]) + "/",
+	}
+	w.uploads = newPartUploader(partUploadConcurrency, w.uploadChunk)
+	return w, nil
+}
+
+func (w *gcsComposeWriter) Write(p []byte) (int, error) {
+	if err := w.uploads.Err(); err != nil {
+		return 0, err
+	}
+	var n int
+	for n != len(p) {
+		var piece = p[n:]
+		if rem := gcsComposeChunkSize - w.spool.Len(); len(piece) > rem {
+			piece = piece[:rem]
+		}
+		w.spool.Write(piece)
+		n += len(piece)
+
+		if w.spool.Len() == gcsComposeChunkSize {
+			if err := w.startChunk(); err != nil {
+				return n, err
+			}
+		}
+	}
+	return n, nil
+}
+
+// Close uploads the final chunk, and composes all chunks into the target
+// object. If no chunk was started, the spooled content is uploaded directly
+// to the target object.
+func (w *gcsComposeWriter) Close() error {
+	if w.parts == 0 {
+		return w.put(w.dst, w.spool.Bytes(), w.contentEncoding)
+	}
+	if w.spool.Len() != 0 {
+		if err := w.startChunk(); err != nil {
+			w.CloseWithError(err)
+			return err
+		}
+	}
+	if err := w.uploads.Wait(); err != nil {
+		w.CloseWithError(err)
+		return err
+	}
+	defer w.removeStaged()
+
+	var sources []*storage.ObjectHandle
+	for part := 1; part <= w.parts; part++ {
+		sources = append(sources, w.bucket.Object(w.chunkName(part)))
+	}
+	// Reduce |sources| by composing intermediate objects, until all remaining
+	// sources may be composed into the target by a single request.
+	for round := 0; len(sources) > gcsMaxComposeSources; round++ {
+		var next []*storage.ObjectHandle
+
+		for i := 0; i < len(sources); i += gcsMaxComposeSources {
+			var end = i + gcsMaxComposeSources
+			if end > len(sources) {
+				end = len(sources)
+			}
+			var name = fmt.Sprintf("%sr%d-%05d", w.stagePrefix, round, len(next))
+			w.addStaged(name)
+
+			if err := w.compose(w.bucket.Object(name), sources[i:end], ""); err != nil {
+				return err
+			}
+			next = append(next, w.bucket.Object(name))
+		}
+		sources = next
+	}
+	return w.compose(w.dst, sources, w.contentEncoding)
+}
+
+// CloseWithError aborts the upload, removing any uploaded chunks.
+func (w *gcsComposeWriter) CloseWithError(err error) error {
+	w.uploads.Wait()
+	w.removeStaged()
+	return nil
+}
+
+// startChunk begins an upload of the spooled content as the next chunk.
+func (w *gcsComposeWriter) startChunk() error {
+	w.parts++
+
+	var content = w.spool.Bytes()
+	w.spool = bytes.Buffer{}
+
+	w.addStaged(w.chunkName(w.parts))
+	return w.uploads.Start(w.parts, content)
+}
+
+func (w *gcsComposeWriter) uploadChunk(part int, content []byte) error {
+	return w.put(w.bucket.Object(w.chunkName(part)), content, "")
+}
+
+func (w *gcsComposeWriter) chunkName(part int) string {
+	return fmt.Sprintf("%s%05d", w.stagePrefix, part)
+}
+
+// put uploads |content| as object |obj|, having |contentEncoding|.
+func (w *gcsComposeWriter) put(obj *storage.ObjectHandle, content []byte, contentEncoding string) error {
+	var ow = obj.NewWriter(context.Background())
+	ow.ContentType = "application/octet-stream"
+	ow.ContentEncoding = contentEncoding
+
+	if _, err := ow.Write(content); err != nil {
+		ow.CloseWithError(err)
+		return err
+	}
+	return ow.Close()
+}
+
+// compose concatenates |sources| into object |dst|, having |contentEncoding|.
+func (w *gcsComposeWriter) compose(dst *storage.ObjectHandle, sources []*storage.ObjectHandle,
+	contentEncoding string) error {
+
+	var composer = dst.ComposerFrom(sources...)
+	composer.ContentType = "application/octet-stream"
+	composer.ContentEncoding = contentEncoding
+
+	var _, err = composer.Run(context.Background())
+	return err
+}
+
+func (w *gcsComposeWriter) addStaged(name string) {
+	w.mu.Lock()
+	w.staged = append(w.staged, name)
+	w.mu.Unlock()
+}
+
+// removeStaged deletes staged chunks and intermediate objects. Failures are
+// logged, but otherwise ignored.
+func (w *gcsComposeWriter) removeStaged() {
+	w.mu.Lock()
+	var staged = w.staged
+	w.staged = nil
+	w.mu.Unlock()
+
+	for _, name := range staged {
+		var err = w.bucket.Object(name).Delete(context.Background())
+		if err != nil && err != storage.ErrObjectNotExist {
+			log.WithFields(log.Fields{"err": err, "name": name}).
+				Warn("failed to remove staged upload object")
+		}
+	}
+}
+
+// isGCSComposeStaging returns whether bucket-relative |path| is of an object
+// staged by a gcsComposeWriter.
+func isGCSComposeStaging(path string) bool {
+	return strings.HasPrefix(path, gcsComposePrefix)
+}
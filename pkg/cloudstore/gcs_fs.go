@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"flag"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
@@ -142,13 +143,14 @@ func (fs *gcsFs) OpenFile(name string, flag int, perm os.FileMode) (File, error)
 		return nil, os.ErrNotExist // Read which doesn't exist. Map to os error.
 	} else if flag == os.O_RDONLY {
 		// Read which exists. Return a file which will lazily open a reader.
-		return &gcsFile{
-			fs:         fs,
-			bucket:     bucket,
-			path:       path,
-			attrs:      attrs,
-			lazyReader: true,
-		}, nil
+		var f = &gcsFile{
+			fs:     fs,
+			bucket: bucket,
+			path:   path,
+			attrs:  attrs,
+		}
+		f.reader = f.newReader()
+		return f, nil
 	} else if flag == os.O_WRONLY|os.O_TRUNC && !exists {
 		return nil, os.ErrNotExist // Write which doesn't exist. Map to os error.
 	} else if flag == os.O_WRONLY|os.O_CREATE|os.O_EXCL && exists {
@@ -157,15 +159,22 @@ func (fs *gcsFs) OpenFile(name string, flag int, perm os.FileMode) (File, error)
 		flag == os.O_WRONLY|os.O_CREATE|os.O_TRUNC ||
 		flag == os.O_WRONLY|os.O_CREATE|os.O_EXCL {
 
-		// Open for writing, potentially wrapped with a compressor.
-		var w = fs.client.Bucket(bucket).Object(path).NewWriter(context.Background())
+		// Open for writing, potentially wrapped with a compressor. Content (after
+		// compression) is uploaded as concurrent chunks, which are composed into
+		// the object.
+		var contentEncoding string
 		var compressor io.WriteCloser
 
 		// TODO(johnny, PUB-4052): Hack to skip gzip compression on recovery logs.
 		// Fix this by implementing configurable compression on journal hierarchies.
 		if fs.compress && !isRecoveryLog(name) {
-			w.ContentEncoding = "gzip"
-			w.ContentType = "application/octet-stream"
+			contentEncoding = "gzip"
+		}
+
+		var w, err = newGCSComposeWriter(fs.client, bucket, path, contentEncoding)
+		if err != nil {
+			return nil, err
+		} else if contentEncoding != "" {
 			compressor = gzip.NewWriter(w)
 		}
 
@@ -272,8 +281,9 @@ func (fs *gcsFs) Walk(root string, walkFn filepath.WalkFunc) error {
 
 	for ; err == nil; obj, err = iter.Next() {
 		// Sometimes there are invisible files named after the prefix,
-		// these aren't real files so pretend they don't exist.
-		if strings.HasSuffix(obj.Name, "/") {
+		// these aren't real files so pretend they don't exist. Nor are chunks
+		// of uploads in progress.
+		if strings.HasSuffix(obj.Name, "/") || isGCSComposeStaging(obj.Name) {
 			continue
 		}
 
@@ -333,12 +343,10 @@ type gcsFile struct {
 	// Cursor from last Readdir.
 	iter *storage.ObjectIterator
 
-	lazyReader bool
-	reader     io.ReadCloser
-	readOffset int64
+	reader *rangeReader
 
 	// If |compressor| is non-nil, it is backed by |writer|.
-	writer     *storage.Writer
+	writer     gcsWriter
 	compressor io.WriteCloser
 
 	// Generation info
@@ -349,20 +357,12 @@ type gcsFile struct {
 
 // File interface method.
 func (f *gcsFile) Read(p []byte) (int, error) {
-	if f.lazyReader {
-		// Lazy open.
-		f.reader, f.err = f.fs.client.Bucket(f.bucket).Object(f.path).NewReader(context.Background())
-		f.lazyReader = false
-	}
-	if f.err != nil {
-		return 0, f.err
-	} else if f.reader == nil {
+	if f.reader == nil {
 		return 0, errors.New("not a gcsFile reader")
 	}
 
 	var n int
 	n, f.err = f.reader.Read(p)
-	f.readOffset += int64(n)
 	return n, f.err
 }
 
@@ -410,31 +410,15 @@ func (f *gcsFile) Close() error {
 	return f.err
 }
 
-// File interface method.
+// File interface method. Seeks are lazy, and are realized by a ranged read
+// of the seek offset on the next Read.
 func (f *gcsFile) Seek(offset int64, whence int) (int64, error) {
 	if f.writer != nil {
 		return 0, errors.New("cannot seek a gcsFs writer")
+	} else if f.reader == nil {
+		return 0, errors.New("not a gcsFile reader")
 	}
-	// Make |offset| absolute.
-	if whence == io.SeekStart {
-	} else if whence == io.SeekCurrent {
-		offset += f.readOffset
-	} else if whence == io.SeekEnd {
-		return 0, errors.New("a gcsFs reader cannot seek relative to file end")
-	}
-	// If seeking before the current |readOffset|, re-open |f.reader|.
-	if offset < f.readOffset && (f.err == nil || f.err == io.EOF) {
-		f.reader.Close()
-		f.reader, f.err = f.fs.client.Bucket(f.bucket).Object(f.path).NewReader(context.Background())
-
-		f.readOffset = 0
-		f.err = nil
-	} else if f.err != nil {
-		return f.readOffset, f.err
-	}
-	// Errors and offsets are tracked by f.Read().
-	io.CopyN(ioutil.Discard, f, offset-f.readOffset)
-	return f.readOffset, f.err
+	return f.reader.Seek(offset, whence)
 }
 
 // File interface method.
@@ -447,7 +431,9 @@ func (f *gcsFile) Readdir(count int) ([]os.FileInfo, error) {
 	var obj, err = f.iter.Next()
 
 	for ; err == nil; obj, err = f.iter.Next() {
-		if obj.Prefix != "" {
+		if isGCSComposeStaging(obj.Prefix) || isGCSComposeStaging(obj.Name) {
+			// Chunks of uploads in progress. Skip.
+		} else if obj.Prefix != "" {
 			// Synthetic subdirectory prefix "file".
 			results = append(results, &gcsFile{
 				fs:     f.fs,
@@ -484,10 +470,13 @@ func (f *gcsFile) Readdir(count int) ([]os.FileInfo, error) {
 	return results, err
 }
 
-// File interface method
+// File interface method. Composite objects don't have an MD5 hash, and use
+// their CRC32C checksum instead.
 func (f *gcsFile) ContentSignature() (string, error) {
 	if f.IsDir() {
 		return "", os.ErrNotExist
+	} else if len(f.attrs.MD5) == 0 {
+		return fmt.Sprintf("crc32c:%08x", f.attrs.CRC32C), nil
 	}
 	return string(f.attrs.MD5), nil
 }
@@ -542,6 +531,23 @@ func (f *gcsFile) Sys() interface{} {
 	return nil
 }
 
+// newReader returns a rangeReader of the file's object generation. Objects
+// having Content-Encoding: gzip are transparently decompressed by GCS, which
+// doesn't support ranges of decompressed content. Those objects are instead
+// read from the beginning, and seeks discard through the seek offset.
+func (f *gcsFile) newReader() *rangeReader {
+	var obj = f.fs.client.Bucket(f.bucket).Object(f.path).Generation(f.attrs.Generation)
+
+	if f.attrs.ContentEncoding == "gzip" {
+		return newRangeReader(-1, false, func(int64) (io.ReadCloser, error) {
+			return obj.NewReader(context.Background())
+		})
+	}
+	return newRangeReader(f.attrs.Size, true, func(offset int64) (io.ReadCloser, error) {
+		return obj.NewRangeReader(context.Background(), offset, -1)
+	})
+}
+
 func (f *gcsFile) transfer(from io.Reader) (int64, error) {
 	var n int64
 
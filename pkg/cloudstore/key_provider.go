@@ -0,0 +1,113 @@
+package cloudstore
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyProvider wraps and unwraps the per-file data keys of an encrypted
+// FileSystem. Wrapped keys are stored alongside the content they encrypt,
+// and are useless without the KeyProvider's own (key-encrypting) keys.
+type KeyProvider interface {
+	// WrapKey encrypts |dataKey|, returning the wrapped key and an identifier
+	// of the key-encrypting key which was used.
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts |wrapped|, which was wrapped by key |keyID|.
+	UnwrapKey(keyID string, wrapped []byte) (dataKey []byte, err error)
+}
+
+// KeyfileProvider is a KeyProvider of AES-256 keys read from a local keyfile.
+// Each non-empty line of the keyfile (other than "#" comments) is a key
+// identifier followed by a base64-encoded 32-byte key:
+//
+//	# Keys used by gazette brokers.
+//	key-2018-03 3q2+7w...
+//	key-2017-11 yv66vg...
+//
+// The first key is used to wrap new data keys. Remaining keys are used only
+// to unwrap, which allows keys to be rotated by prepending a new key.
+type KeyfileProvider struct {
+	ids  []string
+	keys map[string]cipher.AEAD
+}
+
+// NewKeyfileProvider returns a KeyfileProvider of the keys of keyfile |path|.
+func NewKeyfileProvider(path string) (*KeyfileProvider, error) {
+	var f, err = os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var p = &KeyfileProvider{keys: make(map[string]cipher.AEAD)}
+	var scanner = bufio.NewScanner(f)
+
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		var line = strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		var fields = strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("%s:%d: expected key ID and key", path, lineNum)
+		} else if _, ok := p.keys[fields[0]]; ok {
+			return nil, fmt.Errorf("%s:%d: duplicate key ID %s", path, lineNum, fields[0])
+		}
+
+		if key, err := base64.StdEncoding.DecodeString(fields[1]); err != nil {
+			return nil, fmt.Errorf("%s:%d: %s", path, lineNum, err)
+		} else if len(key) != 32 {
+			return nil, fmt.Errorf("%s:%d: expected a 32-byte key (got %d bytes)", path, lineNum, len(key))
+		} else if p.keys[fields[0]], err = newGCM(key); err != nil {
+			return nil, err
+		}
+		p.ids = append(p.ids, fields[0])
+	}
+	if err = scanner.Err(); err != nil {
+		return nil, err
+	} else if len(p.ids) == 0 {
+		return nil, fmt.Errorf("%s: no keys found", path)
+	}
+	return p, nil
+}
+
+// WrapKey satisfies the KeyProvider interface. |dataKey| is encrypted with
+// AES-GCM under the first key of the keyfile.
+func (p *KeyfileProvider) WrapKey(dataKey []byte) (string, []byte, error) {
+	var id = p.ids[0]
+	var aead = p.keys[id]
+
+	var nonce = make([]byte, aead.NonceSize(), aead.NonceSize()+len(dataKey)+aead.Overhead())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return "", nil, err
+	}
+	return id, aead.Seal(nonce, nonce, dataKey, []byte(id)), nil
+}
+
+// UnwrapKey satisfies the KeyProvider interface.
+func (p *KeyfileProvider) UnwrapKey(id string, wrapped []byte) ([]byte, error) {
+	var aead, ok = p.keys[id]
+	if !ok {
+		return nil, fmt.Errorf("unknown key ID: %s", id)
+	} else if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("invalid wrapped key")
+	}
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(id))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	var block, err = aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
@@ -0,0 +1,93 @@
+package cloudstore
+
+import (
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// Number of concurrent part uploads of a single file.
+	partUploadConcurrency = 4
+	// Number of times an upload of a part is attempted before the file upload fails.
+	partUploadAttempts = 4
+)
+
+// partUploadBackoff returns the delay before upload |attempt| of a part.
+// It's swappable for testing.
+var partUploadBackoff = func(attempt int) time.Duration {
+	return time.Second * time.Duration(1<<uint(attempt-1))
+}
+
+// partUploader concurrently uploads the parts of a file. A part which fails to
+// upload is retried with back-off, independently of other parts, so that a
+// transient failure resumes from the parts already uploaded rather than
+// restarting the upload of the entire file.
+type partUploader struct {
+	upload func(part int, content []byte) error
+
+	sem chan struct{} // Bounds the number of concurrent uploads.
+	wg  sync.WaitGroup
+	err error // First failure of a part upload.
+	mu  sync.Mutex
+}
+
+func newPartUploader(concurrency int, upload func(part int, content []byte) error) *partUploader {
+	return &partUploader{
+		upload: upload,
+		sem:    make(chan struct{}, concurrency),
+	}
+}
+
+// Start begins an upload of part |part| having |content|, which must not be
+// modified until Wait returns. Start blocks while the maximum number of
+// uploads are already in progress, and returns an error if a previously
+// started part has failed to upload.
+func (u *partUploader) Start(part int, content []byte) error {
+	if err := u.Err(); err != nil {
+		return err
+	}
+	u.sem <- struct{}{}
+	u.wg.Add(1)
+
+	go func() {
+		defer u.wg.Done()
+		defer func() { <-u.sem }()
+
+		var err error
+		for attempt := 0; attempt != partUploadAttempts; attempt++ {
+			if attempt != 0 {
+				log.WithFields(log.Fields{"err": err, "part": part, "attempt": attempt}).
+					Warn("failed to upload part (will retry)")
+				time.Sleep(partUploadBackoff(attempt))
+			}
+			if err = u.upload(part, content); err == nil {
+				return
+			} else if u.Err() != nil {
+				break // Another part has failed. Don't bother retrying.
+			}
+		}
+
+		u.mu.Lock()
+		if u.err == nil {
+			u.err = err
+		}
+		u.mu.Unlock()
+	}()
+	return nil
+}
+
+// Err returns the first error of a failed part upload, if any.
+func (u *partUploader) Err() error {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.err
+}
+
+// Wait blocks until all started uploads complete, returning the first error
+// of a failed part upload.
+func (u *partUploader) Wait() error {
+	u.wg.Wait()
+	return u.Err()
+}
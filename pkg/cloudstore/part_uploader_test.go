@@ -0,0 +1,100 @@
+package cloudstore
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	gc "github.com/go-check/check"
+)
+
+type PartUploaderSuite struct {
+	backoff func(int) time.Duration
+}
+
+func (s *PartUploaderSuite) SetUpTest(c *gc.C) {
+	s.backoff = partUploadBackoff
+	partUploadBackoff = func(int) time.Duration { return 0 }
+}
+
+func (s *PartUploaderSuite) TearDownTest(c *gc.C) {
+	partUploadBackoff = s.backoff
+}
+
+func (s *PartUploaderSuite) TestConcurrencyIsBounded(c *gc.C) {
+	var mu sync.Mutex
+	var active, maxActive int
+	var uploaded = make(map[int]string)
+
+	var u = newPartUploader(3, func(part int, content []byte) error {
+		mu.Lock()
+		if active++; active > maxActive {
+			maxActive = active
+		}
+		mu.Unlock()
+
+		time.Sleep(time.Millisecond)
+
+		mu.Lock()
+		active--
+		uploaded[part] = string(content)
+		mu.Unlock()
+		return nil
+	})
+
+	for part := 1; part <= 20; part++ {
+		c.Check(u.Start(part, []byte{byte('a' + part)}), gc.IsNil)
+	}
+	c.Check(u.Wait(), gc.IsNil)
+
+	c.Check(maxActive <= 3, gc.Equals, true)
+	c.Check(uploaded, gc.HasLen, 20)
+	c.Check(uploaded[5], gc.Equals, "f")
+}
+
+func (s *PartUploaderSuite) TestTransientFailureIsRetried(c *gc.C) {
+	var mu sync.Mutex
+	var attempts = make(map[int]int)
+
+	var u = newPartUploader(2, func(part int, content []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		// Part 2 fails on its first two attempts.
+		if attempts[part]++; part == 2 && attempts[part] <= 2 {
+			return errors.New("transient error")
+		}
+		return nil
+	})
+
+	for part := 1; part <= 3; part++ {
+		c.Check(u.Start(part, nil), gc.IsNil)
+	}
+	c.Check(u.Wait(), gc.IsNil)
+
+	// Only the failed part was re-uploaded.
+	c.Check(attempts, gc.DeepEquals, map[int]int{1: 1, 2: 3, 3: 1})
+}
+
+func (s *PartUploaderSuite) TestPermanentFailure(c *gc.C) {
+	var mu sync.Mutex
+	var attempts int
+
+	var u = newPartUploader(1, func(part int, content []byte) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		attempts++
+		return errors.New("permanent error")
+	})
+
+	c.Check(u.Start(1, nil), gc.IsNil)
+	c.Check(u.Wait(), gc.ErrorMatches, "permanent error")
+	c.Check(attempts, gc.Equals, partUploadAttempts)
+
+	// Further parts aren't started.
+	c.Check(u.Start(2, nil), gc.ErrorMatches, "permanent error")
+	c.Check(attempts, gc.Equals, partUploadAttempts)
+}
+
+var _ = gc.Suite(&PartUploaderSuite{})
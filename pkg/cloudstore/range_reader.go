@@ -0,0 +1,125 @@
+package cloudstore
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"io/ioutil"
+)
+
+// Size of the read-ahead buffer of a rangeReader. Forward seeks of up to this
+// distance are served by discarding from the current ranged read, rather than
+// by issuing a new one.
+const rangeReadAheadSize = 1 << 18 // 256K.
+
+// rangeReader reads and seeks remote content via ranged reads. Seeks are lazy:
+// the next Read continues the current ranged read if the seek offset is within
+// the read-ahead window, and otherwise closes it and opens a new ranged read
+// beginning at the seek offset. Ranged reads are never opened beyond the end
+// of content of known size (which would be an invalid range).
+type rangeReader struct {
+	// open returns a reader of content beginning at |offset|. If |ranged| is
+	// false, |offset| is always zero and forward seeks discard content of the
+	// returned reader (as is required of, eg, transparently decompressed
+	// content, for which ranges are not meaningful).
+	open   func(offset int64) (io.ReadCloser, error)
+	ranged bool
+	// Content size, or -1 if not known.
+	size int64
+
+	rc       io.ReadCloser
+	br       *bufio.Reader // Read-ahead buffer of |rc|.
+	brOffset int64         // Content offset of the next byte of |br|.
+	offset   int64         // Content offset of the next Read.
+}
+
+func newRangeReader(size int64, ranged bool,
+	open func(offset int64) (io.ReadCloser, error)) *rangeReader {
+	return &rangeReader{open: open, ranged: ranged, size: size}
+}
+
+func (r *rangeReader) Read(p []byte) (int, error) {
+	if err := r.position(); err != nil {
+		return 0, err
+	}
+	var n, err = r.br.Read(p)
+	r.brOffset += int64(n)
+	r.offset += int64(n)
+
+	if err != nil && err != io.EOF {
+		// Release the failed ranged read. A subsequent Read will re-open it.
+		r.Close()
+	}
+	return n, err
+}
+
+func (r *rangeReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		if r.size < 0 {
+			return r.offset, errors.New("cannot seek relative to the end of content of unknown size")
+		}
+		offset += r.size
+	default:
+		return r.offset, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return r.offset, errors.New("negative position")
+	}
+	r.offset = offset
+	return r.offset, nil
+}
+
+func (r *rangeReader) Close() error {
+	var err error
+	if r.rc != nil {
+		err = r.rc.Close()
+	}
+	r.rc, r.br = nil, nil
+	return err
+}
+
+// position prepares |br| to read from |offset|, opening a ranged read if
+// required.
+func (r *rangeReader) position() error {
+	if r.br != nil {
+		var delta = r.offset - r.brOffset
+
+		if delta < 0 || (r.ranged && delta > rangeReadAheadSize) {
+			r.Close() // Re-open at |offset|.
+		} else if delta != 0 {
+			return r.discard(delta)
+		} else {
+			return nil
+		}
+	}
+
+	if r.ranged && r.size >= 0 && r.offset >= r.size {
+		return io.EOF
+	}
+
+	var from int64
+	if r.ranged {
+		from = r.offset
+	}
+	var rc, err = r.open(from)
+	if err != nil {
+		return err
+	}
+	r.rc, r.br, r.brOffset = rc, bufio.NewReaderSize(rc, rangeReadAheadSize), from
+
+	return r.discard(r.offset - from)
+}
+
+func (r *rangeReader) discard(delta int64) error {
+	var n, err = io.CopyN(ioutil.Discard, r.br, delta)
+	r.brOffset += n
+
+	if err != nil && err != io.EOF {
+		r.Close()
+	}
+	return err
+}
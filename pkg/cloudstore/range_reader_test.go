@@ -0,0 +1,142 @@
+package cloudstore
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/ioutil"
+
+	gc "github.com/go-check/check"
+)
+
+type RangeReaderSuite struct {
+	content []byte
+	opens   []int64 // Offsets of opened ranged reads.
+}
+
+func (s *RangeReaderSuite) SetUpTest(c *gc.C) {
+	s.content = randomContent(rangeReadAheadSize * 8)
+	s.opens = nil
+}
+
+func (s *RangeReaderSuite) TestSeeksIssueRangedReads(c *gc.C) {
+	var r = newRangeReader(int64(len(s.content)), true, s.open)
+	defer r.Close()
+
+	// Seeks are lazy. Only reads open a ranged read.
+	s.seek(c, r, 1000, io.SeekStart, 1000)
+	s.seek(c, r, rangeReadAheadSize*4, io.SeekStart, rangeReadAheadSize*4)
+	c.Check(s.opens, gc.HasLen, 0)
+
+	s.expectRead(c, r, 100)
+	c.Check(s.opens, gc.DeepEquals, []int64{rangeReadAheadSize * 4})
+
+	// A short forward seek is served from the current ranged read.
+	s.seek(c, r, 5000, io.SeekCurrent, rangeReadAheadSize*4+5100)
+	s.expectRead(c, r, 100)
+	c.Check(s.opens, gc.HasLen, 1)
+
+	// A long forward seek opens a new ranged read.
+	s.seek(c, r, rangeReadAheadSize*6, io.SeekStart, rangeReadAheadSize*6)
+	s.expectRead(c, r, 100)
+	c.Check(s.opens, gc.DeepEquals, []int64{rangeReadAheadSize * 4, rangeReadAheadSize * 6})
+
+	// As does a backward seek.
+	s.seek(c, r, 10, io.SeekStart, 10)
+	s.expectRead(c, r, 100)
+	c.Check(s.opens[2:], gc.DeepEquals, []int64{10})
+
+	// Seeks relative to the content end are supported.
+	s.seek(c, r, -10, io.SeekEnd, int64(len(s.content)-10))
+	var rest, err = ioutil.ReadAll(r)
+	c.Check(err, gc.IsNil)
+	c.Check(rest, gc.DeepEquals, s.content[len(s.content)-10:])
+	c.Check(s.opens[3:], gc.DeepEquals, []int64{int64(len(s.content) - 10)})
+
+	// A read at or beyond the content end doesn't open a ranged read.
+	s.seek(c, r, 1, io.SeekEnd, int64(len(s.content)+1))
+	var n, _ = r.Read(make([]byte, 1))
+	c.Check(n, gc.Equals, 0)
+	c.Check(s.opens, gc.HasLen, 4)
+
+	_, err = r.Seek(-1, io.SeekStart)
+	c.Check(err, gc.ErrorMatches, "negative position")
+}
+
+func (s *RangeReaderSuite) TestUnrangedReadsDiscard(c *gc.C) {
+	var r = newRangeReader(-1, false, s.open)
+	defer r.Close()
+
+	s.seek(c, r, rangeReadAheadSize*4, io.SeekStart, rangeReadAheadSize*4)
+	s.expectRead(c, r, 100)
+	s.seek(c, r, rangeReadAheadSize*6, io.SeekStart, rangeReadAheadSize*6)
+	s.expectRead(c, r, 100)
+	c.Check(s.opens, gc.DeepEquals, []int64{0})
+
+	// Backward seeks re-open from the content beginning.
+	s.seek(c, r, 10, io.SeekStart, 10)
+	s.expectRead(c, r, 100)
+	c.Check(s.opens, gc.DeepEquals, []int64{0, 0})
+
+	var _, err = r.Seek(0, io.SeekEnd)
+	c.Check(err, gc.ErrorMatches, "cannot seek relative to the end of content of unknown size")
+
+	// Seeks beyond the content end read EOF.
+	s.seek(c, r, int64(len(s.content)+1), io.SeekStart, int64(len(s.content)+1))
+	_, err = r.Read(make([]byte, 1))
+	c.Check(err, gc.Equals, io.EOF)
+}
+
+func (s *RangeReaderSuite) TestReadErrorReopens(c *gc.C) {
+	var fail = true
+	var r = newRangeReader(int64(len(s.content)), true, func(offset int64) (io.ReadCloser, error) {
+		if fail {
+			fail = false
+			s.opens = append(s.opens, offset)
+			return ioutil.NopCloser(io.MultiReader(
+				bytes.NewReader(s.content[offset:offset+10]), errReader{})), nil
+		}
+		return s.open(offset)
+	})
+	defer r.Close()
+
+	var buf = make([]byte, 100)
+	var n, err = io.ReadFull(r, buf)
+	c.Check(n, gc.Equals, 10)
+	c.Check(err, gc.ErrorMatches, "read error")
+
+	// The next read opens a new ranged read at the current offset.
+	s.expectRead(c, r, 100)
+	c.Check(s.opens, gc.DeepEquals, []int64{0, 10})
+
+	// Errors of open are returned.
+	r = newRangeReader(10, true, func(int64) (io.ReadCloser, error) {
+		return nil, errors.New("open error")
+	})
+	_, err = r.Read(buf)
+	c.Check(err, gc.ErrorMatches, "open error")
+}
+
+func (s *RangeReaderSuite) open(offset int64) (io.ReadCloser, error) {
+	s.opens = append(s.opens, offset)
+	return ioutil.NopCloser(bytes.NewReader(s.content[offset:])), nil
+}
+
+func (s *RangeReaderSuite) seek(c *gc.C, r *rangeReader, offset int64, whence int, expect int64) {
+	var n, err = r.Seek(offset, whence)
+	c.Check(err, gc.IsNil)
+	c.Check(n, gc.Equals, expect)
+}
+
+// expectRead reads |n| bytes from |r|, and verifies they match content at the
+// reader's offset.
+func (s *RangeReaderSuite) expectRead(c *gc.C, r *rangeReader, n int) {
+	var offset = r.offset
+	var buf = make([]byte, n)
+
+	var _, err = io.ReadFull(r, buf)
+	c.Check(err, gc.IsNil)
+	c.Check(buf, gc.DeepEquals, s.content[offset:offset+int64(n)])
+}
+
+var _ = gc.Suite(&RangeReaderSuite{})
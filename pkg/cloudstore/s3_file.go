@@ -3,10 +3,12 @@ package cloudstore
 import (
 	"bytes"
 	"errors"
+	"fmt"
 	"io"
-	"io/ioutil"
 	"os"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
@@ -15,12 +17,21 @@ import (
 )
 
 const (
-	// Buffer roughly this much data in memory before flushing a multipart
-	// chunk. (The true maximum multipart upload fragment size is much larger.)
-	// Logistically, 1GiB chunks are more efficient, but we'd probably want to
-	// spool to disk instead. This maximum spool size makes our maximum single
-	// object file size 1TiB, as 10000 parts are allowed to any S3 object.
-	MaxSpoolSizeBytes = 1024 * 1024 * 100
+	// Buffer this much data in memory before flushing a multipart chunk.
+	// (The true maximum multipart upload fragment size is much larger.)
+	// Logistically, larger chunks are more efficient, but we'd probably want
+	// to spool to disk instead. Chunks are uploaded concurrently, and a writer
+	// may hold up to partUploadConcurrency+1 chunks in memory: about 160MiB
+	// per concurrent writer at this size.
+	MaxSpoolSizeBytes = 1024 * 1024 * 32
+
+	// As 10000 parts are allowed to any S3 object, the chunk size doubles
+	// after every s3PartsPerDoubling parts so that large files remain
+	// writable. Chunks of the first 2000 parts (62.5GiB) are
+	// MaxSpoolSizeBytes, and the final chunks are 512MiB, for a maximum
+	// single object file size of about 1.9TiB. Writes beyond that fail.
+	s3PartsPerDoubling = 2000
+	s3MaxParts         = 10000
 )
 
 var (
@@ -39,18 +50,22 @@ type s3File struct {
 	// here. Issue #2039
 
 	// Used in file read operations.
-	readCloser io.ReadCloser
-	readOffset int64
+	ranged *rangeReader
 
 	// Used in directory read operations.
 	results      []os.FileInfo
 	resultsIndex int
 
 	// Used in write operations.
+	acl, sse      *string // Canned ACL and server-side encryption of the upload.
 	uploadId      *string
-	uploadedParts []*s3.CompletedPart
+	uploads       *partUploader
+	partCount     int
+	uploadedParts []*s3.CompletedPart // Guarded by |partsMu|.
+	partsMu       sync.Mutex
 	spool         bytes.Buffer
-	compressor    io.WriteCloser
+	compressor    io.WriteCloser // If non-nil, writes through to |spool|.
+	aborted       bool
 
 	// Global error state for this file.
 	err error
@@ -58,37 +73,44 @@ type s3File struct {
 
 // File interface method.
 func (f *s3File) Read(p []byte) (int, error) {
-	var reader io.ReadCloser
-
-	reader, f.err = f.reader()
-	if f.err != nil {
-		return 0, f.err
+	if f.object == nil || f.isUpload() {
+		return 0, errors.New("not a S3 reader")
 	}
 
 	var n int
-	n, f.err = reader.Read(p)
-	f.readOffset += int64(n)
+	n, f.err = f.reader().Read(p)
 	return n, f.err
 }
 
 // File interface method.
 func (f *s3File) Write(p []byte) (int, error) {
 	var n int
-	var buf = &f.spool
-	if f.compressor != nil {
-		n, f.err = f.compressor.Write(p)
-	} else {
-		n, f.err = buf.Write(p)
-	}
+	for n != len(p) && f.err == nil {
+		// Write in pieces which fill no more than the remainder of a chunk, so
+		// that a large |p| is uploaded as multiple parts. (Compressed content
+		// may slightly overfill the chunk).
+		var piece = p[n:]
+		var partSize = s3PartSize(f.partCount + 1)
+
+		if rem := partSize - f.spool.Len(); len(piece) > rem {
+			piece = piece[:rem]
+		}
 
-	if f.err != nil {
+		var nn int
 		if f.compressor != nil {
-			f.compressor.Close()
+			nn, f.err = f.compressor.Write(piece)
+		} else {
+			nn, f.err = f.spool.Write(piece)
 		}
-		// If necessary, spill spool to S3 multipart chunk.
-	} else if buf.Len() > MaxSpoolSizeBytes {
-		if f.err = f.uploadSpool(); f.err != nil {
-			return 0, f.err
+		n += nn
+
+		if f.err != nil {
+			if f.compressor != nil {
+				f.compressor.Close()
+			}
+			// If necessary, spill spool to S3 multipart chunk.
+		} else if f.spool.Len() >= partSize {
+			f.err = f.uploadSpool()
 		}
 	}
 	return n, f.err
@@ -98,19 +120,44 @@ func (f *s3File) Write(p []byte) (int, error) {
 func (f *s3File) Close() error {
 	if f.IsDir() {
 		// Closing a directory object is a no-op.
-	} else if f.readCloser != nil {
-		// Here, we don't use reader(), as that would cause an unopened
-		// readCloser to be opened before immediately closing it.
-		f.err = f.readCloser.Close()
-		f.readCloser = nil
+	} else if f.ranged != nil {
+		f.err = f.ranged.Close()
+		f.ranged = nil
+	} else if f.object != nil && !f.isUpload() {
+		// Closing a reader which was never read is a no-op.
 	} else if f.isUpload() {
-		// Drain final multipart chunk, if necessary. If uploading a totally
-		// blank file, we still need to provide at least one part.
-		if f.spool.Len() > 0 || len(f.uploadedParts) == 0 {
+		// Flush remaining compressed content to the spool.
+		if f.compressor != nil {
+			f.err = f.compressor.Close()
+			f.compressor = nil
+
+			if f.err != nil {
+				f.abortUpload()
+				return f.err
+			}
+		}
+		// A totally blank file has no parts to upload. Not all S3 implementations
+		// accept an empty part, so instead abort the multipart upload and put an
+		// empty object.
+		if f.spool.Len() == 0 && f.partCount == 0 {
+			f.abortUpload()
+			f.err = f.putEmptyObject()
+			return f.err
+		}
+		// Drain final multipart chunk, if necessary.
+		if f.spool.Len() > 0 {
 			if f.err = f.uploadSpool(); f.err != nil {
 				return f.err
 			}
 		}
+		if f.err = f.uploads.Wait(); f.err != nil {
+			f.abortUpload()
+			return f.err
+		}
+		// Parts complete in arbitrary order, but must be listed in order.
+		sort.Slice(f.uploadedParts, func(i, j int) bool {
+			return *f.uploadedParts[i].PartNumber < *f.uploadedParts[j].PartNumber
+		})
 
 		var params = s3.CompleteMultipartUploadInput{
 			Bucket:   f.bucket,
@@ -122,39 +169,21 @@ func (f *s3File) Close() error {
 		}
 
 		_, f.err = f.svc.CompleteMultipartUpload(&params)
-		if f.compressor != nil {
-			f.compressor.Close()
-		}
 	} else {
 		panic("cannot determine kind of s3File object for Close()")
 	}
 	return f.err
 }
 
-// File interface method.
+// File interface method. Seeks are lazy, and are realized by a ranged
+// GetObject of the seek offset on the next Read.
 func (f *s3File) Seek(offset int64, whence int) (int64, error) {
 	if f.isUpload() {
 		return 0, errors.New("cannot seek a S3 writer")
+	} else if f.object == nil {
+		return 0, errors.New("not a S3 reader")
 	}
-	// Make |offset| absolute.
-	if whence == 0 {
-	} else if whence == 1 {
-		offset += f.readOffset
-	} else if whence == 2 {
-		return 0, errors.New("a S3 writer cannot seek relative to file end")
-	}
-	// If seeking before the current |readOffset|, re-open |f.readCloser|.
-	if offset < f.readOffset && (f.err == nil || f.err == io.EOF) {
-		f.readCloser.Close()
-		f.readCloser = nil
-		f.readOffset = 0
-		f.err = nil
-	} else if f.err != nil {
-		return f.readOffset, f.err
-	}
-	// Errors and offsets are tracked by f.Read().
-	io.CopyN(ioutil.Discard, f, offset-f.readOffset)
-	return f.readOffset, f.err
+	return f.reader().Seek(offset, whence)
 }
 
 // File interface method.
@@ -247,65 +276,111 @@ func (f *s3File) Sys() interface{} {
 	return nil
 }
 
-func (f *s3File) reader() (io.ReadCloser, error) {
-	var err error
-	if f.readCloser == nil {
-		var params = s3.GetObjectInput{
-			Bucket: f.bucket,
-			Key:    f.key,
-		}
+// reader returns the rangeReader of the file, which lazily opens ranged reads.
+func (f *s3File) reader() *rangeReader {
+	if f.ranged == nil {
+		f.ranged = newRangeReader(*f.object.Size, true, f.getObjectRange)
+	}
+	return f.ranged
+}
 
-		var resp *s3.GetObjectOutput
-		resp, err = f.svc.GetObject(&params)
-		if err == nil {
-			f.readCloser = resp.Body
-		}
+// getObjectRange returns a reader of the object beginning at |offset|. The
+// read is conditioned on the object ETag, so that all reads of the file are
+// of the same object content.
+func (f *s3File) getObjectRange(offset int64) (io.ReadCloser, error) {
+	var params = s3.GetObjectInput{
+		Bucket:  f.bucket,
+		Key:     f.key,
+		IfMatch: f.object.ETag,
+		Range:   aws.String(fmt.Sprintf("bytes=%d-", offset)),
 	}
 
-	return f.readCloser, err
+	var resp, err = f.svc.GetObject(&params)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
 }
 
-// Uploads the current content of the spool as a multipart chunk to S3.
-// Records the ETag of the uploaded part on success, then resets the spool and
-// increments the part number to prepare for the next series of writes.
+// Begins a concurrent upload of the current content of the spool as the next
+// multipart chunk to S3, then rotates the spool to prepare for the next series
+// of writes. Returns an error if a previous chunk failed to upload.
 func (f *s3File) uploadSpool() error {
-	var buf = &f.spool
-	var partNumber int64 = int64(len(f.uploadedParts)) + 1
+	if f.partCount == s3MaxParts {
+		f.abortUpload()
+		return fmt.Errorf("S3 object exceeds maximum of %d parts", s3MaxParts)
+	}
+	f.partCount++
+
+	var content = f.spool.Bytes()
+	f.spool = bytes.Buffer{}
+
+	if err := f.uploads.Start(f.partCount, content); err != nil {
+		f.abortUpload()
+		return err
+	}
+	return nil
+}
+
+// Returns the chunk size at which multipart chunk |part| is flushed.
+func s3PartSize(part int) int {
+	return MaxSpoolSizeBytes << uint((part-1)/s3PartsPerDoubling)
+}
+
+// Uploads |content| as multipart chunk |part|. Records the ETag of the
+// uploaded part on success.
+func (f *s3File) uploadPart(part int, content []byte) error {
 	var params = s3.UploadPartInput{
 		Bucket:        f.bucket,
 		Key:           f.key,
-		PartNumber:    aws.Int64(partNumber),
+		PartNumber:    aws.Int64(int64(part)),
 		UploadId:      f.uploadId,
-		Body:          bytes.NewReader(buf.Bytes()),
-		ContentLength: aws.Int64(int64(buf.Len())),
+		Body:          bytes.NewReader(content),
+		ContentLength: aws.Int64(int64(len(content))),
 	}
 
-	var err error
-	var resp *s3.UploadPartOutput
-	resp, err = f.svc.UploadPart(&params)
-
+	var resp, err = f.svc.UploadPart(&params)
 	if err != nil {
-		f.abortUpload()
 		return err
 	} else if resp.ETag == nil {
-		f.abortUpload()
-		err = errors.New("expected ETag on successful UploadPart")
-		return err
+		return errors.New("expected ETag on successful UploadPart")
 	}
 
-	// On success, rotate the spool.
-	buf.Reset()
-
 	// Record response ETag. We must provide it to complete the upload.
+	f.partsMu.Lock()
 	f.uploadedParts = append(f.uploadedParts, &s3.CompletedPart{
 		ETag:       resp.ETag,
-		PartNumber: aws.Int64(partNumber),
+		PartNumber: aws.Int64(int64(part)),
 	})
+	f.partsMu.Unlock()
 
 	return nil
 }
 
+// Puts an empty object under the upload key, with the upload's ACL and
+// server-side encryption.
+func (f *s3File) putEmptyObject() error {
+	var params = s3.PutObjectInput{
+		ACL:                  f.acl,
+		Body:                 bytes.NewReader(nil),
+		Bucket:               f.bucket,
+		ContentLength:        aws.Int64(0),
+		Key:                  f.key,
+		ServerSideEncryption: f.sse,
+	}
+	var _, err = f.svc.PutObject(&params)
+	return err
+}
+
 func (f *s3File) abortUpload() {
+	if f.aborted {
+		return
+	}
+	f.aborted = true
+
+	// Wait for in-flight part uploads, which would otherwise race the abort.
+	f.uploads.Wait()
+
 	// Abort the upload, if possible. Returned |err| is for the initial
 	// part upload that we just tried -- abort error is logged but
 	// swallowed.
@@ -315,8 +390,9 @@ func (f *s3File) abortUpload() {
 		UploadId: f.uploadId,
 	}
 
-	_, abortErr := f.svc.AbortMultipartUpload(&abortParams)
-	log.WithField("err", abortErr).Warn("failed to abort multipart upload")
+	if _, abortErr := f.svc.AbortMultipartUpload(&abortParams); abortErr != nil {
+		log.WithField("err", abortErr).Warn("failed to abort multipart upload")
+	}
 }
 
 func (f *s3File) listObjects() ([]os.FileInfo, error) {
@@ -388,18 +464,15 @@ func (f *s3File) isUpload() bool {
 }
 
 func (f *s3File) transfer(from io.Reader) (int64, error) {
-	var n int64
-	if f.compressor != nil {
-		n, f.err = io.Copy(f.compressor, from)
-	} else {
-		var buf = &f.spool
-		n, f.err = io.Copy(buf, from)
-	}
+	// Copy through Write, which uploads chunks as the spool fills.
+	var n, err = io.Copy(f, from)
 
-	if f.err != nil {
+	if err != nil {
 		if f.compressor != nil {
 			f.compressor.Close()
 		}
+		f.abortUpload()
+		f.err = err
 		return n, f.err
 	}
 
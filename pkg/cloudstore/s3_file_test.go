@@ -0,0 +1,23 @@
+package cloudstore
+
+import (
+	gc "github.com/go-check/check"
+)
+
+type S3FileSuite struct{}
+
+func (s *S3FileSuite) TestPartSizeGrowsWithPartCount(c *gc.C) {
+	c.Check(s3PartSize(1), gc.Equals, MaxSpoolSizeBytes)
+	c.Check(s3PartSize(s3PartsPerDoubling), gc.Equals, MaxSpoolSizeBytes)
+	c.Check(s3PartSize(s3PartsPerDoubling+1), gc.Equals, 2*MaxSpoolSizeBytes)
+	c.Check(s3PartSize(s3MaxParts), gc.Equals, 16*MaxSpoolSizeBytes)
+
+	// The maximum object size permitted by all parts is at least 1TiB.
+	var total int64
+	for part := 1; part <= s3MaxParts; part++ {
+		total += int64(s3PartSize(part))
+	}
+	c.Check(total >= 1<<40, gc.Equals, true)
+}
+
+var _ = gc.Suite(&S3FileSuite{})
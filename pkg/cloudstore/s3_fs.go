@@ -1,7 +1,6 @@
 package cloudstore
 
 import (
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
@@ -174,21 +173,20 @@ func (fs *s3Fs) OpenFile(name string, flag int, perm os.FileMode) (File, error)
 			return nil, errors.New("expected UploadId in MultipartUpload creation")
 		}
 
-		var buf = new(bytes.Buffer)
-		var spool = *buf
-		var compressor io.WriteCloser
-		if fs.compress {
-			compressor = gzip.NewWriter(&spool)
+		var f = &s3File{
+			svc:      svc,
+			bucket:   aws.String(bucket),
+			key:      aws.String(path),
+			acl:      params.ACL,
+			sse:      params.ServerSideEncryption,
+			uploadId: resp.UploadId,
 		}
+		f.uploads = newPartUploader(partUploadConcurrency, f.uploadPart)
 
-		return &s3File{
-			svc:        svc,
-			bucket:     aws.String(bucket),
-			key:        aws.String(path),
-			uploadId:   resp.UploadId,
-			spool:      spool,
-			compressor: compressor,
-		}, nil
+		if fs.compress {
+			f.compressor = gzip.NewWriter(&f.spool)
+		}
+		return f, nil
 	} else {
 		return nil, errors.New("unsupported file flags")
 	}
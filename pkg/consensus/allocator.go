@@ -2,7 +2,9 @@ package consensus
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"math"
 	"math/rand"
 	"os"
 	"os/signal"
@@ -25,6 +27,17 @@ const (
 	// for lock resets, to ensure that ItemStates are polled and updated
 	// into Etcd with sufficient frequency.
 	allocMaxSleepInterval = time.Second * 5
+
+	// Fraction by which a member's weight may exceed the mean member weight
+	// before mastered items are handed off to balance weight.
+	weightTolerance = 0.2
+	// Fraction by which a member's weight must change before the change is
+	// announced in its member entry (prior to the next lock refresh).
+	weightAnnounceTolerance = 0.25
+	// Minimum interval between weight-balancing hand-offs of a member. As the
+	// weight of an item is measured by its master, a newly-promoted master
+	// will under-weigh the item for a time after the hand-off.
+	weightHandoffInterval = RateHalfLife * 2
 )
 
 var ErrAllocatorInstanceExists = errors.New("Allocator member key exists")
@@ -72,12 +85,51 @@ type Inspector interface {
 	InspectChan() chan func(tree *etcd.Node)
 }
 
+// Weigher is an optional interface of an Allocator which weighs items, for
+// example by their recent throughput. Allocators announce the total weight
+// of their mastered items via their member entry, and gradually hand off
+// mastered items to the ready replicas of lighter members to balance weight.
+type Weigher interface {
+	// ItemWeight returns the current weight of |item| mastered by this
+	// Allocator. See also DecayingRate.
+	ItemWeight(item string) float64
+}
+
+// Zoner is an optional interface of an Allocator which places it within a
+// failure domain, such as an availability zone or rack. Allocators announce
+// their zone as the value of their member entry, and prefer allocations which
+// spread the master and replicas of an item across distinct zones.
+type Zoner interface {
+	// Zone of the Allocator, or empty if unknown.
+	Zone() string
+}
+
+// RouteUpdater is an optional interface of the etcd.KeysAPI of an Allocator,
+// which applies changes of an item Route as atomic, multi-key transactions.
+// A change is conditioned upon the Route from which it was decided: it's
+// applied only if no entry of the Route has since been created, modified,
+// or removed, and otherwise fails with etcd.ErrorCodeTestFailed. Allocators
+// acquire and release item entries through a RouteUpdater where available,
+// such that an acquisition can't lose a race for an open slot, and a master
+// hands off an item only to the ready replicas it observed. Otherwise, each
+// change is a create or compare-and-delete of the single entry.
+type RouteUpdater interface {
+	// CreateRouteEntry creates |key| with |value| and |ttl| as a new entry of
+	// |route|, if |route| is unchanged.
+	CreateRouteEntry(ctx context.Context, route Route, key, value string,
+		ttl time.Duration) (*etcd.Response, error)
+	// DeleteRouteEntry deletes |entry| of |route|, if |route| is unchanged.
+	DeleteRouteEntry(ctx context.Context, route Route, entry *etcd.Node) (*etcd.Response, error)
+}
+
 // Create attempts to create an Allocator member lock reflecting instance
 // |alloc|. If the member lock already exists, returns
 // ErrAllocatorInstanceExists. An Allocator member lock should be obtained
 // prior to an Allocate call.
 func Create(alloc Allocator) error {
-	_, err := alloc.KeysAPI().Set(context.Background(), memberKey(alloc), "",
+	var state = encodeMemberState(memberState{Zone: zoneOf(alloc)})
+
+	_, err := alloc.KeysAPI().Set(context.Background(), memberKey(alloc), state,
 		&etcd.SetOptions{PrevExist: etcd.PrevNoExist, TTL: lockDuration})
 
 	if err, ok := err.(etcd.Error); ok && err.Code == etcd.ErrorCodeNodeExist {
@@ -146,8 +198,9 @@ func Allocate(alloc Allocator) error {
 		ActedAt(uint64) // Allocate() acted at |modifiedIndex|.
 	})
 
-	var now time.Time        // Current timepoint.
-	var modifiedIndex uint64 // Current Etcd ModifiedIndex.
+	var now time.Time               // Current timepoint.
+	var modifiedIndex uint64        // Current Etcd ModifiedIndex.
+	var lastWeightHandoff time.Time // Timepoint of last weight-balancing hand-off.
 
 	var inspectCh chan func(tree *etcd.Node)
 	if inspector, ok := alloc.(Inspector); ok {
@@ -196,6 +249,7 @@ func Allocate(alloc Allocator) error {
 		params.Input.Time = now
 		params.Input.Tree = tree
 		params.Input.Index = modifiedIndex
+		params.Input.LastWeightHandoff = lastWeightHandoff
 
 		allocExtract(&params)
 		desiredMaster, desiredTotal := targetCounts(&params)
@@ -219,6 +273,10 @@ func Allocate(alloc Allocator) error {
 			// watch, and defer further processing or actions until we do.
 			modifiedIndex = response.Node.ModifiedIndex
 
+			if params.Output.WeightHandoff {
+				lastWeightHandoff = now
+			}
+
 			if testNotifier != nil {
 				testNotifier.ActedAt(modifiedIndex)
 			}
@@ -283,7 +341,14 @@ func CreateAndAllocateWithSignalHandling(alloc Allocator) error {
 		}
 	}()
 
-	// Obtain Allocator lock. If it exists, retry until signalled.
+	return CreateAndAllocate(alloc, shutdownCh)
+}
+
+// Composes Create and Allocate to run an Allocator which gracefully Cancels
+// itself once |shutdownCh| is closed. Performs a polled retry of Create on
+// ErrAllocatorInstanceExists, until aquired or |shutdownCh| is closed.
+func CreateAndAllocate(alloc Allocator, shutdownCh <-chan struct{}) error {
+	// Obtain Allocator lock. If it exists, retry until shut down.
 	for {
 		err := Create(alloc)
 		if err == nil {
@@ -302,7 +367,7 @@ func CreateAndAllocateWithSignalHandling(alloc Allocator) error {
 		}
 	}
 
-	// Arrange to Cancel Allocate on signal, allowing it to gracefully tear down.
+	// Arrange to Cancel Allocate on shutdown, allowing it to gracefully tear down.
 	go func() {
 		<-shutdownCh
 
@@ -320,6 +385,55 @@ func memberKey(alloc Allocator) string {
 	return alloc.PathRoot() + "/" + MemberPrefix + "/" + alloc.InstanceKey()
 }
 
+// zoneOf returns the zone of |alloc|, or empty if it doesn't implement Zoner.
+func zoneOf(alloc Allocator) string {
+	if zoner, ok := alloc.(Zoner); ok {
+		return zoner.Zone()
+	}
+	return ""
+}
+
+// memberState is announced by an Allocator as the value of its member entry.
+type memberState struct {
+	Zone   string  `json:"zone,omitempty"`   // See Zoner.
+	Weight float64 `json:"weight,omitempty"` // Total weight of mastered items. See Weigher.
+}
+
+// encodeMemberState encodes |state| as a member entry value. The zero-valued
+// memberState is encoded as the empty value.
+func encodeMemberState(state memberState) string {
+	if state == (memberState{}) {
+		return ""
+	}
+	var b, err = json.Marshal(state)
+	if err != nil {
+		panic(err) // Cannot fail.
+	}
+	return string(b)
+}
+
+// decodeMemberState decodes the memberState of a member entry |value|. An
+// empty or malformed value decodes as the zero-valued memberState.
+func decodeMemberState(value string) memberState {
+	var state memberState
+	if value == "" {
+		return state
+	} else if err := json.Unmarshal([]byte(value), &state); err != nil {
+		log.WithFields(log.Fields{"err": err, "value": value}).Warn("failed to decode member state")
+	}
+	return state
+}
+
+// memberStateOf returns the memberState announced by the member
+// |instanceKey| within |tree|, or the zero-valued memberState if the member
+// is unknown.
+func memberStateOf(tree *etcd.Node, instanceKey string) memberState {
+	if member := Child(tree, MemberPrefix, instanceKey); member != nil {
+		return decodeMemberState(member.Value)
+	}
+	return memberState{}
+}
+
 // itemKey returns the item entry key for |item| held by |alloc|.
 // Ex: /path/root/items/an-item/my-alloc-key
 func itemKey(alloc Allocator, item string) string {
@@ -334,6 +448,15 @@ func itemOfItemKey(alloc Allocator, key string) string {
 	return key[lstrip:rstrip]
 }
 
+// itemRoute returns the Route of |item| within |p.Input.Tree|. If the tree has
+// no directory for |item|, the Route has no entries.
+func itemRoute(p *allocParams, item string) Route {
+	if node := Child(p.Input.Tree, ItemsPrefix, item); node != nil {
+		return NewRoute(nil, node)
+	}
+	return Route{Item: &etcd.Node{Key: p.PathRoot() + "/" + ItemsPrefix + "/" + item, Dir: true}}
+}
+
 // POD type built by individual iterations of the Allocate() protocol,
 // to succinctly describe global allocator state.
 type allocParams struct {
@@ -343,22 +466,46 @@ type allocParams struct {
 		Time  time.Time
 		Tree  *etcd.Node
 		Index uint64 // Current Etcd ModifiedIndex.
+		// Time of our last hand-off of a mastered item to balance weight.
+		LastWeightHandoff time.Time
 	}
 	Item struct {
-		Master       []*etcd.Node // Items for which we're master.
-		Replica      []*etcd.Node // Items for which we're a replica.
-		Extra        []*etcd.Node // Items for which we hold an extra lock.
-		Releaseable  []*etcd.Node // Mastered items we may release.
-		OpenMasters  []string     // Names of items in need of a master.
-		OpenReplicas []string     // Names of items in need of a replica.
-		Count        int          // Total number of items.
+		Master         []*etcd.Node // Items for which we're master.
+		Replica        []*etcd.Node // Items for which we're a replica.
+		Extra          []*etcd.Node // Items for which we hold an extra lock.
+		Releaseable    []*etcd.Node // Mastered items we may release.
+		OpenMasters    []string     // Names of items in need of a master.
+		OpenReplicas   []string     // Names of items in need of a replica.
+		SpreadReplicas []string     // OpenReplicas having no entry in our zone.
+		Count          int          // Total number of items.
+		// Releaseable items which may be handed off to balance weight.
+		Handoffs []weightHandoff
 	}
 	Member struct {
-		Entry *etcd.Node // Our member entry.
-		Count int        // Total number of allocator members.
+		Entry      *etcd.Node  // Our member entry.
+		Count      int         // Total number of allocator members.
+		Zone       string      // Our zone, or empty if unknown.
+		Weight     float64     // Total weight of our mastered items.
+		MeanWeight float64     // Mean weight of allocator members.
+		Announced  memberState // Our announced state.
+	}
+	Output struct {
+		// Whether the action hands off a mastered item to balance weight.
+		WeightHandoff bool
 	}
 }
 
+// weightHandoff is a Releaseable mastered item, which may be handed off to
+// its next master to balance weight.
+type weightHandoff struct {
+	Entry  *etcd.Node // Our master entry of the item.
+	Weight float64    // Weight of the item.
+
+	Next        string  // Instance key of the next master.
+	NextWeight  float64 // Announced weight of the next master.
+	NextMasters int     // Number of items mastered by the next master.
+}
+
 // WalkItems performs a zipped, outer-join iteration of items under ItemsPrefix
 // of |tree|, and |fixedItems| (which must be ordered). The argument callback
 // |cb| is invoked for each item, and must not retain |route| after each call.
@@ -389,10 +536,18 @@ func WalkItems(tree *etcd.Node, fixedItems []string, cb func(name string, route
 // allocExtract builds |p.Item| and |p.Member| descriptions of allocParams from
 // |p.Input|.
 func allocExtract(p *allocParams) {
+	p.Member.Zone = zoneOf(p.Allocator)
+
+	var weigher, _ = p.Allocator.(Weigher)
+	var masters = make(map[string]int) // Count of mastered items, by member.
 
 	WalkItems(p.Input.Tree, p.FixedItems(), func(name string, route Route) {
 		p.Item.Count += 1
 
+		if len(route.Entries) != 0 {
+			masters[route.instanceKey(0)] += 1
+		}
+
 		var index = route.Index(p.InstanceKey())
 		p.ItemRoute(name, route, index, p.Input.Tree)
 
@@ -402,15 +557,36 @@ func allocExtract(p *allocParams) {
 				p.Item.OpenMasters = append(p.Item.OpenMasters, name)
 			} else if len(route.Entries) < p.Replicas()+1 {
 				p.Item.OpenReplicas = append(p.Item.OpenReplicas, name)
+
+				if p.Member.Zone != "" && !route.hasZone(p.Input.Tree, p.Member.Zone) {
+					p.Item.SpreadReplicas = append(p.Item.SpreadReplicas, name)
+				}
 			}
 		} else if index == 0 {
 			// We act as item master.
 			p.Item.Master = append(p.Item.Master, route.Entries[0])
 
+			var weight float64
+			if weigher != nil {
+				weight = weigher.ItemWeight(name)
+				p.Member.Weight += weight
+			}
+
 			// We always require that mastered items be ready for hand-off
 			// before we may release them, even if our member lock is gone.
-			if route.IsReadyForHandoff(p) {
+			if route.IsReadyForHandoff(p, p.Input.Tree) {
 				p.Item.Releaseable = append(p.Item.Releaseable, route.Entries[0])
+
+				if weigher != nil && len(route.Entries) > 1 {
+					var next = route.instanceKey(1)
+
+					p.Item.Handoffs = append(p.Item.Handoffs, weightHandoff{
+						Entry:      route.Entries[0],
+						Weight:     weight,
+						Next:       next,
+						NextWeight: memberStateOf(p.Input.Tree, next).Weight,
+					})
+				}
 			}
 		} else if index < p.Replicas()+1 {
 			// We act as an item replica.
@@ -424,6 +600,23 @@ func allocExtract(p *allocParams) {
 	if membersDir := Child(p.Input.Tree, MemberPrefix); membersDir != nil {
 		p.Member.Entry = Child(membersDir, p.InstanceKey())
 		p.Member.Count = len(membersDir.Nodes)
+
+		if p.Member.Entry != nil {
+			p.Member.Announced = decodeMemberState(p.Member.Entry.Value)
+		}
+		if weigher != nil && p.Member.Count != 0 {
+			// Our current weight stands in for our announced weight.
+			var total = p.Member.Weight
+			for _, member := range membersDir.Nodes {
+				if member != p.Member.Entry {
+					total += decodeMemberState(member.Value).Weight
+				}
+			}
+			p.Member.MeanWeight = total / float64(p.Member.Count)
+		}
+	}
+	for i := range p.Item.Handoffs {
+		p.Item.Handoffs[i].NextMasters = masters[p.Item.Handoffs[i].Next]
 	}
 }
 
@@ -449,13 +642,40 @@ func allocAction(p *allocParams, desiredMaster, desiredTotal int) (*etcd.Respons
 		return p.KeysAPI().Set(context.Background(), key, "",
 			&etcd.SetOptions{PrevExist: etcd.PrevNoExist, TTL: lockDuration})
 	}
+	// Helper which acquires our entry of item |name|, conditioned upon the
+	// item's Route if supported.
+	var acquire = func(name string) (*etcd.Response, error) {
+		var key = itemKey(p, name)
 
-	// 1) Refresh the member lock.
+		if updater, ok := p.KeysAPI().(RouteUpdater); ok {
+			return updater.CreateRouteEntry(context.Background(), itemRoute(p, name),
+				key, "", lockDuration)
+		}
+		return create(key)
+	}
+	// Helper which releases our item |entry|, conditioned upon the item's
+	// Route if supported.
+	var release = func(entry *etcd.Node) (*etcd.Response, error) {
+		if updater, ok := p.KeysAPI().(RouteUpdater); ok {
+			return updater.DeleteRouteEntry(context.Background(),
+				itemRoute(p, itemOfItemKey(p, entry.Key)), entry)
+		}
+		return compareAndDelete(entry)
+	}
+
+	// 1) Refresh or update the member lock. Changes of weight are announced
+	// only if significant, or with the lock refresh.
 	if p.Member.Entry != nil {
-		if p.Member.Entry.Expiration.Before(horizon) {
-			log.WithField("key", p.Member.Entry.Key).Debug("refreshing member lock")
+		var state = memberState{Zone: p.Member.Zone, Weight: p.Member.Weight}
+
+		if expiresBefore(p.Member.Entry, horizon) ||
+			p.Member.Announced.Zone != state.Zone ||
+			weightChanged(p.Member.Announced.Weight, state.Weight) {
+
+			log.WithFields(log.Fields{"key": p.Member.Entry.Key, "state": state}).
+				Debug("refreshing member lock")
 
-			return compareAndSet(p.Member.Entry, "")
+			return compareAndSet(p.Member.Entry, encodeMemberState(state))
 		}
 	}
 
@@ -467,7 +687,7 @@ func allocAction(p *allocParams, desiredMaster, desiredTotal int) (*etcd.Respons
 	for _, entry := range p.Item.Master {
 		value := p.ItemState(itemOfItemKey(p, entry.Key))
 
-		if entry.Expiration.Before(horizon) || value != entry.Value {
+		if expiresBefore(entry, horizon) || value != entry.Value {
 			log.WithFields(log.Fields{"key": entry.Key, "value": value}).
 				Debug("refreshing allocated master lock")
 
@@ -478,7 +698,7 @@ func allocAction(p *allocParams, desiredMaster, desiredTotal int) (*etcd.Respons
 	for _, entry := range p.Item.Replica {
 		value := p.ItemState(itemOfItemKey(p, entry.Key))
 
-		if entry.Expiration.Before(horizon) || value != entry.Value {
+		if expiresBefore(entry, horizon) || value != entry.Value {
 			log.WithFields(log.Fields{"key": entry.Key, "value": value}).
 				Debug("refreshing allocated replica lock")
 
@@ -499,40 +719,59 @@ func allocAction(p *allocParams, desiredMaster, desiredTotal int) (*etcd.Respons
 		entry := p.Item.Releaseable[rand.Int()%len(p.Item.Releaseable)]
 		log.WithField("key", entry.Key).Debug("releasing mastered item lock")
 
-		return compareAndDelete(entry)
+		return release(entry)
 	}
-	// 6) Select a random replica to release. In normal operation we never
+	// 6) Select a mastered item to hand off to balance weight. This may occur iff:
+	//  * We hold a member lock, and aren't releasing mastered items by count.
+	//  * Our weight exceeds the mean member weight by weightTolerance, and we
+	//    haven't handed off an item to balance weight within the last
+	//    weightHandoffInterval.
+	//  * The item is ready for hand-off, and its next master would remain
+	//    lighter than us, and within the desired number of mastered items.
+	if entry := selectWeightHandoff(p, desiredMaster); entry != nil {
+		log.WithFields(log.Fields{
+			"key":        entry.Key,
+			"weight":     p.Member.Weight,
+			"meanWeight": p.Member.MeanWeight,
+		}).Debug("handing off mastered item lock to balance weight")
+
+		p.Output.WeightHandoff = true
+		return release(entry)
+	}
+	// 7) Select a random replica to release. In normal operation we never
 	// release a replica we hold. However, iff we have no member lock (we're in
 	// the process of shutdown), then we may release held replicas (not masters).
-	if p.Member.Entry == nil && len(p.Item.Replica) != 0 {
+	// Replicas are released only after all mastered items are handed off, so
+	// that items mastered elsewhere remain fully replicated for as long as
+	// possible.
+	if p.Member.Entry == nil && len(p.Item.Master) == 0 && len(p.Item.Replica) != 0 {
 		entry := p.Item.Replica[rand.Int()%len(p.Item.Replica)]
 		log.WithField("key", entry.Key).Debug("releasing replica item lock")
 
 		return compareAndDelete(entry)
 	}
-	// 7) Select a random item to master. This may occur iff:
+	// 8) Select a random item to master. This may occur iff:
 	//  * We don't hold an entry for the item.
 	//  * The item has an open master slot.
 	//  * We'd like to have another master.
 	if len(p.Item.Master) < desiredMaster && len(p.Item.OpenMasters) != 0 {
 		name := p.Item.OpenMasters[rand.Int()%len(p.Item.OpenMasters)]
-		key := itemKey(p, name)
-		log.WithField("key", key).Debug("aquiring item master lock")
+		log.WithField("key", itemKey(p, name)).Debug("aquiring item master lock")
 
-		return create(key)
+		return acquire(name)
 	}
-	// 8) Select a random item to replicate. This may occur iff:
+	// 9) Select a random item to replicate. This may occur iff:
 	//  * We don't hold an entry for the item.
 	//  * The item has an open replica slot.
 	//  * We'd like to have another replica.
+	// Items having no entry in our zone are preferred.
 	if len(p.Item.Master)+len(p.Item.Replica) < desiredTotal && len(p.Item.OpenReplicas) != 0 {
-		name := p.Item.OpenReplicas[rand.Int()%len(p.Item.OpenReplicas)]
-		key := itemKey(p, name)
-		log.WithField("key", key).Debug("aquiring item replica lock")
+		name := pickOpenReplica(p)
+		log.WithField("key", itemKey(p, name)).Debug("aquiring item replica lock")
 
-		return create(key)
+		return acquire(name)
 	}
-	// 9) Deadlock avoidance: Select a random master to release, iff:
+	// 10) Deadlock avoidance: Select a random master to release, iff:
 	//  * We are currently the item master.
 	//  * The item has the required number of ready replicas.
 	//  * We hold exactly as many master slots as we'd like.
@@ -543,9 +782,9 @@ func allocAction(p *allocParams, desiredMaster, desiredTotal int) (*etcd.Respons
 
 		var entry = p.Item.Releaseable[rand.Int()%len(p.Item.Releaseable)]
 		log.WithField("key", entry.Key).Debug("releasing EXTRA mastered item lock")
-		return compareAndDelete(entry)
+		return release(entry)
 	}
-	// 10) Deadlock avoidance: Select a random item to replicate with delay, iff:
+	// 11) Deadlock avoidance: Select a random item to replicate with delay, iff:
 	//  * We don't hold an entry for the item.
 	//  * The item has an open replica slot.
 	//  * We have the exact right number of items overall (we'll be going over).
@@ -561,16 +800,63 @@ func allocAction(p *allocParams, desiredMaster, desiredTotal int) (*etcd.Respons
 		len(p.Item.OpenReplicas) != 0 &&
 		p.Member.Entry != nil {
 
-		var name = p.Item.OpenReplicas[rand.Int()%len(p.Item.OpenReplicas)]
-		var key = itemKey(p, name)
+		var name = pickOpenReplica(p)
 
 		time.Sleep(100 * time.Millisecond)
-		log.WithField("key", key).Debug("aquiring EXTRA item replica lock")
-		return create(key)
+		log.WithField("key", itemKey(p, name)).Debug("aquiring EXTRA item replica lock")
+		return acquire(name)
 	}
 	return nil, nil
 }
 
+// selectWeightHandoff returns the master entry of the heaviest item of
+// Handoffs which may be handed off to balance weight, or nil if none may be.
+func selectWeightHandoff(p *allocParams, desiredMaster int) *etcd.Node {
+	if p.Member.Entry == nil ||
+		len(p.Item.Master) > desiredMaster ||
+		p.Member.Weight <= p.Member.MeanWeight*(1+weightTolerance) ||
+		p.Input.Time.Sub(p.Input.LastWeightHandoff) < weightHandoffInterval {
+		return nil
+	}
+
+	var selected *weightHandoff
+	for i, h := range p.Item.Handoffs {
+		if h.Weight <= 0 ||
+			h.NextWeight+h.Weight >= p.Member.Weight ||
+			h.NextMasters >= desiredMaster {
+			continue
+		} else if selected == nil || h.Weight > selected.Weight {
+			selected = &p.Item.Handoffs[i]
+		}
+	}
+	if selected == nil {
+		return nil
+	}
+	return selected.Entry
+}
+
+// weightChanged returns whether weight |next| differs significantly from
+// |prev|.
+func weightChanged(prev, next float64) bool {
+	return math.Abs(next-prev) > weightAnnounceTolerance*math.Max(prev, next)
+}
+
+// expiresBefore returns whether entry |node| expires prior to |t|, and must be
+// refreshed. Entries without an Expiration (eg, because their liveness is
+// instead maintained by an Etcd v3 keep-alive lease) are never refreshed.
+func expiresBefore(node *etcd.Node, t time.Time) bool {
+	return node.Expiration != nil && node.Expiration.Before(t)
+}
+
+// pickOpenReplica selects a random item of SpreadReplicas or, if there are
+// none, of OpenReplicas.
+func pickOpenReplica(p *allocParams) string {
+	if l := p.Item.SpreadReplicas; len(l) != 0 {
+		return l[rand.Int()%len(l)]
+	}
+	return p.Item.OpenReplicas[rand.Int()%len(p.Item.OpenReplicas)]
+}
+
 // targetCounts returns the desired number of mastered and total (mastered +
 // replica) items.
 func targetCounts(p *allocParams) (desiredMaster, desiredTotal int) {
@@ -589,21 +875,28 @@ func targetCounts(p *allocParams) (desiredMaster, desiredTotal int) {
 // nextDeadline computes the next deadline by finding the minimum Expiration of
 // all held Etcd entries, and subtracting 1/2 of lockDuration. Eg, we wish to
 // refresh a held entry once its remaining TTL is less than 1/2 of
-// lockDuration.
+// lockDuration. Entries without an Expiration are treated as expiring a full
+// lockDuration from now.
 func nextDeadline(p *allocParams) time.Time {
 	var firstExpire time.Time
+	var visit = func(entry *etcd.Node) {
+		var expire = p.Input.Time.Add(lockDuration)
+		if entry.Expiration != nil {
+			expire = *entry.Expiration
+		}
+		if firstExpire.IsZero() || expire.Before(firstExpire) {
+			firstExpire = expire
+		}
+	}
+
 	if p.Member.Entry != nil {
-		firstExpire = *p.Member.Entry.Expiration
+		visit(p.Member.Entry)
 	}
 	for _, entry := range p.Item.Master {
-		if firstExpire.IsZero() || entry.Expiration.Before(firstExpire) {
-			firstExpire = *entry.Expiration
-		}
+		visit(entry)
 	}
 	for _, entry := range p.Item.Replica {
-		if firstExpire.IsZero() || entry.Expiration.Before(firstExpire) {
-			firstExpire = *entry.Expiration
-		}
+		visit(entry)
 	}
 
 	if firstExpire.IsZero() {
@@ -17,8 +17,12 @@ import (
 )
 
 type AllocRunSuite struct {
+	// Whether the suite runs against an Etcd service, rather than a
+	// MemoryKeysAPI.
+	useEtcd bool
+
 	// Allocator fields.
-	etcdClient etcd.Client
+	keysAPI    etcd.KeysAPI
 	pathRoot   string
 	replicas   int
 	fixedItems []string
@@ -31,7 +35,12 @@ type AllocRunSuite struct {
 }
 
 func (s *AllocRunSuite) SetUpSuite(c *gc.C) {
-	if testing.Short() {
+	s.notifyCh = make(chan notify, 1)
+
+	if !s.useEtcd {
+		s.keysAPI = NewMemoryKeysAPI()
+		return
+	} else if testing.Short() {
 		c.Skip("skipping allocator integration tests in short mode")
 	}
 
@@ -40,16 +49,15 @@ func (s *AllocRunSuite) SetUpSuite(c *gc.C) {
 	envflag.CommandLine.Parse()
 	flag.Parse()
 
-	s.etcdClient, _ = etcd.New(etcd.Config{
+	var etcdClient, _ = etcd.New(etcd.Config{
 		Endpoints: []string{"http://" + *etcdEndpoint}})
+	s.keysAPI = etcd.NewKeysAPI(etcdClient)
 
 	// Skip suite if Etcd is not available.
 	if _, err := s.KeysAPI().Get(context.Background(), "/",
 		&etcd.GetOptions{Recursive: false}); err != nil {
 		c.Skip(err.Error())
 	}
-
-	s.notifyCh = make(chan notify, 1)
 }
 
 func (s *AllocRunSuite) SetUpTest(c *gc.C) {
@@ -405,7 +413,7 @@ func (s *AllocRunSuite) wait(desc waitFor) int {
 }
 
 // Partial Allocator implementation.
-func (s *AllocRunSuite) KeysAPI() etcd.KeysAPI        { return etcd.NewKeysAPI(s.etcdClient) }
+func (s *AllocRunSuite) KeysAPI() etcd.KeysAPI        { return s.keysAPI }
 func (s *AllocRunSuite) PathRoot() string             { return s.pathRoot }
 func (s *AllocRunSuite) Replicas() int                { return s.replicas }
 func (s *AllocRunSuite) FixedItems() []string         { return s.fixedItems }
@@ -470,3 +478,4 @@ type notify struct {
 }
 
 var _ = gc.Suite(&AllocRunSuite{})
+var _ = gc.Suite(&AllocRunSuite{useEtcd: true})
@@ -1,6 +1,7 @@
 package consensus
 
 import (
+	"context"
 	"errors"
 	"time"
 
@@ -99,6 +100,156 @@ func (s *AllocSuite) TestAllocParamExtraction(c *gc.C) {
 	alloc.AssertExpectations(c)
 }
 
+func (s *AllocSuite) TestAllocParamExtractionWithZones(c *gc.C) {
+	alloc := &MockAllocator{}
+	alloc.On("InstanceKey").Return("my-key")
+	alloc.On("Replicas").Return(2)
+	alloc.On("FixedItems").Return([]string{})
+	alloc.On("ItemRoute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	params := allocParams{Allocator: zonedAllocator{alloc, "zone-a"}}
+	params.Input.Tree = buildTree(c, []etcd.Node{
+		// Item having an entry in our zone.
+		{Key: "/foo/items/a-item/other-key", CreatedIndex: 111},
+		// Items having entries only in other zones.
+		{Key: "/foo/items/b-item/another-key", CreatedIndex: 222},
+		{Key: "/foo/items/b-item/unknown-key", CreatedIndex: 333},
+		{Key: "/foo/items/c-item/another-key", CreatedIndex: 444},
+		// Member annoucements.
+		{Key: "/foo/members/another-key", Value: `{"zone":"zone-b"}`},
+		{Key: "/foo/members/my-key", Value: `{"zone":"zone-a"}`},
+		{Key: "/foo/members/other-key", Value: `{"zone":"zone-a"}`},
+	}).Nodes[0]
+
+	allocExtract(&params)
+
+	c.Check(params.Member.Zone, gc.Equals, "zone-a")
+	c.Check(params.Item.OpenReplicas, gc.DeepEquals, []string{"a-item", "b-item", "c-item"})
+	c.Check(params.Item.SpreadReplicas, gc.DeepEquals, []string{"b-item", "c-item"})
+
+	// Spread replicas are preferred for acquisition.
+	for i := 0; i != 10; i++ {
+		c.Check(pickOpenReplica(&params), gc.Not(gc.Equals), "a-item")
+	}
+	// Otherwise, any open replica is acquired.
+	params.Item.SpreadReplicas = nil
+	c.Check(pickOpenReplica(&params), gc.Matches, "[abc]-item")
+}
+
+func (s *AllocSuite) TestAllocParamExtractionWithWeights(c *gc.C) {
+	alloc := &MockAllocator{}
+	alloc.On("InstanceKey").Return("my-key")
+	alloc.On("Replicas").Return(1)
+	alloc.On("FixedItems").Return([]string{})
+	alloc.On("ItemRoute", mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	alloc.On("ItemIsReadyForPromotion", mock.Anything, "ready").Return(true)
+	alloc.On("ItemIsReadyForPromotion", mock.Anything, "").Return(false)
+
+	params := allocParams{Allocator: weightedAllocator{alloc, map[string]float64{
+		"a-item": 10, "b-item": 20, "c-item": 30, "d-item": 40}}}
+	params.Input.Tree = buildTree(c, []etcd.Node{
+		// Items we master, which are ready for hand-off.
+		{Key: "/foo/items/a-item/my-key", CreatedIndex: 1},
+		{Key: "/foo/items/a-item/other-key", Value: "ready", CreatedIndex: 2},
+		{Key: "/foo/items/b-item/my-key", CreatedIndex: 3},
+		{Key: "/foo/items/b-item/another-key", Value: "ready", CreatedIndex: 4},
+		// Item we master, which isn't ready for hand-off.
+		{Key: "/foo/items/c-item/my-key", CreatedIndex: 5},
+		{Key: "/foo/items/c-item/other-key", CreatedIndex: 6},
+		// Items mastered by other members.
+		{Key: "/foo/items/d-item/other-key", CreatedIndex: 7},
+		{Key: "/foo/items/e-item/other-key", CreatedIndex: 8},
+		// Member annoucements.
+		{Key: "/foo/members/another-key"},
+		{Key: "/foo/members/my-key", Value: `{"weight":50}`},
+		{Key: "/foo/members/other-key", Value: `{"weight":15}`},
+	}).Nodes[0]
+
+	allocExtract(&params)
+
+	// Only mastered items are weighed.
+	c.Check(params.Member.Weight, gc.Equals, 60.0)
+	c.Check(params.Member.Announced, gc.Equals, memberState{Weight: 50})
+	// Our current weight stands in for our announced weight.
+	c.Check(params.Member.MeanWeight, gc.Equals, 25.0)
+
+	c.Check(params.Item.Handoffs, gc.DeepEquals, []weightHandoff{
+		{
+			Entry:       Child(params.Input.Tree, ItemsPrefix, "a-item", "my-key"),
+			Weight:      10,
+			Next:        "other-key",
+			NextWeight:  15,
+			NextMasters: 2,
+		},
+		{
+			Entry:       Child(params.Input.Tree, ItemsPrefix, "b-item", "my-key"),
+			Weight:      20,
+			Next:        "another-key",
+			NextWeight:  0,
+			NextMasters: 0,
+		},
+	})
+}
+
+func (s *AllocSuite) TestWeightHandoffSelection(c *gc.C) {
+	var a = &etcd.Node{Key: "/foo/items/a-item/my-key"}
+	var b = &etcd.Node{Key: "/foo/items/b-item/my-key"}
+
+	var fixture = func() *allocParams {
+		var p = new(allocParams)
+		p.Input.Time = time.Unix(1234, 0)
+		p.Member.Entry = &etcd.Node{Key: "/foo/members/my-key"}
+		p.Member.Weight = 60
+		p.Member.MeanWeight = 25
+		p.Item.Master = make([]*etcd.Node, 2)
+		p.Item.Handoffs = []weightHandoff{
+			{Entry: a, Weight: 10, NextWeight: 15, NextMasters: 2},
+			{Entry: b, Weight: 20, NextWeight: 0, NextMasters: 0},
+		}
+		return p
+	}
+
+	// The heaviest item is handed off.
+	var p = fixture()
+	c.Check(selectWeightHandoff(p, 3), gc.Equals, b)
+
+	// Unless its next master would become as heavy as we are.
+	p.Item.Handoffs[1].NextWeight = 40
+	c.Check(selectWeightHandoff(p, 3), gc.Equals, a)
+
+	// Or would exceed the desired number of mastered items.
+	c.Check(selectWeightHandoff(p, 2), gc.IsNil)
+	p = fixture()
+	c.Check(selectWeightHandoff(p, 2), gc.Equals, b)
+
+	// Items without weight are not handed off.
+	p.Item.Handoffs[0].Weight, p.Item.Handoffs[1].Weight = 0, 0
+	c.Check(selectWeightHandoff(p, 3), gc.IsNil)
+
+	// Nothing is handed off if our weight is within tolerance of the mean.
+	p = fixture()
+	p.Member.Weight = 30
+	c.Check(selectWeightHandoff(p, 3), gc.IsNil)
+
+	// Or if we recently handed off an item.
+	p = fixture()
+	p.Input.LastWeightHandoff = p.Input.Time.Add(-weightHandoffInterval + time.Second)
+	c.Check(selectWeightHandoff(p, 3), gc.IsNil)
+	p.Input.LastWeightHandoff = p.Input.Time.Add(-weightHandoffInterval)
+	c.Check(selectWeightHandoff(p, 3), gc.Equals, b)
+
+	// Or if we're releasing mastered items by count.
+	p = fixture()
+	c.Check(selectWeightHandoff(p, 2), gc.Equals, b)
+	p.Item.Master = make([]*etcd.Node, 4)
+	c.Check(selectWeightHandoff(p, 3), gc.IsNil)
+
+	// Or if we hold no member lock.
+	p = fixture()
+	p.Member.Entry = nil
+	c.Check(selectWeightHandoff(p, 3), gc.IsNil)
+}
+
 func (s *AllocSuite) TestAllocParamExtractionEmptyTree(c *gc.C) {
 	alloc := &MockAllocator{}
 
@@ -207,6 +358,33 @@ func (s *AllocSuite) TestAllocationActions(c *gc.C) {
 		Return(respFixture, errFixture).Once()
 	verify(0, 0, true)
 
+	// Entry is updated if our zone has changed.
+	underTest.Member.Entry = &etcd.Node{
+		Key: "/foo/members/my-key", Expiration: &afterHorizon, ModifiedIndex: 123}
+	underTest.Member.Zone = "zone-a"
+
+	mockKV.On("Set", mock.Anything, "/foo/members/my-key", `{"zone":"zone-a"}`,
+		&etcd.SetOptions{PrevIndex: 123, TTL: lockDuration}).
+		Return(respFixture, errFixture).Once()
+	verify(0, 0, true)
+
+	// Entry is updated if our weight has changed significantly.
+	underTest.Member.Announced.Weight = 100
+	underTest.Member.Weight = 110
+	verify(0, 0, false)
+
+	underTest.Member.Announced.Weight = 100
+	underTest.Member.Weight = 140
+
+	mockKV.On("Set", mock.Anything, "/foo/members/my-key", `{"weight":140}`,
+		&etcd.SetOptions{TTL: lockDuration}).
+		Return(respFixture, errFixture).Once()
+	verify(0, 0, true)
+
+	// Entry held through a lease (without an Expiration) is not refreshed.
+	underTest.Member.Entry = &etcd.Node{Key: "/foo/members/my-key", ModifiedIndex: 123}
+	verify(0, 0, false)
+
 	// Expect |upToDate| isn't refreshed as a Master or Replica.
 	upToDate := []*etcd.Node{{
 		Key:        "/foo/items/an-item/my-key",
@@ -259,6 +437,23 @@ func (s *AllocSuite) TestAllocationActions(c *gc.C) {
 		&etcd.DeleteOptions{PrevIndex: 345}).Return(respFixture, errFixture).Once()
 	verify(0, 0, true)
 
+	// Hand-off of mastered item entry to balance weight.
+	underTest.Member.Weight, underTest.Member.MeanWeight = 60, 25
+	underTest.Member.Announced.Weight = 60
+	underTest.Item.Master = upToDate
+	underTest.Item.Handoffs = []weightHandoff{{
+		Entry:  &etcd.Node{Key: "/foo/items/an-item/my-key", ModifiedIndex: 678},
+		Weight: 20,
+	}}
+	mockKV.On("Delete", mock.Anything, "/foo/items/an-item/my-key",
+		&etcd.DeleteOptions{PrevIndex: 678}).Return(respFixture, errFixture).Once()
+
+	response, err := allocAction(&underTest, 1, 3)
+	c.Check(response, gc.Equals, respFixture)
+	c.Check(err, gc.Equals, errFixture)
+	c.Check(underTest.Output.WeightHandoff, gc.Equals, true)
+	underTest = model // Reset.
+
 	// Release of mastered item entry.
 	underTest.Item.Releaseable = []*etcd.Node{{
 		Key:           "/foo/items/an-item/my-key",
@@ -291,6 +486,17 @@ func (s *AllocSuite) TestAllocationActions(c *gc.C) {
 	}}
 	verify(0, 0, true)
 
+	// Unless we continue to master an item which isn't yet ready for hand-off.
+	underTest.Member.Entry = nil
+	underTest.Item.Master = upToDate
+	underTest.Item.Replica = []*etcd.Node{{
+		Key:           "/foo/items/an-item/my-key",
+		Expiration:    &afterHorizon,
+		Value:         "a-value",
+		ModifiedIndex: 456,
+	}}
+	verify(0, 0, false)
+
 	// Acquire of master entry.
 	mockKV.On("Set", mock.Anything, "/foo/items/new-item/my-key", "",
 		&etcd.SetOptions{PrevExist: "false", TTL: lockDuration}).
@@ -304,6 +510,81 @@ func (s *AllocSuite) TestAllocationActions(c *gc.C) {
 	verify(0, 1, true)
 }
 
+func (s *AllocSuite) TestAllocationActionsUseRouteUpdater(c *gc.C) {
+	var mockKV routeUpdaterKeysAPI
+	var mockAlloc MockAllocator
+
+	var model allocParams
+	model.Allocator = &mockAlloc
+	model.Input.Time = time.Unix(1234, 0)
+
+	afterHorizon := model.Input.Time.Add(lockDuration / 2).Add(time.Microsecond)
+
+	model.Input.Tree = &etcd.Node{Key: "/foo", Dir: true, Nodes: etcd.Nodes{
+		{Key: "/foo/items", Dir: true, Nodes: etcd.Nodes{
+			{Key: "/foo/items/an-item", Dir: true, Nodes: etcd.Nodes{
+				{Key: "/foo/items/an-item/my-key", Value: "a-value", Expiration: &afterHorizon,
+					CreatedIndex: 400, ModifiedIndex: 456},
+				{Key: "/foo/items/an-item/other-key", CreatedIndex: 401, ModifiedIndex: 457},
+			}},
+		}},
+	}}
+	model.Member.Entry = &etcd.Node{Key: "/foo/members/my-key", Expiration: &afterHorizon}
+	model.Member.Count = 2
+
+	mockAlloc.On("KeysAPI").Return(&mockKV)
+	mockAlloc.On("InstanceKey").Return("my-key")
+	mockAlloc.On("ItemState", "an-item").Return("a-value")
+	mockAlloc.On("PathRoot").Return("/foo")
+	mockAlloc.On("Replicas").Return(2)
+
+	var respFixture = &etcd.Response{Action: "verifies response pass-through"}
+	var errFixture = errors.New("verifies error pass-through")
+
+	// routeOf matches a Route of |item| having |entries|.
+	var routeOf = func(item string, entries int) interface{} {
+		return mock.MatchedBy(func(rt Route) bool {
+			return rt.Item.Key == "/foo/items/"+item && len(rt.Entries) == entries
+		})
+	}
+
+	// Release of a mastered item entry is conditioned upon the item Route.
+	var underTest = model
+	underTest.Item.Master = []*etcd.Node{model.Input.Tree.Nodes[0].Nodes[0].Nodes[0]}
+	underTest.Item.Releaseable = underTest.Item.Master
+
+	mockKV.On("DeleteRouteEntry", mock.Anything, routeOf("an-item", 2), underTest.Item.Master[0]).
+		Return(respFixture, errFixture).Once()
+
+	response, err := allocAction(&underTest, 0, 0)
+	c.Check(response, gc.Equals, respFixture)
+	c.Check(err, gc.Equals, errFixture)
+
+	// As is acquisition of an item not yet in the tree.
+	underTest = model
+	underTest.Item.OpenMasters = []string{"new-item"}
+
+	mockKV.On("CreateRouteEntry", mock.Anything, routeOf("new-item", 0),
+		"/foo/items/new-item/my-key", "", lockDuration).Return(respFixture, errFixture).Once()
+
+	response, err = allocAction(&underTest, 1, 1)
+	c.Check(response, gc.Equals, respFixture)
+	c.Check(err, gc.Equals, errFixture)
+
+	// Extra item locks are released regardless of their Route.
+	underTest = model
+	underTest.Item.Extra = []*etcd.Node{{Key: "/foo/items/extra-item/my-key", ModifiedIndex: 345}}
+
+	mockKV.On("Delete", mock.Anything, "/foo/items/extra-item/my-key",
+		&etcd.DeleteOptions{PrevIndex: 345}).Return(respFixture, errFixture).Once()
+
+	response, err = allocAction(&underTest, 0, 0)
+	c.Check(response, gc.Equals, respFixture)
+	c.Check(err, gc.Equals, errFixture)
+
+	mockKV.AssertExpectations(c)
+}
+
 func (s *AllocSuite) TestNextDeadline(c *gc.C) {
 	var p allocParams
 
@@ -318,6 +599,16 @@ func (s *AllocSuite) TestNextDeadline(c *gc.C) {
 	c.Check(nextDeadline(&p), gc.Equals, now.Add(lockDuration/2-1))
 	p.Item.Replica = []*etcd.Node{{Expiration: box(now.Add(lockDuration - 2))}}
 	c.Check(nextDeadline(&p), gc.Equals, now.Add(lockDuration/2-2))
+
+	// Entries without an Expiration are held through an Etcd v3 lease. They
+	// never expire, but Allocate must continue to wake while they're held.
+	p = allocParams{}
+	p.Input.Time = now
+	p.Member.Entry = &etcd.Node{}
+	p.Item.Master = []*etcd.Node{{}}
+	c.Check(nextDeadline(&p), gc.Equals, now.Add(lockDuration/2))
+	p.Item.Replica = []*etcd.Node{{Expiration: box(now.Add(lockDuration - 2))}}
+	c.Check(nextDeadline(&p), gc.Equals, now.Add(lockDuration/2-2))
 }
 
 func buildTree(c *gc.C, nodes []etcd.Node) *etcd.Node {
@@ -330,4 +621,39 @@ func buildTree(c *gc.C, nodes []etcd.Node) *etcd.Node {
 	return tree
 }
 
+// zonedAllocator composes an Allocator with a Zoner implementation.
+type zonedAllocator struct {
+	Allocator
+	zone string
+}
+
+func (a zonedAllocator) Zone() string { return a.zone }
+
+// weightedAllocator composes an Allocator with a Weigher implementation.
+type weightedAllocator struct {
+	Allocator
+	weights map[string]float64
+}
+
+func (a weightedAllocator) ItemWeight(item string) float64 { return a.weights[item] }
+
+// routeUpdaterKeysAPI composes a MockKeysAPI with a mocked RouteUpdater.
+type routeUpdaterKeysAPI struct {
+	MockKeysAPI
+}
+
+func (m *routeUpdaterKeysAPI) CreateRouteEntry(ctx context.Context, route Route, key, value string,
+	ttl time.Duration) (*etcd.Response, error) {
+
+	var ret = m.Called(ctx, route, key, value, ttl)
+	return ret.Get(0).(*etcd.Response), ret.Error(1)
+}
+
+func (m *routeUpdaterKeysAPI) DeleteRouteEntry(ctx context.Context, route Route,
+	entry *etcd.Node) (*etcd.Response, error) {
+
+	var ret = m.Called(ctx, route, entry)
+	return ret.Get(0).(*etcd.Response), ret.Error(1)
+}
+
 var _ = gc.Suite(&AllocSuite{})
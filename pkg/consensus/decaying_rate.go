@@ -0,0 +1,51 @@
+package consensus
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// DecayingRate is an exponentially-decaying measure of the rate of a
+// quantity (eg, bytes appended per second), suitable for use as an item
+// weight (see Weigher). Recent additions dominate the measure, and the
+// contribution of an addition halves with each RateHalfLife. It is safe
+// for concurrent use.
+type DecayingRate struct {
+	// Decayed sum of additions, as of |last|.
+	sum  float64
+	last time.Time
+	mu   sync.Mutex
+}
+
+// RateHalfLife is the half-life of additions to a DecayingRate.
+const RateHalfLife = time.Minute
+
+// Mean lifetime of additions to a DecayingRate, in seconds. A constant rate
+// of additions R converges to a decayed sum of R * rateMeanLifetime.
+var rateMeanLifetime = RateHalfLife.Seconds() / math.Ln2
+
+// Add |n| to the DecayingRate at time |now|.
+func (r *DecayingRate) Add(n float64, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decay(now)
+	r.sum += n
+}
+
+// Rate returns the per-second rate of the DecayingRate at time |now|.
+func (r *DecayingRate) Rate(now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.decay(now)
+	return r.sum / rateMeanLifetime
+}
+
+func (r *DecayingRate) decay(now time.Time) {
+	if d := now.Sub(r.last); d > 0 {
+		r.sum *= math.Exp(-d.Seconds() / rateMeanLifetime)
+		r.last = now
+	}
+}
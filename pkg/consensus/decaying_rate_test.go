@@ -0,0 +1,47 @@
+package consensus
+
+import (
+	"math"
+	"time"
+
+	gc "github.com/go-check/check"
+)
+
+type DecayingRateSuite struct{}
+
+func (s *DecayingRateSuite) TestDecay(c *gc.C) {
+	var r DecayingRate
+	var now = time.Unix(1500000000, 0)
+
+	c.Check(r.Rate(now), gc.Equals, 0.0)
+
+	r.Add(1000, now)
+	var rate = r.Rate(now)
+	c.Check(rate > 0, gc.Equals, true)
+
+	// The rate halves with each half-life.
+	now = now.Add(RateHalfLife)
+	c.Check(r.Rate(now), gc.Not(gc.Equals), rate)
+	c.Check(approxEqual(r.Rate(now)*2, rate), gc.Equals, true)
+
+	// Observations of a time prior to the last are not decayed.
+	r.Add(1000, now.Add(-time.Second))
+	c.Check(approxEqual(r.Rate(now), rate*1.5), gc.Equals, true)
+}
+
+func (s *DecayingRateSuite) TestConvergesToConstantRate(c *gc.C) {
+	var r DecayingRate
+	var now = time.Unix(1500000000, 0)
+
+	// Add 100 per second for many half-lives.
+	for i := 0; i != 3600; i++ {
+		now = now.Add(time.Second)
+		r.Add(100, now)
+	}
+	c.Check(math.Abs(r.Rate(now)-100) < 1, gc.Equals, true,
+		gc.Commentf("rate %v", r.Rate(now)))
+}
+
+func approxEqual(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+var _ = gc.Suite(&DecayingRateSuite{})
@@ -0,0 +1,642 @@
+// Package etcdv3 implements the Etcd v2 KeysAPI, upon which package consensus
+// and its dependents are built, atop an Etcd v3 cluster.
+//
+// Etcd v2 keys and directories are mapped onto the flat v3 keyspace: a v2 key
+// is stored under the same v3 key, and a v2 directory exists implicitly if it
+// has subordinate keys. Explicitly created directories are additionally
+// represented by a marker key, which is the directory key with a trailing
+// slash. V2 indices are v3 revisions, and compare-and-swap and
+// compare-and-delete operations are applied as v3 transactions.
+//
+// Rather than expiring individual keys, keys set with a TTL are attached to a
+// shared lease which is kept alive for the lifetime of the KeysAPI. Such keys
+// have no Expiration, and need not be refreshed: they're removed only when the
+// KeysAPI is closed or its process fails to keep the lease alive.
+//
+// Each etcd.KeysAPI operation is applied as a single v3 transaction over the
+// keys of one v2 key or directory. KeysAPI is also a consensus.RouteUpdater:
+// Allocators acquire and release item entries through v3 transactions which
+// compare every entry of the item's Route, such that the change is applied
+// only if the Route is unchanged from that which the Allocator observed.
+// CreateInOrder, which neither package consensus nor its dependents use, is
+// not supported.
+package etcdv3
+
+import (
+	"context"
+	"errors"
+	"math"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/mvcc/mvccpb"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+)
+
+// KeysAPI is an etcd.KeysAPI implementation which uses an Etcd v3 cluster.
+type KeysAPI struct {
+	client *clientv3.Client
+
+	// Keep-alive leases of keys having a TTL, keyed on the TTL.
+	leases map[time.Duration]clientv3.LeaseID
+	ctx    context.Context
+	cancel context.CancelFunc
+	mu     sync.Mutex
+}
+
+// NewKeysAPI returns a KeysAPI which uses Etcd v3 |client|.
+func NewKeysAPI(client *clientv3.Client) *KeysAPI {
+	var ctx, cancel = context.WithCancel(context.Background())
+
+	return &KeysAPI{
+		client: client,
+		leases: make(map[time.Duration]clientv3.LeaseID),
+		ctx:    ctx,
+		cancel: cancel,
+	}
+}
+
+// Close stops keep-alives of the leases of the KeysAPI and revokes them,
+// immediately removing all keys which were set with a TTL.
+func (k *KeysAPI) Close() error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.cancel()
+
+	var err error
+	for ttl, id := range k.leases {
+		if _, rErr := k.client.Revoke(context.Background(), id); rErr != nil {
+			err = rErr
+		}
+		delete(k.leases, ttl)
+	}
+	return err
+}
+
+func (k *KeysAPI) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = new(etcd.GetOptions)
+	}
+	key = normalize(key)
+
+	var resp, err = k.client.Get(ctx, key, clientv3.WithPrefix())
+	if err != nil {
+		return nil, err
+	}
+	var index = uint64(resp.Header.Revision)
+
+	var node = buildNode(key, resp.Kvs)
+	if node == nil {
+		return nil, keyNotFound(key, index)
+	}
+	if !opts.Recursive {
+		for _, child := range node.Nodes {
+			child.Nodes = nil
+		}
+	}
+	return &etcd.Response{Action: "get", Node: node, Index: index}, nil
+}
+
+func (k *KeysAPI) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = new(etcd.SetOptions)
+	}
+	key = normalize(key)
+
+	// |target| is the v3 key which is put.
+	var target = key
+	if opts.Dir {
+		target, value = dirMarker(key), ""
+	}
+
+	var cmps []clientv3.Cmp
+	var action = "set"
+
+	switch opts.PrevExist {
+	case etcd.PrevNoExist:
+		// Neither the key, nor a directory of the key may exist.
+		cmps = append(cmps,
+			clientv3.Compare(clientv3.CreateRevision(key), "=", 0),
+			clientv3.Compare(clientv3.CreateRevision(dirMarker(key)), "=", 0).WithPrefix())
+		action = "create"
+	case etcd.PrevExist:
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(target), ">", 0))
+		action = "update"
+	}
+	if opts.PrevIndex != 0 {
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(target), "=", int64(opts.PrevIndex)))
+		action = "compareAndSwap"
+	}
+	if opts.PrevValue != "" {
+		cmps = append(cmps, clientv3.Compare(clientv3.Value(target), "=", opts.PrevValue))
+		action = "compareAndSwap"
+	}
+
+	var putOpts = []clientv3.OpOption{clientv3.WithPrevKV()}
+	if opts.TTL != 0 {
+		var lease, err = k.lease(ctx, opts.TTL)
+		if err != nil {
+			return nil, err
+		}
+		putOpts = append(putOpts, clientv3.WithLease(lease))
+	}
+
+	var resp, err = k.client.Txn(ctx).
+		If(cmps...).
+		Then(clientv3.OpPut(target, value, putOpts...), clientv3.OpGet(target)).
+		Else(clientv3.OpGet(target)).
+		Commit()
+
+	if err != nil {
+		return nil, err
+	}
+	var index = uint64(resp.Header.Revision)
+
+	if !resp.Succeeded {
+		var existed = len(resp.Responses[0].GetResponseRange().Kvs) != 0
+
+		if opts.PrevExist == etcd.PrevNoExist {
+			return nil, etcd.Error{Code: etcd.ErrorCodeNodeExist, Message: "Key already exists", Cause: key, Index: index}
+		} else if !existed {
+			return nil, keyNotFound(key, index)
+		}
+		return nil, etcd.Error{Code: etcd.ErrorCodeTestFailed, Message: "Compare failed", Cause: key, Index: index}
+	}
+
+	var result = &etcd.Response{
+		Action: action,
+		Node:   nodeOf(resp.Responses[1].GetResponseRange().Kvs[0]),
+		Index:  index,
+	}
+	if prev := resp.Responses[0].GetResponsePut().PrevKv; prev != nil {
+		result.PrevNode = nodeOf(prev)
+	}
+	return result, nil
+}
+
+func (k *KeysAPI) Delete(ctx context.Context, key string, opts *etcd.DeleteOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = new(etcd.DeleteOptions)
+	}
+	key = normalize(key)
+
+	var cmps []clientv3.Cmp
+	var ops = []clientv3.Op{clientv3.OpDelete(key, clientv3.WithPrevKV())}
+	var action = "delete"
+
+	if opts.Recursive {
+		ops = append(ops, clientv3.OpDelete(dirMarker(key), clientv3.WithPrefix(), clientv3.WithPrevKV()))
+	} else if opts.Dir {
+		// Only an empty directory may be deleted.
+		cmps = append(cmps, clientv3.Compare(clientv3.CreateRevision(dirMarker(key)+"\x00"), "=", 0).
+			WithRange(clientv3.GetPrefixRangeEnd(dirMarker(key))))
+		ops = append(ops, clientv3.OpDelete(dirMarker(key), clientv3.WithPrevKV()))
+	}
+	if opts.PrevIndex != 0 {
+		cmps = append(cmps, clientv3.Compare(clientv3.ModRevision(key), "=", int64(opts.PrevIndex)))
+		action = "compareAndDelete"
+	}
+	if opts.PrevValue != "" {
+		cmps = append(cmps, clientv3.Compare(clientv3.Value(key), "=", opts.PrevValue))
+		action = "compareAndDelete"
+	}
+
+	var resp, err = k.client.Txn(ctx).
+		If(cmps...).
+		Then(ops...).
+		Else(clientv3.OpGet(key)).
+		Commit()
+
+	if err != nil {
+		return nil, err
+	}
+	var index = uint64(resp.Header.Revision)
+
+	if !resp.Succeeded {
+		if len(resp.Responses[0].GetResponseRange().Kvs) == 0 && opts.Dir && !opts.Recursive {
+			return nil, etcd.Error{Code: etcd.ErrorCodeDirNotEmpty, Message: "Directory not empty", Cause: key, Index: index}
+		} else if len(resp.Responses[0].GetResponseRange().Kvs) == 0 {
+			return nil, keyNotFound(key, index)
+		}
+		return nil, etcd.Error{Code: etcd.ErrorCodeTestFailed, Message: "Compare failed", Cause: key, Index: index}
+	}
+
+	// Collect deleted keys, and build the previous node of |key| from them.
+	var prev []*mvccpb.KeyValue
+	for _, r := range resp.Responses {
+		prev = append(prev, r.GetResponseDeleteRange().PrevKvs...)
+	}
+	sort.Slice(prev, func(i, j int) bool { return string(prev[i].Key) < string(prev[j].Key) })
+
+	var prevNode = buildNode(key, prev)
+	if prevNode == nil {
+		return nil, keyNotFound(key, index)
+	}
+
+	var node = &etcd.Node{
+		Key:           key,
+		Dir:           prevNode.Dir,
+		CreatedIndex:  prevNode.CreatedIndex,
+		ModifiedIndex: index,
+	}
+	return &etcd.Response{Action: action, Node: node, PrevNode: prevNode, Index: index}, nil
+}
+
+func (k *KeysAPI) Create(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return k.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevNoExist})
+}
+
+func (k *KeysAPI) CreateInOrder(ctx context.Context, dir, value string, opts *etcd.CreateInOrderOptions) (*etcd.Response, error) {
+	return nil, errors.New("etcdv3: CreateInOrder is not supported")
+}
+
+func (k *KeysAPI) Update(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return k.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevExist})
+}
+
+func (k *KeysAPI) Watcher(key string, opts *etcd.WatcherOptions) etcd.Watcher {
+	if opts == nil {
+		opts = new(etcd.WatcherOptions)
+	}
+	return &watcher{
+		client:     k.client,
+		ctx:        k.ctx,
+		key:        normalize(key),
+		recursive:  opts.Recursive,
+		afterIndex: int64(opts.AfterIndex),
+	}
+}
+
+// CreateRouteEntry creates |key| as a new entry of |route|, in a transaction
+// which requires that |route| is unchanged. See consensus.RouteUpdater.
+func (k *KeysAPI) CreateRouteEntry(ctx context.Context, route consensus.Route, key, value string,
+	ttl time.Duration) (*etcd.Response, error) {
+
+	key = normalize(key)
+
+	var putOpts []clientv3.OpOption
+	if ttl != 0 {
+		var lease, err = k.lease(ctx, ttl)
+		if err != nil {
+			return nil, err
+		}
+		putOpts = append(putOpts, clientv3.WithLease(lease))
+	}
+
+	var resp, err = k.client.Txn(ctx).
+		If(append(routeCmps(route), clientv3.Compare(clientv3.CreateRevision(key), "=", 0))...).
+		Then(clientv3.OpPut(key, value, putOpts...), clientv3.OpGet(key)).
+		Commit()
+
+	if err != nil {
+		return nil, err
+	}
+	var index = uint64(resp.Header.Revision)
+
+	if !resp.Succeeded {
+		return nil, routeChanged(route, index)
+	}
+	return &etcd.Response{
+		Action: "create",
+		Node:   nodeOf(resp.Responses[1].GetResponseRange().Kvs[0]),
+		Index:  index,
+	}, nil
+}
+
+// DeleteRouteEntry deletes |entry| of |route|, in a transaction which
+// requires that |route| is unchanged. See consensus.RouteUpdater.
+func (k *KeysAPI) DeleteRouteEntry(ctx context.Context, route consensus.Route,
+	entry *etcd.Node) (*etcd.Response, error) {
+
+	var key = normalize(entry.Key)
+
+	var resp, err = k.client.Txn(ctx).
+		If(append(routeCmps(route),
+			clientv3.Compare(clientv3.ModRevision(key), "=", int64(entry.ModifiedIndex)))...).
+		Then(clientv3.OpDelete(key, clientv3.WithPrevKV())).
+		Commit()
+
+	if err != nil {
+		return nil, err
+	}
+	var index = uint64(resp.Header.Revision)
+
+	if !resp.Succeeded {
+		return nil, routeChanged(route, index)
+	}
+	var prevNode = nodeOf(resp.Responses[0].GetResponseDeleteRange().PrevKvs[0])
+
+	var node = &etcd.Node{
+		Key:           key,
+		CreatedIndex:  prevNode.CreatedIndex,
+		ModifiedIndex: index,
+	}
+	return &etcd.Response{Action: "compareAndDelete", Node: node, PrevNode: prevNode, Index: index}, nil
+}
+
+// lease returns the keep-alive lease of keys having |ttl|, granting it if
+// required.
+func (k *KeysAPI) lease(ctx context.Context, ttl time.Duration) (clientv3.LeaseID, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if id, ok := k.leases[ttl]; ok {
+		return id, nil
+	}
+
+	var grant, err = k.client.Grant(ctx, int64(math.Ceil(ttl.Seconds())))
+	if err != nil {
+		return clientv3.NoLease, err
+	}
+	keepAliveCh, err := k.client.KeepAlive(k.ctx, grant.ID)
+	if err != nil {
+		return clientv3.NoLease, err
+	}
+	k.leases[ttl] = grant.ID
+
+	go k.keepAlive(ttl, grant.ID, keepAliveCh)
+	return grant.ID, nil
+}
+
+// keepAlive consumes keep-alive responses of lease |id| until they stop, as
+// occurs if the lease expires or the KeysAPI is closed. A new lease is then
+// granted on the next use of |ttl|.
+func (k *KeysAPI) keepAlive(ttl time.Duration, id clientv3.LeaseID, ch <-chan *clientv3.LeaseKeepAliveResponse) {
+	for range ch {
+	}
+
+	k.mu.Lock()
+	if k.leases[ttl] == id {
+		delete(k.leases, ttl)
+	}
+	k.mu.Unlock()
+
+	if k.ctx.Err() == nil {
+		log.WithFields(log.Fields{"lease": id, "ttl": ttl}).Warn("lease keep-alive stopped")
+	}
+}
+
+// watcher is an etcd.Watcher which watches revisions of an Etcd v3 key or
+// prefix. A single v3 watch is established by the first Next call, and is
+// shared by subsequent calls. As the etcd.Watcher interface has no means of
+// closing the watcher, the v3 watch is closed only upon an error (after which
+// the next call re-establishes it from the last observed revision), or when
+// the KeysAPI is closed.
+type watcher struct {
+	client     *clientv3.Client
+	ctx        context.Context // Context of the KeysAPI.
+	key        string
+	recursive  bool
+	afterIndex int64
+
+	watchCh clientv3.WatchChan
+	cancel  context.CancelFunc
+	pending []*etcd.Response
+}
+
+func (w *watcher) Next(ctx context.Context) (*etcd.Response, error) {
+	for len(w.pending) == 0 {
+		if w.watchCh == nil {
+			w.open()
+		}
+
+		var resp clientv3.WatchResponse
+		var ok bool
+
+		select {
+		case resp, ok = <-w.watchCh:
+		case <-ctx.Done():
+			return nil, ctx.Err() // The v3 watch remains open.
+		}
+
+		if !ok {
+			w.close()
+			if err := w.ctx.Err(); err != nil {
+				return nil, err
+			}
+			return nil, errors.New("etcdv3: watch closed")
+		} else if resp.CompactRevision != 0 {
+			w.close()
+			return nil, etcd.Error{
+				Code:    etcd.ErrorCodeEventIndexCleared,
+				Message: "The event in requested index is outdated and cleared",
+				Cause:   w.key,
+				Index:   uint64(resp.CompactRevision),
+			}
+		} else if err := resp.Err(); err != nil {
+			w.close()
+			return nil, err
+		}
+		w.pending = w.responses(resp.Events)
+	}
+
+	var next = w.pending[0]
+	w.pending = w.pending[1:]
+	return next, nil
+}
+
+// open establishes the v3 watch of the watcher, beginning at the revision
+// following |afterIndex|.
+func (w *watcher) open() {
+	var watchOpts = []clientv3.OpOption{clientv3.WithPrevKV()}
+	if w.afterIndex != 0 {
+		watchOpts = append(watchOpts, clientv3.WithRev(w.afterIndex+1))
+	}
+	if w.recursive {
+		watchOpts = append(watchOpts, clientv3.WithPrefix())
+	}
+
+	var watchCtx context.Context
+	watchCtx, w.cancel = context.WithCancel(w.ctx)
+	w.watchCh = w.client.Watch(watchCtx, w.key, watchOpts...)
+}
+
+// close the v3 watch of the watcher.
+func (w *watcher) close() {
+	w.cancel()
+	w.watchCh, w.cancel = nil, nil
+}
+
+// responses maps |events| into etcd.Responses, and advances |afterIndex|.
+// Deletions of keys within a directory which was deleted by the same revision
+// are implied by the directory deletion, and are elided.
+func (w *watcher) responses(events []*clientv3.Event) []*etcd.Response {
+	var out []*etcd.Response
+	var deletedDir string
+
+	for _, ev := range events {
+		w.afterIndex = ev.Kv.ModRevision
+
+		var key = string(ev.Kv.Key)
+		if key != w.key && !strings.HasPrefix(key, dirMarker(w.key)) {
+			continue // A sibling key of |w.key| sharing its prefix.
+		}
+
+		var resp = &etcd.Response{Node: nodeOf(ev.Kv), Index: uint64(ev.Kv.ModRevision)}
+		if ev.PrevKv != nil {
+			resp.PrevNode = nodeOf(ev.PrevKv)
+		}
+
+		switch ev.Type {
+		case mvccpb.PUT:
+			if ev.IsCreate() {
+				resp.Action = "create"
+			} else {
+				resp.Action = "set"
+			}
+		case mvccpb.DELETE:
+			if deletedDir != "" && strings.HasPrefix(key, deletedDir) &&
+				len(out) != 0 && out[len(out)-1].Index == resp.Index {
+				continue
+			}
+			if strings.HasSuffix(key, "/") {
+				deletedDir = key
+			}
+			resp.Action = "delete"
+			if resp.PrevNode != nil {
+				resp.Node.CreatedIndex = resp.PrevNode.CreatedIndex
+			}
+		}
+		out = append(out, resp)
+	}
+	return out
+}
+
+// buildNode returns the etcd.Node of |key| from |kvs|, which must be sorted
+// on key, or nil if |key| does not exist within |kvs|. |kvs| may include
+// keys which are not |key| or its children. These are ignored.
+func buildNode(key string, kvs []*mvccpb.KeyValue) *etcd.Node {
+	var dirs = make(map[string]*etcd.Node)
+
+	// dirOf returns the directory node of |dirKey|, creating it and its
+	// parent directories (up to |key|) if required.
+	var dirOf func(dirKey string, kv *mvccpb.KeyValue) *etcd.Node
+	dirOf = func(dirKey string, kv *mvccpb.KeyValue) *etcd.Node {
+		if node, ok := dirs[dirKey]; ok {
+			return node
+		}
+		var node = &etcd.Node{
+			Key:           dirKey,
+			Dir:           true,
+			CreatedIndex:  uint64(kv.CreateRevision),
+			ModifiedIndex: uint64(kv.ModRevision),
+		}
+		dirs[dirKey] = node
+
+		if dirKey != key {
+			var parent = dirOf(path.Dir(dirKey), kv)
+			parent.Nodes = append(parent.Nodes, node)
+		}
+		return node
+	}
+
+	for _, kv := range kvs {
+		var k = string(kv.Key)
+
+		if k == key {
+			return nodeOf(kv) // |key| is not a directory.
+		} else if !strings.HasPrefix(k, dirMarker(key)) {
+			continue
+		}
+
+		if strings.HasSuffix(k, "/") {
+			// Marker of an explicitly created directory.
+			var node = dirOf(strings.TrimSuffix(k, "/"), kv)
+			node.CreatedIndex = uint64(kv.CreateRevision)
+			node.ModifiedIndex = uint64(kv.ModRevision)
+		} else {
+			var parent = dirOf(path.Dir(k), kv)
+			parent.Nodes = append(parent.Nodes, nodeOf(kv))
+		}
+	}
+
+	// Keys are ordered by their full v3 key, which differs from the order of
+	// names within a directory (eg, "a/b-c" sorts before "a/b/", but "b"
+	// sorts before "b-c").
+	for _, node := range dirs {
+		sort.Slice(node.Nodes, func(i, j int) bool { return node.Nodes[i].Key < node.Nodes[j].Key })
+	}
+	return dirs[key]
+}
+
+// routeCmps returns comparisons which hold only if |route| is unchanged: each
+// of its entries retains its ModifiedIndex, and no key of the item directory
+// was created or modified after the greatest index within |route|.
+func routeCmps(route consensus.Route) []clientv3.Cmp {
+	var dir = normalize(route.Item.Key)
+	var index = maxIndex(route.Item)
+
+	var cmps []clientv3.Cmp
+	for _, entry := range route.Entries {
+		if !entry.Dir {
+			// Entries which are directories (eg, of nested items) have no v3 key.
+			cmps = append(cmps, clientv3.Compare(
+				clientv3.ModRevision(normalize(entry.Key)), "=", int64(entry.ModifiedIndex)))
+		}
+		if i := maxIndex(entry); i > index {
+			index = i
+		}
+	}
+	return append(cmps, clientv3.Compare(
+		clientv3.ModRevision(dirMarker(dir)), "<", int64(index)+1).WithPrefix())
+}
+
+// maxIndex returns the greatest ModifiedIndex of |node| and its descendants.
+func maxIndex(node *etcd.Node) uint64 {
+	var index = node.ModifiedIndex
+	for _, child := range node.Nodes {
+		if i := maxIndex(child); i > index {
+			index = i
+		}
+	}
+	return index
+}
+
+// nodeOf maps a v3 KeyValue to an etcd.Node.
+func nodeOf(kv *mvccpb.KeyValue) *etcd.Node {
+	var key = string(kv.Key)
+
+	if strings.HasSuffix(key, "/") && key != "/" {
+		return &etcd.Node{
+			Key:           strings.TrimSuffix(key, "/"),
+			Dir:           true,
+			CreatedIndex:  uint64(kv.CreateRevision),
+			ModifiedIndex: uint64(kv.ModRevision),
+		}
+	}
+	return &etcd.Node{
+		Key:           key,
+		Value:         string(kv.Value),
+		CreatedIndex:  uint64(kv.CreateRevision),
+		ModifiedIndex: uint64(kv.ModRevision),
+	}
+}
+
+// normalize |key| to the form of a v2 key, having a leading but no trailing
+// slash.
+func normalize(key string) string { return path.Join("/", key) }
+
+// dirMarker returns the marker key of directory |key|, which is also the
+// prefix of all keys within the directory.
+func dirMarker(key string) string {
+	if key == "/" {
+		return key
+	}
+	return key + "/"
+}
+
+func routeChanged(route consensus.Route, index uint64) error {
+	return etcd.Error{Code: etcd.ErrorCodeTestFailed, Message: "Route changed", Cause: route.Item.Key, Index: index}
+}
+
+func keyNotFound(key string, index uint64) error {
+	return etcd.Error{Code: etcd.ErrorCodeKeyNotFound, Message: "Key not found", Cause: key, Index: index}
+}
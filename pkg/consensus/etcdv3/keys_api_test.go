@@ -0,0 +1,494 @@
+package etcdv3
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/coreos/etcd/clientv3"
+	"github.com/coreos/etcd/embed"
+	gc "github.com/go-check/check"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+)
+
+type KeysAPISuite struct {
+	dir    string
+	server *embed.Etcd
+	client *clientv3.Client
+
+	keysAPI *KeysAPI
+}
+
+func (s *KeysAPISuite) SetUpSuite(c *gc.C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "etcdv3-test")
+	c.Assert(err, gc.IsNil)
+
+	var clientURL, peerURL = unusedURL(c), unusedURL(c)
+
+	var cfg = embed.NewConfig()
+	cfg.Dir = s.dir
+	cfg.LCUrls, cfg.ACUrls = []url.URL{clientURL}, []url.URL{clientURL}
+	cfg.LPUrls, cfg.APUrls = []url.URL{peerURL}, []url.URL{peerURL}
+	cfg.InitialCluster = cfg.InitialClusterFromName(cfg.Name)
+
+	s.server, err = embed.StartEtcd(cfg)
+	c.Assert(err, gc.IsNil)
+
+	select {
+	case <-s.server.Server.ReadyNotify():
+	case <-time.After(time.Minute):
+		c.Fatal("embedded Etcd failed to start")
+	}
+
+	s.client, err = clientv3.New(clientv3.Config{Endpoints: []string{clientURL.String()}})
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *KeysAPISuite) TearDownSuite(c *gc.C) {
+	if s.client != nil {
+		s.client.Close()
+	}
+	if s.server != nil {
+		s.server.Close()
+	}
+	os.RemoveAll(s.dir)
+}
+
+func (s *KeysAPISuite) SetUpTest(c *gc.C) {
+	var _, err = s.client.Delete(context.Background(), "/", clientv3.WithPrefix())
+	c.Assert(err, gc.IsNil)
+
+	s.keysAPI = NewKeysAPI(s.client)
+}
+
+func (s *KeysAPISuite) TearDownTest(c *gc.C) {
+	c.Check(s.keysAPI.Close(), gc.IsNil)
+}
+
+func (s *KeysAPISuite) TestKeyOperations(c *gc.C) {
+	var ctx = context.Background()
+
+	resp, err := s.keysAPI.Create(ctx, "/root/a", "1")
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "create")
+	c.Check(resp.Node.Key, gc.Equals, "/root/a")
+	c.Check(resp.Node.Value, gc.Equals, "1")
+	c.Check(resp.Node.CreatedIndex, gc.Equals, resp.Node.ModifiedIndex)
+
+	var createdIndex = resp.Node.CreatedIndex
+
+	_, err = s.keysAPI.Create(ctx, "root/a", "1")
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeNodeExist)
+	_, err = s.keysAPI.Update(ctx, "/root/missing", "1")
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeKeyNotFound)
+
+	// Compare-and-swap of the key.
+	_, err = s.keysAPI.Set(ctx, "/root/a", "2", &etcd.SetOptions{PrevIndex: createdIndex + 100})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+
+	resp, err = s.keysAPI.Set(ctx, "/root/a", "2", &etcd.SetOptions{PrevIndex: createdIndex})
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "compareAndSwap")
+	c.Check(resp.Node.Value, gc.Equals, "2")
+	c.Check(resp.Node.CreatedIndex, gc.Equals, createdIndex)
+	c.Check(resp.Node.ModifiedIndex > createdIndex, gc.Equals, true)
+	c.Check(resp.PrevNode.Value, gc.Equals, "1")
+
+	var modifiedIndex = resp.Node.ModifiedIndex
+
+	// Create a directory, and keys within it. Note that "a-b" orders before
+	// "dir/" in the v3 keyspace, but after "a" as a directory entry.
+	_, err = s.keysAPI.Set(ctx, "/root/dir", "", &etcd.SetOptions{Dir: true, PrevExist: etcd.PrevNoExist})
+	c.Assert(err, gc.IsNil)
+	_, err = s.keysAPI.Set(ctx, "/root/dir", "", &etcd.SetOptions{Dir: true, PrevExist: etcd.PrevNoExist})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeNodeExist)
+	// "/root" exists implicitly.
+	_, err = s.keysAPI.Set(ctx, "/root", "", &etcd.SetOptions{Dir: true, PrevExist: etcd.PrevNoExist})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeNodeExist)
+
+	for key, value := range map[string]string{
+		"/root/a-b":       "3",
+		"/root/dir/b":     "4",
+		"/root/dir/sub/c": "5",
+		"/rootsibling":    "6",
+	} {
+		_, err = s.keysAPI.Set(ctx, key, value, nil)
+		c.Assert(err, gc.IsNil)
+	}
+
+	resp, err = s.keysAPI.Get(ctx, "/root", &etcd.GetOptions{Recursive: true, Sort: true})
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "get")
+	c.Check(resp.Node.Dir, gc.Equals, true)
+	c.Check(terminalKeys(resp.Node), gc.DeepEquals,
+		[]string{"/root/a", "/root/a-b", "/root/dir/b", "/root/dir/sub/c"})
+	c.Check(consensus.Child(resp.Node, "dir", "sub", "c").Value, gc.Equals, "5")
+
+	var a = consensus.Child(resp.Node, "a")
+	c.Check(a.CreatedIndex, gc.Equals, createdIndex)
+	c.Check(a.ModifiedIndex, gc.Equals, modifiedIndex)
+	c.Check(a.Expiration, gc.IsNil)
+
+	// A non-recursive Get omits the children of sub-directories.
+	resp, err = s.keysAPI.Get(ctx, "/root", nil)
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Node.Nodes, gc.HasLen, 3)
+	c.Check(consensus.Child(resp.Node, "dir").Dir, gc.Equals, true)
+	c.Check(consensus.Child(resp.Node, "dir").Nodes, gc.HasLen, 0)
+
+	_, err = s.keysAPI.Get(ctx, "/root/missing", nil)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeKeyNotFound)
+
+	// Compare-and-delete of the key.
+	_, err = s.keysAPI.Delete(ctx, "/root/a", &etcd.DeleteOptions{PrevIndex: createdIndex})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+
+	resp, err = s.keysAPI.Delete(ctx, "/root/a", &etcd.DeleteOptions{PrevIndex: modifiedIndex})
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "compareAndDelete")
+	c.Check(resp.PrevNode.Value, gc.Equals, "2")
+
+	_, err = s.keysAPI.Delete(ctx, "/root/a", nil)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeKeyNotFound)
+
+	// Only empty directories may be deleted non-recursively.
+	_, err = s.keysAPI.Delete(ctx, "/root/dir", &etcd.DeleteOptions{Dir: true})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeDirNotEmpty)
+
+	resp, err = s.keysAPI.Delete(ctx, "/root/dir", &etcd.DeleteOptions{Dir: true, Recursive: true})
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "delete")
+	c.Check(resp.Node.Dir, gc.Equals, true)
+	c.Check(terminalKeys(resp.PrevNode), gc.DeepEquals, []string{"/root/dir/b", "/root/dir/sub/c"})
+
+	_, err = s.keysAPI.Get(ctx, "/root/dir", nil)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeKeyNotFound)
+}
+
+func (s *KeysAPISuite) TestWatchedTreeIsConsistent(c *gc.C) {
+	var ctx = context.Background()
+
+	var _, err = s.keysAPI.Set(ctx, "/root", "", &etcd.SetOptions{Dir: true})
+	c.Assert(err, gc.IsNil)
+
+	var watcher = consensus.RetryWatcher(s.keysAPI, "/root",
+		&etcd.GetOptions{Recursive: true, Sort: true},
+		&etcd.WatcherOptions{Recursive: true}, nil)
+
+	resp, err := watcher.Next(ctx)
+	c.Assert(err, gc.IsNil)
+	var tree = resp.Node
+
+	// Apply a sequence of mutations, each of which is observed as one watch
+	// response.
+	var mutations = []func() error{
+		func() error { _, err := s.keysAPI.Create(ctx, "/root/a", "1"); return err },
+		func() error {
+			_, err := s.keysAPI.Set(ctx, "/root/b", "2", &etcd.SetOptions{TTL: time.Minute})
+			return err
+		},
+		func() error { _, err := s.keysAPI.Set(ctx, "/root/dir", "", &etcd.SetOptions{Dir: true}); return err },
+		func() error { _, err := s.keysAPI.Set(ctx, "/root/dir/x", "3", nil); return err },
+		func() error { _, err := s.keysAPI.Set(ctx, "/root/dir/y", "4", nil); return err },
+		func() error {
+			_, err := s.keysAPI.Delete(ctx, "/root/dir", &etcd.DeleteOptions{Recursive: true})
+			return err
+		},
+		func() error { _, err := s.keysAPI.Set(ctx, "/root/implicit/z", "5", nil); return err },
+		func() error { _, err := s.keysAPI.Update(ctx, "/root/a", "6"); return err },
+		func() error { _, err := s.keysAPI.Set(ctx, "/rootsibling", "7", nil); return err },
+	}
+	for _, m := range mutations {
+		c.Assert(m(), gc.IsNil)
+	}
+	for i := 0; i != len(mutations)-1; i++ {
+		resp, err = watcher.Next(ctx)
+		c.Assert(err, gc.IsNil)
+
+		tree, err = consensus.PatchTree(tree, resp)
+		c.Assert(err, gc.IsNil)
+	}
+
+	// The change of a sibling key isn't observed.
+	var timeoutCtx, cancel = context.WithTimeout(ctx, 100*time.Millisecond)
+	defer cancel()
+
+	_, err = watcher.Next(timeoutCtx)
+	c.Check(err, gc.Equals, context.DeadlineExceeded)
+
+	// Expect the incrementally patched tree matches a fresh Get.
+	resp, err = s.keysAPI.Get(ctx, "/root", &etcd.GetOptions{Recursive: true, Sort: true})
+	c.Assert(err, gc.IsNil)
+
+	c.Check(consensus.TerminalNodes(tree), gc.DeepEquals, consensus.TerminalNodes(resp.Node))
+	c.Check(terminalKeys(tree), gc.DeepEquals, []string{"/root/a", "/root/b", "/root/implicit/z"})
+}
+
+func (s *KeysAPISuite) TestWatchIsSharedAcrossNextCalls(c *gc.C) {
+	var ctx = context.Background()
+
+	var w = s.keysAPI.Watcher("/root", &etcd.WatcherOptions{Recursive: true}).(*watcher)
+	var _, err = s.keysAPI.Create(ctx, "/root/a", "1")
+	c.Assert(err, gc.IsNil)
+
+	// A Next which is cancelled leaves the v3 watch open.
+	var timeoutCtx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+
+	for {
+		if _, err = w.Next(timeoutCtx); err == context.DeadlineExceeded {
+			break
+		}
+		c.Assert(err, gc.IsNil)
+	}
+	var watchCh = w.watchCh
+	c.Check(watchCh, gc.NotNil)
+
+	// Changes made between Next calls are delivered over the same watch.
+	_, err = s.keysAPI.Create(ctx, "/root/b", "2")
+	c.Assert(err, gc.IsNil)
+	_, err = s.keysAPI.Create(ctx, "/root/c", "3")
+	c.Assert(err, gc.IsNil)
+
+	for _, key := range []string{"/root/b", "/root/c"} {
+		resp, err := w.Next(ctx)
+		c.Assert(err, gc.IsNil)
+		c.Check(resp.Node.Key, gc.Equals, key)
+	}
+	c.Check(w.watchCh, gc.Equals, watchCh)
+
+	// Closing the KeysAPI closes the watch.
+	c.Check(s.keysAPI.Close(), gc.IsNil)
+	_, err = w.Next(ctx)
+	c.Check(err, gc.Equals, context.Canceled)
+}
+
+func (s *KeysAPISuite) TestCompactedWatchIsCleared(c *gc.C) {
+	var ctx = context.Background()
+
+	var _, err = s.keysAPI.Create(ctx, "/root/a", "1")
+	c.Assert(err, gc.IsNil)
+	resp, err := s.keysAPI.Update(ctx, "/root/a", "2")
+	c.Assert(err, gc.IsNil)
+	_, err = s.keysAPI.Update(ctx, "/root/a", "3")
+	c.Assert(err, gc.IsNil)
+
+	_, err = s.client.Compact(ctx, int64(resp.Index)+1)
+	c.Assert(err, gc.IsNil)
+
+	var watcher = s.keysAPI.Watcher("/root", &etcd.WatcherOptions{AfterIndex: resp.Index - 1, Recursive: true})
+	_, err = watcher.Next(ctx)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeEventIndexCleared)
+}
+
+func (s *KeysAPISuite) TestKeysWithTTLShareLease(c *gc.C) {
+	var ctx = context.Background()
+
+	for _, key := range []string{"/root/a", "/root/b"} {
+		var _, err = s.keysAPI.Set(ctx, key, "", &etcd.SetOptions{TTL: time.Minute})
+		c.Assert(err, gc.IsNil)
+	}
+	var _, err = s.keysAPI.Set(ctx, "/root/c", "", nil)
+	c.Assert(err, gc.IsNil)
+
+	resp, err := s.client.Get(ctx, "/root/", clientv3.WithPrefix())
+	c.Assert(err, gc.IsNil)
+	c.Assert(resp.Kvs, gc.HasLen, 3)
+
+	c.Check(resp.Kvs[0].Lease, gc.Not(gc.Equals), int64(clientv3.NoLease))
+	c.Check(resp.Kvs[1].Lease, gc.Equals, resp.Kvs[0].Lease)
+	c.Check(resp.Kvs[2].Lease, gc.Equals, int64(clientv3.NoLease))
+
+	// Closing the KeysAPI revokes its lease, removing keys having a TTL.
+	c.Check(s.keysAPI.Close(), gc.IsNil)
+
+	getResp, err := s.keysAPI.Get(ctx, "/root", &etcd.GetOptions{Recursive: true})
+	c.Assert(err, gc.IsNil)
+	c.Check(terminalKeys(getResp.Node), gc.DeepEquals, []string{"/root/c"})
+}
+
+func (s *KeysAPISuite) TestRouteUpdates(c *gc.C) {
+	var ctx = context.Background()
+
+	// route returns the current Route of item "foo".
+	var route = func() consensus.Route {
+		var resp, err = s.keysAPI.Get(ctx, "/root/items/foo", &etcd.GetOptions{Recursive: true})
+		if errorCode(err) == etcd.ErrorCodeKeyNotFound {
+			return consensus.Route{Item: &etcd.Node{Key: "/root/items/foo", Dir: true}}
+		}
+		c.Assert(err, gc.IsNil)
+		return consensus.NewRoute(resp, resp.Node)
+	}
+	var empty = route()
+
+	resp, err := s.keysAPI.CreateRouteEntry(ctx, empty, "/root/items/foo/a", "1", time.Minute)
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "create")
+	c.Check(resp.Node.Key, gc.Equals, "/root/items/foo/a")
+	c.Check(resp.Node.Value, gc.Equals, "1")
+
+	// A creation from the stale Route loses the race for the open slot.
+	_, err = s.keysAPI.CreateRouteEntry(ctx, empty, "/root/items/foo/b", "", time.Minute)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+
+	var withA = route()
+	c.Check(withA.Entries, gc.HasLen, 1)
+	_, err = s.keysAPI.CreateRouteEntry(ctx, withA, "/root/items/foo/b", "", time.Minute)
+	c.Assert(err, gc.IsNil)
+
+	// Deletions from a Route which doesn't reflect "b" fail.
+	_, err = s.keysAPI.DeleteRouteEntry(ctx, withA, withA.Entries[0])
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+
+	// As do deletions from a Route in which "b" was since modified.
+	var withAB = route()
+	c.Check(withAB.Entries, gc.HasLen, 2)
+	_, err = s.keysAPI.Set(ctx, "/root/items/foo/b", "ready",
+		&etcd.SetOptions{PrevIndex: withAB.Entries[1].ModifiedIndex})
+	c.Assert(err, gc.IsNil)
+
+	_, err = s.keysAPI.DeleteRouteEntry(ctx, withAB, withAB.Entries[0])
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+
+	var current = route()
+	resp, err = s.keysAPI.DeleteRouteEntry(ctx, current, current.Entries[0])
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "compareAndDelete")
+	c.Check(resp.Node.Key, gc.Equals, "/root/items/foo/a")
+	c.Check(resp.PrevNode.Value, gc.Equals, "1")
+
+	// Creations from a Route having an entry which was since removed fail.
+	current = route()
+	_, err = s.keysAPI.Delete(ctx, "/root/items/foo/b", nil)
+	c.Assert(err, gc.IsNil)
+
+	_, err = s.keysAPI.CreateRouteEntry(ctx, current, "/root/items/foo/c", "", time.Minute)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+
+	// Directories nested within the item don't prevent updates.
+	_, err = s.keysAPI.Set(ctx, "/root/items/foo/nested/bar", "", nil)
+	c.Assert(err, gc.IsNil)
+
+	current = route()
+	c.Check(current.Entries, gc.HasLen, 1)
+	c.Check(current.Entries[0].Dir, gc.Equals, true)
+
+	_, err = s.keysAPI.CreateRouteEntry(ctx, current, "/root/items/foo/c", "", time.Minute)
+	c.Check(err, gc.IsNil)
+
+	getResp, err := s.keysAPI.Get(ctx, "/root", &etcd.GetOptions{Recursive: true})
+	c.Assert(err, gc.IsNil)
+	c.Check(terminalKeys(getResp.Node), gc.DeepEquals,
+		[]string{"/root/items/foo/c", "/root/items/foo/nested/bar"})
+}
+
+func (s *KeysAPISuite) TestAllocation(c *gc.C) {
+	var alloc = &testAllocator{
+		keysAPI: s.keysAPI,
+		items:   []string{"bar", "baz", "foo"},
+		routes:  make(map[string]consensus.Route),
+	}
+	c.Assert(consensus.Create(alloc), gc.IsNil)
+	c.Check(consensus.Create(alloc), gc.Equals, consensus.ErrAllocatorInstanceExists)
+
+	var doneCh = make(chan error)
+	go func() { doneCh <- consensus.Allocate(alloc) }()
+
+	// Expect that all items are eventually mastered by the allocator.
+	for deadline := time.Now().Add(time.Minute); true; time.Sleep(10 * time.Millisecond) {
+		if alloc.masters() == len(alloc.items) {
+			break
+		}
+		c.Assert(time.Now().Before(deadline), gc.Equals, true)
+	}
+
+	// Cancel the allocator. It releases items and exits.
+	c.Check(consensus.Cancel(alloc), gc.IsNil)
+
+	select {
+	case err := <-doneCh:
+		c.Check(err, gc.IsNil)
+	case <-time.After(time.Minute):
+		c.Fatal("Allocate didn't exit")
+	}
+
+	resp, err := s.keysAPI.Get(context.Background(), "/root", &etcd.GetOptions{Recursive: true})
+	if err == nil {
+		c.Check(terminalKeys(resp.Node), gc.HasLen, 0)
+	} else {
+		c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeKeyNotFound)
+	}
+}
+
+// testAllocator is a consensus.Allocator of fixed items, without replicas.
+type testAllocator struct {
+	keysAPI etcd.KeysAPI
+	items   []string
+
+	routes map[string]consensus.Route
+	mu     sync.Mutex
+}
+
+func (a *testAllocator) KeysAPI() etcd.KeysAPI                       { return a.keysAPI }
+func (a *testAllocator) PathRoot() string                            { return "/root" }
+func (a *testAllocator) InstanceKey() string                         { return "my-key" }
+func (a *testAllocator) Replicas() int                               { return 0 }
+func (a *testAllocator) FixedItems() []string                        { return a.items }
+func (a *testAllocator) ItemState(item string) string                { return "" }
+func (a *testAllocator) ItemIsReadyForPromotion(string, string) bool { return true }
+
+func (a *testAllocator) ItemRoute(item string, route consensus.Route, index int, tree *etcd.Node) {
+	a.mu.Lock()
+	a.routes[item] = route.Copy()
+	a.mu.Unlock()
+}
+
+// masters returns the number of items mastered by the testAllocator.
+func (a *testAllocator) masters() (n int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, route := range a.routes {
+		if route.Index(a.InstanceKey()) == 0 {
+			n++
+		}
+	}
+	return
+}
+
+// unusedURL returns a local URL having a currently unused port.
+func unusedURL(c *gc.C) url.URL {
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, gc.IsNil)
+	defer l.Close()
+
+	return url.URL{Scheme: "http", Host: l.Addr().String()}
+}
+
+func terminalKeys(node *etcd.Node) []string {
+	var keys = []string{}
+	for _, n := range consensus.TerminalNodes(node) {
+		keys = append(keys, n.Key)
+	}
+	return keys
+}
+
+func errorCode(err error) int {
+	var etcdErr, _ = err.(etcd.Error)
+	return etcdErr.Code
+}
+
+var _ etcd.KeysAPI = new(KeysAPI)
+var _ consensus.RouteUpdater = new(KeysAPI)
+
+var _ = gc.Suite(&KeysAPISuite{})
+
+func Test(t *testing.T) { gc.TestingT(t) }
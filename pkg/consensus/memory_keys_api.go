@@ -0,0 +1,261 @@
+package consensus
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	etcdErr "github.com/coreos/etcd/error"
+	"github.com/coreos/etcd/store"
+	"golang.org/x/net/context"
+)
+
+// MemoryKeysAPI is an in-memory etcd.KeysAPI, suitable for tests and for
+// single-process use. It's backed by the same store which backs the v2 API
+// of an Etcd server, and shares its semantics: keys expire after their TTL,
+// PrevExist, PrevIndex and PrevValue conditions are evaluated atomically, and
+// watchers observe ordered events (including expirations) from a bounded
+// event history.
+//
+// Keys expire on a timer armed for the earliest TTL, such that watchers
+// observe expirations without further use of the MemoryKeysAPI. Advance may
+// be used to deterministically expire keys in tests.
+type MemoryKeysAPI struct {
+	store store.Store
+
+	// Offset of the MemoryKeysAPI clock from time.Now().
+	offset time.Duration
+	// Ordered expiration times of keys set with a TTL, and a timer which
+	// fires at the earliest of them. Times of keys which were since refreshed
+	// or removed remain, and fire harmlessly.
+	expirations []time.Time
+	timer       *time.Timer
+	mu          sync.Mutex
+}
+
+func NewMemoryKeysAPI() *MemoryKeysAPI {
+	return &MemoryKeysAPI{store: store.New()}
+}
+
+// Advance the clock of the MemoryKeysAPI by |d|, expiring keys having a TTL
+// which elapses within |d|.
+func (k *MemoryKeysAPI) Advance(d time.Duration) {
+	k.mu.Lock()
+	k.offset += d
+	k.mu.Unlock()
+
+	k.expire()
+}
+
+func (k *MemoryKeysAPI) Get(ctx context.Context, key string, opts *etcd.GetOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = new(etcd.GetOptions)
+	}
+	k.expire()
+
+	return toResponse(k.store.Get(key, opts.Recursive, opts.Sort))
+}
+
+func (k *MemoryKeysAPI) Set(ctx context.Context, key, value string, opts *etcd.SetOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = new(etcd.SetOptions)
+	}
+	var now = k.expire()
+
+	var ttl = store.TTLOptionSet{Refresh: opts.Refresh}
+	if opts.TTL != 0 {
+		ttl.ExpireTime = now.Add(opts.TTL)
+		defer k.scheduleExpiry(ttl.ExpireTime)
+	}
+	if opts.Refresh {
+		// A refresh updates only the TTL of the current value.
+		if ev, err := k.store.Get(key, false, false); err != nil {
+			return toResponse(ev, err)
+		} else if ev.Node.Value != nil {
+			value = *ev.Node.Value
+		}
+	}
+
+	// Dispatch to a store operation, as does the v2 API of an Etcd server.
+	var isCompare = opts.PrevIndex != 0 || opts.PrevValue != ""
+
+	switch {
+	case opts.PrevExist == etcd.PrevNoExist:
+		return toResponse(k.store.Create(key, opts.Dir, value, false, ttl))
+	case opts.PrevExist == etcd.PrevExist && !isCompare:
+		return toResponse(k.store.Update(key, value, ttl))
+	case isCompare:
+		return toResponse(k.store.CompareAndSwap(key, opts.PrevValue, opts.PrevIndex, value, ttl))
+	default:
+		return toResponse(k.store.Set(key, opts.Dir, value, ttl))
+	}
+}
+
+func (k *MemoryKeysAPI) Delete(ctx context.Context, key string, opts *etcd.DeleteOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = new(etcd.DeleteOptions)
+	}
+	k.expire()
+
+	if opts.PrevIndex != 0 || opts.PrevValue != "" {
+		return toResponse(k.store.CompareAndDelete(key, opts.PrevValue, opts.PrevIndex))
+	}
+	return toResponse(k.store.Delete(key, opts.Dir, opts.Recursive))
+}
+
+func (k *MemoryKeysAPI) Create(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return k.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevNoExist})
+}
+
+func (k *MemoryKeysAPI) CreateInOrder(ctx context.Context, dir, value string, opts *etcd.CreateInOrderOptions) (*etcd.Response, error) {
+	if opts == nil {
+		opts = new(etcd.CreateInOrderOptions)
+	}
+	var now = k.expire()
+
+	var ttl store.TTLOptionSet
+	if opts.TTL != 0 {
+		ttl.ExpireTime = now.Add(opts.TTL)
+		defer k.scheduleExpiry(ttl.ExpireTime)
+	}
+	return toResponse(k.store.Create(dir, false, value, true, ttl))
+}
+
+func (k *MemoryKeysAPI) Update(ctx context.Context, key, value string) (*etcd.Response, error) {
+	return k.Set(ctx, key, value, &etcd.SetOptions{PrevExist: etcd.PrevExist})
+}
+
+func (k *MemoryKeysAPI) Watcher(key string, opts *etcd.WatcherOptions) etcd.Watcher {
+	if opts == nil {
+		opts = new(etcd.WatcherOptions)
+	}
+	return &memoryWatcher{
+		keysAPI:    k,
+		key:        key,
+		recursive:  opts.Recursive,
+		afterIndex: opts.AfterIndex,
+	}
+}
+
+// expire keys having elapsed TTLs, and return the current time of the
+// MemoryKeysAPI clock.
+func (k *MemoryKeysAPI) expire() time.Time {
+	k.mu.Lock()
+	var now = time.Now().Add(k.offset)
+
+	var n = sort.Search(len(k.expirations), func(i int) bool {
+		return k.expirations[i].After(now)
+	})
+	if n != 0 {
+		k.expirations = k.expirations[n:]
+		k.armExpiryTimer(now)
+	}
+	k.mu.Unlock()
+
+	k.store.DeleteExpiredKeys(now)
+	return now
+}
+
+// scheduleExpiry arranges for keys to be expired at |at|.
+func (k *MemoryKeysAPI) scheduleExpiry(at time.Time) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	var ind = sort.Search(len(k.expirations), func(i int) bool {
+		return k.expirations[i].After(at)
+	})
+	k.expirations = append(k.expirations, time.Time{})
+	copy(k.expirations[ind+1:], k.expirations[ind:])
+	k.expirations[ind] = at
+
+	if ind == 0 {
+		k.armExpiryTimer(time.Now().Add(k.offset))
+	}
+}
+
+// armExpiryTimer (re)arms the timer to fire at the earliest expiration,
+// given the current time |now| of the MemoryKeysAPI clock. |mu| must be held.
+func (k *MemoryKeysAPI) armExpiryTimer(now time.Time) {
+	if k.timer != nil {
+		k.timer.Stop()
+		k.timer = nil
+	}
+	if len(k.expirations) != 0 {
+		k.timer = time.AfterFunc(k.expirations[0].Sub(now), func() { k.expire() })
+	}
+}
+
+// memoryWatcher is an etcd.Watcher of a MemoryKeysAPI. Like the etcd v2
+// client, each Next call waits for the next event having an index greater
+// than that of the last returned event.
+type memoryWatcher struct {
+	keysAPI    *MemoryKeysAPI
+	key        string
+	recursive  bool
+	afterIndex uint64
+}
+
+func (w *memoryWatcher) Next(ctx context.Context) (*etcd.Response, error) {
+	w.keysAPI.expire()
+
+	var sinceIndex uint64
+	if w.afterIndex != 0 {
+		sinceIndex = w.afterIndex + 1
+	}
+	var watcher, err = w.keysAPI.store.Watch(w.key, w.recursive, false, sinceIndex)
+	if err != nil {
+		return toResponse(nil, err)
+	}
+
+	select {
+	case ev, ok := <-watcher.EventChan():
+		if !ok {
+			// The watcher missed an event, and was removed.
+			return nil, etcd.Error{Code: etcd.ErrorCodeWatcherCleared, Message: "watcher is cleared", Cause: w.key}
+		}
+		w.afterIndex = ev.Index()
+		return toResponse(ev, nil)
+	case <-ctx.Done():
+		watcher.Remove()
+		return nil, ctx.Err()
+	}
+}
+
+// toResponse maps a store Event and error into an etcd.Response and error,
+// as they would be returned by the etcd v2 client.
+func toResponse(ev *store.Event, err error) (*etcd.Response, error) {
+	if err != nil {
+		if e, ok := err.(*etcdErr.Error); ok {
+			return nil, etcd.Error{Code: e.ErrorCode, Message: e.Message, Cause: e.Cause, Index: e.Index}
+		}
+		return nil, err
+	}
+	return &etcd.Response{
+		Action:   ev.Action,
+		Node:     toNode(ev.Node),
+		PrevNode: toNode(ev.PrevNode),
+		Index:    ev.EtcdIndex,
+	}, nil
+}
+
+func toNode(n *store.NodeExtern) *etcd.Node {
+	if n == nil {
+		return nil
+	}
+	var node = &etcd.Node{
+		Key:           n.Key,
+		Dir:           n.Dir,
+		Expiration:    n.Expiration,
+		TTL:           n.TTL,
+		CreatedIndex:  n.CreatedIndex,
+		ModifiedIndex: n.ModifiedIndex,
+	}
+	if n.Value != nil {
+		node.Value = *n.Value
+	}
+	for _, child := range n.Nodes {
+		node.Nodes = append(node.Nodes, toNode(child))
+	}
+	return node
+}
@@ -0,0 +1,177 @@
+package consensus
+
+import (
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+	"golang.org/x/net/context"
+)
+
+type MemoryKeysAPISuite struct{}
+
+func (s *MemoryKeysAPISuite) TestCompareAndSwap(c *gc.C) {
+	var keys = NewMemoryKeysAPI()
+	var ctx = context.Background()
+
+	// Create fails if the key exists.
+	resp, err := keys.Create(ctx, "/foo/bar", "one")
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "create")
+	c.Check(resp.Node.Value, gc.Equals, "one")
+
+	_, err = keys.Create(ctx, "/foo/bar", "two")
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeNodeExist)
+
+	// Update fails if the key doesn't exist.
+	_, err = keys.Update(ctx, "/foo/missing", "two")
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeKeyNotFound)
+
+	// PrevIndex and PrevValue must match the current node.
+	_, err = keys.Set(ctx, "/foo/bar", "two", &etcd.SetOptions{PrevIndex: resp.Index + 1})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+	_, err = keys.Set(ctx, "/foo/bar", "two", &etcd.SetOptions{PrevValue: "other"})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+
+	resp, err = keys.Set(ctx, "/foo/bar", "two", &etcd.SetOptions{
+		PrevIndex: resp.Index, PrevValue: "one", PrevExist: etcd.PrevExist})
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "compareAndSwap")
+	c.Check(resp.PrevNode.Value, gc.Equals, "one")
+
+	// Non-empty directories may be deleted only recursively.
+	_, err = keys.Delete(ctx, "/foo", &etcd.DeleteOptions{Dir: true})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeDirNotEmpty)
+
+	_, err = keys.Delete(ctx, "/foo/bar", &etcd.DeleteOptions{PrevValue: "one"})
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeTestFailed)
+	resp, err = keys.Delete(ctx, "/foo/bar", &etcd.DeleteOptions{PrevValue: "two"})
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "compareAndDelete")
+
+	_, err = keys.Get(ctx, "/foo/bar", nil)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeKeyNotFound)
+}
+
+func (s *MemoryKeysAPISuite) TestTTLExpiry(c *gc.C) {
+	var keys = NewMemoryKeysAPI()
+	var ctx = context.Background()
+
+	_, err := keys.Set(ctx, "/lock", "me", &etcd.SetOptions{TTL: time.Minute})
+	c.Assert(err, gc.IsNil)
+
+	keys.Advance(30 * time.Second)
+
+	resp, err := keys.Get(ctx, "/lock", nil)
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Node.Expiration, gc.NotNil)
+
+	// Refresh the TTL of the key.
+	_, err = keys.Set(ctx, "/lock", "", &etcd.SetOptions{
+		TTL: time.Minute, Refresh: true, PrevExist: etcd.PrevExist})
+	c.Assert(err, gc.IsNil)
+
+	keys.Advance(45 * time.Second)
+
+	resp, err = keys.Get(ctx, "/lock", nil)
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Node.Value, gc.Equals, "me")
+
+	keys.Advance(30 * time.Second)
+
+	_, err = keys.Get(ctx, "/lock", nil)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeKeyNotFound)
+}
+
+func (s *MemoryKeysAPISuite) TestKeysExpireWithoutFurtherUse(c *gc.C) {
+	var keys = NewMemoryKeysAPI()
+	var ctx = context.Background()
+
+	resp, err := keys.Set(ctx, "/lock", "me", &etcd.SetOptions{TTL: 50 * time.Millisecond})
+	c.Assert(err, gc.IsNil)
+	_, err = keys.Set(ctx, "/other-lock", "me", &etcd.SetOptions{TTL: time.Hour})
+	c.Assert(err, gc.IsNil)
+
+	var watcher = keys.Watcher("/lock", &etcd.WatcherOptions{AfterIndex: resp.Index})
+	var timeoutCtx, cancel = context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	// Expect the watcher observes the expiration as its TTL elapses.
+	resp, err = watcher.Next(timeoutCtx)
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "expire")
+	c.Check(resp.Node.Key, gc.Equals, "/lock")
+
+	// The timer is re-armed for the next expiration.
+	keys.mu.Lock()
+	c.Check(keys.expirations, gc.HasLen, 1)
+	c.Check(keys.timer, gc.NotNil)
+	keys.mu.Unlock()
+}
+
+func (s *MemoryKeysAPISuite) TestWatchEventsAreOrdered(c *gc.C) {
+	var keys = NewMemoryKeysAPI()
+	var ctx = context.Background()
+
+	resp, err := keys.Set(ctx, "/root/a", "1", nil)
+	c.Assert(err, gc.IsNil)
+
+	var watcher = keys.Watcher("/root", &etcd.WatcherOptions{
+		AfterIndex: resp.Index, Recursive: true})
+
+	// Events which occur prior to Next are retained, and delivered in order.
+	keys.Set(ctx, "/root/b", "2", &etcd.SetOptions{TTL: time.Second})
+	keys.Set(ctx, "/root/a", "3", nil)
+	keys.Set(ctx, "/other", "4", nil)
+	keys.Advance(time.Minute)
+
+	for _, expect := range []struct{ action, key string }{
+		{"set", "/root/b"},
+		{"set", "/root/a"},
+		{"expire", "/root/b"},
+	} {
+		resp, err = watcher.Next(ctx)
+		c.Assert(err, gc.IsNil)
+		c.Check(resp.Action, gc.Equals, expect.action)
+		c.Check(resp.Node.Key, gc.Equals, expect.key)
+	}
+
+	// Next blocks until an event occurs.
+	go keys.Delete(ctx, "/root/a", nil)
+
+	resp, err = watcher.Next(ctx)
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Action, gc.Equals, "delete")
+	c.Check(resp.PrevNode.Value, gc.Equals, "3")
+
+	// Or until the context is cancelled.
+	var cancelCtx, cancel = context.WithCancel(ctx)
+	cancel()
+
+	_, err = watcher.Next(cancelCtx)
+	c.Check(err, gc.Equals, context.Canceled)
+}
+
+func (s *MemoryKeysAPISuite) TestWatchOfClearedIndex(c *gc.C) {
+	var keys = NewMemoryKeysAPI()
+	var ctx = context.Background()
+
+	// Overflow the store's bounded event history.
+	for i := 0; i != 2000; i++ {
+		_, err := keys.Set(ctx, "/key", "value", nil)
+		c.Assert(err, gc.IsNil)
+	}
+
+	var watcher = keys.Watcher("/key", &etcd.WatcherOptions{AfterIndex: 1})
+	_, err := watcher.Next(ctx)
+	c.Check(errorCode(err), gc.Equals, etcd.ErrorCodeEventIndexCleared)
+}
+
+func errorCode(err error) int {
+	if e, ok := err.(etcd.Error); ok {
+		return e.Code
+	}
+	return 0
+}
+
+var _ = gc.Suite(&MemoryKeysAPISuite{})
@@ -41,8 +41,13 @@ func (rt Route) Index(name string) int {
 
 // IsReadyForHandoff returns whether all replicas are ready for the item
 // master to hand off item responsibility, without causing a violation of the
-// required replica count.
-func (rt Route) IsReadyForHandoff(alloc Allocator) bool {
+// required replica count. Where members of |tree| announce zones (see Zoner),
+// and the master holds its member lock, hand-off must also not increase the
+// number of replicas which share a zone, as would occur were an extra entry
+// promoted into the replica set. A master which has lost its member lock (eg,
+// because it's shutting down) must hand off regardless, and isn't held to
+// this constraint.
+func (rt Route) IsReadyForHandoff(alloc Allocator, tree *etcd.Node) bool {
 	if wanted := alloc.Replicas() + 1; len(rt.Entries) < wanted {
 		return false
 	} else {
@@ -53,8 +58,60 @@ func (rt Route) IsReadyForHandoff(alloc Allocator) bool {
 				return false
 			}
 		}
-		return true
+
+		if Child(tree, MemberPrefix, rt.instanceKey(0)) == nil {
+			return true
+		}
+		// Compare the current master & replicas with those following hand-off.
+		next := wanted + 1
+		if next > len(rt.Entries) {
+			next = len(rt.Entries)
+		}
+		return rt.sharedZones(tree, 1, next) <= rt.sharedZones(tree, 0, wanted)
+	}
+}
+
+// hasZone returns whether an entry of the Route is held by a member of |tree|
+// in |zone|.
+func (rt Route) hasZone(tree *etcd.Node, zone string) bool {
+	for ind := range rt.Entries {
+		if rt.zoneOf(tree, ind) == zone {
+			return true
+		}
+	}
+	return false
+}
+
+// sharedZones returns the number of Entries in [begin, end) which are held
+// by a member of |tree| in the same zone as a preceding entry of the range.
+// Entries of unknown zone are not counted.
+func (rt Route) sharedZones(tree *etcd.Node, begin, end int) int {
+	var seen = make(map[string]struct{})
+	var count int
+
+	for ind := begin; ind < end; ind++ {
+		var zone = rt.zoneOf(tree, ind)
+
+		if zone == "" {
+			continue
+		} else if _, ok := seen[zone]; ok {
+			count++
+		} else {
+			seen[zone] = struct{}{}
+		}
 	}
+	return count
+}
+
+// instanceKey returns the member instance key which holds entry |ind|.
+func (rt Route) instanceKey(ind int) string {
+	return rt.Entries[ind].Key[len(rt.Item.Key)+1:]
+}
+
+// zoneOf returns the zone announced within |tree| by the member holding
+// entry |ind|, or empty if unknown.
+func (rt Route) zoneOf(tree *etcd.Node, ind int) string {
+	return memberStateOf(tree, rt.instanceKey(ind)).Zone
 }
 
 // Copy performs a deep-copy of Route.
@@ -30,22 +30,96 @@ func (s *RouteSuite) TestReadyForHandoff(c *gc.C) {
 
 	// No replicas required: always ready for handoff.
 	alloc.On("Replicas").Return(0).Once()
-	c.Check(rt.IsReadyForHandoff(alloc), gc.Equals, true)
+	c.Check(rt.IsReadyForHandoff(alloc, nil), gc.Equals, true)
 
 	// More replicas than entries: not ready.
 	alloc.On("Replicas").Return(100).Once()
-	c.Check(rt.IsReadyForHandoff(alloc), gc.Equals, false)
+	c.Check(rt.IsReadyForHandoff(alloc, nil), gc.Equals, false)
 
 	alloc.On("ItemIsReadyForPromotion", "bar", "ready").Return(true)
 	alloc.On("ItemIsReadyForPromotion", "bar", "not-ready").Return(false)
 
 	// Sufficient entries, but one is not ready.
 	alloc.On("Replicas").Return(2).Once()
-	c.Check(rt.IsReadyForHandoff(alloc), gc.Equals, false)
+	c.Check(rt.IsReadyForHandoff(alloc, nil), gc.Equals, false)
 
 	// Required number of replicas are ready.
 	alloc.On("Replicas").Return(1).Once()
-	c.Check(rt.IsReadyForHandoff(alloc), gc.Equals, true)
+	c.Check(rt.IsReadyForHandoff(alloc, nil), gc.Equals, true)
+}
+
+func (s *RouteSuite) TestReadyForHandoffWithZones(c *gc.C) {
+	rt := s.fixture()
+	alloc := &MockAllocator{}
+	alloc.On("ItemIsReadyForPromotion", "bar", "ready").Return(true)
+
+	tree := buildTree(c, []etcd.Node{
+		{Key: "/foo/members/aaa", Value: `{"zone":"zone-b"}`},
+		{Key: "/foo/members/bbb", Value: `{"zone":"zone-b"}`},
+		{Key: "/foo/members/ccc", Value: `{"zone":"zone-a"}`},
+	}).Nodes[0]
+
+	// Master "ccc" is in zone-a, and replica "aaa" in zone-b. Were "ccc" to
+	// hand off, extra entry "bbb" would become a replica sharing zone-b.
+	alloc.On("Replicas").Return(1).Once()
+	c.Check(rt.IsReadyForHandoff(alloc, tree), gc.Equals, false)
+
+	// If "bbb" is of another zone, hand-off may proceed.
+	Child(tree, MemberPrefix, "bbb").Value = `{"zone":"zone-c"}`
+	alloc.On("Replicas").Return(1).Once()
+	c.Check(rt.IsReadyForHandoff(alloc, tree), gc.Equals, true)
+
+	// As it may if "bbb" is of an unknown zone.
+	Child(tree, MemberPrefix, "bbb").Value = ""
+	alloc.On("Replicas").Return(1).Once()
+	c.Check(rt.IsReadyForHandoff(alloc, tree), gc.Equals, true)
+
+	// Hand-off may also proceed if the master already shares a zone with
+	// its replica.
+	Child(tree, MemberPrefix, "bbb").Value = `{"zone":"zone-b"}`
+	Child(tree, MemberPrefix, "ccc").Value = `{"zone":"zone-b"}`
+	alloc.On("Replicas").Return(1).Once()
+	c.Check(rt.IsReadyForHandoff(alloc, tree), gc.Equals, true)
+
+}
+
+func (s *RouteSuite) TestReadyForHandoffWithExtraInReplicaZone(c *gc.C) {
+	item := &etcd.Node{
+		Key: "/foo/bar",
+		Nodes: []*etcd.Node{
+			{Key: "/foo/bar/master", CreatedIndex: 1},
+			{Key: "/foo/bar/replica-one", Value: "ready", CreatedIndex: 2},
+			{Key: "/foo/bar/replica-two", Value: "ready", CreatedIndex: 3},
+			{Key: "/foo/bar/extra", Value: "ready", CreatedIndex: 4},
+		},
+	}
+	rt := NewRoute(nil, item)
+
+	alloc := &MockAllocator{}
+	alloc.On("Replicas").Return(2)
+	alloc.On("ItemIsReadyForPromotion", "bar", "ready").Return(true)
+
+	// Master is in zone-1, with replicas in zone-2 and zone-3, and an extra
+	// entry in zone-2.
+	var members = []etcd.Node{
+		{Key: "/foo/members/extra", Value: `{"zone":"zone-2"}`},
+		{Key: "/foo/members/master", Value: `{"zone":"zone-1"}`},
+		{Key: "/foo/members/replica-one", Value: `{"zone":"zone-2"}`},
+		{Key: "/foo/members/replica-two", Value: `{"zone":"zone-3"}`},
+	}
+	// While the master holds its member lock, it doesn't hand off to a
+	// replica set which would place two replicas in zone-2.
+	tree := buildTree(c, members).Nodes[0]
+	c.Check(rt.IsReadyForHandoff(alloc, tree), gc.Equals, false)
+
+	// Once the extra entry is released, hand-off may proceed.
+	rt = NewRoute(nil, &etcd.Node{Key: item.Key, Nodes: item.Nodes[:3]})
+	c.Check(rt.IsReadyForHandoff(alloc, tree), gc.Equals, true)
+
+	// A master without a member lock hands off despite the extra entry.
+	rt = NewRoute(nil, item)
+	tree = buildTree(c, append(members[:1:1], members[2:]...)).Nodes[0]
+	c.Check(rt.IsReadyForHandoff(alloc, tree), gc.Equals, true)
 }
 
 func (s *RouteSuite) TestCopy(c *gc.C) {
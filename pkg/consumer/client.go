@@ -2,12 +2,14 @@ package consumer
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"sync"
 	"time"
 
 	log "github.com/sirupsen/logrus"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 
 	"github.com/LiveRamp/gazette/pkg/journal"
 	"github.com/LiveRamp/gazette/pkg/keepalive"
@@ -18,6 +20,8 @@ import (
 // snapshot of Consumer topology for selecting appropriate endpoints.
 type Client struct {
 	service *grpc.ClientConn
+	// Transport security option used to dial Consumer endpoints.
+	security grpc.DialOption
 
 	// Drives periodic polls of the consumer's state.
 	refreshTicker *time.Ticker
@@ -59,17 +63,29 @@ var (
 )
 
 func NewClient(endpoint string) (*Client, error) {
+	return newClient(endpoint, grpc.WithInsecure())
+}
+
+// NewClientWithTLS returns a Client which dials Consumer endpoints using TLS
+// |config|, presenting its client certificate (if any) to Consumers which
+// require mutual authentication.
+func NewClientWithTLS(endpoint string, config *tls.Config) (*Client, error) {
+	return newClient(endpoint, grpc.WithTransportCredentials(credentials.NewTLS(config)))
+}
+
+func newClient(endpoint string, security grpc.DialOption) (*Client, error) {
 	var conn, err = grpc.Dial(endpoint,
 		grpc.WithBlock(),
 		grpc.WithDialer(keepalive.DialerFunc),
-		grpc.WithInsecure())
+		security)
 
 	if err != nil {
 		return nil, err
 	}
 
 	var cc = &Client{
-		service: conn,
+		service:  conn,
+		security: security,
 
 		refreshTicker: time.NewTicker(shardClientCachePollInterval),
 		signalCh:      make(chan clientSignal),
@@ -183,7 +199,7 @@ func (c *Client) update() error {
 			conns[ep] = conn
 		} else if conn, err = grpc.Dial(ep,
 			grpc.WithDialer(keepalive.DialerFunc),
-			grpc.WithInsecure(),
+			c.security,
 		); err != nil {
 			// Without grpc.WithBlock, Dial should return immediately, with
 			// connections attempted in the background. We fatal rather than return
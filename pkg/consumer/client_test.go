@@ -1,12 +1,18 @@
 package consumer
 
 import (
+	"context"
+	"io/ioutil"
 	"net"
+	"os"
 	"sort"
 
 	gc "github.com/go-check/check"
 	"github.com/stretchr/testify/mock"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/LiveRamp/gazette/pkg/tlsconfig"
 )
 
 type ClientSuite struct{}
@@ -85,8 +91,44 @@ type mockConsumerServer struct {
 	addr string
 }
 
-func buildMockServer(c *gc.C) mockConsumerServer {
-	var srv = grpc.NewServer()
+func (s *ClientSuite) TestClientWithMutualTLS(c *gc.C) {
+	var dir, err = ioutil.TempDir("", "client-suite")
+	c.Assert(err, gc.IsNil)
+	defer os.RemoveAll(dir)
+
+	files, err := tlsconfig.WriteTestCertificates(dir)
+	c.Assert(err, gc.IsNil)
+	serverConfig, err := tlsconfig.Load(files.ServerCert, files.ServerKey, files.CA)
+	c.Assert(err, gc.IsNil)
+	clientConfig, err := tlsconfig.Load(files.ClientCert, files.ClientKey, files.CA)
+	c.Assert(err, gc.IsNil)
+
+	var creds = grpc.Creds(credentials.NewTLS(serverConfig))
+	var s0, s1, s2 = buildMockServer(c, creds), buildMockServer(c, creds), buildMockServer(c, creds)
+	defer s0.srv.GracefulStop()
+	defer s1.srv.GracefulStop()
+	defer s2.srv.GracefulStop()
+
+	s0.mock.On("CurrentConsumerState", mock.Anything, &Empty{}).Return(
+		buildConsumerStateFixture(s0.addr, s1.addr, s2.addr), nil).Once()
+
+	client, err := NewClientWithTLS(s0.addr, clientConfig)
+	c.Assert(err, gc.IsNil)
+	defer client.Close()
+
+	c.Check(client.State(), gc.DeepEquals, *buildConsumerStateFixture(s0.addr, s1.addr, s2.addr))
+
+	// Connections to Consumer endpoints also use TLS.
+	conn, _, err := client.PartitionClient("partition/zero")
+	c.Assert(err, gc.IsNil)
+
+	s1.mock.On("CurrentConsumerState", mock.Anything, &Empty{}).Return(&ConsumerState{}, nil).Once()
+	_, err = NewConsumerClient(conn).CurrentConsumerState(context.Background(), &Empty{})
+	c.Check(err, gc.IsNil)
+}
+
+func buildMockServer(c *gc.C, opts ...grpc.ServerOption) mockConsumerServer {
+	var srv = grpc.NewServer(opts...)
 	var m = new(MockConsumerServer)
 
 	RegisterConsumerServer(srv, m)
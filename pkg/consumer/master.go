@@ -12,6 +12,7 @@ import (
 	log "github.com/sirupsen/logrus"
 	rocks "github.com/tecbot/gorocksdb"
 
+	"github.com/LiveRamp/gazette/pkg/consensus"
 	"github.com/LiveRamp/gazette/pkg/journal"
 	"github.com/LiveRamp/gazette/pkg/metrics"
 	"github.com/LiveRamp/gazette/pkg/recoverylog"
@@ -83,6 +84,9 @@ type master struct {
 
 	database *database
 	cache    interface{}
+
+	// Rate of seconds spent in consumer transactions.
+	txRate consensus.DecayingRate
 }
 
 func newMaster(shard *shard, tree *etcd.Node) (*master, error) {
@@ -406,6 +410,7 @@ func (m *master) consumerLoop(runner *Runner, source <-chan topic.Envelope) erro
 			metrics.GazetteConsumerTxStalledSecondsTotal.Add((txDuration - *maxConsumeQuantum).Seconds())
 		}
 		metrics.GazetteConsumerTxSecondsTotal.Add(txDuration.Seconds())
+		m.txRate.Add(txDuration.Seconds(), time.Now())
 
 		metrics.GazetteConsumerTxMessagesTotal.Add(float64(txMessages))
 		metrics.GazetteConsumerTxCountTotal.Inc()
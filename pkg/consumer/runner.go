@@ -3,6 +3,7 @@ package consumer
 import (
 	"path"
 	"sort"
+	"time"
 
 	etcd "github.com/coreos/etcd/client"
 	log "github.com/sirupsen/logrus"
@@ -38,9 +39,16 @@ type Runner struct {
 	RecoveryLogRoot string
 	// Required number of replicas of the consumer.
 	ReplicaCount int
+	// Optional failure domain of this runner (eg, an availability zone or
+	// rack). Replicas of shards are spread across the zones of runners.
+	LocalZone string
 
 	Etcd    etcd.Client
 	Gazette journal.Client
+	// Optional KeysAPI through which the Runner coordinates, which is used in
+	// place of a KeysAPI of |Etcd| (eg, an Etcd v3 KeysAPI of package
+	// consensus/etcdv3).
+	EtcdKeysAPI etcd.KeysAPI
 
 	// Optional hooks for notification of Shard lifecycle. These are largely
 	// intended to facilitate testing cases.
@@ -177,10 +185,17 @@ func (r *Runner) FixedItems() []string {
 	r.updateShards()
 	return r.shardNames
 }
-func (r *Runner) InstanceKey() string   { return r.LocalRouteKey }
-func (r *Runner) KeysAPI() etcd.KeysAPI { return etcd.NewKeysAPI(r.Etcd) }
-func (r *Runner) PathRoot() string      { return r.ConsumerRoot }
-func (r *Runner) Replicas() int         { return r.ReplicaCount }
+func (r *Runner) InstanceKey() string { return r.LocalRouteKey }
+func (r *Runner) PathRoot() string    { return r.ConsumerRoot }
+func (r *Runner) Replicas() int       { return r.ReplicaCount }
+func (r *Runner) Zone() string        { return r.LocalZone }
+
+func (r *Runner) KeysAPI() etcd.KeysAPI {
+	if r.EtcdKeysAPI != nil {
+		return r.EtcdKeysAPI
+	}
+	return etcd.NewKeysAPI(r.Etcd)
+}
 
 func (r *Runner) ItemState(name string) string {
 	if shard, ok := r.liveShards[ShardID(name)]; !ok {
@@ -198,6 +213,15 @@ func (r *Runner) ItemIsReadyForPromotion(item, state string) bool {
 	return state == Ready
 }
 
+// ItemWeight is the recent fraction of time which the master of shard |name|
+// spends in consumer transactions, which balances shards by their load.
+func (r *Runner) ItemWeight(name string) float64 {
+	if shard, ok := r.liveShards[ShardID(name)]; ok && shard.master != nil {
+		return shard.master.txRate.Rate(time.Now())
+	}
+	return 0
+}
+
 func (r *Runner) ItemRoute(name string, rt consensus.Route, index int, tree *etcd.Node) {
 	var id = ShardID(name)
 	var current, exists = r.liveShards[id]
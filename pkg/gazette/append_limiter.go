@@ -0,0 +1,234 @@
+package gazette
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"path"
+	"sync"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+// AppendQuota limits the rate of appends to journals having a name prefix.
+type AppendQuota struct {
+	// Prefix of journal names to which the quota applies. As with ACLRules, the
+	// prefix matches only on path segment boundaries. The empty prefix applies
+	// to all journals.
+	Prefix journal.Name `json:"prefix"`
+	// Sustained rate of appended bytes per second, or zero if unlimited.
+	BytesPerSecond float64 `json:"bytesPerSecond"`
+	// Sustained rate of appends per second, or zero if unlimited.
+	AppendsPerSecond float64 `json:"appendsPerSecond"`
+	// If true, the quota applies to each journal of the prefix individually.
+	// Otherwise the journals of the prefix share the quota.
+	PerJournal bool `json:"perJournal"`
+}
+
+// AppendLimiter enforces AppendQuotas of journals, using token buckets which
+// permit bursts of up to one second of the quota rate. Every quota matching
+// a journal applies to its appends. AppendQuotas are stored in Etcd under
+// QuotasPrefix, and are watched for changes which take effect immediately
+// (and reset the state of all buckets).
+type AppendLimiter struct {
+	keysAPI etcd.KeysAPI
+
+	quotas []AppendQuota
+	// Buckets of |quotas|, keyed on quota index and the limited journal (for
+	// a PerJournal quota) or prefix.
+	buckets map[quotaBucketKey]*quotaBuckets
+	cancel  context.CancelFunc
+	mu      sync.Mutex
+
+	// Test support: allow time.Now() to be swapped out.
+	timeNow func() time.Time
+}
+
+type quotaBucketKey struct {
+	quota int
+	name  journal.Name
+}
+
+// quotaBuckets are token buckets of appends and bytes of an AppendQuota.
+type quotaBuckets struct {
+	appends, bytes tokenBucket
+}
+
+// tokenBucket accrues |rate| tokens per second, up to a capacity of one
+// second of accrual (or of one token, if larger). Tokens may be debited past
+// zero, putting the bucket into debt until accruals repay it.
+type tokenBucket struct {
+	rate   float64
+	tokens float64
+	last   time.Time
+}
+
+func NewAppendLimiter(keysAPI etcd.KeysAPI) *AppendLimiter {
+	return &AppendLimiter{
+		keysAPI: keysAPI,
+		buckets: make(map[quotaBucketKey]*quotaBuckets),
+		timeNow: time.Now,
+	}
+}
+
+// StartWatching loads current AppendQuotas and begins watching for changes.
+// It returns an error if AppendQuotas could not be loaded.
+func (l *AppendLimiter) StartWatching() error {
+	var cancel, err = watchTree(l.keysAPI, path.Join(ServiceRoot, QuotasPrefix), l.update)
+	if err != nil {
+		return err
+	}
+	l.mu.Lock()
+	l.cancel = cancel
+	l.mu.Unlock()
+
+	return nil
+}
+
+// Stop watching for changes of AppendQuotas.
+func (l *AppendLimiter) Stop() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.cancel != nil {
+		l.cancel()
+	}
+}
+
+// Admit debits an append of journal |name| from the AppendQuotas which apply
+// to it, and returns zero. If any quota is exhausted, nothing is debited and
+// Admit instead returns the duration after which the append may be retried.
+func (l *AppendLimiter) Admit(name journal.Name) time.Duration {
+	var now = l.timeNow()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var matched []*quotaBuckets
+	var retryAfter time.Duration
+
+	for i := range l.quotas {
+		var b = l.bucketsOf(i, name, now)
+		if b == nil {
+			continue
+		}
+		matched = append(matched, b)
+
+		// An append requires a whole token, and is admitted for any number of
+		// bytes so long as the bytes bucket is not in debt.
+		if d := b.appends.delay(1); d > retryAfter {
+			retryAfter = d
+		}
+		if d := b.bytes.delay(0); d > retryAfter {
+			retryAfter = d
+		}
+	}
+	if retryAfter != 0 {
+		return retryAfter
+	}
+	for _, b := range matched {
+		b.appends.debit(1)
+	}
+	return 0
+}
+
+// Charge debits |bytes| appended to journal |name| from the AppendQuotas
+// which apply to it.
+func (l *AppendLimiter) Charge(name journal.Name, bytes int64) {
+	var now = l.timeNow()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for i := range l.quotas {
+		if b := l.bucketsOf(i, name, now); b != nil {
+			b.bytes.debit(float64(bytes))
+		}
+	}
+}
+
+// bucketsOf returns the refilled quotaBuckets of quota index |i| for journal
+// |name|, or nil if the quota doesn't apply to |name|. l.mu must be held.
+func (l *AppendLimiter) bucketsOf(i int, name journal.Name, now time.Time) *quotaBuckets {
+	var quota = l.quotas[i]
+	if !hasPathPrefix(string(name), string(quota.Prefix)) {
+		return nil
+	}
+
+	var key = quotaBucketKey{quota: i, name: quota.Prefix}
+	if quota.PerJournal {
+		key.name = name
+	}
+	var b, ok = l.buckets[key]
+	if !ok {
+		b = &quotaBuckets{
+			appends: newTokenBucket(quota.AppendsPerSecond, now),
+			bytes:   newTokenBucket(quota.BytesPerSecond, now),
+		}
+		l.buckets[key] = b
+	}
+	b.appends.refill(now)
+	b.bytes.refill(now)
+	return b
+}
+
+// update replaces AppendQuotas with those decoded from |tree|.
+func (l *AppendLimiter) update(tree *etcd.Node) {
+	var quotas []AppendQuota
+
+	for _, node := range tree.Nodes {
+		var quota AppendQuota
+
+		if node.Dir {
+			continue
+		} else if err := json.Unmarshal([]byte(node.Value), &quota); err != nil {
+			log.WithFields(log.Fields{"err": err, "key": node.Key}).
+				Warn("failed to decode append quota")
+			continue
+		}
+		quotas = append(quotas, quota)
+	}
+
+	l.mu.Lock()
+	l.quotas = quotas
+	l.buckets = make(map[quotaBucketKey]*quotaBuckets)
+	l.mu.Unlock()
+}
+
+// newTokenBucket returns a full tokenBucket of |rate|. A zero |rate| is
+// unlimited.
+func newTokenBucket(rate float64, now time.Time) tokenBucket {
+	var b = tokenBucket{rate: rate, last: now}
+	b.tokens = b.capacity()
+	return b
+}
+
+func (b *tokenBucket) capacity() float64 {
+	return math.Max(b.rate, 1)
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	if b.rate == 0 {
+		return
+	}
+	b.tokens = math.Min(b.tokens+now.Sub(b.last).Seconds()*b.rate, b.capacity())
+	b.last = now
+}
+
+// delay returns the duration until the bucket holds at least |n| tokens.
+func (b *tokenBucket) delay(n float64) time.Duration {
+	if b.rate == 0 || b.tokens >= n {
+		return 0
+	}
+	return time.Duration(math.Ceil((n - b.tokens) / b.rate * float64(time.Second)))
+}
+
+func (b *tokenBucket) debit(n float64) {
+	if b.rate != 0 {
+		b.tokens -= n
+	}
+}
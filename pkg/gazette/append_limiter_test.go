@@ -0,0 +1,117 @@
+package gazette
+
+import (
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+)
+
+type AppendLimiterSuite struct {
+	limiter *AppendLimiter
+	now     time.Time
+}
+
+func (s *AppendLimiterSuite) SetUpTest(c *gc.C) {
+	s.limiter = NewAppendLimiter(nil)
+	s.now = time.Unix(1500000000, 0)
+	s.limiter.timeNow = func() time.Time { return s.now }
+}
+
+func (s *AppendLimiterSuite) TestAppendsQuota(c *gc.C) {
+	s.setQuotas(`{"prefix": "a/", "appendsPerSecond": 2}`)
+
+	// A burst of up to one second of appends is admitted.
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, 500*time.Millisecond)
+
+	// Journals not matching the quota are unlimited.
+	c.Check(s.limiter.Admit("b/journal"), gc.Equals, time.Duration(0))
+
+	s.now = s.now.Add(250 * time.Millisecond)
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, 250*time.Millisecond)
+	s.now = s.now.Add(250 * time.Millisecond)
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, 500*time.Millisecond)
+
+	// Accruals are capped to the burst capacity.
+	s.now = s.now.Add(time.Hour)
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, 500*time.Millisecond)
+}
+
+func (s *AppendLimiterSuite) TestBytesQuota(c *gc.C) {
+	s.setQuotas(`{"prefix": "a/", "bytesPerSecond": 100}`)
+
+	// An append is admitted while the quota isn't in debt, regardless of size.
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+	s.limiter.Charge("a/journal", 250)
+
+	// The append overdrew the quota, which must be repaid.
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, 1500*time.Millisecond)
+	s.now = s.now.Add(1500 * time.Millisecond)
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+
+	s.limiter.Charge("a/journal", 1)
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, 10*time.Millisecond)
+	c.Check(s.limiter.Admit("b/journal"), gc.Equals, time.Duration(0))
+}
+
+func (s *AppendLimiterSuite) TestPerJournalAndSharedQuotas(c *gc.C) {
+	s.setQuotas(
+		`{"prefix": "a/", "appendsPerSecond": 1, "perJournal": true}`,
+		`{"prefix": "", "appendsPerSecond": 3}`,
+	)
+
+	c.Check(s.limiter.Admit("a/one"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/one"), gc.Equals, time.Second)
+	// Journals of "a/" have individual quotas. The rejected append above
+	// wasn't debited from the shared quota.
+	c.Check(s.limiter.Admit("a/two"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("b/one"), gc.Equals, time.Duration(0))
+	// The shared quota is now exhausted.
+	c.Check(s.limiter.Admit("a/three"), gc.Equals, time.Second/3+1)
+	c.Check(s.limiter.Admit("b/two"), gc.Equals, time.Second/3+1)
+
+	s.now = s.now.Add(time.Second)
+	c.Check(s.limiter.Admit("a/three"), gc.Equals, time.Duration(0))
+}
+
+func (s *AppendLimiterSuite) TestPrefixMatchesPathBoundaries(c *gc.C) {
+	s.setQuotas(`{"prefix": "a", "appendsPerSecond": 1}`)
+
+	c.Check(s.limiter.Admit("a/one"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/two"), gc.Equals, time.Second)
+	c.Check(s.limiter.Admit("a"), gc.Equals, time.Second)
+	// "ab/" is not under prefix "a".
+	c.Check(s.limiter.Admit("ab/one"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("ab/one"), gc.Equals, time.Duration(0))
+}
+
+func (s *AppendLimiterSuite) TestUpdateResetsQuotas(c *gc.C) {
+	s.setQuotas(`{"prefix": "a/", "appendsPerSecond": 1}`)
+
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Second)
+
+	// Malformed quotas are ignored.
+	s.setQuotas(`{"prefix": "a/", "appendsPerSecond": 1}`, `{"prefix": `)
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Second)
+
+	// Removing the quota removes the limit.
+	s.setQuotas()
+	c.Check(s.limiter.Admit("a/journal"), gc.Equals, time.Duration(0))
+}
+
+func (s *AppendLimiterSuite) setQuotas(values ...string) {
+	var tree = &etcd.Node{Key: ServiceRoot + "/quotas", Dir: true}
+	for _, v := range values {
+		tree.Nodes = append(tree.Nodes, &etcd.Node{Value: v})
+	}
+	s.limiter.update(tree)
+}
+
+var _ = gc.Suite(&AppendLimiterSuite{})
@@ -0,0 +1,234 @@
+package gazette
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+
+	etcd "github.com/coreos/etcd/client"
+	log "github.com/sirupsen/logrus"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+// Operation is a journal operation which is subject to authorization.
+type Operation string
+
+const (
+	OpRead      Operation = "read"
+	OpAppend    Operation = "append"
+	OpCreate    Operation = "create"
+	OpReplicate Operation = "replicate"
+	OpDelete    Operation = "delete"
+	// OpAdmin updates the JournalSpec of a journal. OpAdmin of the empty
+	// journal name (granted only by a rule with an empty Prefix) also
+	// permits draining a broker.
+	OpAdmin Operation = "admin"
+)
+
+// Principal identifies the client of a journal operation.
+type Principal struct {
+	// Bearer token presented by the client, or empty.
+	Token string
+	// Subject common name of the client's verified TLS certificate, or empty.
+	Identity string
+}
+
+// Authorizer authorizes journal operations of Principals.
+type Authorizer interface {
+	// Authorize returns nil if |principal| may perform |op| on journal |name|,
+	// and journal.ErrNotAuthorized otherwise.
+	Authorize(principal Principal, op Operation, name journal.Name) error
+}
+
+// ACLRule grants a principal operations on journals having a name prefix.
+type ACLRule struct {
+	// Principal to which the rule applies. Either "cert:" followed by the
+	// common name of a client certificate, "token:" followed by the hex-encoded
+	// SHA-256 digest of a bearer token, or "*" for all principals (including
+	// clients which present no credentials).
+	Principal string `json:"principal"`
+	// Prefix of journal names to which the rule applies. The prefix matches
+	// only on path segment boundaries: "team-a" and "team-a/" apply to
+	// journals "team-a" and "team-a/journal", but not "team-ab/journal". The
+	// empty prefix applies to all journals.
+	Prefix journal.Name `json:"prefix"`
+	// Operations granted by the rule.
+	Operations []Operation `json:"operations"`
+}
+
+// ACLAuthorizer is an Authorizer which authorizes an operation iff an ACLRule
+// grants it. ACLRules are stored in Etcd under ACLsPrefix, and are watched
+// for changes which take effect immediately.
+type ACLAuthorizer struct {
+	keysAPI etcd.KeysAPI
+
+	rules  []ACLRule
+	cancel context.CancelFunc
+	mu     sync.RWMutex
+}
+
+func NewACLAuthorizer(keysAPI etcd.KeysAPI) *ACLAuthorizer {
+	return &ACLAuthorizer{keysAPI: keysAPI}
+}
+
+// StartWatching loads current ACLRules and begins watching for changes. It
+// returns an error if ACLRules could not be loaded.
+func (a *ACLAuthorizer) StartWatching() error {
+	var cancel, err = watchTree(a.keysAPI, path.Join(ServiceRoot, ACLsPrefix), a.update)
+	if err != nil {
+		return err
+	}
+	a.mu.Lock()
+	a.cancel = cancel
+	a.mu.Unlock()
+
+	return nil
+}
+
+// Stop watching for changes of ACLRules.
+func (a *ACLAuthorizer) Stop() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.cancel != nil {
+		a.cancel()
+	}
+}
+
+func (a *ACLAuthorizer) Authorize(principal Principal, op Operation, name journal.Name) error {
+	var names = []string{"*"}
+	if principal.Token != "" {
+		var digest = sha256.Sum256([]byte(principal.Token))
+		names = append(names, "token:"+hex.EncodeToString(digest[:]))
+	}
+	if principal.Identity != "" {
+		names = append(names, "cert:"+principal.Identity)
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	for _, rule := range a.rules {
+		if rule.grants(names, op, name) {
+			return nil
+		}
+	}
+	return journal.ErrNotAuthorized
+}
+
+// update replaces ACLRules with those decoded from |tree|.
+func (a *ACLAuthorizer) update(tree *etcd.Node) {
+	var rules []ACLRule
+
+	for _, node := range tree.Nodes {
+		var rule ACLRule
+
+		if node.Dir {
+			continue
+		} else if err := json.Unmarshal([]byte(node.Value), &rule); err != nil {
+			log.WithFields(log.Fields{"err": err, "key": node.Key}).
+				Warn("failed to decode ACL rule")
+			continue
+		}
+		rules = append(rules, rule)
+	}
+
+	a.mu.Lock()
+	a.rules = rules
+	a.mu.Unlock()
+}
+
+// grants returns whether the rule grants |op| of journal |name| to a
+// principal known by any of |names|.
+func (r ACLRule) grants(names []string, op Operation, name journal.Name) bool {
+	if !hasPathPrefix(string(name), string(r.Prefix)) {
+		return false
+	}
+
+	var matched bool
+	for _, n := range names {
+		if n == r.Principal {
+			matched = true
+		}
+	}
+	if !matched {
+		return false
+	}
+
+	for _, o := range r.Operations {
+		if o == op {
+			return true
+		}
+	}
+	return false
+}
+
+// hasPathPrefix returns whether |name| has |prefix|, where the prefix ends on
+// a path segment boundary of |name|.
+func hasPathPrefix(name, prefix string) bool {
+	if !strings.HasPrefix(name, prefix) {
+		return false
+	} else if len(name) == len(prefix) || prefix == "" || prefix[len(prefix)-1] == '/' {
+		return true
+	}
+	return name[len(prefix)] == '/'
+}
+
+// authorize checks |op| of journal |name| by the client of HTTP request |r|
+// against |authorizer|. All operations are permitted if |authorizer| is nil.
+func authorize(authorizer Authorizer, r *http.Request, op Operation, name journal.Name) error {
+	if authorizer == nil {
+		return nil
+	}
+	var principal Principal
+
+	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
+		principal.Token = auth[len("Bearer "):]
+	}
+	principal.Identity = tlsIdentity(r.TLS)
+
+	return authorizer.Authorize(principal, op, name)
+}
+
+// authorizeRPC checks |op| of journal |name| by the client of gRPC context
+// |ctx| against |authorizer|. All operations are permitted if |authorizer|
+// is nil.
+func authorizeRPC(authorizer Authorizer, ctx context.Context, op Operation, name journal.Name) error {
+	if authorizer == nil {
+		return nil
+	}
+	var principal Principal
+
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		for _, auth := range md["authorization"] {
+			if strings.HasPrefix(auth, "Bearer ") {
+				principal.Token = auth[len("Bearer "):]
+			}
+		}
+	}
+	if p, ok := peer.FromContext(ctx); ok {
+		if info, ok := p.AuthInfo.(credentials.TLSInfo); ok {
+			principal.Identity = tlsIdentity(&info.State)
+		}
+	}
+	return authorizer.Authorize(principal, op, name)
+}
+
+// tlsIdentity returns the subject common name of the verified client
+// certificate of |state|, or empty if there is none.
+func tlsIdentity(state *tls.ConnectionState) string {
+	if state == nil || len(state.VerifiedChains) == 0 || len(state.VerifiedChains[0]) == 0 {
+		return ""
+	}
+	return state.VerifiedChains[0][0].Subject.CommonName
+}
@@ -0,0 +1,153 @@
+package gazette
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+type AuthorizerSuite struct{}
+
+func (s *AuthorizerSuite) TestRuleMatching(c *gc.C) {
+	var digest = sha256.Sum256([]byte("secret"))
+	var dir = ServiceRoot + "/acls"
+
+	var a = NewACLAuthorizer(nil)
+	a.update(&etcd.Node{Key: dir, Dir: true, Nodes: etcd.Nodes{
+		{Key: dir + "/team-a", Value: `{"principal": "cert:team-a",
+			"prefix": "team-a/", "operations": ["read", "append"]}`},
+		{Key: dir + "/team-b", Value: `{"principal": "token:` + hex.EncodeToString(digest[:]) + `",
+			"prefix": "team-b/", "operations": ["create"]}`},
+		{Key: dir + "/public", Value: `{"principal": "*",
+			"prefix": "public/", "operations": ["read"]}`},
+		{Key: dir + "/team-c", Value: `{"principal": "cert:team-c",
+			"prefix": "team-c", "operations": ["delete", "admin"]}`},
+		{Key: dir + "/malformed", Value: `{"principal": `},
+		{Key: dir + "/directory", Dir: true},
+	}})
+
+	var teamA = Principal{Identity: "team-a"}
+	var teamB = Principal{Token: "secret"}
+	var teamC = Principal{Identity: "team-c"}
+	var anonymous = Principal{}
+
+	for _, tc := range []struct {
+		principal Principal
+		op        Operation
+		name      journal.Name
+		expect    error
+	}{
+		{teamA, OpRead, "team-a/journal", nil},
+		{teamA, OpAppend, "team-a/journal", nil},
+		{teamA, OpCreate, "team-a/journal", journal.ErrNotAuthorized}, // Not granted.
+		{teamA, OpAppend, "team-b/journal", journal.ErrNotAuthorized}, // Wrong prefix.
+		{teamA, OpRead, "public/journal", nil},                        // Granted to all.
+
+		{teamB, OpCreate, "team-b/journal", nil},
+		{teamB, OpAppend, "team-b/journal", journal.ErrNotAuthorized},
+		{Principal{Token: "other"}, OpCreate, "team-b/journal", journal.ErrNotAuthorized},
+		// A certificate identity doesn't match a token rule, or vice versa.
+		{Principal{Identity: "secret"}, OpCreate, "team-b/journal", journal.ErrNotAuthorized},
+		{Principal{Token: "team-a"}, OpRead, "team-a/journal", journal.ErrNotAuthorized},
+		// A principal having both a token and identity is granted the union.
+		{Principal{Token: "secret", Identity: "team-a"}, OpCreate, "team-b/journal", nil},
+		{Principal{Token: "secret", Identity: "team-a"}, OpAppend, "team-a/journal", nil},
+
+		{anonymous, OpRead, "public/journal", nil},
+		{anonymous, OpAppend, "public/journal", journal.ErrNotAuthorized},
+		{anonymous, OpRead, "team-a/journal", journal.ErrNotAuthorized},
+
+		// Prefixes match only on path segment boundaries.
+		{teamC, OpDelete, "team-c", nil},
+		{teamC, OpDelete, "team-c/journal", nil},
+		{teamC, OpAdmin, "team-c/nested/journal", nil},
+		{teamC, OpDelete, "team-cd/journal", journal.ErrNotAuthorized},
+		{teamC, OpDelete, "team-c-other", journal.ErrNotAuthorized},
+		{teamA, OpRead, "team-a", journal.ErrNotAuthorized}, // Prefix is "team-a/".
+		{teamA, OpRead, "team-ab/journal", journal.ErrNotAuthorized},
+		{teamA, OpDelete, "team-a/journal", journal.ErrNotAuthorized},
+	} {
+		c.Check(a.Authorize(tc.principal, tc.op, tc.name), gc.Equals, tc.expect,
+			gc.Commentf("case %#v", tc))
+	}
+}
+
+func (s *AuthorizerSuite) TestWatchesRuleChanges(c *gc.C) {
+	var keys = new(consensus.MockKeysAPI)
+	var watcher consensus.MockWatcher
+	var dir = ServiceRoot + "/acls"
+
+	// Expect the ACL directory is created, and already exists.
+	keys.On("Set", mock.Anything, dir, "", &etcd.SetOptions{
+		Dir:       true,
+		PrevExist: etcd.PrevNoExist,
+	}).Return(nil, etcd.Error{Code: etcd.ErrorCodeNodeExist})
+
+	keys.On("Get", mock.Anything, dir, &etcd.GetOptions{Recursive: true, Sort: true}).
+		Return(&etcd.Response{
+			Action: "get",
+			Index:  10,
+			Node: &etcd.Node{Key: dir, Dir: true, Nodes: etcd.Nodes{
+				{Key: dir + "/one", Value: `{"principal": "*", "prefix": "a/", "operations": ["read"]}`},
+			}},
+		}, nil)
+
+	keys.On("Watcher", dir, &etcd.WatcherOptions{Recursive: true, AfterIndex: 10}).
+		Return(&watcher)
+
+	// The watch observes a second rule.
+	watcher.On("Next", mock.Anything).Return(&etcd.Response{
+		Action: "set",
+		Node: &etcd.Node{Key: dir + "/two", ModifiedIndex: 11,
+			Value: `{"principal": "*", "prefix": "b/", "operations": ["read"]}`},
+	}, nil).Once()
+
+	// Then blocks until the watch is cancelled.
+	var updated = make(chan struct{})
+	watcher.On("Next", mock.Anything).Return(nil, context.Canceled).Run(func(args mock.Arguments) {
+		close(updated)
+		<-args.Get(0).(context.Context).Done()
+	}).Once()
+
+	var a = NewACLAuthorizer(keys)
+	c.Assert(a.StartWatching(), gc.IsNil)
+
+	<-updated
+	c.Check(a.Authorize(Principal{}, OpRead, "a/journal"), gc.IsNil)
+	c.Check(a.Authorize(Principal{}, OpRead, "b/journal"), gc.IsNil)
+	c.Check(a.Authorize(Principal{}, OpRead, "c/journal"), gc.Equals, journal.ErrNotAuthorized)
+
+	a.Stop()
+	keys.AssertExpectations(c)
+}
+
+func (s *AuthorizerSuite) TestLoadError(c *gc.C) {
+	var keys = new(consensus.MockKeysAPI)
+	var dir = ServiceRoot + "/acls"
+
+	keys.On("Set", mock.Anything, dir, "", mock.Anything).Return(&etcd.Response{}, nil)
+	keys.On("Get", mock.Anything, dir, mock.Anything).
+		Return(nil, errors.New("get error"))
+
+	c.Check(NewACLAuthorizer(keys).StartWatching(), gc.ErrorMatches, "get error")
+}
+
+// staticAuthorizer is an Authorizer which grants operations to a fixed token.
+type staticAuthorizer string
+
+func (a staticAuthorizer) Authorize(principal Principal, op Operation, name journal.Name) error {
+	if principal.Token != string(a) {
+		return journal.ErrNotAuthorized
+	}
+	return nil
+}
+
+var _ = gc.Suite(&AuthorizerSuite{})
@@ -1,6 +1,8 @@
 package gazette
 
 import (
+	"bytes"
+	"crypto/tls"
 	"encoding/json"
 	"expvar"
 	"fmt"
@@ -75,6 +77,8 @@ type Client struct {
 
 	// Underlying HTTP Client to use for all requests.
 	httpClient httpClient
+	// Bearer token presented to brokers, or empty.
+	bearerToken string
 	// Test support: allow time.Now() to be swapped out.
 	timeNow func() time.Time
 }
@@ -85,6 +89,20 @@ func NewClient(endpoint string) (*Client, error) {
 	return NewClientWithHttpClient(endpoint, &http.Client{})
 }
 
+// NewClientWithTLS returns a new Client which uses TLS |config| for requests
+// of https:// endpoints, presenting its client certificate (if any) to
+// brokers which require mutual authentication. HTTPS is assumed if |endpoint|
+// specifies no protocol.
+func NewClientWithTLS(endpoint string, config *tls.Config) (*Client, error) {
+	if strings.Index(endpoint, "://") == -1 {
+		endpoint = "https://" + endpoint
+	}
+	var transport = MakeHttpTransport()
+	transport.TLSClientConfig = config
+
+	return NewClientWithHttpClient(endpoint, &http.Client{Transport: transport})
+}
+
 func NewClientWithHttpClient(endpoint string, hc *http.Client) (*Client, error) {
 	// Assume HTTP if no protocol is specified.
 	if strings.Index(endpoint, "://") == -1 {
@@ -126,6 +144,12 @@ func NewClientWithHttpClient(endpoint string, hc *http.Client) (*Client, error)
 	return c, nil
 }
 
+// UseBearerToken sets |token| as the bearer token presented with requests of
+// brokers which authorize journal operations (see Authorizer).
+func (c *Client) UseBearerToken(token string) {
+	c.bearerToken = token
+}
+
 // If you want to use your own |http.Transport| with Gazette, start with this one.
 func MakeHttpTransport() *http.Transport {
 	// See definition of |http.DefaultTransport| here:
@@ -268,36 +292,131 @@ func (c *Client) makeReadStatsWrapper(stream io.ReadCloser, name journal.Name, o
 // decompressed while being read).
 func (c *Client) openFragment(location *url.URL,
 	result journal.ReadResult) (io.ReadCloser, error) {
+	return openFragment(c.httpClient, location, result)
+}
 
-	response, err := c.httpClient.Get(location.String())
+func openFragment(hc httpClient, location *url.URL,
+	result journal.ReadResult) (io.ReadCloser, error) {
+
+	response, err := hc.Get(location.String())
 	if err != nil {
 		return nil, err
 	} else if response.StatusCode != http.StatusOK {
 		response.Body.Close()
 		return nil, fmt.Errorf("fetching fragment: %s", response.Status)
 	}
+	// Decompress fragment content encoded with a compression codec.
+	body, err := journal.NewDecompressor(response.Body, result.Fragment.Codec)
+	if err != nil {
+		response.Body.Close()
+		return nil, fmt.Errorf("decompressing fragment: %s", err)
+	}
 	// Attempt to seek to |result.Offset| within the fragment.
 	delta := result.Offset - result.Fragment.Begin
-	if _, err := io.CopyN(ioutil.Discard, response.Body, delta); err != nil {
-		response.Body.Close()
+	if _, err := io.CopyN(ioutil.Discard, body, delta); err != nil {
+		body.Close()
 		return nil, fmt.Errorf("seeking fragment: %s", err)
 	}
 
 	var deltaF64 = float64(delta)
 	metrics.GazetteReadBytesTotal.Add(deltaF64)
 	metrics.GazetteDiscardBytesTotal.Add(deltaF64)
-	return response.Body, nil // Success.
+	return body, nil // Success.
 }
 
-// Creates the Journal of the given name.
+// Creates journal |name| with the cluster default JournalSpec.
 func (c *Client) Create(name journal.Name) error {
+	return c.CreateWithSpec(name, journal.JournalSpec{})
+}
+
+// Creates journal |name| with JournalSpec |spec|.
+func (c *Client) CreateWithSpec(name journal.Name, spec journal.JournalSpec) error {
+	return c.doSpecRequest("POST", name, spec)
+}
+
+// Updates the JournalSpec of journal |name|. Non-zero fields of |spec| are
+// merged into the journal's current JournalSpec.
+func (c *Client) UpdateSpec(name journal.Name, spec journal.JournalSpec) error {
+	return c.doSpecRequest("PATCH", name, spec)
+}
+
+// Deletes journal |args.Journal|, and disposes of its fragments per |args|.
+// Blocks until the journal's replicas have been torn down and the deletion
+// has completed.
+func (c *Client) Delete(args journal.DeleteArgs) error {
+	ep := c.defaultEndpoint // Copy.
+	ep.Path = "/" + args.Journal.String()
+
+	var query = make(url.Values)
+	if args.Fragments != "" {
+		query.Set("fragments", string(args.Fragments))
+	}
+	if args.Destination != "" {
+		query.Set("destination", args.Destination.String())
+	}
+	ep.RawQuery = query.Encode()
+
+	request, err := http.NewRequest("DELETE", ep.String(), nil)
+	if err != nil {
+		return err
+	}
+	c.authenticate(request)
+
+	// Issue the request without using or updating the Journal location cache.
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	return journal.ErrorFromResponse(response)
+}
+
+// Lists journals matching |args|.
+func (c *Client) List(args journal.ListArgs) ([]journal.ListedJournal, error) {
+	ep := c.defaultEndpoint // Copy.
+	ep.Path = "/" + args.Prefix.String()
+
+	var query = make(url.Values)
+	for key, value := range args.Labels {
+		query.Add("label", key+"="+value)
+	}
+	ep.RawQuery = query.Encode()
+
+	request, err := http.NewRequest("LIST", ep.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.authenticate(request)
+
+	// Issue the request without using or updating the Journal location cache.
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	} else if response.StatusCode != http.StatusOK {
+		return nil, journal.ErrorFromResponse(response)
+	}
+	defer response.Body.Close()
+
+	var body listResponse
+	if err = json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("decoding list response: %s", err)
+	}
+	return body.Journals, nil
+}
+
+func (c *Client) doSpecRequest(method string, name journal.Name, spec journal.JournalSpec) error {
 	url := c.defaultEndpoint // Copy.
 	url.Path = "/" + name.String()
 
-	request, err := http.NewRequest("POST", url.String(), nil)
+	body, err := json.Marshal(spec)
 	if err != nil {
 		return err
 	}
+	request, err := http.NewRequest(method, url.String(), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	c.authenticate(request)
+
 	// Issue the request without using or updating the Journal location cache.
 	response, err := c.httpClient.Do(request)
 	if err != nil {
@@ -313,6 +432,13 @@ func (c *Client) Put(args journal.AppendArgs) journal.AppendResult {
 	if err != nil {
 		return journal.AppendResult{Error: err}
 	}
+	if args.ExpectWriteHead != nil {
+		request.Header.Set(ExpectWriteHeadHeader, strconv.FormatInt(*args.ExpectWriteHead, 10))
+	}
+	if args.Producer != "" {
+		request.Header.Set(ProducerHeader, string(args.Producer))
+		request.Header.Set(ProducerSequenceHeader, strconv.FormatInt(args.Sequence, 10))
+	}
 	if _, ok := c.locationCache.Get(request.URL.Path); !ok {
 		// Speculatively issue a HEAD to fill the location cache for this path.
 		result, _ := c.Head(journal.ReadArgs{Journal: args.Journal, Blocking: false, Offset: -1})
@@ -352,6 +478,9 @@ func (c *Client) buildReadURL(args journal.ReadArgs) *url.URL {
 		"offset": {strconv.FormatInt(args.Offset, 10)},
 		"block":  {strconv.FormatBool(args.Blocking)},
 	}
+	if !args.Timestamp.IsZero() {
+		v.Add("timestamp", args.Timestamp.Format(time.RFC3339Nano))
+	}
 	var blockms int64
 	if !args.Deadline.IsZero() {
 		blockms = args.Deadline.Sub(c.timeNow()).Nanoseconds() / time.Millisecond.Nanoseconds()
@@ -481,6 +610,14 @@ func (c *Client) parseAppendResponse(response *http.Response) journal.AppendResu
 				Error("error parsing write head")
 		}
 	}
+	if retryAfter := response.Header.Get(RetryAfterHeader); retryAfter != "" {
+		if seconds, err := strconv.ParseInt(retryAfter, 10, 64); err != nil {
+			log.WithFields(log.Fields{"err": err, "retryAfter": retryAfter}).
+				Error("error parsing retry after")
+		} else {
+			result.RetryAfter = time.Duration(seconds) * time.Second
+		}
+	}
 	return result
 }
 
@@ -509,6 +646,8 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 		// Note that Path & RawQuery are not re-written.
 	}
 
+	c.authenticate(request)
+
 	c.requests.Put(request.URL.String(), requestData{request.Method, c.timeNow()})
 	defer c.requests.Delete(request.URL.String())
 
@@ -533,6 +672,13 @@ func (c *Client) Do(request *http.Request) (*http.Response, error) {
 	return response, err
 }
 
+// authenticate adds the Client's credentials (if any) to |request|.
+func (c *Client) authenticate(request *http.Request) {
+	if c.bearerToken != "" {
+		request.Header.Set("Authorization", "Bearer "+c.bearerToken)
+	}
+}
+
 // Returns the |Fragment| whose Modified time is closest to but prior to the
 // given |t|. Can return a zeroed Fragment structure, if no fragment matches.
 func (c *Client) FragmentBeforeTime(name journal.Name, t time.Time) (journal.Fragment, error) {
@@ -2,12 +2,15 @@ package gazette
 
 import (
 	"bytes"
+	"compress/gzip"
 	"errors"
 	"expvar"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
 	"strconv"
 	"strings"
 	"time"
@@ -16,6 +19,7 @@ import (
 	"github.com/stretchr/testify/mock"
 
 	"github.com/LiveRamp/gazette/pkg/journal"
+	"github.com/LiveRamp/gazette/pkg/tlsconfig"
 )
 
 const (
@@ -240,6 +244,44 @@ func (s *ClientSuite) TestGetWithFragmentLocation(c *gc.C) {
 	c.Check(string(data), gc.Equals, "fragment-content...")
 }
 
+func (s *ClientSuite) TestGetWithCompressedFragmentLocation(c *gc.C) {
+	mockClient := &mockHttpClient{}
+
+	// Expect an initial HEAD request, returning a gzip'd fragment.
+	var response = newReadResponseFixture()
+	response.Header.Set(FragmentNameHeader, kFragmentFixtureStr+".gz")
+
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "HEAD" &&
+			request.URL.String() == "http://default/a/journal?block=false&offset=1005"
+	})).Return(response, nil).Once()
+
+	var compressed bytes.Buffer
+	var gz = gzip.NewWriter(&compressed)
+	gz.Write([]byte("xxxxxfragment-content..."))
+	c.Assert(gz.Close(), gc.IsNil)
+
+	mockClient.On("Get", "http://cloud/fragment/location").Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body:       ioutil.NopCloser(&compressed),
+	}, nil).Once()
+
+	s.client.httpClient = mockClient
+	result, body := s.client.Get(
+		journal.ReadArgs{Journal: "a/journal", Offset: 1005, Blocking: false})
+
+	var expect = fragmentFixture
+	expect.Codec = journal.CodecGzip
+
+	c.Check(result.Error, gc.IsNil)
+	c.Check(result.Fragment, gc.DeepEquals, expect)
+	mockClient.AssertExpectations(c)
+
+	// Expect the returned response is decompressed, and pre-seeked.
+	data, _ := ioutil.ReadAll(body)
+	c.Check(string(data), gc.Equals, "fragment-content...")
+}
+
 func (s *ClientSuite) TestGetWithFragmentLocationFails(c *gc.C) {
 	mockClient := &mockHttpClient{}
 
@@ -330,6 +372,118 @@ func (s *ClientSuite) TestCreate(c *gc.C) {
 	mockClient.AssertExpectations(c)
 }
 
+func (s *ClientSuite) TestCreateAndUpdateWithSpec(c *gc.C) {
+	mockClient := &mockHttpClient{}
+
+	var bodyMatches = func(request *http.Request, expect string) bool {
+		var r, _ = request.GetBody()
+		var body, _ = ioutil.ReadAll(r)
+		return string(body) == expect
+	}
+
+	// Expect a POST of the journal with its spec.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "POST" &&
+			request.URL.String() == "http://default/a/journal" &&
+			bodyMatches(request, `{"replication":1,"fragment_size":1024}`)
+	})).Return(&http.Response{
+		StatusCode: http.StatusCreated,
+		Body:       ioutil.NopCloser(nil),
+	}, nil).Once()
+
+	// Expect a PATCH of the journal spec.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "PATCH" &&
+			request.URL.String() == "http://default/a/journal" &&
+			bodyMatches(request, `{"fragment_size":2048}`)
+	})).Return(&http.Response{
+		StatusCode: http.StatusNoContent,
+		Body:       ioutil.NopCloser(nil),
+	}, nil).Once()
+
+	s.client.httpClient = mockClient
+	c.Check(s.client.CreateWithSpec("a/journal",
+		journal.JournalSpec{Replication: 1, FragmentSize: 1024}), gc.IsNil)
+	c.Check(s.client.UpdateSpec("a/journal",
+		journal.JournalSpec{FragmentSize: 2048}), gc.IsNil)
+
+	mockClient.AssertExpectations(c)
+}
+
+func (s *ClientSuite) TestList(c *gc.C) {
+	mockClient := &mockHttpClient{}
+
+	// Expect a LIST of the prefix and labels.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "LIST" &&
+			request.URL.String() == "http://default/a/?label=topic%3Da%2Ftopic"
+	})).Return(&http.Response{
+		StatusCode: http.StatusOK,
+		Body: ioutil.NopCloser(strings.NewReader(`{"journals":[
+			{"name":"a/journal","route_token":"http://broker","write_head":1234,
+			 "labels":{"topic":"a/topic"}}]}`)),
+	}, nil).Once()
+
+	// Expect a second LIST, which fails.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "LIST" && request.URL.String() == "http://default/"
+	})).Return(&http.Response{
+		StatusCode: http.StatusInternalServerError,
+		Status:     "500 Internal Server Error",
+		Body:       ioutil.NopCloser(strings.NewReader("error!")),
+	}, nil).Once()
+
+	s.client.httpClient = mockClient
+
+	var journals, err = s.client.List(journal.ListArgs{
+		Prefix: "a/",
+		Labels: map[string]string{"topic": "a/topic"},
+	})
+	c.Check(err, gc.IsNil)
+	c.Check(journals, gc.DeepEquals, []journal.ListedJournal{{
+		Name:       "a/journal",
+		RouteToken: "http://broker",
+		WriteHead:  1234,
+		Labels:     map[string]string{"topic": "a/topic"},
+	}})
+
+	_, err = s.client.List(journal.ListArgs{})
+	c.Check(err, gc.ErrorMatches, `500 Internal Server Error \(error!\)`)
+
+	mockClient.AssertExpectations(c)
+}
+
+func (s *ClientSuite) TestDelete(c *gc.C) {
+	mockClient := &mockHttpClient{}
+
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "DELETE" &&
+			request.URL.String() == "http://default/a/journal?destination=archive&fragments=move"
+	})).Return(&http.Response{
+		StatusCode: http.StatusNoContent,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil).Once()
+
+	// Expect a second DELETE, of a journal which doesn't exist.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "DELETE" && request.URL.String() == "http://default/a/missing"
+	})).Return(&http.Response{
+		StatusCode: http.StatusNotFound,
+		Body:       ioutil.NopCloser(strings.NewReader("not found")),
+	}, nil).Once()
+
+	s.client.httpClient = mockClient
+
+	c.Check(s.client.Delete(journal.DeleteArgs{
+		Journal:     "a/journal",
+		Fragments:   journal.MoveFragments,
+		Destination: "archive",
+	}), gc.IsNil)
+	c.Check(s.client.Delete(journal.DeleteArgs{Journal: "a/missing"}), gc.Equals, journal.ErrNotFound)
+
+	mockClient.AssertExpectations(c)
+}
+
 func (s *ClientSuite) TestPut(c *gc.C) {
 	content := strings.NewReader("foobar")
 	mockClient := &mockHttpClient{}
@@ -420,6 +574,73 @@ func (s *ClientSuite) TestPut(c *gc.C) {
 	c.Check(writerMap.Get("head").(*expvar.Int).String(), gc.Equals, "12341235")
 }
 
+func (s *ClientSuite) TestBearerToken(c *gc.C) {
+	mockClient := &mockHttpClient{}
+
+	// Expect both requests routed through the location cache, and those
+	// issued directly to the default endpoint, present the token.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "HEAD" &&
+			request.Header.Get("Authorization") == "Bearer a-token"
+	})).Return(&http.Response{
+		StatusCode: http.StatusForbidden,
+		Body:       ioutil.NopCloser(nil),
+	}, nil).Once()
+
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "POST" &&
+			request.Header.Get("Authorization") == "Bearer a-token"
+	})).Return(&http.Response{
+		StatusCode: http.StatusCreated,
+		Body:       ioutil.NopCloser(nil),
+	}, nil).Once()
+
+	s.client.httpClient = mockClient
+	s.client.UseBearerToken("a-token")
+
+	var result, _ = s.client.Head(journal.ReadArgs{Journal: "a/journal", Offset: -1})
+	c.Check(result.Error, gc.Equals, journal.ErrNotAuthorized)
+	c.Check(s.client.Create("a/journal"), gc.IsNil)
+
+	mockClient.AssertExpectations(c)
+}
+
+func (s *ClientSuite) TestPutWithExpectedWriteHead(c *gc.C) {
+	mockClient := &mockHttpClient{}
+
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "HEAD" && request.URL.Path == "/a/journal"
+	})).Return(&http.Response{
+		StatusCode: http.StatusRequestedRangeNotSatisfiable,
+		Request:    &http.Request{URL: newURL("http://broker/a/journal")},
+		Body:       ioutil.NopCloser(nil),
+	}, nil).Once()
+
+	// Expect the PUT carries the expected write head. Fail it, returning the
+	// current write head of the journal.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "PUT" &&
+			request.URL.Host == "broker" &&
+			request.Header.Get(ExpectWriteHeadHeader) == "1234"
+	})).Return(&http.Response{
+		StatusCode: http.StatusPreconditionFailed,
+		Body:       ioutil.NopCloser(bytes.NewBufferString("wrong write head")),
+		Header:     http.Header{WriteHeadHeader: []string{"5678"}},
+	}, nil).Once()
+
+	s.client.httpClient = mockClient
+
+	var expect int64 = 1234
+	var res = s.client.Put(journal.AppendArgs{
+		Journal:         "a/journal",
+		Content:         strings.NewReader("foobar"),
+		ExpectWriteHead: &expect,
+	})
+	c.Check(res.Error, gc.Equals, journal.ErrWrongWriteHead)
+	c.Check(res.WriteHead, gc.Equals, int64(5678))
+	mockClient.AssertExpectations(c)
+}
+
 func (s *ClientSuite) TestReadResultParsingErrorCases(c *gc.C) {
 	args := journal.ReadArgs{Journal: "a/journal"}
 
@@ -517,6 +738,34 @@ func (s *ClientSuite) TestDialerIsNonNil(c *gc.C) {
 	c.Check(client.httpClient.(*http.Client).Transport.(*http.Transport).Dial, gc.NotNil)
 }
 
+func (s *ClientSuite) TestClientWithMutualTLS(c *gc.C) {
+	var dir, err = ioutil.TempDir("", "client-suite")
+	c.Assert(err, gc.IsNil)
+	defer os.RemoveAll(dir)
+
+	files, err := tlsconfig.WriteTestCertificates(dir)
+	c.Assert(err, gc.IsNil)
+	serverConfig, err := tlsconfig.Load(files.ServerCert, files.ServerKey, files.CA)
+	c.Assert(err, gc.IsNil)
+	clientConfig, err := tlsconfig.Load(files.ClientCert, files.ClientKey, files.CA)
+	c.Assert(err, gc.IsNil)
+
+	var server = httptest.NewUnstartedServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			c.Check(r.TLS.PeerCertificates[0].Subject.CommonName, gc.Equals, "client")
+			w.WriteHeader(http.StatusCreated)
+		}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	// HTTPS is assumed if the endpoint has no protocol.
+	client, err := NewClientWithTLS(strings.TrimPrefix(server.URL, "https://"), clientConfig)
+	c.Assert(err, gc.IsNil)
+	c.Check(client.defaultEndpoint.Scheme, gc.Equals, "https")
+	c.Check(client.Create("a/journal"), gc.IsNil)
+}
+
 func (s *ClientSuite) TestFragmentBeforeTime(c *gc.C) {
 	var mockClient = new(mockHttpClient)
 	var response = newReadResponseFixture()
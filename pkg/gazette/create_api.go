@@ -2,9 +2,12 @@ package gazette
 
 import (
 	"context"
+	"encoding/json"
+	"io"
 	"net/http"
 	"net/url"
 	"path"
+	"reflect"
 	"time"
 
 	etcd "github.com/coreos/etcd/client"
@@ -19,10 +22,12 @@ import (
 // API for creation of a new Journal. In particular, CreateAPI creates an Etcd
 // item directory for the Journal under Gazette's consensus.Allocator root
 // and responds to the client when the Journal is ready for transactions.
+// The request body may optionally provide a JSON-encoded journal.JournalSpec.
 type CreateAPI struct {
 	cfs              cloudstore.FileSystem
 	keysAPI          etcd.KeysAPI
 	requiredReplicas int
+	authorizer       Authorizer
 }
 
 func NewCreateAPI(cfs cloudstore.FileSystem, keysAPI etcd.KeysAPI,
@@ -34,6 +39,12 @@ func NewCreateAPI(cfs cloudstore.FileSystem, keysAPI etcd.KeysAPI,
 	}
 }
 
+// UseAuthorizer authorizes journal creations with |authorizer|.
+func (h *CreateAPI) UseAuthorizer(authorizer Authorizer) *CreateAPI {
+	h.authorizer = authorizer
+	return h
+}
+
 func (h *CreateAPI) Register(router *mux.Router) {
 	router.NewRoute().Methods("POST").HandlerFunc(h.Create)
 }
@@ -41,12 +52,39 @@ func (h *CreateAPI) Register(router *mux.Router) {
 func (h *CreateAPI) Create(w http.ResponseWriter, r *http.Request) {
 	var name = path.Clean(r.URL.Path[1:])
 
+	if err := authorize(h.authorizer, r, OpCreate, journal.Name(name)); err != nil {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+
+	var spec journal.JournalSpec
+	if r.Body == nil {
+		// No spec was provided.
+	} else if err := json.NewDecoder(r.Body).Decode(&spec); err != nil && err != io.EOF {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateSpec(spec, h.requiredReplicas); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.create(name, spec); err != nil {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+// create creates journal |name| with validated JournalSpec |spec|, and blocks
+// until the journal has its required replicas. It returns ErrExists if the
+// journal already exists.
+func (h *CreateAPI) create(name string, spec journal.JournalSpec) error {
 	// Create the fragment directory. Add a trailing slash to unambiguously
 	// represent it as a directory: some cloudstore implementations (eg, GCS)
 	// require this if no subordinate files are present.
 	if err := h.cfs.MkdirAll(name+"/", 0750); err != nil {
-		http.Error(w, err.Error(), journal.StatusCodeForError(err))
-		return
+		return err
 	}
 
 	// Create an allocated item entry in Etcd.
@@ -61,11 +99,25 @@ func (h *CreateAPI) Create(w http.ResponseWriter, r *http.Request) {
 		err = journal.ErrExists
 	}
 	if err != nil {
-		http.Error(w, err.Error(), journal.StatusCodeForError(err))
-		return
+		return err
+	}
+
+	// Clear the tombstone of a previously deleted journal of the same name.
+	if _, err = h.keysAPI.Delete(context.Background(), journalTombstonePath(journal.Name(name)),
+		nil); err != nil && !isKeyNotFound(err) {
+		return err
+	}
+
+	// Store a provided JournalSpec. Runners apply the cluster defaults until
+	// the spec is observed.
+	if !reflect.DeepEqual(spec, journal.JournalSpec{}) {
+		if err = putJournalSpec(h.keysAPI, journal.Name(name), spec); err != nil {
+			return err
+		}
 	}
 
-	log.WithFields(log.Fields{"path": itemPath, "name": name}).Info("created journal")
+	log.WithFields(log.Fields{"path": itemPath, "name": name, "spec": spec}).
+		Info("created journal")
 
 	// Briefly block until we see the required number of ready replicas under
 	// the new item. If we returned immediately, the client will likely race
@@ -82,23 +134,20 @@ func (h *CreateAPI) Create(w http.ResponseWriter, r *http.Request) {
 	for {
 		var err error
 		if response, err = watcher.Next(ctx); err != nil {
-			http.Error(w, err.Error(), journal.StatusCodeForError(err))
-			return
+			return err
 		} else if tree, err = consensus.PatchTree(tree, response); err != nil {
-			http.Error(w, err.Error(), journal.StatusCodeForError(err))
-			return
+			return err
 		}
 
 		var readyCount int
 		for _, node := range tree.Nodes {
-			if node.Value == "ready" {
+			if node.Value == itemStateReady {
 				readyCount += 1
 			}
 		}
 
 		if readyCount > h.requiredReplicas {
-			w.WriteHeader(http.StatusCreated)
-			return
+			return nil
 		}
 	}
 }
@@ -4,6 +4,7 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"strings"
 
 	etcd "github.com/coreos/etcd/client"
 	gc "github.com/go-check/check"
@@ -38,6 +39,10 @@ func (s *CreateAPISuite) TestCreateSuccess(c *gc.C) {
 			PrevExist: etcd.PrevNoExist}).
 		Return(&etcd.Response{Index: 1234}, nil)
 
+	// Expect the tombstone of a previously deleted journal is cleared.
+	s.keys.On("Delete", mock.Anything, ServiceRoot+"/deleted/journal%2Fname",
+		(*etcd.DeleteOptions)(nil)).Return(&etcd.Response{Index: 1235}, nil)
+
 	var watcher consensus.MockWatcher
 
 	s.keys.On("Watcher", ServiceRoot+"/items/journal%2Fname",
@@ -71,6 +76,65 @@ func (s *CreateAPISuite) TestCreateSuccess(c *gc.C) {
 	}
 }
 
+func (s *CreateAPISuite) TestCreateWithSpec(c *gc.C) {
+	s.keys.On("Set", mock.Anything, ServiceRoot+"/items/journal%2Fname", "",
+		&etcd.SetOptions{
+			Dir:       true,
+			PrevExist: etcd.PrevNoExist}).
+		Return(&etcd.Response{Index: 1234}, nil)
+	s.keys.On("Delete", mock.Anything, ServiceRoot+"/deleted/journal%2Fname",
+		(*etcd.DeleteOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+
+	// Expect the provided spec is stored.
+	s.keys.On("Set", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		`{"replication":1,"fragment_size":1024}`, (*etcd.SetOptions)(nil)).
+		Return(&etcd.Response{Index: 1235}, nil)
+
+	var watcher consensus.MockWatcher
+
+	s.keys.On("Watcher", ServiceRoot+"/items/journal%2Fname",
+		&etcd.WatcherOptions{
+			AfterIndex: 1234,
+			Recursive:  true}).
+		Return(&watcher)
+
+	watcher.On("Next", mock.Anything).Return(
+		&etcd.Response{
+			Action: "get",
+			Node: &etcd.Node{Nodes: etcd.Nodes{
+				{Value: "ready"}, {Value: "ready"}, {Value: "ready"}}},
+		}, nil)
+
+	req, _ := http.NewRequest("POST", "/journal/name",
+		strings.NewReader(`{"replication": 1, "fragment_size": 1024}`))
+	w := httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusCreated)
+	s.keys.AssertExpectations(c)
+}
+
+func (s *CreateAPISuite) TestCreateWithInvalidSpec(c *gc.C) {
+	// Replication exceeds that of the cluster.
+	req, _ := http.NewRequest("POST", "/journal/name",
+		strings.NewReader(`{"replication": 3}`))
+	w := httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusBadRequest)
+	c.Check(w.Body.String(), gc.Matches, "invalid journal replication .*\n")
+
+	// Spec is malformed.
+	req, _ = http.NewRequest("POST", "/journal/name", strings.NewReader(`{"replication": "foo"}`))
+	w = httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusBadRequest)
+
+	// Expect no Etcd requests were made.
+	s.keys.AssertExpectations(c)
+}
+
 func (s *CreateAPISuite) TestEtcdConflict(c *gc.C) {
 	s.keys.On("Set", mock.Anything, ServiceRoot+"/items/journal%2Fname", "",
 		&etcd.SetOptions{
@@ -86,6 +150,21 @@ func (s *CreateAPISuite) TestEtcdConflict(c *gc.C) {
 	s.keys.AssertExpectations(c)
 }
 
+func (s *CreateAPISuite) TestNotAuthorized(c *gc.C) {
+	var m = mux.NewRouter()
+	NewCreateAPI(s.cfs, s.keys, 2).UseAuthorizer(staticAuthorizer("secret")).Register(m)
+
+	req, _ := http.NewRequest("POST", "/journal/name", nil)
+	req.Header.Set("Authorization", "Bearer other")
+	w := httptest.NewRecorder()
+
+	m.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusForbidden)
+
+	// Expect no Etcd requests were made.
+	s.keys.AssertExpectations(c)
+}
+
 func (s *CreateAPISuite) TestJournalIsAlreadyCFSFile(c *gc.C) {
 	var fixture, err = s.cfs.OpenFile("a-file-path",
 		os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
@@ -0,0 +1,254 @@
+package gazette
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+const (
+	// Maximum duration for which a deletion awaits the teardown of the Journal's
+	// replicas. Runners publish item state updates within a few seconds of
+	// teardown, but teardown includes the persisting of replica spools, which
+	// are converged by Persisters each minute.
+	kDeleteDrainTimeout = 5 * time.Minute
+	// Duration for which a tombstone is retained after a deletion.
+	kDeleteTombstoneTTL = 24 * time.Hour
+)
+
+// API for deletion of a Journal. DeleteAPI fences the Journal by writing a
+// tombstone to Etcd. Runners observe the tombstone by removing the Journal's
+// route, which fails further appends and shuts down local replicas (handing
+// their spools to the Persister). Once all replicas have been torn down and
+// their spools persisted, persisted fragments are kept, deleted, or moved as
+// requested, and the Journal's item, JournalSpec, and producers are removed
+// from Etcd.
+//
+// Query arguments "fragments" and "destination" provide the journal.DeleteArgs
+// of the request. The tombstone expires kDeleteTombstoneTTL after deletion.
+// Until then, spools persisted late (eg, recovered after a broker crash)
+// observe the requested fragment disposition. It's cleared if the Journal is
+// created again.
+type DeleteAPI struct {
+	cfs        cloudstore.FileSystem
+	decoder    *schema.Decoder
+	keysAPI    etcd.KeysAPI
+	authorizer Authorizer
+}
+
+func NewDeleteAPI(cfs cloudstore.FileSystem, keysAPI etcd.KeysAPI) *DeleteAPI {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(false)
+	decoder.SetAliasTag("json")
+
+	return &DeleteAPI{cfs: cfs, decoder: decoder, keysAPI: keysAPI}
+}
+
+// UseAuthorizer authorizes journal deletions with |authorizer|.
+func (h *DeleteAPI) UseAuthorizer(authorizer Authorizer) *DeleteAPI {
+	h.authorizer = authorizer
+	return h
+}
+
+func (h *DeleteAPI) Register(router *mux.Router) {
+	router.NewRoute().Methods("DELETE").HandlerFunc(h.Delete)
+}
+
+func (h *DeleteAPI) Delete(w http.ResponseWriter, r *http.Request) {
+	var args journal.DeleteArgs
+	var err error
+
+	if err = r.ParseForm(); err == nil {
+		err = h.decoder.Decode(&args, r.Form)
+	}
+	if err == nil {
+		err = args.Validate()
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	args.Journal = journal.Name(path.Clean(r.URL.Path[1:]))
+
+	if err = authorize(h.authorizer, r, OpDelete, args.Journal); err != nil {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+	if err = h.delete(args); err != nil {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+	log.WithField("args", args).Info("deleted journal")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *DeleteAPI) delete(args journal.DeleteArgs) error {
+	var itemPath = path.Join(ServiceRoot, consensus.ItemsPrefix, journalToItem(args.Journal))
+
+	var response, err = h.keysAPI.Get(context.Background(), itemPath,
+		&etcd.GetOptions{Recursive: true})
+	if isKeyNotFound(err) {
+		return journal.ErrNotFound
+	} else if err != nil {
+		return err
+	}
+
+	// Fence the journal. Deletion is idempotent, and a retried deletion
+	// updates the tombstone with the current DeleteArgs (and refreshes its TTL).
+	if value, err := json.Marshal(args); err != nil {
+		return err
+	} else if _, err = h.keysAPI.Set(context.Background(),
+		journalTombstonePath(args.Journal), string(value),
+		&etcd.SetOptions{TTL: kDeleteTombstoneTTL}); err != nil {
+		return err
+	}
+
+	if err = h.awaitDrain(itemPath, response); err != nil {
+		return err
+	}
+	if err = h.disposeFragments(args); err != nil {
+		return err
+	}
+
+	// Remove the journal's spec, producers, and item. The item is removed
+	// last, so that a failed deletion may be retried.
+	for _, key := range []string{
+		journalSpecPath(args.Journal),
+		journalProducersPath(args.Journal),
+	} {
+		if _, err = h.keysAPI.Delete(context.Background(), key,
+			nil); err != nil && !isKeyNotFound(err) {
+			return err
+		}
+	}
+	if _, err = h.keysAPI.Delete(context.Background(), itemPath,
+		&etcd.DeleteOptions{Recursive: true, Dir: true}); err != nil && !isKeyNotFound(err) {
+		return err
+	}
+	return nil
+}
+
+// Blocks until each route entry of the item of |response| has the "deleted"
+// ItemState, indicating the replica of that entry has been torn down and its
+// spools persisted.
+func (h *DeleteAPI) awaitDrain(itemPath string, response *etcd.Response) error {
+	var ctx, cancel = context.WithTimeout(context.Background(), kDeleteDrainTimeout)
+	defer cancel()
+
+	var tree = response.Node
+	var watcher = h.keysAPI.Watcher(itemPath, &etcd.WatcherOptions{
+		AfterIndex: response.Index,
+		Recursive:  true,
+	})
+
+	for {
+		var drained = true
+		for _, node := range tree.Nodes {
+			if node.Value != itemStateDeleted {
+				drained = false
+			}
+		}
+		if drained {
+			return nil
+		}
+
+		var err error
+		if response, err = watcher.Next(ctx); err != nil {
+			return fmt.Errorf("awaiting journal drain: %s", err)
+		} else if tree, err = consensus.PatchTree(tree, response); err != nil {
+			return err
+		}
+	}
+}
+
+// Deletes or moves the persisted fragments of the journal, per |args|.
+func (h *DeleteAPI) disposeFragments(args journal.DeleteArgs) error {
+	if args.Fragments != journal.DeleteFragments && args.Fragments != journal.MoveFragments {
+		return nil // Fragments are kept.
+	}
+	var fragments []journal.Fragment
+
+	if err := h.cfs.Walk(args.Journal.String()+"/", journal.NewWalkFuncAdapter(
+		func(fragment journal.Fragment) error {
+			// Fragments of other journals nested under this one are not walked.
+			if fragment.Journal == args.Journal {
+				fragments = append(fragments, fragment)
+			}
+			return nil
+		})); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	for _, fragment := range fragments {
+		if args.Fragments == journal.MoveFragments {
+			var moved = fragment
+			moved.Journal = args.FragmentJournal(fragment.Journal)
+
+			if err := copyFragment(h.cfs, fragment, moved); err != nil {
+				return err
+			}
+		}
+		if err := h.cfs.Remove(fragment.TimeIndexPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		} else if err = h.cfs.Remove(fragment.ContentPath()); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	// Remove the fragment directory. This may fail if it's not empty (eg,
+	// because nested journals exist), or if the cloudstore has no directories.
+	if err := h.cfs.Remove(args.Journal.String() + "/"); err != nil && !os.IsNotExist(err) {
+		log.WithFields(log.Fields{"err": err, "journal": args.Journal}).
+			Warn("failed to remove fragment directory")
+	}
+	return nil
+}
+
+// Copies persisted |fragment| (and its TimeIndex, if any) to |to|, which
+// differs only in its Journal.
+func copyFragment(cfs cloudstore.FileSystem, fragment, to journal.Fragment) error {
+	var r, err = cfs.Open(fragment.ContentPath())
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	if err = cfs.MkdirAll(to.Journal.String(), 0750); err != nil {
+		return err
+	}
+	// As with persisted fragments, the TimeIndex is stored before content.
+	if index, err := journal.LoadTimeIndex(cfs, fragment); err != nil {
+		return err
+	} else if index != nil {
+		if err = journal.StoreTimeIndex(cfs, to, index); err != nil {
+			return err
+		}
+	}
+	w, err := cfs.OpenFile(to.ContentPath(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
+	if os.IsExist(err) {
+		return nil // Already copied.
+	} else if err != nil {
+		return err
+	}
+
+	_, err = cfs.CopyAtomic(w, r)
+	return err
+}
+
+func isKeyNotFound(err error) bool {
+	var etcdErr, _ = err.(etcd.Error)
+	return etcdErr.Code == etcd.ErrorCodeKeyNotFound
+}
@@ -0,0 +1,188 @@
+package gazette
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+type DeleteAPISuite struct {
+	keys *consensus.MockKeysAPI
+	cfs  cloudstore.FileSystem
+	mux  *mux.Router
+}
+
+func (s *DeleteAPISuite) SetUpTest(c *gc.C) {
+	s.keys = new(consensus.MockKeysAPI)
+	s.mux = mux.NewRouter()
+	s.cfs = cloudstore.NewTmpFileSystem()
+	NewDeleteAPI(s.cfs, s.keys).Register(s.mux)
+
+	// Persisted fragments of the journal, and of a nested journal.
+	s.writeFragment(c, "journal/name", 0, 100)
+	s.writeFragment(c, "journal/name", 100, 200)
+	s.writeFragment(c, "journal/name/nested", 0, 100)
+}
+
+func (s *DeleteAPISuite) TearDownTest(c *gc.C) {
+	c.Check(s.cfs.Close(), gc.IsNil)
+}
+
+func (s *DeleteAPISuite) TestDeleteWithMovedFragments(c *gc.C) {
+	// The journal has two replicas, one of which has already been torn down.
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		&etcd.GetOptions{Recursive: true}).Return(&etcd.Response{
+		Index: 1234,
+		Node: &etcd.Node{Key: ServiceRoot + "/items/journal%2Fname", Dir: true, Nodes: etcd.Nodes{
+			{Key: ServiceRoot + "/items/journal%2Fname/http%3A%2F%2Fbroker", Value: "ready"},
+			{Key: ServiceRoot + "/items/journal%2Fname/http%3A%2F%2Freplica", Value: "deleted"},
+		}},
+	}, nil)
+
+	s.keys.On("Set", mock.Anything, ServiceRoot+"/deleted/journal%2Fname",
+		`{"fragments":"move","destination":"archive"}`, &etcd.SetOptions{TTL: kDeleteTombstoneTTL}).
+		Return(&etcd.Response{Index: 1235}, nil)
+
+	// Expect to watch until the remaining replica is torn down.
+	var watcher consensus.MockWatcher
+	s.keys.On("Watcher", ServiceRoot+"/items/journal%2Fname",
+		&etcd.WatcherOptions{AfterIndex: 1234, Recursive: true}).Return(&watcher)
+
+	watcher.On("Next", mock.Anything).Return(&etcd.Response{
+		Action: "compareAndSwap",
+		Node:   &etcd.Node{Key: ServiceRoot + "/items/journal%2Fname/http%3A%2F%2Fbroker", Value: "deleted"},
+	}, nil).Once()
+
+	s.expectRemoval()
+
+	var w = s.delete("/journal/name?fragments=move&destination=archive")
+	c.Check(w.Code, gc.Equals, http.StatusNoContent)
+	s.keys.AssertExpectations(c)
+	watcher.AssertExpectations(c)
+
+	// Expect fragments of the journal were moved, and the nested journal is untouched.
+	c.Check(s.fragmentNames(c, "journal/name"), gc.DeepEquals, []string{
+		"journal/name/nested/0000000000000000-0000000000000064-0000000000000000000000000000000000000000",
+	})
+	c.Check(s.fragmentNames(c, "archive/journal/name"), gc.DeepEquals, []string{
+		"archive/journal/name/0000000000000000-0000000000000064-0000000000000000000000000000000000000000",
+		"archive/journal/name/0000000000000064-00000000000000c8-0000000000000000000000000000000000000000",
+	})
+}
+
+func (s *DeleteAPISuite) TestDeleteWithDeletedFragments(c *gc.C) {
+	// All replicas have already been torn down.
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		&etcd.GetOptions{Recursive: true}).Return(&etcd.Response{
+		Index: 1234,
+		Node: &etcd.Node{Key: ServiceRoot + "/items/journal%2Fname", Dir: true, Nodes: etcd.Nodes{
+			{Key: ServiceRoot + "/items/journal%2Fname/http%3A%2F%2Fbroker", Value: "deleted"},
+		}},
+	}, nil)
+
+	s.keys.On("Set", mock.Anything, ServiceRoot+"/deleted/journal%2Fname",
+		`{"fragments":"delete"}`, &etcd.SetOptions{TTL: kDeleteTombstoneTTL}).
+		Return(&etcd.Response{Index: 1235}, nil)
+	s.keys.On("Watcher", ServiceRoot+"/items/journal%2Fname",
+		&etcd.WatcherOptions{AfterIndex: 1234, Recursive: true}).Return(new(consensus.MockWatcher))
+
+	s.expectRemoval()
+
+	var w = s.delete("/journal/name?fragments=delete")
+	c.Check(w.Code, gc.Equals, http.StatusNoContent)
+	s.keys.AssertExpectations(c)
+
+	c.Check(s.fragmentNames(c, "journal/name"), gc.DeepEquals, []string{
+		"journal/name/nested/0000000000000000-0000000000000064-0000000000000000000000000000000000000000",
+	})
+}
+
+func (s *DeleteAPISuite) TestJournalNotFound(c *gc.C) {
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		&etcd.GetOptions{Recursive: true}).
+		Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+
+	var w = s.delete("/journal/name")
+	c.Check(w.Code, gc.Equals, http.StatusNotFound)
+	s.keys.AssertExpectations(c)
+}
+
+func (s *DeleteAPISuite) TestNotAuthorized(c *gc.C) {
+	var m = mux.NewRouter()
+	NewDeleteAPI(s.cfs, s.keys).UseAuthorizer(staticAuthorizer("secret")).Register(m)
+
+	var req, _ = http.NewRequest("DELETE", "/journal/name?fragments=keep", nil)
+	req.Header.Set("Authorization", "Bearer other")
+	var w = httptest.NewRecorder()
+
+	m.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusForbidden)
+
+	// Expect no Etcd requests were made, and fragments remain.
+	s.keys.AssertExpectations(c)
+	c.Check(s.fragmentNames(c, "journal/name/"), gc.HasLen, 3)
+}
+
+func (s *DeleteAPISuite) TestInvalidArguments(c *gc.C) {
+	for _, query := range []string{
+		"/journal/name?fragments=other",
+		"/journal/name?fragments=move",
+		"/journal/name?fragments=delete&destination=archive",
+		"/journal/name?other=foo",
+	} {
+		c.Check(s.delete(query).Code, gc.Equals, http.StatusBadRequest)
+	}
+}
+
+func (s *DeleteAPISuite) expectRemoval() {
+	s.keys.On("Delete", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		(*etcd.DeleteOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+	s.keys.On("Delete", mock.Anything, ServiceRoot+"/producers/journal%2Fname",
+		(*etcd.DeleteOptions)(nil)).Return(&etcd.Response{}, nil)
+	s.keys.On("Delete", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		&etcd.DeleteOptions{Recursive: true, Dir: true}).Return(&etcd.Response{}, nil)
+}
+
+func (s *DeleteAPISuite) delete(path string) *httptest.ResponseRecorder {
+	var req, _ = http.NewRequest("DELETE", path, nil)
+	var w = httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	return w
+}
+
+func (s *DeleteAPISuite) writeFragment(c *gc.C, name journal.Name, begin, end int64) {
+	var fragment = journal.Fragment{Journal: name, Begin: begin, End: end}
+
+	c.Assert(s.cfs.MkdirAll(name.String(), 0750), gc.IsNil)
+	f, err := s.cfs.OpenFile(fragment.ContentPath(), os.O_WRONLY|os.O_CREATE, 0640)
+	c.Assert(err, gc.IsNil)
+	_, err = f.Write(make([]byte, end-begin))
+	c.Assert(err, gc.IsNil)
+	c.Assert(f.Close(), gc.IsNil)
+}
+
+func (s *DeleteAPISuite) fragmentNames(c *gc.C, prefix string) []string {
+	var names []string
+	c.Assert(s.cfs.Walk(prefix, func(name string, info os.FileInfo, err error) error {
+		// Expect fragments retain their content.
+		var fragment, _ = journal.ParseFragment(journal.Name(path.Dir(name)), path.Base(name))
+		c.Check(info.Size(), gc.Equals, fragment.Size())
+
+		names = append(names, name)
+		return err
+	}), gc.IsNil)
+	return names
+}
+
+var _ = gc.Suite(&DeleteAPISuite{})
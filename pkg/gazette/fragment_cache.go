@@ -0,0 +1,293 @@
+package gazette
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/journal"
+	"github.com/LiveRamp/gazette/pkg/metrics"
+)
+
+// FragmentCache is a bounded, disk-backed, read-through cache of remote
+// fragment content. The first read of a remote fragment copies its complete,
+// decompressed content from the cloudstore.FileSystem into a local file, and
+// that and subsequent reads are then served from the local file via ReadAt.
+// Cached fragments are evicted in least-recently-used order once the total
+// size of cached content exceeds the cache bound.
+//
+// Backing files are unlinked upon creation and are held only by open
+// descriptors, so cached content never outlives the process. An evicted
+// fragment's file is closed (and its disk space released) after in-flight
+// readers of the fragment complete. The size of a fragment counts against
+// the cache bound from before its fill begins until its file is closed, so
+// the bound may be exceeded only while fills are in progress or evicted
+// fragments are still being read.
+type FragmentCache struct {
+	dir      string
+	maxBytes int64
+	cfs      cloudstore.FileSystem
+
+	lru     *list.List               // Of *cacheEntry, most-recently used at front.
+	entries map[string]*list.Element // Keyed on Fragment.ContentPath().
+	size    int64                    // Total size of entries having open references.
+	mu      sync.Mutex
+}
+
+type cacheEntry struct {
+	key  string
+	size int64
+	file *os.File
+
+	// |ready| is closed once the entry is filled, after which |file| and
+	// |err| may be read.
+	ready chan struct{}
+	err   error
+
+	// Number of readers of |file|, plus one if the entry is held by the cache.
+	// |size| is counted by the cache until the last reference is released.
+	refs int
+}
+
+// NewFragmentCache returns a FragmentCache which fills from |cfs| into files
+// of local directory |dir|, retaining at most |maxBytes| of fragment content.
+func NewFragmentCache(dir string, maxBytes int64, cfs cloudstore.FileSystem) (*FragmentCache, error) {
+	if maxBytes <= 0 {
+		return nil, fmt.Errorf("invalid maxBytes: %d", maxBytes)
+	} else if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FragmentCache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		cfs:      cfs,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+	}, nil
+}
+
+// ReaderFromOffset returns a reader of |fragment| content beginning at
+// |offset|, which must be closed when reading completes. Local fragments
+// and fragments larger than the cache bound are read directly, as by
+// Fragment.ReaderFromOffset. Otherwise, the fragment is filled into the
+// cache if not already present, and read from its cached file. A fill
+// performed on behalf of the caller is aborted if |ctx| is cancelled.
+func (c *FragmentCache) ReaderFromOffset(ctx context.Context, fragment journal.Fragment,
+	offset int64) (io.ReadCloser, error) {
+
+	if fragment.IsLocal() || fragment.Size() > c.maxBytes {
+		return fragment.ReaderFromOffset(offset, c.cfs)
+	}
+
+	var entry, err = c.acquire(ctx, fragment)
+	if err != nil {
+		return nil, err
+	}
+	return &cacheReader{
+		SectionReader: io.NewSectionReader(entry.file,
+			offset-fragment.Begin, fragment.End-offset),
+		cache: c,
+		entry: entry,
+	}, nil
+}
+
+// acquire returns a filled cacheEntry of |fragment| having a reference held
+// on behalf of the caller, filling the entry if required.
+func (c *FragmentCache) acquire(ctx context.Context, fragment journal.Fragment) (*cacheEntry, error) {
+	var key = fragment.ContentPath()
+
+	c.mu.Lock()
+	if elem, ok := c.entries[key]; ok {
+		var entry = elem.Value.(*cacheEntry)
+		entry.refs++
+		c.lru.MoveToFront(elem)
+		c.mu.Unlock()
+
+		metrics.FragmentCacheHitsTotal.Inc()
+
+		// Wait for a concurrent fill of the entry to complete.
+		select {
+		case <-entry.ready:
+		case <-ctx.Done():
+			c.release(entry)
+			return nil, ctx.Err()
+		}
+
+		if entry.err == nil {
+			return entry, nil
+		}
+		c.release(entry)
+
+		if (entry.err == context.Canceled || entry.err == context.DeadlineExceeded) &&
+			ctx.Err() == nil {
+			// The fill was aborted by the context of the reader which began it,
+			// rather than failing. Retry on behalf of this caller.
+			return c.acquire(ctx, fragment)
+		}
+		return nil, entry.err
+	}
+
+	// Insert an entry to be filled by this caller, and reserve its size before
+	// the fill begins. Concurrent readers of the fragment will wait for the
+	// fill to complete.
+	var entry = &cacheEntry{
+		key:   key,
+		size:  fragment.Size(),
+		ready: make(chan struct{}),
+		refs:  2,
+	}
+	c.entries[key] = c.lru.PushFront(entry)
+	c.size += entry.size
+	metrics.FragmentCacheBytes.Add(float64(entry.size))
+	c.evict()
+	c.mu.Unlock()
+
+	metrics.FragmentCacheMissesTotal.Inc()
+
+	entry.file, entry.err = c.fill(ctx, fragment)
+
+	if entry.err != nil {
+		// Remove the failed entry, so that a later read may retry the fill.
+		c.mu.Lock()
+		c.remove(entry)
+		c.releaseLocked(entry)
+		c.mu.Unlock()
+	}
+	close(entry.ready)
+
+	if entry.err != nil {
+		c.release(entry)
+		return nil, entry.err
+	}
+	return entry, nil
+}
+
+// fill copies the complete content of |fragment| into a new, unlinked file.
+// The copy fails with the error of |ctx| if it's cancelled.
+func (c *FragmentCache) fill(ctx context.Context, fragment journal.Fragment) (*os.File, error) {
+	var file, err = ioutil.TempFile(c.dir, "fragment-cache-")
+	if err != nil {
+		return nil, err
+	} else if err = os.Remove(file.Name()); err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	var reader io.ReadCloser
+	var n int64
+
+	if reader, err = fragment.ReaderFromOffset(fragment.Begin, c.cfs); err == nil {
+		n, err = io.Copy(file, contextReader{ctx: ctx, Reader: reader})
+		reader.Close()
+	}
+	if err == nil && n != fragment.Size() {
+		err = fmt.Errorf("fragment content is %d bytes (expected %d)", n, fragment.Size())
+	}
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+// evict removes least-recently used entries until the cache is within its
+// bound. Entries which are still being filled are skipped. The size of an
+// evicted entry which is still being read is counted until its readers
+// complete. c.mu must be held.
+func (c *FragmentCache) evict() {
+	for elem := c.lru.Back(); elem != nil && c.size > c.maxBytes; {
+		var entry = elem.Value.(*cacheEntry)
+		elem = elem.Prev()
+
+		select {
+		case <-entry.ready:
+		default:
+			continue // Fill is in progress.
+		}
+
+		log.WithFields(log.Fields{"fragment": entry.key, "size": entry.size}).
+			Debug("evicting cached fragment")
+
+		metrics.FragmentCacheEvictionsTotal.Inc()
+
+		c.remove(entry)
+		c.releaseLocked(entry)
+	}
+}
+
+// remove drops |entry| from the cache index. c.mu must be held.
+func (c *FragmentCache) remove(entry *cacheEntry) {
+	if elem, ok := c.entries[entry.key]; ok && elem.Value.(*cacheEntry) == entry {
+		c.lru.Remove(elem)
+		delete(c.entries, entry.key)
+	}
+}
+
+// release drops a reference of |entry|. On the last reference, its file is
+// closed and its size is no longer counted by the cache.
+func (c *FragmentCache) release(entry *cacheEntry) {
+	c.mu.Lock()
+	c.releaseLocked(entry)
+	c.mu.Unlock()
+}
+
+func (c *FragmentCache) releaseLocked(entry *cacheEntry) {
+	if entry.refs--; entry.refs != 0 {
+		return
+	}
+	c.size -= entry.size
+	metrics.FragmentCacheBytes.Sub(float64(entry.size))
+
+	if entry.file != nil {
+		if err := entry.file.Close(); err != nil {
+			log.WithFields(log.Fields{"err": err, "fragment": entry.key}).
+				Warn("failed to close cached fragment")
+		}
+	}
+}
+
+// fragmentReader returns a reader of |fragment| beginning at |offset|, which
+// reads through |cache| if it is non-nil.
+func fragmentReader(ctx context.Context, cache *FragmentCache, cfs cloudstore.FileSystem,
+	fragment journal.Fragment, offset int64) (io.ReadCloser, error) {
+
+	if cache != nil {
+		return cache.ReaderFromOffset(ctx, fragment, offset)
+	}
+	return fragment.ReaderFromOffset(offset, cfs)
+}
+
+// cacheReader reads a cached fragment, and releases its entry on Close.
+type cacheReader struct {
+	*io.SectionReader
+
+	cache *FragmentCache
+	entry *cacheEntry
+	once  sync.Once
+}
+
+func (r *cacheReader) Close() error {
+	r.once.Do(func() { r.cache.release(r.entry) })
+	return nil
+}
+
+// contextReader is an io.Reader which fails with the error of |ctx| once it
+// has been cancelled.
+type contextReader struct {
+	ctx context.Context
+	io.Reader
+}
+
+func (r contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.Reader.Read(p)
+}
@@ -0,0 +1,244 @@
+package gazette
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+
+	gc "github.com/go-check/check"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+type FragmentCacheSuite struct {
+	dir string
+	cfs cloudstore.FileSystem
+}
+
+func (s *FragmentCacheSuite) SetUpTest(c *gc.C) {
+	s.dir = c.MkDir()
+	s.cfs = cloudstore.NewTmpFileSystem()
+}
+
+func (s *FragmentCacheSuite) TearDownTest(c *gc.C) {
+	c.Check(s.cfs.Close(), gc.IsNil)
+}
+
+func (s *FragmentCacheSuite) TestFillAndReadFromOffsets(c *gc.C) {
+	var cache, err = NewFragmentCache(s.dir, 1000, s.cfs)
+	c.Assert(err, gc.IsNil)
+
+	for _, codec := range []journal.CompressionCodec{journal.CodecNone, journal.CodecGzip} {
+		var fragment = s.fragment(c, "a/journal", 100, "some fragment content", codec)
+
+		s.expectRead(c, cache, fragment, 100, "some fragment content")
+		s.expectRead(c, cache, fragment, 105, "fragment content")
+		s.expectRead(c, cache, fragment, 121, "")
+		c.Check(cache.lru.Len(), gc.Equals, 1)
+
+		// Reads are served from the cache after the fragment is removed.
+		c.Check(s.cfs.Remove(fragment.ContentPath()), gc.IsNil)
+		s.expectRead(c, cache, fragment, 114, "content")
+
+		s.evictAll(cache)
+	}
+	// Backing files are unlinked, and never visible in the cache directory.
+	entries, err := ioutil.ReadDir(s.dir)
+	c.Check(err, gc.IsNil)
+	c.Check(entries, gc.HasLen, 0)
+}
+
+func (s *FragmentCacheSuite) TestLeastRecentlyUsedEviction(c *gc.C) {
+	var cache, err = NewFragmentCache(s.dir, 20, s.cfs)
+	c.Assert(err, gc.IsNil)
+
+	var f1 = s.fragment(c, "a/journal", 0, "0123456789", journal.CodecNone)
+	var f2 = s.fragment(c, "a/journal", 10, "abcdefghij", journal.CodecNone)
+	var f3 = s.fragment(c, "a/journal", 20, "ABCDEFGHIJ", journal.CodecNone)
+
+	s.expectRead(c, cache, f1, 0, "0123456789")
+	s.expectRead(c, cache, f2, 10, "abcdefghij")
+
+	// Open a reader of |f2| prior to its eviction.
+	r, err := cache.ReaderFromOffset(context.Background(), f2, 12)
+	c.Assert(err, gc.IsNil)
+
+	s.expectRead(c, cache, f1, 5, "56789") // |f1| is now most-recently used.
+	c.Check(cache.size, gc.Equals, int64(20))
+
+	// Filling |f3| evicts |f2|. Its size is counted until its reader is
+	// closed, so |f1| is also evicted to remain within the cache bound.
+	s.expectRead(c, cache, f3, 20, "ABCDEFGHIJ")
+	c.Check(cache.size, gc.Equals, int64(20))
+	c.Check(s.cachedKeys(cache), gc.DeepEquals, []string{f3.ContentPath()})
+
+	// The evicted reader remains readable until closed.
+	b, err := ioutil.ReadAll(r)
+	c.Check(err, gc.IsNil)
+	c.Check(string(b), gc.Equals, "cdefghij")
+	c.Check(r.Close(), gc.IsNil)
+	c.Check(cache.size, gc.Equals, int64(10))
+
+	// A read of |f2| re-fills it from the FileSystem.
+	s.expectRead(c, cache, f2, 15, "fghij")
+	c.Check(s.cachedKeys(cache), gc.DeepEquals, []string{f2.ContentPath(), f3.ContentPath()})
+}
+
+func (s *FragmentCacheSuite) TestLargeFragmentsBypassCache(c *gc.C) {
+	var cache, err = NewFragmentCache(s.dir, 10, s.cfs)
+	c.Assert(err, gc.IsNil)
+
+	var fragment = s.fragment(c, "a/journal", 0, "larger than the cache", journal.CodecNone)
+	s.expectRead(c, cache, fragment, 7, "than the cache")
+	c.Check(cache.lru.Len(), gc.Equals, 0)
+}
+
+func (s *FragmentCacheSuite) TestFailedFillIsRetried(c *gc.C) {
+	var cache, err = NewFragmentCache(s.dir, 100, s.cfs)
+	c.Assert(err, gc.IsNil)
+
+	var fragment = journal.Fragment{Journal: "a/journal", Begin: 0, End: 7}
+	_, err = cache.ReaderFromOffset(context.Background(), fragment, 0)
+	c.Check(os.IsNotExist(err), gc.Equals, true)
+	c.Check(cache.lru.Len(), gc.Equals, 0)
+
+	// Persisted content which doesn't match the fragment's size is an error.
+	s.persist(c, fragment, "short")
+
+	_, err = cache.ReaderFromOffset(context.Background(), fragment, 0)
+	c.Check(err, gc.ErrorMatches, `fragment content is 5 bytes \(expected 7\)`)
+	c.Check(cache.lru.Len(), gc.Equals, 0)
+
+	s.persist(c, fragment, "content")
+	s.expectRead(c, cache, fragment, 3, "tent")
+	c.Check(cache.lru.Len(), gc.Equals, 1)
+}
+
+func (s *FragmentCacheSuite) TestSizeIsReservedBeforeFill(c *gc.C) {
+	var cache, err = NewFragmentCache(s.dir, 20, s.cfs)
+	c.Assert(err, gc.IsNil)
+
+	var f1 = s.fragment(c, "a/journal", 0, "0123456789", journal.CodecNone)
+	var f2 = s.fragment(c, "a/journal", 10, "abcdefghijklmno", journal.CodecNone)
+	s.expectRead(c, cache, f1, 0, "0123456789")
+
+	// Begin a fill of |f2| which blocks on its first read of the FileSystem.
+	var cfs = &blockingFileSystem{FileSystem: s.cfs, opened: make(chan struct{}), proceed: make(chan struct{})}
+	cache.cfs = cfs
+
+	var done = make(chan struct{})
+	go func() {
+		s.expectRead(c, cache, f2, 10, "abcdefghijklmno")
+		close(done)
+	}()
+	<-cfs.opened
+
+	// |f2| is counted, and |f1| was evicted to make room, before the fill completes.
+	cache.mu.Lock()
+	c.Check(cache.size, gc.Equals, int64(15))
+	c.Check(s.cachedKeys(cache), gc.DeepEquals, []string{f2.ContentPath()})
+	cache.mu.Unlock()
+
+	close(cfs.proceed)
+	<-done
+	c.Check(cache.size, gc.Equals, int64(15))
+}
+
+func (s *FragmentCacheSuite) TestCancelledFillIsRetried(c *gc.C) {
+	var cache, err = NewFragmentCache(s.dir, 100, s.cfs)
+	c.Assert(err, gc.IsNil)
+
+	var fragment = s.fragment(c, "a/journal", 0, "content", journal.CodecNone)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+
+	_, err = cache.ReaderFromOffset(ctx, fragment, 0)
+	c.Check(err, gc.Equals, context.Canceled)
+	c.Check(cache.lru.Len(), gc.Equals, 0)
+	c.Check(cache.size, gc.Equals, int64(0))
+
+	s.expectRead(c, cache, fragment, 3, "tent")
+	c.Check(cache.size, gc.Equals, int64(7))
+}
+
+// fragment returns a Fragment of |name| which begins at |begin|, and has the
+// content and compression codec of a persisted |content|.
+func (s *FragmentCacheSuite) fragment(c *gc.C, name journal.Name, begin int64,
+	content string, codec journal.CompressionCodec) journal.Fragment {
+
+	var fragment = journal.Fragment{
+		Journal: name,
+		Begin:   begin,
+		End:     begin + int64(len(content)),
+		Codec:   codec,
+	}
+	s.persist(c, fragment, content)
+	return fragment
+}
+
+// persist writes |content| to the FileSystem path of |fragment|.
+func (s *FragmentCacheSuite) persist(c *gc.C, fragment journal.Fragment, content string) {
+	c.Assert(s.cfs.MkdirAll(fragment.Journal.String(), 0750), gc.IsNil)
+
+	var buf bytes.Buffer
+	var w, err = journal.NewCompressor(&buf, fragment.Codec)
+	c.Assert(err, gc.IsNil)
+	_, err = io.WriteString(w, content)
+	c.Assert(err, gc.IsNil)
+	c.Assert(w.Close(), gc.IsNil)
+
+	f, err := s.cfs.OpenFile(fragment.ContentPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	c.Assert(err, gc.IsNil)
+	_, err = s.cfs.CopyAtomic(f, &buf)
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *FragmentCacheSuite) expectRead(c *gc.C, cache *FragmentCache,
+	fragment journal.Fragment, offset int64, expect string) {
+
+	var r, err = cache.ReaderFromOffset(context.Background(), fragment, offset)
+	c.Assert(err, gc.IsNil)
+
+	b, err := ioutil.ReadAll(r)
+	c.Check(err, gc.IsNil)
+	c.Check(string(b), gc.Equals, expect)
+	c.Check(r.Close(), gc.IsNil)
+}
+
+func (s *FragmentCacheSuite) cachedKeys(cache *FragmentCache) []string {
+	var keys []string
+	for elem := cache.lru.Front(); elem != nil; elem = elem.Next() {
+		keys = append(keys, elem.Value.(*cacheEntry).key)
+	}
+	return keys
+}
+
+func (s *FragmentCacheSuite) evictAll(cache *FragmentCache) {
+	cache.mu.Lock()
+	defer cache.mu.Unlock()
+
+	var maxBytes = cache.maxBytes
+	cache.maxBytes = 0
+	cache.evict()
+	cache.maxBytes = maxBytes
+}
+
+// blockingFileSystem signals |opened| as a file is opened, and blocks the
+// open until |proceed| is closed.
+type blockingFileSystem struct {
+	cloudstore.FileSystem
+	opened, proceed chan struct{}
+}
+
+func (fs *blockingFileSystem) Open(name string) (http.File, error) {
+	close(fs.opened)
+	<-fs.proceed
+	return fs.FileSystem.Open(name)
+}
+
+var _ = gc.Suite(&FragmentCacheSuite{})
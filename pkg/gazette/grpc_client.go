@@ -0,0 +1,407 @@
+package gazette
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/golang-lru"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
+	"github.com/LiveRamp/gazette/pkg/keepalive"
+	"github.com/LiveRamp/gazette/pkg/metrics"
+)
+
+// Size of content chunks streamed by GRPCClient appends.
+const kAppendChunkSize = 1 << 15 // 32K.
+
+// GRPCClient is a journal.Client which uses the Journal gRPC service. By
+// default, operations are applied against the default endpoint. The client
+// caches routes of journals from route tokens returned by the service, and
+// directly routes future operations to the journal's broker. Brokers are
+// assumed to serve the Journal gRPC service on the port of the default
+// endpoint.
+type GRPCClient struct {
+	// Endpoint which is queried by default, in "host:port" format.
+	defaultEndpoint string
+	// Port of the Journal gRPC service.
+	port string
+
+	// Maps journal.Name to a cached broker endpoint for the journal.
+	routeCache *lru.Cache
+
+	// Dialed connections, indexed on endpoint.
+	conns   map[string]*grpc.ClientConn
+	connsMu sync.Mutex
+
+	// HTTP Client used to directly fetch persisted fragments.
+	httpClient httpClient
+}
+
+var _ journal.Client = (*GRPCClient)(nil)
+
+// NewGRPCClient returns a new GRPCClient of the Journal gRPC service at
+// |endpoint|, in "host:port" format.
+func NewGRPCClient(endpoint string) (*GRPCClient, error) {
+	var _, port, err = net.SplitHostPort(endpoint)
+	if err != nil {
+		return nil, err
+	}
+	cache, err := lru.New(kClientRouteCacheSize)
+	if err != nil {
+		return nil, err
+	}
+	return &GRPCClient{
+		defaultEndpoint: endpoint,
+		port:            port,
+		routeCache:      cache,
+		conns:           make(map[string]*grpc.ClientConn),
+		httpClient:      &http.Client{Transport: MakeHttpTransport()},
+	}, nil
+}
+
+// Close closes all connections of the GRPCClient.
+func (c *GRPCClient) Close() error {
+	c.connsMu.Lock()
+	defer c.connsMu.Unlock()
+
+	var err error
+	for ep, conn := range c.conns {
+		if closeErr := conn.Close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		delete(c.conns, ep)
+	}
+	return err
+}
+
+func (c *GRPCClient) Head(args journal.ReadArgs) (journal.ReadResult, *url.URL) {
+	var result, fragmentLocation, stream, cancel = c.read(args, true)
+	if stream != nil {
+		cancel()
+	}
+	return result, fragmentLocation
+}
+
+func (c *GRPCClient) Get(args journal.ReadArgs) (journal.ReadResult, io.ReadCloser) {
+	// Perform a non-blocking Head first, to check for an available persisted fragment.
+	var headArgs = args
+	headArgs.Blocking = false
+	headArgs.Deadline = time.Time{}
+	var result, fragmentLocation = c.Head(headArgs)
+
+	if result.Error == journal.ErrNotYetAvailable {
+		// Fall-through, re-attempting as a streamed read.
+	} else if result.Error != nil {
+		return result, nil
+	} else if fragmentLocation != nil {
+		if body, err := openFragment(c.httpClient, fragmentLocation, result); err != nil {
+			result.Error = err
+			return result, nil
+		} else {
+			return result, body
+		}
+	}
+	// No persisted fragment is available. Stream content from the service.
+	result, _, stream, cancel := c.read(args, false)
+	if result.Error != nil {
+		if stream != nil {
+			cancel()
+		}
+		return result, nil
+	}
+	return result, &readStreamReader{stream: stream, cancel: cancel}
+}
+
+// Creates journal |name| with the cluster default JournalSpec.
+func (c *GRPCClient) Create(name journal.Name) error {
+	return c.CreateWithSpec(name, journal.JournalSpec{})
+}
+
+// Creates journal |name| with JournalSpec |spec|.
+func (c *GRPCClient) CreateWithSpec(name journal.Name, spec journal.JournalSpec) error {
+	var body, err = json.Marshal(spec)
+	if err != nil {
+		return err
+	}
+	// Issue the request against the default endpoint.
+	client, err := c.client(c.defaultEndpoint)
+	if err != nil {
+		return err
+	}
+	response, err := client.Create(context.Background(),
+		&journal.CreateRequest{Journal: name, Spec: body})
+	if err != nil {
+		return err
+	}
+	return journal.ErrorForStatus(response.Status)
+}
+
+func (c *GRPCClient) Write(name journal.Name, buffer []byte) (*journal.AsyncAppend, error) {
+	return c.ReadFrom(name, bytes.NewReader(buffer))
+}
+
+// ReadFrom appends |r| to journal |name|. The append is performed
+// synchronously, and the returned AsyncAppend is already Ready.
+func (c *GRPCClient) ReadFrom(name journal.Name, r io.Reader) (*journal.AsyncAppend, error) {
+	var async = &journal.AsyncAppend{
+		AppendResult: c.Put(journal.AppendArgs{Journal: name, Content: r}),
+		Ready:        make(chan struct{}),
+	}
+	close(async.Ready)
+	return async, async.Error
+}
+
+// Put appends |args.Content| to |args.Journal|, and returns the result.
+func (c *GRPCClient) Put(args journal.AppendArgs) journal.AppendResult {
+	if _, ok := c.routeCache.Get(args.Journal); !ok {
+		// Speculatively issue a Head to fill the route cache for this journal.
+		var result, _ = c.Head(journal.ReadArgs{Journal: args.Journal, Blocking: false, Offset: -1})
+		if result.Error != nil && result.Error != journal.ErrNotYetAvailable {
+			return journal.AppendResult{Error: result.Error}
+		}
+	}
+	var ctx = args.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	// Appends are aborted by cancelling |ctx|: closing the stream would instead
+	// commit the content sent thus far.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	var client, err = c.client(c.endpoint(args.Journal))
+	if err != nil {
+		return journal.AppendResult{Error: err}
+	}
+	stream, err := client.Append(ctx)
+	if err != nil {
+		c.routeCache.Remove(args.Journal)
+		return journal.AppendResult{Error: err}
+	}
+
+	var req = &journal.AppendRequest{
+		Journal:  args.Journal,
+		Producer: args.Producer,
+		Sequence: args.Sequence,
+	}
+	if args.ExpectWriteHead != nil {
+		req.CheckWriteHead, req.ExpectWriteHead = true, *args.ExpectWriteHead
+	}
+	err = stream.Send(req)
+
+	var buffer = make([]byte, kAppendChunkSize)
+	var written int64
+
+	for err == nil {
+		var n int
+		if n, err = args.Content.Read(buffer); n != 0 {
+			if sendErr := stream.Send(&journal.AppendRequest{Content: buffer[:n]}); sendErr != nil {
+				err = sendErr
+			}
+			written += int64(n)
+		}
+	}
+
+	// io.EOF of Send indicates the service has already responded, which may be
+	// read with CloseAndRecv. Any other error aborts the append.
+	if err != io.EOF {
+		return journal.AppendResult{Error: err}
+	}
+	response, err := stream.CloseAndRecv()
+	if err != nil {
+		c.routeCache.Remove(args.Journal)
+		return journal.AppendResult{Error: err}
+	}
+	c.updateRoute(args.Journal, response.RouteToken)
+
+	var result = journal.AppendResult{
+		Error:      journal.ErrorForStatus(response.Status),
+		WriteHead:  response.WriteHead,
+		RouteToken: response.RouteToken,
+		RetryAfter: time.Duration(response.RetryAfter),
+	}
+	if result.Error == nil {
+		metrics.GazetteWriteBytesTotal.Add(float64(written))
+	}
+	return result
+}
+
+// read issues a Read RPC of |args|, and returns the initial ReadResult and
+// (iff successful) the stream of the RPC and its CancelFunc. If the journal
+// is served by another broker, the read is retried once against that broker.
+func (c *GRPCClient) read(args journal.ReadArgs, metadataOnly bool) (journal.ReadResult,
+	*url.URL, journal.Journal_ReadClient, context.CancelFunc) {
+
+	var req = &journal.ReadRequest{
+		Journal:      args.Journal,
+		Offset:       args.Offset,
+		Block:        args.Blocking,
+		MetadataOnly: metadataOnly,
+	}
+	if !args.Timestamp.IsZero() {
+		req.Timestamp = args.Timestamp.UnixNano()
+	}
+	var result, fragmentLocation, stream, cancel = c.readAttempt(args, req)
+
+	if result.Error == journal.ErrNotReplica {
+		// Retry once, against the updated route of the journal.
+		result, fragmentLocation, stream, cancel = c.readAttempt(args, req)
+	}
+	return result, fragmentLocation, stream, cancel
+}
+
+func (c *GRPCClient) readAttempt(args journal.ReadArgs, req *journal.ReadRequest) (journal.ReadResult,
+	*url.URL, journal.Journal_ReadClient, context.CancelFunc) {
+
+	var result journal.ReadResult
+
+	var client, err = c.client(c.endpoint(req.Journal))
+	if err != nil {
+		result.Error = err
+		return result, nil, nil, nil
+	}
+
+	var ctx = args.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	var cancel context.CancelFunc
+	if !args.Deadline.IsZero() {
+		ctx, cancel = context.WithDeadline(ctx, args.Deadline)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+
+	stream, err := client.Read(ctx, req)
+	if err != nil {
+		cancel()
+		c.routeCache.Remove(req.Journal)
+		result.Error = err
+		return result, nil, nil, nil
+	}
+	response, err := stream.Recv()
+	if err != nil {
+		cancel()
+		c.routeCache.Remove(req.Journal)
+		result.Error = err
+		return result, nil, nil, nil
+	}
+	c.updateRoute(req.Journal, response.RouteToken)
+
+	result = journal.ReadResult{
+		Error:      journal.ErrorForStatus(response.Status),
+		Offset:     response.Offset,
+		WriteHead:  response.WriteHead,
+		RouteToken: response.RouteToken,
+	}
+	if result.Error != nil {
+		cancel()
+		return result, nil, nil, nil
+	}
+
+	var fragmentLocation *url.URL
+
+	// Fragment name is optional (it won't be available on blocked requests).
+	if response.Fragment != "" {
+		if result.Fragment, err = journal.ParseFragment(req.Journal, response.Fragment); err != nil {
+			result.Error = fmt.Errorf("parsing fragment: %s", err)
+		}
+	}
+	if response.FragmentModTime != 0 {
+		result.Fragment.RemoteModTime = time.Unix(response.FragmentModTime, 0)
+	}
+	if response.FragmentUrl != "" && result.Error == nil {
+		if fragmentLocation, err = url.Parse(response.FragmentUrl); err != nil {
+			result.Error = fmt.Errorf("parsing fragment URL: %s", err)
+		}
+	}
+	if result.Error != nil {
+		cancel()
+		return result, nil, nil, nil
+	}
+	return result, fragmentLocation, stream, cancel
+}
+
+// endpoint returns the cached broker endpoint of journal |name|, or the
+// default endpoint if there is none.
+func (c *GRPCClient) endpoint(name journal.Name) string {
+	if ep, ok := c.routeCache.Get(name); ok {
+		return ep.(string)
+	}
+	return c.defaultEndpoint
+}
+
+// updateRoute caches the broker endpoint of |token| as the route of
+// journal |name|.
+func (c *GRPCClient) updateRoute(name journal.Name, token journal.RouteToken) {
+	if token == "" {
+		return
+	}
+	// The broker is the first entry of |token|.
+	var broker = string(token)
+	if ind := strings.IndexByte(broker, '|'); ind != -1 {
+		broker = broker[:ind]
+	}
+	if u, err := url.Parse(broker); err != nil || u.Hostname() == "" {
+		c.routeCache.Remove(name)
+	} else {
+		c.routeCache.Add(name, net.JoinHostPort(u.Hostname(), c.port))
+	}
+}
+
+// client returns a JournalClient of |endpoint|, dialing it if required.
+func (c *GRPCClient) client(endpoint string) (journal.JournalClient, error) {
+	c.connsMu.Lock()
+	defer c.connsMu.Unlock()
+
+	var conn, ok = c.conns[endpoint]
+	if !ok {
+		var err error
+		if conn, err = grpc.Dial(endpoint,
+			grpc.WithDialer(keepalive.DialerFunc),
+			grpc.WithInsecure()); err != nil {
+			return nil, err
+		}
+		c.conns[endpoint] = conn
+	}
+	return journal.NewJournalClient(conn), nil
+}
+
+// readStreamReader adapts the content of a Journal_ReadClient stream to
+// an io.ReadCloser.
+type readStreamReader struct {
+	stream journal.Journal_ReadClient
+	cancel context.CancelFunc
+	chunk  []byte
+}
+
+func (r *readStreamReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		// Responses describing a next fragment have no content, and are skipped.
+		if response, err := r.stream.Recv(); err != nil {
+			return 0, err
+		} else {
+			r.chunk = response.Content
+		}
+	}
+	var n = copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+
+	metrics.GazetteReadBytesTotal.Add(float64(n))
+	return n, nil
+}
+
+func (r *readStreamReader) Close() error {
+	r.cancel()
+	return nil
+}
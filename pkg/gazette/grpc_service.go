@@ -0,0 +1,357 @@
+package gazette
+
+import (
+	"encoding/json"
+	"io"
+	"path"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/journal"
+	"github.com/LiveRamp/gazette/pkg/metrics"
+)
+
+// Size of content chunks streamed by JournalService.Read.
+const kReadChunkSize = 1 << 15 // 32K.
+
+// JournalService serves the Journal gRPC service (see journal.proto). Read,
+// Append, and Replicate operations are dispatched to a JournalOpHandler
+// (typically the Router), and journals are created via a CreateAPI. Journal
+// protocol errors are returned as a response Status, while other failures
+// are returned as gRPC errors.
+type JournalService struct {
+	handler    JournalOpHandler
+	cfs        cloudstore.FileSystem
+	create     *CreateAPI
+	authorizer Authorizer
+	cache      *FragmentCache
+}
+
+func NewJournalService(handler JournalOpHandler, cfs cloudstore.FileSystem,
+	create *CreateAPI) *JournalService {
+	return &JournalService{handler: handler, cfs: cfs, create: create}
+}
+
+// UseAuthorizer authorizes operations of the service with |authorizer|.
+func (s *JournalService) UseAuthorizer(authorizer Authorizer) *JournalService {
+	s.authorizer = authorizer
+	return s
+}
+
+// UseFragmentCache reads remote fragments through |cache|.
+func (s *JournalService) UseFragmentCache(cache *FragmentCache) *JournalService {
+	s.cache = cache
+	return s
+}
+
+func (s *JournalService) Read(req *journal.ReadRequest, stream journal.Journal_ReadServer) error {
+	if err := authorizeRPC(s.authorizer, stream.Context(), OpRead, req.Journal); err != nil {
+		var status, _ = journal.StatusForError(err)
+		return stream.Send(&journal.ReadResponse{Status: status})
+	}
+
+	var op = journal.ReadOp{
+		ReadArgs: journal.ReadArgs{
+			Journal:  req.Journal,
+			Offset:   req.Offset,
+			Blocking: false,
+			Context:  stream.Context(),
+		},
+		Result: make(chan journal.ReadResult, 1),
+	}
+	if req.Timestamp != 0 {
+		op.Timestamp = time.Unix(0, req.Timestamp)
+	}
+	// Perform an initial non-blocking read to test for request legality.
+	s.handler.Read(op)
+	var result = <-op.Result
+
+	if !op.Timestamp.IsZero() && result.Error != journal.ErrNotReplica {
+		// The initial read resolved |Timestamp| to an offset. Continue from it.
+		op.Offset, op.Timestamp = result.Offset, time.Time{}
+	}
+	// Switch to requested blocking mode.
+	op.Blocking = req.Block
+
+	if result.Error == journal.ErrNotYetAvailable && req.Block {
+		// Retry, actually blocking this time.
+		s.handler.Read(op)
+		result = <-op.Result
+	}
+
+	var buffer []byte
+
+	// Loop performing incremental reads and streaming to the client.
+	for iter := 0; ; iter++ {
+		if result.Error != nil {
+			if iter != 0 {
+				// Content has already been streamed. End the stream, and let the
+				// client re-issue its read.
+				return nil
+			}
+			var status, ok = journal.StatusForError(result.Error)
+			if !ok {
+				log.WithFields(log.Fields{"err": result.Error, "ReadOp": op}).Warn("read failed")
+				return result.Error
+			}
+			return stream.Send(&journal.ReadResponse{
+				Status:     status,
+				Offset:     result.Offset,
+				WriteHead:  result.WriteHead,
+				RouteToken: result.RouteToken,
+			})
+		}
+
+		if !result.Fragment.IsLocal() && iter != 0 {
+			// We've already streamed a fragment. Force the client to re-issue its
+			// read, and a well-behaved client will then fetch the persisted
+			// fragment directly.
+			return nil
+		}
+
+		// Describe the fragment being read.
+		var response = &journal.ReadResponse{
+			Offset:     result.Offset,
+			WriteHead:  result.WriteHead,
+			RouteToken: result.RouteToken,
+			Fragment:   result.Fragment.ContentName(),
+		}
+		if !result.Fragment.IsLocal() {
+			if url, err := result.Fragment.AsDirectURL(s.cfs, time.Minute); err == nil {
+				response.FragmentUrl = url.String()
+				response.FragmentModTime = result.Fragment.RemoteModTime.Unix()
+			} else if err != cloudstore.ErrURLNotSupported {
+				log.WithFields(log.Fields{"err": err, "fragment": result.Fragment}).
+					Warn("failed to generate remote URL")
+			}
+		}
+		if err := stream.Send(response); err != nil {
+			return err
+		} else if req.MetadataOnly {
+			return nil
+		}
+
+		if !result.Fragment.IsLocal() && s.cache == nil {
+			// An uncached, proxied read of a remote fragment is inefficient. We'll
+			// still do it if explicitly asked, but surface the call via logging.
+			log.WithField("fragment", result.Fragment.ContentPath()).
+				Warn("non-local fragment read")
+		}
+
+		var reader, err = fragmentReader(stream.Context(), s.cache, s.cfs, result.Fragment, result.Offset)
+		if err != nil {
+			log.WithFields(log.Fields{"err": err, "ReadOp": op, "ReadIter": iter}).
+				Warn("failed to get a fragment reader")
+			return err
+		}
+		if buffer == nil {
+			buffer = make([]byte, kReadChunkSize)
+		}
+		var offset = result.Offset
+
+		for err == nil {
+			var n int
+			if n, err = reader.Read(buffer); n != 0 {
+				// Send marshals |buffer| before returning, allowing its re-use.
+				if sendErr := stream.Send(&journal.ReadResponse{
+					Offset:  offset,
+					Content: buffer[:n],
+				}); sendErr != nil {
+					err = sendErr
+				}
+				offset += int64(n)
+			}
+		}
+		reader.Close()
+
+		if err != io.EOF {
+			log.WithFields(log.Fields{"err": err, "ReadOp": op, "ReadIter": iter}).
+				Warn("failed to stream to client")
+			return err
+		}
+		op.Offset = offset
+
+		// Next incremental read.
+		s.handler.Read(op)
+		result = <-op.Result
+	}
+}
+
+func (s *JournalService) Append(stream journal.Journal_AppendServer) error {
+	var req, err = stream.Recv()
+	if err != nil {
+		return err
+	} else if err = authorizeRPC(s.authorizer, stream.Context(), OpAppend, req.Journal); err != nil {
+		var status, _ = journal.StatusForError(err)
+		return stream.SendAndClose(&journal.AppendResponse{Status: status})
+	}
+
+	var op = journal.AppendOp{
+		AppendArgs: journal.AppendArgs{
+			Journal:  req.Journal,
+			Content:  &appendStreamReader{stream: stream, chunk: req.Content},
+			Producer: req.Producer,
+			Sequence: req.Sequence,
+			Context:  stream.Context(),
+		},
+		Result: make(chan journal.AppendResult, 1),
+	}
+	if req.CheckWriteHead {
+		op.ExpectWriteHead = &req.ExpectWriteHead
+	}
+	s.handler.Append(op)
+	var result = <-op.Result
+
+	var status, ok = journal.StatusForError(result.Error)
+	if !ok {
+		return result.Error
+	}
+	return stream.SendAndClose(&journal.AppendResponse{
+		Status:     status,
+		WriteHead:  result.WriteHead,
+		RouteToken: result.RouteToken,
+		RetryAfter: int64(result.RetryAfter),
+	})
+}
+
+func (s *JournalService) Replicate(stream journal.Journal_ReplicateServer) error {
+	// Serve pipelined transactions until the client closes the stream.
+	for {
+		var req, err = stream.Recv()
+		if err == io.EOF {
+			return nil
+		} else if err != nil {
+			return err
+		} else if err = s.replicate(req, stream); err != nil {
+			return err
+		}
+	}
+}
+
+// replicate serves a single transaction of a Replicate stream, described by
+// header request |req|.
+func (s *JournalService) replicate(req *journal.ReplicateRequest,
+	stream journal.Journal_ReplicateServer) error {
+
+	var op = journal.ReplicateOp{
+		ReplicateArgs: journal.ReplicateArgs{
+			Journal:    req.Journal,
+			RouteToken: req.RouteToken,
+			WriteHead:  req.WriteHead,
+			NewSpool:   req.NewSpool,
+			Context:    stream.Context(),
+		},
+		Result: make(chan journal.ReplicateResult, 1),
+	}
+	var result journal.ReplicateResult
+	var err error
+
+	if result.Error = authorizeRPC(s.authorizer, stream.Context(), OpReplicate,
+		req.Journal); result.Error == nil {
+		s.handler.Replicate(op)
+		result = <-op.Result
+	}
+
+	if result.Error != nil {
+		var status, ok = journal.StatusForError(result.Error)
+		if !ok {
+			return result.Error
+		}
+		if err = stream.Send(&journal.ReplicateResponse{
+			Status:    status,
+			WriteHead: result.ErrorWriteHead,
+		}); err != nil {
+			return err
+		}
+		// Discard content the client pipelined through the transaction's commit.
+		for {
+			if req, err = stream.Recv(); err == io.EOF {
+				return io.ErrUnexpectedEOF
+			} else if err != nil {
+				return err
+			} else if req.Commit {
+				return nil
+			}
+		}
+	}
+
+	// Acknowledge verification of the transaction.
+	if err = stream.Send(&journal.ReplicateResponse{Status: journal.Status_OK}); err != nil {
+		result.Writer.Commit(0) // Abort.
+		return err
+	}
+	for err == nil {
+		if req, err = stream.Recv(); err == io.EOF {
+			err = io.ErrUnexpectedEOF // Stream ended without a commit.
+		} else if err == nil && req.Commit {
+			break
+		} else if err == nil {
+			_, err = result.Writer.Write(req.Content)
+		}
+	}
+
+	if err != nil {
+		result.Writer.Commit(0) // Abort.
+	} else {
+		err = result.Writer.Commit(req.CommitDelta)
+	}
+	if err != nil {
+		log.WithField("err", err).Warn("failed to commit transaction")
+		metrics.FailedCommitsTotal.Inc()
+		return err
+	}
+	return stream.Send(&journal.ReplicateResponse{Status: journal.Status_OK})
+}
+
+func (s *JournalService) Create(ctx context.Context,
+	req *journal.CreateRequest) (*journal.CreateResponse, error) {
+
+	var name = path.Clean(req.Journal.String())
+
+	if err := authorizeRPC(s.authorizer, ctx, OpCreate, journal.Name(name)); err != nil {
+		var status, _ = journal.StatusForError(err)
+		return &journal.CreateResponse{Status: status}, nil
+	}
+
+	var spec journal.JournalSpec
+	if len(req.Spec) != 0 {
+		if err := json.Unmarshal(req.Spec, &spec); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "decoding spec: %s", err)
+		}
+	}
+	if err := validateSpec(spec, s.create.requiredReplicas); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	var err = s.create.create(name, spec)
+	if code, ok := journal.StatusForError(err); ok {
+		return &journal.CreateResponse{Status: code}, nil
+	}
+	return nil, err
+}
+
+// appendStreamReader adapts the content of a Journal_AppendServer stream to
+// an io.Reader.
+type appendStreamReader struct {
+	stream journal.Journal_AppendServer
+	chunk  []byte
+}
+
+func (r *appendStreamReader) Read(p []byte) (int, error) {
+	for len(r.chunk) == 0 {
+		// Recv returns io.EOF when the client has closed its side of the stream.
+		if req, err := r.stream.Recv(); err != nil {
+			return 0, err
+		} else {
+			r.chunk = req.Content
+		}
+	}
+	var n = copy(p, r.chunk)
+	r.chunk = r.chunk[n:]
+	return n, nil
+}
@@ -0,0 +1,523 @@
+package gazette
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+	"github.com/stretchr/testify/mock"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+type GRPCSuite struct {
+	localDir string
+	spool    *journal.Spool
+	cfs      cloudstore.FileSystem
+	keys     *consensus.MockKeysAPI
+
+	server *grpc.Server
+	client *GRPCClient
+
+	appendCallbacks    []func(op journal.AppendOp)
+	readCallbacks      []func(op journal.ReadOp)
+	replicateCallbacks []func(op journal.ReplicateOp)
+}
+
+func (s *GRPCSuite) SetUpSuite(c *gc.C) {
+	// Create a file-backed fragment fixture to return.
+	var err error
+	s.localDir, err = ioutil.TempDir("", "grpc-suite")
+	c.Assert(err, gc.IsNil)
+
+	s.spool, err = journal.NewSpool(s.localDir, journal.Mark{Journal: "journal/name", Offset: 12345})
+	c.Check(err, gc.IsNil)
+
+	n, err := s.spool.Write([]byte("XXXXXexpected read fixture"))
+	c.Check(err, gc.IsNil)
+	c.Check(s.spool.Commit(int64(n)), gc.IsNil)
+}
+
+func (s *GRPCSuite) SetUpTest(c *gc.C) {
+	s.cfs = cloudstore.NewTmpFileSystem()
+	s.keys = new(consensus.MockKeysAPI)
+
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, gc.IsNil)
+
+	s.server = grpc.NewServer()
+	journal.RegisterJournalServer(s.server, NewJournalService(s, s.cfs, NewCreateAPI(s.cfs, s.keys, 2)))
+	go s.server.Serve(l)
+
+	s.client, err = NewGRPCClient(l.Addr().String())
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *GRPCSuite) TearDownTest(c *gc.C) {
+	c.Check(s.client.Close(), gc.IsNil)
+	s.server.Stop()
+	c.Check(s.cfs.Close(), gc.IsNil)
+
+	// All callbacks were consumed.
+	c.Check(s.appendCallbacks, gc.HasLen, 0)
+	c.Check(s.readCallbacks, gc.HasLen, 0)
+	c.Check(s.replicateCallbacks, gc.HasLen, 0)
+}
+
+func (s *GRPCSuite) TearDownSuite(c *gc.C) {
+	os.RemoveAll(s.localDir)
+}
+
+func (s *GRPCSuite) TestReadOfLocalFragment(c *gc.C) {
+	var success = func(op journal.ReadOp) {
+		c.Check(op.Journal, gc.Equals, journal.Name("journal/name"))
+		c.Check(op.Offset, gc.Equals, int64(12350))
+		c.Check(op.Blocking, gc.Equals, false)
+
+		op.Result <- journal.ReadResult{
+			Offset:     12350,
+			WriteHead:  12371,
+			RouteToken: "http://127.0.0.1:8081|http://bar:8081",
+			Fragment:   s.spool.Fragment,
+		}
+	}
+	s.readCallbacks = []func(journal.ReadOp){
+		success, // Non-blocking Head.
+		success, // Initial read of the stream.
+		func(op journal.ReadOp) {
+			// Next read. Expect the offset reflects the previous read.
+			c.Check(op.Offset, gc.Equals, int64(12371))
+			op.Result <- journal.ReadResult{Error: journal.ErrNotYetAvailable, WriteHead: 12371}
+		},
+	}
+
+	var result, body = s.client.Get(journal.ReadArgs{Journal: "journal/name", Offset: 12350})
+	c.Check(result.Error, gc.IsNil)
+	c.Check(result.Offset, gc.Equals, int64(12350))
+	c.Check(result.WriteHead, gc.Equals, int64(12371))
+	c.Check(result.Fragment.ContentName(), gc.Equals, s.spool.Fragment.ContentName())
+
+	var content, err = ioutil.ReadAll(body)
+	c.Check(err, gc.IsNil)
+	c.Check(string(content), gc.Equals, "expected read fixture")
+	c.Check(body.Close(), gc.IsNil)
+}
+
+func (s *GRPCSuite) TestHeadOfRemoteFragment(c *gc.C) {
+	var fragment = s.spool.Fragment
+	fragment.File = nil
+	fragment.RemoteModTime = fragment.RemoteModTime.Add(1234)
+
+	s.readCallbacks = []func(journal.ReadOp){
+		func(op journal.ReadOp) {
+			op.Result <- journal.ReadResult{
+				Offset:    12350,
+				WriteHead: 12371,
+				Fragment:  fragment,
+			}
+		},
+	}
+	var result, location = s.client.Head(journal.ReadArgs{Journal: "journal/name", Offset: 12350})
+	c.Check(result.Error, gc.IsNil)
+	c.Check(result.Fragment.ContentName(), gc.Equals, fragment.ContentName())
+	c.Check(result.Fragment.RemoteModTime.Unix(), gc.Equals, fragment.RemoteModTime.Unix())
+
+	c.Assert(location, gc.NotNil)
+	c.Check(strings.HasSuffix(location.Path, fragment.ContentPath()), gc.Equals, true)
+}
+
+func (s *GRPCSuite) TestReadErrorsAndRouting(c *gc.C) {
+	s.readCallbacks = []func(journal.ReadOp){
+		func(op journal.ReadOp) {
+			op.Result <- journal.ReadResult{Error: journal.ErrNotFound}
+		},
+		func(op journal.ReadOp) {
+			// Routes to the broker (ie, 127.0.0.1) on the client's port.
+			op.Result <- journal.ReadResult{
+				Error:      journal.ErrNotReplica,
+				RouteToken: "http://127.0.0.1:8081|http://other:8081",
+			}
+		},
+		// Read is retried against the routed endpoint.
+		func(op journal.ReadOp) {
+			op.Result <- journal.ReadResult{Error: journal.ErrNotYetAvailable, WriteHead: 12371}
+		},
+		func(op journal.ReadOp) {
+			op.Result <- journal.ReadResult{Error: errors.New("an error")}
+		},
+	}
+
+	var result, _ = s.client.Head(journal.ReadArgs{Journal: "journal/name"})
+	c.Check(result.Error, gc.Equals, journal.ErrNotFound)
+
+	result, _ = s.client.Head(journal.ReadArgs{Journal: "journal/name"})
+	c.Check(result.Error, gc.Equals, journal.ErrNotYetAvailable)
+	c.Check(result.WriteHead, gc.Equals, int64(12371))
+
+	var ep, _ = s.client.routeCache.Get(journal.Name("journal/name"))
+	c.Check(ep, gc.Equals, s.client.defaultEndpoint)
+
+	result, _ = s.client.Head(journal.ReadArgs{Journal: "journal/name"})
+	c.Check(result.Error, gc.ErrorMatches, ".*an error")
+}
+
+func (s *GRPCSuite) TestAppend(c *gc.C) {
+	s.readCallbacks = []func(journal.ReadOp){
+		func(op journal.ReadOp) {
+			// Speculative Head to determine the journal route.
+			c.Check(op.Offset, gc.Equals, int64(-1))
+			op.Result <- journal.ReadResult{
+				Error:      journal.ErrNotYetAvailable,
+				RouteToken: "http://127.0.0.1:8081",
+			}
+		},
+	}
+	s.appendCallbacks = []func(journal.AppendOp){
+		func(op journal.AppendOp) {
+			c.Check(op.Journal, gc.Equals, journal.Name("journal/name"))
+			c.Check(*op.ExpectWriteHead, gc.Equals, int64(1234))
+			c.Check(op.Producer, gc.Equals, journal.ProducerID("a-producer"))
+			c.Check(op.Sequence, gc.Equals, int64(5))
+
+			var content, err = ioutil.ReadAll(op.Content)
+			c.Check(err, gc.IsNil)
+			c.Check(content, gc.HasLen, kAppendChunkSize+3)
+
+			op.Result <- journal.AppendResult{WriteHead: 5678, RouteToken: "http://127.0.0.1:8081"}
+		},
+		func(op journal.AppendOp) {
+			c.Check(op.ExpectWriteHead, gc.IsNil)
+			op.Result <- journal.AppendResult{Error: journal.ErrWrongWriteHead, WriteHead: 5678}
+		},
+		func(op journal.AppendOp) {
+			op.Result <- journal.AppendResult{Error: journal.ErrRateLimited, RetryAfter: time.Second}
+		},
+	}
+
+	var expect int64 = 1234
+	var result = s.client.Put(journal.AppendArgs{
+		Journal:         "journal/name",
+		Content:         bytes.NewReader(make([]byte, kAppendChunkSize+3)),
+		ExpectWriteHead: &expect,
+		Producer:        "a-producer",
+		Sequence:        5,
+	})
+	c.Check(result.Error, gc.IsNil)
+	c.Check(result.WriteHead, gc.Equals, int64(5678))
+
+	var async, err = s.client.Write("journal/name", []byte("content"))
+	c.Check(err, gc.Equals, journal.ErrWrongWriteHead)
+	<-async.Ready
+	c.Check(async.WriteHead, gc.Equals, int64(5678))
+
+	// A rate-limited append returns its retry-after hint.
+	result = s.client.Put(journal.AppendArgs{Journal: "journal/name", Content: strings.NewReader("")})
+	c.Check(result.Error, gc.Equals, journal.ErrRateLimited)
+	c.Check(result.RetryAfter, gc.Equals, time.Second)
+}
+
+func (s *GRPCSuite) TestAuthorization(c *gc.C) {
+	var l, err = net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, gc.IsNil)
+
+	var server = grpc.NewServer()
+	journal.RegisterJournalServer(server, NewJournalService(s, s.cfs,
+		NewCreateAPI(s.cfs, s.keys, 2)).UseAuthorizer(staticAuthorizer("secret")))
+	go server.Serve(l)
+	defer server.Stop()
+
+	conn, err := grpc.Dial(l.Addr().String(), grpc.WithInsecure())
+	c.Assert(err, gc.IsNil)
+	defer conn.Close()
+
+	var client = journal.NewJournalClient(conn)
+	var ctx = context.Background()
+
+	// Operations of a client which presents no token are rejected.
+	createResp, err := client.Create(ctx, &journal.CreateRequest{Journal: "journal/name"})
+	c.Check(err, gc.IsNil)
+	c.Check(createResp.Status, gc.Equals, journal.Status_NOT_AUTHORIZED)
+
+	readStream, err := client.Read(ctx, &journal.ReadRequest{Journal: "journal/name"})
+	c.Assert(err, gc.IsNil)
+	readResp, err := readStream.Recv()
+	c.Check(err, gc.IsNil)
+	c.Check(readResp.Status, gc.Equals, journal.Status_NOT_AUTHORIZED)
+
+	// Replicated transactions are rejected, and their content is discarded.
+	replicateStream, err := client.Replicate(ctx)
+	c.Assert(err, gc.IsNil)
+
+	for i := 0; i != 2; i++ {
+		c.Check(replicateStream.Send(&journal.ReplicateRequest{Journal: "journal/name"}), gc.IsNil)
+		c.Check(replicateStream.Send(&journal.ReplicateRequest{Content: []byte("content")}), gc.IsNil)
+		c.Check(replicateStream.Send(&journal.ReplicateRequest{Commit: true, CommitDelta: 7}), gc.IsNil)
+
+		replicateResp, err := replicateStream.Recv()
+		c.Check(err, gc.IsNil)
+		c.Check(replicateResp.Status, gc.Equals, journal.Status_NOT_AUTHORIZED)
+	}
+	c.Check(replicateStream.CloseSend(), gc.IsNil)
+	_, err = replicateStream.Recv()
+	c.Check(err, gc.Equals, io.EOF)
+
+	// An append presenting the token is authorized.
+	s.appendCallbacks = []func(journal.AppendOp){
+		func(op journal.AppendOp) {
+			c.Check(op.Journal, gc.Equals, journal.Name("journal/name"))
+			op.Result <- journal.AppendResult{WriteHead: 1234}
+		},
+	}
+	ctx = metadata.NewOutgoingContext(ctx, metadata.Pairs("authorization", "Bearer secret"))
+
+	appendStream, err := client.Append(ctx)
+	c.Assert(err, gc.IsNil)
+	c.Check(appendStream.Send(&journal.AppendRequest{Journal: "journal/name"}), gc.IsNil)
+
+	appendResp, err := appendStream.CloseAndRecv()
+	c.Check(err, gc.IsNil)
+	c.Check(appendResp.Status, gc.Equals, journal.Status_OK)
+	c.Check(appendResp.WriteHead, gc.Equals, int64(1234))
+}
+
+func (s *GRPCSuite) TestReplicate(c *gc.C) {
+	var committed bytes.Buffer
+	var writer = &testWriteCommitter{committed: &committed}
+
+	s.replicateCallbacks = []func(journal.ReplicateOp){
+		func(op journal.ReplicateOp) {
+			c.Check(op.ReplicateArgs.String(), gc.Equals, journal.ReplicateArgs{
+				Journal:    "journal/name",
+				WriteHead:  1234,
+				RouteToken: "a-token",
+				NewSpool:   true,
+			}.String())
+			op.Result <- journal.ReplicateResult{Writer: writer}
+		},
+		func(op journal.ReplicateOp) {
+			op.Result <- journal.ReplicateResult{
+				Error:          journal.ErrWrongWriteHead,
+				ErrorWriteHead: 5678,
+			}
+		},
+		func(op journal.ReplicateOp) {
+			c.Check(op.WriteHead, gc.Equals, int64(5678))
+			op.Result <- journal.ReplicateResult{Writer: writer}
+		},
+	}
+
+	var client, err = s.client.client(s.client.defaultEndpoint)
+	c.Assert(err, gc.IsNil)
+
+	stream, err := client.Replicate(context.Background())
+	c.Assert(err, gc.IsNil)
+
+	// Pipeline a transaction which commits a portion of its content.
+	c.Check(stream.Send(&journal.ReplicateRequest{
+		Journal:    "journal/name",
+		WriteHead:  1234,
+		RouteToken: "a-token",
+		NewSpool:   true,
+	}), gc.IsNil)
+	c.Check(stream.Send(&journal.ReplicateRequest{Content: []byte("hello, ")}), gc.IsNil)
+	c.Check(stream.Send(&journal.ReplicateRequest{Content: []byte("world!")}), gc.IsNil)
+	c.Check(stream.Send(&journal.ReplicateRequest{Commit: true, CommitDelta: 12}), gc.IsNil)
+
+	// Pipeline a second transaction, which the replica rejects.
+	c.Check(stream.Send(&journal.ReplicateRequest{Journal: "journal/name", WriteHead: 1234}), gc.IsNil)
+	c.Check(stream.Send(&journal.ReplicateRequest{Content: []byte("discarded")}), gc.IsNil)
+	c.Check(stream.Send(&journal.ReplicateRequest{Commit: true, CommitDelta: 9}), gc.IsNil)
+
+	// And a third, from the replica's write head.
+	c.Check(stream.Send(&journal.ReplicateRequest{Journal: "journal/name", WriteHead: 5678}), gc.IsNil)
+	c.Check(stream.Send(&journal.ReplicateRequest{Content: []byte(" again")}), gc.IsNil)
+	c.Check(stream.Send(&journal.ReplicateRequest{Commit: true, CommitDelta: 6}), gc.IsNil)
+	c.Check(stream.CloseSend(), gc.IsNil)
+
+	// Expect verification & commit responses of the first transaction,
+	// only verification of the second, and both of the third.
+	for _, expect := range []journal.ReplicateResponse{
+		{Status: journal.Status_OK},
+		{Status: journal.Status_OK},
+		{Status: journal.Status_WRONG_WRITE_HEAD, WriteHead: 5678},
+		{Status: journal.Status_OK},
+		{Status: journal.Status_OK},
+	} {
+		var response, err = stream.Recv()
+		c.Check(err, gc.IsNil)
+		c.Check(*response, gc.DeepEquals, expect)
+	}
+	_, err = stream.Recv()
+	c.Check(err, gc.Equals, io.EOF)
+
+	c.Check(committed.String(), gc.Equals, "hello, world again")
+}
+
+func (s *GRPCSuite) TestReplicateStream(c *gc.C) {
+	var committed bytes.Buffer
+	var writer = &testWriteCommitter{committed: &committed}
+
+	s.replicateCallbacks = []func(journal.ReplicateOp){
+		func(op journal.ReplicateOp) {
+			c.Check(op.WriteHead, gc.Equals, int64(1234))
+			c.Check(op.RouteToken, gc.Equals, journal.RouteToken("a-token"))
+			op.Result <- journal.ReplicateResult{Writer: writer}
+		},
+		func(op journal.ReplicateOp) {
+			op.Result <- journal.ReplicateResult{
+				Error:          journal.ErrWrongWriteHead,
+				ErrorWriteHead: 5678,
+			}
+		},
+		func(op journal.ReplicateOp) {
+			c.Check(op.WriteHead, gc.Equals, int64(5678))
+			op.Result <- journal.ReplicateResult{Writer: writer}
+		},
+	}
+	var rs = NewReplicateStream(s.client.defaultEndpoint, nil)
+
+	var replicate = func(writeHead int64) journal.WriteCommitter {
+		var op = journal.ReplicateOp{
+			ReplicateArgs: journal.ReplicateArgs{
+				Journal:    "journal/name",
+				WriteHead:  writeHead,
+				RouteToken: "a-token",
+			},
+			Result: make(chan journal.ReplicateResult),
+		}
+		rs.Replicate(op)
+
+		var result = <-op.Result
+		c.Assert(result.Error, gc.IsNil)
+		return result.Writer
+	}
+
+	// First transaction is verified and committed.
+	var w = replicate(1234)
+	var conn = rs.conn
+	w.Write([]byte("hello, "))
+	w.Write([]byte("world!"))
+	c.Check(w.(journal.PendingWriteCommitter).Await(), gc.DeepEquals, journal.ReplicateResult{})
+	c.Check(w.Commit(12), gc.IsNil)
+	c.Check(committed.String(), gc.Equals, "hello, world")
+
+	// Second is rejected by the replica.
+	w = replicate(1234)
+	w.Write([]byte("discarded"))
+	c.Check(w.(journal.PendingWriteCommitter).Await(), gc.DeepEquals, journal.ReplicateResult{
+		Error:          journal.ErrWrongWriteHead,
+		ErrorWriteHead: 5678,
+	})
+	c.Check(w.Commit(0), gc.Equals, journal.ErrWrongWriteHead)
+
+	// Third is committed without awaiting verification.
+	w = replicate(5678)
+	w.Write([]byte(" again"))
+	c.Check(w.Commit(6), gc.IsNil)
+	c.Check(committed.String(), gc.Equals, "hello, world again")
+
+	// All transactions were carried by the same stream.
+	c.Check(rs.conn, gc.Equals, conn)
+
+	// A closed ReplicateStream fails pending and future transactions.
+	s.replicateCallbacks = []func(journal.ReplicateOp){
+		func(op journal.ReplicateOp) {
+			op.Result <- journal.ReplicateResult{Writer: writer}
+		},
+	}
+	w = replicate(5684)
+	c.Check(w.(journal.PendingWriteCommitter).Await().Error, gc.IsNil)
+	rs.Close()
+	c.Check(w.Commit(1), gc.NotNil)
+
+	var op = journal.ReplicateOp{Result: make(chan journal.ReplicateResult)}
+	rs.Replicate(op)
+	c.Check((<-op.Result).Error, gc.Equals, errReplicateStreamClosed)
+
+	// The connection of the closed ReplicateStream was released.
+	streamConnsMu.Lock()
+	c.Check(streamConns, gc.HasLen, 0)
+	streamConnsMu.Unlock()
+}
+
+func (s *GRPCSuite) TestStreamConnsAreKeyedOnTLSConfig(c *gc.C) {
+	var tlsA, tlsB = new(tls.Config), new(tls.Config)
+
+	var connA, err = acquireStreamConn("127.0.0.1:1", tlsA)
+	c.Assert(err, gc.IsNil)
+	connB, err := acquireStreamConn("127.0.0.1:1", tlsB)
+	c.Assert(err, gc.IsNil)
+	connA2, err := acquireStreamConn("127.0.0.1:1", tlsA)
+	c.Assert(err, gc.IsNil)
+
+	// Connections are shared only by streams of the same TLS configuration.
+	c.Check(connA, gc.Not(gc.Equals), connB)
+	c.Check(connA, gc.Equals, connA2)
+
+	releaseStreamConn("127.0.0.1:1", tlsA)
+	releaseStreamConn("127.0.0.1:1", tlsA)
+	releaseStreamConn("127.0.0.1:1", tlsB)
+
+	streamConnsMu.Lock()
+	c.Check(streamConns, gc.HasLen, 0)
+	streamConnsMu.Unlock()
+}
+
+func (s *GRPCSuite) TestCreate(c *gc.C) {
+	// Replication exceeds that of the cluster.
+	c.Check(s.client.CreateWithSpec("journal/name", journal.JournalSpec{Replication: 3}),
+		gc.ErrorMatches, ".*invalid journal replication .*")
+
+	s.keys.On("Set", mock.Anything, ServiceRoot+"/items/journal%2Fname", "",
+		&etcd.SetOptions{
+			Dir:       true,
+			PrevExist: etcd.PrevNoExist}).
+		Return(nil, etcd.Error{Code: etcd.ErrorCodeNodeExist})
+
+	c.Check(s.client.Create("journal/name"), gc.Equals, journal.ErrExists)
+	s.keys.AssertExpectations(c)
+}
+
+func (s *GRPCSuite) Append(op journal.AppendOp) {
+	s.appendCallbacks[0](op)
+	s.appendCallbacks = s.appendCallbacks[1:]
+}
+
+func (s *GRPCSuite) Read(op journal.ReadOp) {
+	s.readCallbacks[0](op)
+	s.readCallbacks = s.readCallbacks[1:]
+}
+
+func (s *GRPCSuite) Replicate(op journal.ReplicateOp) {
+	s.replicateCallbacks[0](op)
+	s.replicateCallbacks = s.replicateCallbacks[1:]
+}
+
+// testWriteCommitter buffers writes, and commits them to |committed|.
+type testWriteCommitter struct {
+	pending   bytes.Buffer
+	committed *bytes.Buffer
+}
+
+func (w *testWriteCommitter) Write(p []byte) (int, error) { return w.pending.Write(p) }
+
+func (w *testWriteCommitter) Commit(count int64) error {
+	w.committed.Write(w.pending.Next(int(count)))
+	w.pending.Reset()
+	return nil
+}
+
+var _ = gc.Suite(&GRPCSuite{})
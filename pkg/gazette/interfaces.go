@@ -16,12 +16,21 @@ type ReplicateOpHandler interface {
 	Replicate(journal.ReplicateOp)
 }
 
+// Composes AppendOpHandler, ReadOpHandler, and ReplicateOpHandler.
+// See Router.
+type JournalOpHandler interface {
+	AppendOpHandler
+	ReadOpHandler
+	ReplicateOpHandler
+}
+
 // See journal.Replica.
 type JournalReplica interface {
 	AppendOpHandler
 	ReadOpHandler
 	ReplicateOpHandler
-	Shutdown()
+	Shutdown() <-chan struct{}
 	StartBrokeringWithPeers(journal.RouteToken, []journal.Replicator)
 	StartReplicating(journal.RouteToken)
+	UpdateSpec(journal.JournalSpec)
 }
@@ -0,0 +1,191 @@
+package gazette
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/gorilla/mux"
+	"github.com/gorilla/schema"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+// Maximum number of concurrent write head requests of a single LIST.
+const kListWriteHeadConcurrency = 16
+
+// API for listing Journals. The request path is a prefix of listed Journal
+// names, and repeated "label" query arguments of the form "key=value" further
+// select Journals having each label. The response body is a JSON-encoded
+// listResponse. Journals and their routes are enumerated from Etcd, while
+// write heads are fetched from each Journal's replicas using |header|. If the
+// ListAPI has an Authorizer, only Journals which the client may read are listed.
+type ListAPI struct {
+	decoder    *schema.Decoder
+	header     journal.Header
+	keysAPI    etcd.KeysAPI
+	authorizer Authorizer
+}
+
+type listResponse struct {
+	Journals []journal.ListedJournal `json:"journals"`
+}
+
+func NewListAPI(keysAPI etcd.KeysAPI, header journal.Header) *ListAPI {
+	decoder := schema.NewDecoder()
+	decoder.IgnoreUnknownKeys(false)
+	decoder.SetAliasTag("json")
+
+	return &ListAPI{decoder: decoder, header: header, keysAPI: keysAPI}
+}
+
+// UseAuthorizer lists only Journals which |authorizer| permits the client
+// to read.
+func (h *ListAPI) UseAuthorizer(authorizer Authorizer) *ListAPI {
+	h.authorizer = authorizer
+	return h
+}
+
+func (h *ListAPI) Register(router *mux.Router) {
+	router.NewRoute().Methods("LIST").HandlerFunc(h.List)
+}
+
+func (h *ListAPI) List(w http.ResponseWriter, r *http.Request) {
+	var schema struct {
+		Label []string
+	}
+	var err error
+
+	if err = r.ParseForm(); err == nil {
+		err = h.decoder.Decode(&schema, r.Form)
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var args = journal.ListArgs{Prefix: journal.Name(r.URL.Path[1:])}
+	if args.Labels, err = parseLabels(schema.Label); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	journals, err := h.listJournals(args, func(name journal.Name) bool {
+		return authorize(h.authorizer, r, OpRead, name) == nil
+	})
+	if err != nil {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+	h.fetchWriteHeads(r.Context(), journals)
+
+	w.Header().Set("Content-Type", "application/json")
+	if err = json.NewEncoder(w).Encode(listResponse{Journals: journals}); err != nil {
+		log.WithFields(log.Fields{"err": err, "args": args}).Warn("failed to write list response")
+	}
+}
+
+// Enumerates Journals matching |args| and |filter| from Etcd, ordered on name.
+func (h *ListAPI) listJournals(args journal.ListArgs,
+	filter func(journal.Name) bool) ([]journal.ListedJournal, error) {
+	var response, err = h.keysAPI.Get(context.Background(), ServiceRoot,
+		&etcd.GetOptions{Recursive: true, Sort: true})
+
+	if etcdErr, _ := err.(etcd.Error); etcdErr.Code == etcd.ErrorCodeKeyNotFound {
+		return nil, nil // No Journals have been created.
+	} else if err != nil {
+		return nil, err
+	}
+
+	var journals []journal.ListedJournal
+
+	consensus.WalkItems(response.Node, nil, func(item string, route consensus.Route) {
+		var name, err = itemToJournal(item)
+		if err != nil {
+			log.WithFields(log.Fields{"err": err, "item": item}).
+				Error("failed to decode journal")
+			return
+		} else if !strings.HasPrefix(name.String(), args.Prefix.String()) || !filter(name) {
+			return
+		}
+
+		var spec = decodeJournalSpec(item, response.Node)
+		if !spec.MatchesLabels(args.Labels) {
+			return
+		}
+
+		token, err := routeToToken(route)
+		if err != nil {
+			log.WithFields(log.Fields{"route": route, "err": err}).
+				Error("failed to extract route token")
+			return
+		}
+
+		journals = append(journals, journal.ListedJournal{
+			Name:       name,
+			RouteToken: token,
+			WriteHead:  -1,
+			Labels:     spec.Labels,
+		})
+	})
+
+	sort.Slice(journals, func(i, j int) bool { return journals[i].Name < journals[j].Name })
+	return journals, nil
+}
+
+// Fetches the write head of each routed Journal of |journals|.
+func (h *ListAPI) fetchWriteHeads(ctx context.Context, journals []journal.ListedJournal) {
+	var wg sync.WaitGroup
+	var sem = make(chan struct{}, kListWriteHeadConcurrency)
+
+	for i := range journals {
+		if journals[i].RouteToken == "" {
+			continue // Journal has no replicas to query.
+		}
+		wg.Add(1)
+		sem <- struct{}{}
+
+		go func(listed *journal.ListedJournal) {
+			defer func() { <-sem; wg.Done() }()
+
+			var result, _ = h.header.Head(journal.ReadArgs{
+				Journal: listed.Name,
+				Offset:  -1,
+				Context: ctx,
+			})
+
+			switch result.Error {
+			case nil, journal.ErrNotYetAvailable:
+				listed.WriteHead = result.WriteHead
+			default:
+				log.WithFields(log.Fields{"err": result.Error, "name": listed.Name}).
+					Warn("failed to fetch journal write head")
+			}
+		}(&journals[i])
+	}
+	wg.Wait()
+}
+
+// Parses "key=value" |labels| into a map.
+func parseLabels(labels []string) (map[string]string, error) {
+	if len(labels) == 0 {
+		return nil, nil
+	}
+	var m = make(map[string]string, len(labels))
+
+	for _, label := range labels {
+		var kv = strings.SplitN(label, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%s (expected key=value: %q)", journal.ErrInvalidLabel, label)
+		}
+		m[kv[0]] = kv[1]
+	}
+	return m, nil
+}
@@ -0,0 +1,159 @@
+package gazette
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+type ListAPISuite struct {
+	keys   *consensus.MockKeysAPI
+	header *journal.MockHeader
+	mux    *mux.Router
+}
+
+func (s *ListAPISuite) SetUpTest(c *gc.C) {
+	s.keys = new(consensus.MockKeysAPI)
+	s.header = new(journal.MockHeader)
+	s.mux = mux.NewRouter()
+	NewListAPI(s.keys, s.header).Register(s.mux)
+
+	s.keys.On("Get", mock.Anything, ServiceRoot,
+		&etcd.GetOptions{Recursive: true, Sort: true}).Return(&etcd.Response{
+		Node: listTreeFixture(),
+	}, nil)
+}
+
+func (s *ListAPISuite) TestListByPrefix(c *gc.C) {
+	s.expectHead("a/one", journal.ReadResult{Error: journal.ErrNotYetAvailable, WriteHead: 1234})
+
+	c.Check(s.list(c, "/a/"), gc.DeepEquals, []journal.ListedJournal{
+		{
+			Name:       "a/one",
+			RouteToken: "http://broker-1|http://replica-2",
+			WriteHead:  1234,
+			Labels:     map[string]string{"topic": "a", "env": "prod"},
+		},
+		{
+			// Expect a journal without a route has an unknown write head.
+			Name:       "a/two",
+			RouteToken: "",
+			WriteHead:  -1,
+		},
+	})
+	s.header.AssertExpectations(c)
+}
+
+func (s *ListAPISuite) TestListByLabel(c *gc.C) {
+	s.expectHead("a/one", journal.ReadResult{Error: journal.ErrNotYetAvailable, WriteHead: 1234})
+	s.expectHead("b/three", journal.ReadResult{Error: errors.New("error!")})
+
+	c.Check(s.list(c, "/?label=env%3Dprod"), gc.DeepEquals, []journal.ListedJournal{
+		{
+			Name:       "a/one",
+			RouteToken: "http://broker-1|http://replica-2",
+			WriteHead:  1234,
+			Labels:     map[string]string{"topic": "a", "env": "prod"},
+		},
+		{
+			// Expect a failed head request results in an unknown write head.
+			Name:       "b/three",
+			RouteToken: "http://broker-2",
+			WriteHead:  -1,
+			Labels:     map[string]string{"topic": "b", "env": "prod"},
+		},
+	})
+	c.Check(s.list(c, "/?label=env%3Dprod&label=topic%3Db"), gc.HasLen, 1)
+	c.Check(s.list(c, "/a/?label=topic%3Db"), gc.HasLen, 0)
+	c.Check(s.list(c, "/?label=env%3Dtest"), gc.HasLen, 0)
+}
+
+func (s *ListAPISuite) TestInvalidArguments(c *gc.C) {
+	for _, query := range []string{"/?label=env", "/?label=%3Dprod", "/?other=foo"} {
+		var req, _ = http.NewRequest("LIST", query, nil)
+		var w = httptest.NewRecorder()
+
+		s.mux.ServeHTTP(w, req)
+		c.Check(w.Code, gc.Equals, http.StatusBadRequest)
+	}
+}
+
+func (s *ListAPISuite) TestListOnlyAuthorizedJournals(c *gc.C) {
+	var m = mux.NewRouter()
+	NewListAPI(s.keys, s.header).UseAuthorizer(prefixAuthorizer("a/")).Register(m)
+	s.mux = m
+
+	s.expectHead("a/one", journal.ReadResult{Error: journal.ErrNotYetAvailable, WriteHead: 1234})
+
+	// Journal b/three is not listed, as the client may not read it.
+	var listed = s.list(c, "/")
+	c.Assert(listed, gc.HasLen, 2)
+	c.Check(listed[0].Name, gc.Equals, journal.Name("a/one"))
+	c.Check(listed[1].Name, gc.Equals, journal.Name("a/two"))
+
+	c.Check(s.list(c, "/b/"), gc.HasLen, 0)
+}
+
+func (s *ListAPISuite) expectHead(name journal.Name, result journal.ReadResult) {
+	s.header.On("Head", mock.MatchedBy(func(args journal.ReadArgs) bool {
+		return args.Journal == name && args.Offset == -1 && !args.Blocking
+	})).Return(result, (*url.URL)(nil))
+}
+
+func (s *ListAPISuite) list(c *gc.C, path string) []journal.ListedJournal {
+	var req, _ = http.NewRequest("LIST", path, nil)
+	var w = httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Assert(w.Code, gc.Equals, http.StatusOK)
+
+	var response listResponse
+	c.Assert(json.NewDecoder(w.Body).Decode(&response), gc.IsNil)
+	return response.Journals
+}
+
+// prefixAuthorizer is an Authorizer which grants reads of journals having
+// a name prefix.
+type prefixAuthorizer string
+
+func (a prefixAuthorizer) Authorize(principal Principal, op Operation, name journal.Name) error {
+	if op != OpRead || !strings.HasPrefix(name.String(), string(a)) {
+		return journal.ErrNotAuthorized
+	}
+	return nil
+}
+
+func listTreeFixture() *etcd.Node {
+	return &etcd.Node{Key: ServiceRoot, Dir: true, Nodes: etcd.Nodes{
+		{Key: ServiceRoot + "/items", Dir: true, Nodes: etcd.Nodes{
+			{Key: ServiceRoot + "/items/a%2Fone", Dir: true, Nodes: etcd.Nodes{
+				// Route entries are ordered on CreatedIndex, not key.
+				{Key: ServiceRoot + "/items/a%2Fone/http%3A%2F%2Freplica-2", CreatedIndex: 2},
+				{Key: ServiceRoot + "/items/a%2Fone/http%3A%2F%2Fbroker-1", CreatedIndex: 1},
+			}},
+			{Key: ServiceRoot + "/items/a%2Ftwo", Dir: true},
+			{Key: ServiceRoot + "/items/b%2Fthree", Dir: true, Nodes: etcd.Nodes{
+				{Key: ServiceRoot + "/items/b%2Fthree/http%3A%2F%2Fbroker-2", CreatedIndex: 3},
+			}},
+		}},
+		{Key: ServiceRoot + "/specs", Dir: true, Nodes: etcd.Nodes{
+			{Key: ServiceRoot + "/specs/a%2Fone",
+				Value: `{"labels":{"topic":"a","env":"prod"}}`},
+			{Key: ServiceRoot + "/specs/b%2Fthree",
+				Value: `{"replication":1,"labels":{"topic":"b","env":"prod"}}`},
+		}},
+	}}
+}
+
+var _ = gc.Suite(&ListAPISuite{})
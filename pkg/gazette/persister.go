@@ -3,9 +3,12 @@ package gazette
 import (
 	"context"
 	"encoding/json"
+	"expvar"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
+	"path"
 	"path/filepath"
 	"sync"
 	"sync/atomic"
@@ -17,6 +20,7 @@ import (
 	"github.com/LiveRamp/gazette/pkg/async"
 	"github.com/LiveRamp/gazette/pkg/cloudstore"
 	"github.com/LiveRamp/gazette/pkg/journal"
+	"github.com/LiveRamp/gazette/pkg/metrics"
 )
 
 const (
@@ -35,9 +39,16 @@ type Persister struct {
 
 	queue        map[string]journal.Fragment
 	shuttingDown uint32
+	stopCh       chan struct{}
 	loopExited   chan struct{}
 	mu           sync.Mutex
 
+	// Cumulative upload throughput, exported to expvar.
+	stats struct {
+		bytes, fragments *expvar.Int
+		seconds          *expvar.Float
+	}
+
 	// Effective constants, which are swappable for testing.
 	osRemove         func(path string) error
 	persisterLockTTL time.Duration
@@ -52,11 +63,21 @@ func NewPersister(directory string, cfs cloudstore.FileSystem,
 		osRemove:         os.Remove,
 		persisterLockTTL: kPersisterLockTTL,
 		queue:            make(map[string]journal.Fragment),
+		stopCh:           make(chan struct{}),
 		loopExited:       make(chan struct{}),
 		routeKey:         routeKey,
 	}
-	// Make the state of the persister queue available to expvar.
+	p.stats.bytes, p.stats.fragments = new(expvar.Int), new(expvar.Int)
+	p.stats.seconds = new(expvar.Float)
+
+	var stats = new(expvar.Map).Init()
+	stats.Set("bytes", p.stats.bytes)
+	stats.Set("fragments", p.stats.fragments)
+	stats.Set("seconds", p.stats.seconds)
+
+	// Make the state of the persister queue, and its throughput, available to expvar.
 	gazetteMap.Set("persister", p)
+	gazetteMap.Set("persisted", stats)
 
 	return p
 }
@@ -86,16 +107,25 @@ func (p *Persister) IsShuttingDown() bool {
 	return atomic.LoadUint32(&p.shuttingDown) == 1
 }
 
+// Stop the Persister, blocking until all queued fragments have been persisted.
 func (p *Persister) Stop() {
 	atomic.StoreUint32(&p.shuttingDown, 1)
+	close(p.stopCh)
 	<-p.loopExited
 }
 
 func (p *Persister) StartPersisting() *Persister {
 	go func() {
 		interval := time.Tick(kPersisterConvergeInterval)
+		stopCh := p.stopCh
 		for {
-			<-interval
+			select {
+			case <-interval:
+			case <-stopCh:
+				// Converge immediately upon Stop, rather than awaiting the next
+				// interval. Further attempts (if required) occur at the interval.
+				stopCh = nil
+			}
 
 			// Attempt to converge all items in the queue.
 			p.converge()
@@ -129,6 +159,20 @@ func (p *Persister) Persist(fragment journal.Fragment) {
 	p.mu.Unlock()
 }
 
+// IsPersisting returns whether a fragment of journal |name| is queued for
+// persisting.
+func (p *Persister) IsPersisting(name journal.Name) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for _, fragment := range p.queue {
+		if fragment.Journal == name {
+			return true
+		}
+	}
+	return false
+}
+
 func (p *Persister) converge() {
 	p.mu.Lock()
 	for name, fragment := range p.queue {
@@ -144,6 +188,31 @@ func (p *Persister) converge() {
 }
 
 func (p *Persister) convergeOne(fragment journal.Fragment) bool {
+	var spec, ok = p.journalSpec(fragment.Journal)
+	if !ok {
+		return false // Retry on next convergence.
+	}
+
+	// Fragments which have already exceeded their journal's retention needn't
+	// be persisted. This is typically the case for spools recovered long after
+	// they were written.
+	if p.isExpired(fragment, spec) {
+		log.WithField("path", fragment.ContentPath()).Info("dropping expired fragment")
+		p.removeLocal(fragment)
+		return true
+	}
+
+	// Spools of a deleted journal may be persisted after its deletion. Apply
+	// the fragment disposition of the deletion.
+	deleteArgs, deleted, ok := p.journalTombstone(fragment.Journal)
+	if !ok {
+		return false
+	} else if deleted && deleteArgs.Fragments == journal.DeleteFragments {
+		log.WithField("path", fragment.ContentPath()).Info("dropping fragment of deleted journal")
+		p.removeLocal(fragment)
+		return true
+	}
+
 	var lockPath = PersisterLocksRoot + fragment.ContentName()
 	var lockIndex uint64
 
@@ -176,12 +245,19 @@ func (p *Persister) convergeOne(fragment journal.Fragment) bool {
 	done := make(async.Promise)
 
 	// Perform the actual transfer in a goroutine which resolves |done|. Capture
-	// whether the transfer completed successfully.
+	// whether the transfer completed successfully, and the bytes it uploaded.
 	var success bool
-	go func(success *bool) {
+	var uploaded int64
+	var started = time.Now()
+
+	go func() {
 		defer done.Resolve()
-		*success = transferFragmentToGCS(p.cfs, fragment)
-	}(&success)
+		var remote = fragment
+		remote.Journal = deleteArgs.FragmentJournal(fragment.Journal)
+		remote.Codec = spec.CompressionCodec
+
+		uploaded, success = transferFragmentToGCS(p.cfs, fragment, remote)
+	}()
 
 	// Wait for |done|, periodically refreshing the held lock.
 	done.WaitWithPeriodicTask(p.persisterLockTTL/2, func() {
@@ -201,39 +277,158 @@ func (p *Persister) convergeOne(fragment journal.Fragment) bool {
 	if success {
 		p.removeLocal(fragment)
 	}
+	if uploaded != 0 {
+		p.recordUpload(uploaded, time.Since(started))
+	}
 	return success
 }
 
-func transferFragmentToGCS(cfs cloudstore.FileSystem, fragment journal.Fragment) bool {
+// Records an upload of |bytes| which took |duration|.
+func (p *Persister) recordUpload(bytes int64, duration time.Duration) {
+	p.stats.bytes.Add(bytes)
+	p.stats.fragments.Add(1)
+	p.stats.seconds.Add(duration.Seconds())
+
+	metrics.PersistedBytesTotal.Add(float64(bytes))
+	metrics.PersistedFragmentsTotal.Inc()
+	metrics.PersistDurationSecondsTotal.Add(duration.Seconds())
+}
+
+// Returns the current JournalSpec of journal |name|, and whether it could be
+// determined. Journals without a spec (or with a malformed one) use defaults.
+// A Retention not set by the spec is taken from RetentionPolicies.
+func (p *Persister) journalSpec(name journal.Name) (journal.JournalSpec, bool) {
+	var spec journal.JournalSpec
+	if found, ok := p.getJSON(journalSpecPath(name), &spec); !ok {
+		return spec, false
+	} else if !found {
+		spec = journal.JournalSpec{}
+	}
+	if spec.Retention != 0 {
+		return spec, true
+	}
+
+	var policies = path.Join(ServiceRoot, RetentionPrefix)
+	var response, err = p.keysAPI.Get(context.Background(), policies,
+		&etcd.GetOptions{Recursive: true})
+
+	if etcdErr, _ := err.(etcd.Error); etcdErr.Code == etcd.ErrorCodeKeyNotFound {
+		return spec, true
+	} else if err != nil {
+		log.WithFields(log.Fields{"err": err, "key": policies}).Warn("failed to fetch key")
+		return spec, false
+	}
+	return applyRetentionPolicies(spec, name, response.Node), true
+}
+
+// Returns the DeleteArgs of journal |name|'s tombstone, whether the journal
+// is deleted, and whether its deletion status could be determined.
+func (p *Persister) journalTombstone(name journal.Name) (journal.DeleteArgs, bool, bool) {
+	var args journal.DeleteArgs
+	var found, ok = p.getJSON(journalTombstonePath(name), &args)
+	return args, found, ok
+}
+
+// Fetches and decodes the JSON value at Etcd |key| into |v|. Returns whether
+// |key| exists, and whether it could be fetched. A malformed value is logged
+// and treated as missing.
+func (p *Persister) getJSON(key string, v interface{}) (found, ok bool) {
+	response, err := p.keysAPI.Get(context.Background(), key, nil)
+	if etcdErr, _ := err.(etcd.Error); etcdErr.Code == etcd.ErrorCodeKeyNotFound {
+		return false, true
+	} else if err != nil {
+		log.WithFields(log.Fields{"err": err, "key": key}).Warn("failed to fetch key")
+		return false, false
+	}
+
+	if err = json.Unmarshal([]byte(response.Node.Value), v); err != nil {
+		log.WithFields(log.Fields{"err": err, "key": key}).Warn("failed to decode key")
+		return false, true
+	}
+	return true, true
+}
+
+// Returns whether the local spool of |fragment| was last modified longer ago
+// than the retention of |spec|.
+func (p *Persister) isExpired(fragment journal.Fragment, spec journal.JournalSpec) bool {
+	if spec.Retention == 0 {
+		return false
+	}
+	var info, err = os.Stat(filepath.Join(p.directory, fragment.ContentPath()))
+	if err != nil {
+		return false
+	}
+	return time.Since(info.ModTime()) > spec.Retention
+}
+
+// Persists local |fragment| to |cfs| as |remote|, which may differ from
+// |fragment| in its Journal and Codec. Content is compressed with the Codec of
+// |remote|, which is encoded into the content name of the persisted fragment.
+// Returns the number of (compressed) bytes uploaded, which is zero if |remote|
+// was already present, and whether |fragment| is now persisted.
+func transferFragmentToGCS(cfs cloudstore.FileSystem, fragment, remote journal.Fragment) (int64, bool) {
 	// Create the journal's fragment directory, if not already present.
-	if err := cfs.MkdirAll(fragment.Journal.String(), 0750); err != nil {
-		log.WithFields(log.Fields{"err": err, "path": fragment.Journal}).
+	if err := cfs.MkdirAll(remote.Journal.String(), 0750); err != nil {
+		log.WithFields(log.Fields{"err": err, "path": remote.Journal}).
 			Warn("failed to make fragment directory")
-		return false
+		return 0, false
+	}
+	// Store the fragment's TimeIndex before its content, such that the index of
+	// a visible fragment is also visible.
+	if fragment.TimeIndex != nil {
+		if err := journal.StoreTimeIndex(cfs, remote, fragment.TimeIndex); err != nil {
+			log.WithFields(log.Fields{"err": err, "path": remote.TimeIndexPath()}).
+				Warn("failed to store fragment time index")
+			return 0, false
+		}
 	}
 
-	var w, err = cfs.OpenFile(fragment.ContentPath(),
+	var w, err = cfs.OpenFile(remote.ContentPath(),
 		os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0640)
 
 	if os.IsExist(err) {
 		// Already present on target file system. No need to re-upload.
-		return true
+		return 0, true
 	} else if err != nil {
-		log.WithFields(log.Fields{"err": err, "path": fragment.ContentPath()}).
+		log.WithFields(log.Fields{"err": err, "path": remote.ContentPath()}).
 			Warn("failed to open fragment for writing")
-		return false
+		return 0, false
 	}
-	var r = io.NewSectionReader(fragment.File, 0, fragment.End-fragment.Begin)
+	var r = compressingReader(io.NewSectionReader(fragment.File, 0,
+		fragment.End-fragment.Begin), remote.Codec)
+	defer r.Close()
 
-	if _, err := cfs.CopyAtomic(w, r); err != nil {
-		log.WithFields(log.Fields{"err": err, "path": fragment.ContentPath()}).
+	if n, err := cfs.CopyAtomic(w, r); err != nil {
+		log.WithFields(log.Fields{"err": err, "path": remote.ContentPath()}).
 			Warn("failed to copy fragment")
-		return false
+		return 0, false
 	} else {
-		return true
+		return n, true
 	}
 }
 
+// Returns a ReadCloser of |r| compressed with |codec|. Compression happens in
+// a separate goroutine, which exits once |r| is consumed or the returned
+// ReadCloser is closed.
+func compressingReader(r io.Reader, codec journal.CompressionCodec) io.ReadCloser {
+	if !codec.IsCompressed() {
+		return ioutil.NopCloser(r)
+	}
+	var pr, pw = io.Pipe()
+
+	go func() {
+		var cw, err = journal.NewCompressor(pw, codec)
+		if err == nil {
+			_, err = io.Copy(cw, r)
+			if closeErr := cw.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
 func (p *Persister) removeLocal(fragment journal.Fragment) {
 	localPath := filepath.Join(p.directory, fragment.ContentPath())
 
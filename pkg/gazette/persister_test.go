@@ -4,6 +4,7 @@ import (
 	"encoding/json"
 	"io/ioutil"
 	"os"
+	"path/filepath"
 	"sort"
 	"time"
 
@@ -49,6 +50,12 @@ func (s *PersisterSuite) TestPersistence(c *gc.C) {
 	var contentFixture = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
 	var lockPath = PersisterLocksRoot + s.fragment.ContentName()
 
+	// Journal has no spec.
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+	s.expectNoRetentionPolicies()
+	s.expectNoTombstone()
+
 	// Expect lock is created, refreshed, and deleted.
 	s.keysAPI.On("Set", mock.Anything, lockPath, "route-key",
 		&etcd.SetOptions{
@@ -106,6 +113,12 @@ func (s *PersisterSuite) TestPersistence(c *gc.C) {
 func (s *PersisterSuite) TestLockIsAlreadyHeld(c *gc.C) {
 	var lockPath = PersisterLocksRoot + s.fragment.ContentName()
 
+	// Journal has no spec.
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+	s.expectNoRetentionPolicies()
+	s.expectNoTombstone()
+
 	// Expect lock creation is attempted, but return an error that it exists.
 	s.keysAPI.On("Set", mock.Anything, lockPath, "route-key",
 		&etcd.SetOptions{
@@ -144,6 +157,12 @@ func (s *PersisterSuite) TestTargetFileAlreadyExists(c *gc.C) {
 		c.Assert(w.Close(), gc.IsNil)
 	}
 
+	// Journal has no spec.
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+	s.expectNoRetentionPolicies()
+	s.expectNoTombstone()
+
 	// Expect a lock to be obtained, and then released.
 	s.keysAPI.On("Set", mock.Anything, lockPath, "route-key",
 		&etcd.SetOptions{
@@ -165,6 +184,227 @@ func (s *PersisterSuite) TestTargetFileAlreadyExists(c *gc.C) {
 	s.keysAPI.AssertExpectations(c)
 	s.file.AssertExpectations(c)
 	c.Check(s.persister.osRemove, gc.IsNil)
+
+	// Nothing was uploaded.
+	c.Check(s.persister.stats.fragments.Value(), gc.Equals, int64(0))
+}
+
+func (s *PersisterSuite) TestJournalSpecAppliesRetentionPolicies(c *gc.C) {
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/retention",
+		&etcd.GetOptions{Recursive: true}).Return(&etcd.Response{
+		Node: &etcd.Node{
+			Key: ServiceRoot + "/retention",
+			Dir: true,
+			Nodes: etcd.Nodes{
+				{Key: ServiceRoot + "/retention/a%2F", Value: `{"prefix":"a/","retention":3600000000000}`},
+			},
+		},
+	}, nil)
+
+	var spec, ok = s.persister.journalSpec("a/journal")
+	c.Check(ok, gc.Equals, true)
+	c.Check(spec, gc.DeepEquals, journal.JournalSpec{Retention: time.Hour})
+
+	s.keysAPI.AssertExpectations(c)
+}
+
+func (s *PersisterSuite) TestExpiredFragment(c *gc.C) {
+	var dir, err = ioutil.TempDir("", "persister-suite")
+	c.Assert(err, gc.IsNil)
+	defer os.RemoveAll(dir)
+
+	// Write a local spool fixture which was last modified two hours ago.
+	var localPath = filepath.Join(dir, s.fragment.ContentPath())
+	c.Assert(os.MkdirAll(filepath.Dir(localPath), 0750), gc.IsNil)
+	c.Assert(ioutil.WriteFile(localPath, []byte("content"), 0640), gc.IsNil)
+
+	var modTime = time.Now().Add(-2 * time.Hour)
+	c.Assert(os.Chtimes(localPath, modTime, modTime), gc.IsNil)
+
+	s.persister.directory = dir
+
+	// Journal is retained for three hours. Expect the fragment is persisted.
+	c.Check(s.persister.isExpired(s.fragment,
+		journal.JournalSpec{Retention: 3 * time.Hour}), gc.Equals, false)
+	// Journal has default retention. Expect the fragment is persisted.
+	c.Check(s.persister.isExpired(s.fragment, journal.JournalSpec{}), gc.Equals, false)
+
+	// Journal is retained for one hour. Expect the fragment is dropped without
+	// being locked or read.
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{
+		Node: &etcd.Node{Value: `{"retention": 3600000000000}`},
+	}, nil).Once()
+
+	s.persister.osRemove = func(path string) error {
+		c.Check(path, gc.Equals, localPath)
+		s.persister.osRemove = nil // Mark we were called.
+		return nil
+	}
+	c.Check(s.persister.convergeOne(s.fragment), gc.Equals, true)
+
+	s.keysAPI.AssertExpectations(c)
+	s.file.AssertExpectations(c)
+	c.Check(s.persister.osRemove, gc.IsNil)
+}
+
+func (s *PersisterSuite) TestCompressedPersistence(c *gc.C) {
+	var contentFixture = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	var lockPath = PersisterLocksRoot + s.fragment.ContentName()
+
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{
+		Node: &etcd.Node{Value: `{"compression_codec": "gzip"}`},
+	}, nil)
+	s.expectNoRetentionPolicies()
+	s.expectNoTombstone()
+
+	// Expect a lock to be obtained, and then released.
+	s.keysAPI.On("Set", mock.Anything, lockPath, "route-key",
+		&etcd.SetOptions{
+			PrevExist: etcd.PrevNoExist,
+			TTL:       kPersisterLockTTL,
+		}).Return(&etcd.Response{Index: 1234}, nil)
+
+	s.keysAPI.On("Delete", mock.Anything, lockPath,
+		&etcd.DeleteOptions{PrevIndex: 1234}).Return(&etcd.Response{}, nil)
+
+	s.file.On("ReadAt", mock.AnythingOfType("[]uint8"), int64(0)).
+		Return(10, nil).
+		Run(func(args mock.Arguments) {
+			copy(args.Get(0).([]byte), contentFixture)
+		}).Once()
+
+	s.persister.osRemove = func(path string) error {
+		c.Check(path, gc.Equals, "base/directory/a/journal/"+s.fragment.ContentName())
+		s.persister.osRemove = nil // Mark we were called.
+		return nil
+	}
+	var timeIndex = journal.TimeIndex{{Offset: 1000, Time: time.Unix(1500000000, 0).UTC()}}
+	s.fragment.TimeIndex = timeIndex
+
+	c.Check(s.persister.convergeOne(s.fragment), gc.Equals, true)
+
+	s.keysAPI.AssertExpectations(c)
+	s.file.AssertExpectations(c)
+	c.Check(s.persister.osRemove, gc.IsNil)
+
+	// Expect the persisted fragment is named with, and compressed by, the codec.
+	var remote = s.fragment
+	remote.File, remote.Codec, remote.TimeIndex = nil, journal.CodecGzip, nil
+	c.Check(remote.ContentName(), gc.Matches, ".*\\.gz")
+
+	// Expect the upload of compressed content is reflected in persister throughput.
+	var f, _ = s.cfs.Open(remote.ContentPath())
+	var info, _ = f.Stat()
+	c.Check(s.persister.stats.fragments.Value(), gc.Equals, int64(1))
+	c.Check(s.persister.stats.bytes.Value(), gc.Equals, info.Size())
+	c.Check(f.Close(), gc.IsNil)
+
+	// Expect the fragment's TimeIndex was persisted alongside it.
+	loaded, err := journal.LoadTimeIndex(s.cfs, remote)
+	c.Check(err, gc.IsNil)
+	c.Check(loaded, gc.DeepEquals, timeIndex)
+
+	r, err := remote.ReaderFromOffset(1004, s.cfs)
+	c.Assert(err, gc.IsNil)
+	content, _ := ioutil.ReadAll(r)
+	c.Check(content, gc.DeepEquals, contentFixture[4:])
+	c.Check(r.Close(), gc.IsNil)
+}
+
+func (s *PersisterSuite) TestFragmentOfDeletedJournalIsDropped(c *gc.C) {
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+	s.expectNoRetentionPolicies()
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/deleted/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{
+		Node: &etcd.Node{Value: `{"fragments":"delete"}`},
+	}, nil)
+
+	// Expect the fragment is removed without being locked or read.
+	s.persister.osRemove = func(path string) error {
+		c.Check(path, gc.Equals, "base/directory/a/journal/"+s.fragment.ContentName())
+		s.persister.osRemove = nil // Mark we were called.
+		return nil
+	}
+	c.Check(s.persister.convergeOne(s.fragment), gc.Equals, true)
+
+	s.keysAPI.AssertExpectations(c)
+	s.file.AssertExpectations(c)
+	c.Check(s.persister.osRemove, gc.IsNil)
+}
+
+func (s *PersisterSuite) TestStopFlushesQueue(c *gc.C) {
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+	s.expectNoRetentionPolicies()
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/deleted/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{
+		Node: &etcd.Node{Value: `{"fragments":"delete"}`},
+	}, nil)
+
+	s.persister.osRemove = func(path string) error { return nil }
+	s.persister.Persist(s.fragment)
+	s.persister.StartPersisting()
+
+	// Expect Stop converges the queue immediately, rather than at the next
+	// convergence interval.
+	var stopped = make(chan struct{})
+	go func() {
+		s.persister.Stop()
+		close(stopped)
+	}()
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout awaiting Stop")
+	}
+	c.Check(s.persister.queue, gc.HasLen, 0)
+	s.keysAPI.AssertExpectations(c)
+}
+
+func (s *PersisterSuite) TestFragmentOfDeletedJournalIsMoved(c *gc.C) {
+	var contentFixture = []byte{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+	var lockPath = PersisterLocksRoot + s.fragment.ContentName()
+
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/specs/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+	s.expectNoRetentionPolicies()
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/deleted/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{
+		Node: &etcd.Node{Value: `{"fragments":"move","destination":"archive"}`},
+	}, nil)
+
+	s.keysAPI.On("Set", mock.Anything, lockPath, "route-key",
+		&etcd.SetOptions{
+			PrevExist: etcd.PrevNoExist,
+			TTL:       kPersisterLockTTL,
+		}).Return(&etcd.Response{Index: 1234}, nil)
+	s.keysAPI.On("Delete", mock.Anything, lockPath,
+		&etcd.DeleteOptions{PrevIndex: 1234}).Return(&etcd.Response{}, nil)
+
+	s.file.On("ReadAt", mock.AnythingOfType("[]uint8"), int64(0)).
+		Return(10, nil).
+		Run(func(args mock.Arguments) {
+			copy(args.Get(0).([]byte), contentFixture)
+		}).Once()
+
+	s.persister.osRemove = func(path string) error { return nil }
+	c.Check(s.persister.convergeOne(s.fragment), gc.Equals, true)
+
+	s.keysAPI.AssertExpectations(c)
+	s.file.AssertExpectations(c)
+
+	// Expect the fragment was persisted under the destination.
+	r, err := s.cfs.Open("archive/a/journal/" + s.fragment.ContentName())
+	c.Assert(err, gc.IsNil)
+	content, _ := ioutil.ReadAll(r)
+	c.Check(content, gc.DeepEquals, contentFixture)
+	c.Check(r.Close(), gc.IsNil)
 }
 
 func (s *PersisterSuite) TestEmptyFragment(c *gc.C) {
@@ -216,4 +456,14 @@ func (s *PersisterSuite) TestStringFunction(c *gc.C) {
 	})
 }
 
+func (s *PersisterSuite) expectNoRetentionPolicies() {
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/retention",
+		&etcd.GetOptions{Recursive: true}).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+}
+
+func (s *PersisterSuite) expectNoTombstone() {
+	s.keysAPI.On("Get", mock.Anything, ServiceRoot+"/deleted/a%2Fjournal",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+}
+
 var _ = gc.Suite(&PersisterSuite{})
@@ -0,0 +1,61 @@
+package gazette
+
+import (
+	"context"
+	"encoding/json"
+
+	etcd "github.com/coreos/etcd/client"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+// ProducerStore is a journal.ProducerStore which stores the ProducerStates of
+// each journal as a JSON-encoded Etcd value under ProducersPrefix. The Etcd
+// ModifiedIndex of the value is its revision, and stores compare-and-swap
+// against it.
+type ProducerStore struct {
+	keysAPI etcd.KeysAPI
+}
+
+func NewProducerStore(keysAPI etcd.KeysAPI) *ProducerStore {
+	return &ProducerStore{keysAPI: keysAPI}
+}
+
+func (s *ProducerStore) LoadProducers(name journal.Name) (journal.ProducerStates, uint64, error) {
+	var states journal.ProducerStates
+
+	var response, err = s.keysAPI.Get(context.Background(), journalProducersPath(name), nil)
+	if isKeyNotFound(err) {
+		return states, 0, nil // Journal has no producers.
+	} else if err != nil {
+		return states, 0, err
+	}
+
+	if err = json.Unmarshal([]byte(response.Node.Value), &states); err != nil {
+		return states, 0, err
+	}
+	return states, response.Node.ModifiedIndex, nil
+}
+
+func (s *ProducerStore) StoreProducers(name journal.Name, states journal.ProducerStates,
+	revision uint64) (uint64, error) {
+
+	var value, err = json.Marshal(states)
+	if err != nil {
+		return 0, err
+	}
+
+	var opts = &etcd.SetOptions{PrevIndex: revision}
+	if revision == 0 {
+		opts.PrevExist = etcd.PrevNoExist
+	}
+
+	response, err := s.keysAPI.Set(context.Background(), journalProducersPath(name), string(value), opts)
+	if etcdErr, ok := err.(etcd.Error); ok && (etcdErr.Code == etcd.ErrorCodeTestFailed ||
+		etcdErr.Code == etcd.ErrorCodeNodeExist) {
+		return 0, journal.ErrProducersConflict
+	} else if err != nil {
+		return 0, err
+	}
+	return response.Node.ModifiedIndex, nil
+}
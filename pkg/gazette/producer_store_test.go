@@ -0,0 +1,92 @@
+package gazette
+
+import (
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+type ProducerStoreSuite struct{}
+
+func (s *ProducerStoreSuite) TestLoadAndStore(c *gc.C) {
+	var keys = new(consensus.MockKeysAPI)
+	var store = NewProducerStore(keys)
+	var updated = time.Unix(1500000000, 0).UTC()
+
+	keys.On("Get", mock.Anything, ServiceRoot+"/producers/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{
+		Node: &etcd.Node{
+			Value:         `{"producers":{"a-producer":{"sequence":42,"updated":"2017-07-14T02:40:00Z"}}}`,
+			ModifiedIndex: 1234,
+		},
+	}, nil).Once()
+
+	var states, revision, err = store.LoadProducers("journal/name")
+	c.Check(err, gc.IsNil)
+	c.Check(revision, gc.Equals, uint64(1234))
+	c.Check(states, gc.DeepEquals, journal.ProducerStates{
+		Producers: map[journal.ProducerID]journal.ProducerState{
+			"a-producer": {Sequence: 42, Updated: updated},
+		},
+	})
+
+	states.Pending = &journal.PendingAppends{
+		Begin:     100,
+		End:       200,
+		Sequences: map[journal.ProducerID]int64{"another-producer": 7},
+		Updated:   updated,
+	}
+
+	// Stores compare-and-swap against the loaded revision.
+	keys.On("Set", mock.Anything, ServiceRoot+"/producers/journal%2Fname",
+		`{"producers":{"a-producer":{"sequence":42,"updated":"2017-07-14T02:40:00Z"}},`+
+			`"pending":{"begin":100,"end":200,"sequences":{"another-producer":7},`+
+			`"updated":"2017-07-14T02:40:00Z"}}`,
+		&etcd.SetOptions{PrevIndex: 1234}).Return(&etcd.Response{
+		Node: &etcd.Node{ModifiedIndex: 1240},
+	}, nil).Once()
+
+	revision, err = store.StoreProducers("journal/name", states, revision)
+	c.Check(err, gc.IsNil)
+	c.Check(revision, gc.Equals, uint64(1240))
+
+	// A concurrent modification is reported as a conflict.
+	keys.On("Set", mock.Anything, ServiceRoot+"/producers/journal%2Fname",
+		mock.AnythingOfType("string"), &etcd.SetOptions{PrevIndex: 1240}).
+		Return(nil, etcd.Error{Code: etcd.ErrorCodeTestFailed}).Once()
+
+	_, err = store.StoreProducers("journal/name", states, revision)
+	c.Check(err, gc.Equals, journal.ErrProducersConflict)
+
+	keys.AssertExpectations(c)
+}
+
+func (s *ProducerStoreSuite) TestLoadAndStoreOfJournalWithoutProducers(c *gc.C) {
+	var keys = new(consensus.MockKeysAPI)
+	var store = NewProducerStore(keys)
+
+	keys.On("Get", mock.Anything, ServiceRoot+"/producers/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound}).Once()
+
+	var states, revision, err = store.LoadProducers("journal/name")
+	c.Check(err, gc.IsNil)
+	c.Check(states.Producers, gc.HasLen, 0)
+	c.Check(revision, gc.Equals, uint64(0))
+
+	// A store of revision zero requires that no ProducerStates exist.
+	keys.On("Set", mock.Anything, ServiceRoot+"/producers/journal%2Fname",
+		`{"producers":null}`, &etcd.SetOptions{PrevExist: etcd.PrevNoExist}).
+		Return(nil, etcd.Error{Code: etcd.ErrorCodeNodeExist}).Once()
+
+	_, err = store.StoreProducers("journal/name", states, revision)
+	c.Check(err, gc.Equals, journal.ErrProducersConflict)
+
+	keys.AssertExpectations(c)
+}
+
+var _ = gc.Suite(&ProducerStoreSuite{})
@@ -18,9 +18,11 @@ import (
 )
 
 type ReadAPI struct {
-	cfs     cloudstore.FileSystem
-	decoder *schema.Decoder
-	handler ReadOpHandler
+	cfs        cloudstore.FileSystem
+	decoder    *schema.Decoder
+	handler    ReadOpHandler
+	authorizer Authorizer
+	cache      *FragmentCache
 }
 
 func NewReadAPI(handler ReadOpHandler, cfs cloudstore.FileSystem) *ReadAPI {
@@ -31,6 +33,18 @@ func NewReadAPI(handler ReadOpHandler, cfs cloudstore.FileSystem) *ReadAPI {
 	return &ReadAPI{handler: handler, cfs: cfs, decoder: decoder}
 }
 
+// UseAuthorizer authorizes reads with |authorizer|.
+func (h *ReadAPI) UseAuthorizer(authorizer Authorizer) *ReadAPI {
+	h.authorizer = authorizer
+	return h
+}
+
+// UseFragmentCache reads remote fragments through |cache|.
+func (h *ReadAPI) UseFragmentCache(cache *FragmentCache) *ReadAPI {
+	h.cache = cache
+	return h
+}
+
 func (h *ReadAPI) Register(router *mux.Router) {
 	router.NewRoute().Methods("HEAD").HandlerFunc(h.Head)
 	router.NewRoute().Methods("GET").HandlerFunc(h.Read)
@@ -43,7 +57,8 @@ func (h *ReadAPI) Head(w http.ResponseWriter, r *http.Request) {
 	var op, result = h.initialRead(w, r)
 
 	switch result.Error {
-	case nil, journal.ErrNotYetAvailable, journal.ErrNotReplica, journal.ErrNotFound:
+	case nil, journal.ErrNotYetAvailable, journal.ErrNotReplica, journal.ErrNotFound,
+		journal.ErrNotAuthorized:
 		// Common expected error cases: don't log.
 	default:
 		log.WithFields(log.Fields{"err": result.Error, "ReadOp": op}).Warn("head failed")
@@ -62,7 +77,8 @@ func (h *ReadAPI) Read(w http.ResponseWriter, r *http.Request) {
 	for iter := 0; true; iter++ {
 
 		switch result.Error {
-		case journal.ErrNotYetAvailable, journal.ErrNotReplica, journal.ErrNotFound:
+		case journal.ErrNotYetAvailable, journal.ErrNotReplica, journal.ErrNotFound,
+			journal.ErrNotAuthorized:
 			return // Common error cases: don't log.
 		case nil:
 			// Fall through.
@@ -73,21 +89,20 @@ func (h *ReadAPI) Read(w http.ResponseWriter, r *http.Request) {
 		}
 
 		if !result.Fragment.IsLocal() {
-			if iter == 0 {
-				// A proxied read of a remote fragment is inefficient. We'll still do
-				// it if explicitly asked, but surface the call via logging.
-				log.WithField("fragment", result.Fragment.ContentPath()).
-					Warn("non-local fragment read")
-			} else {
+			if iter != 0 {
 				// The client has fallen behind, or we've already proxied a fragment
 				// from remote storage. Force the client to explicitly re-issue the
 				// request. A well-behaved client will then stream directly.
 				break
+			} else if h.cache == nil {
+				// An uncached, proxied read of a remote fragment is inefficient. We'll
+				// still do it if explicitly asked, but surface the call via logging.
+				log.WithField("fragment", result.Fragment.ContentPath()).
+					Warn("non-local fragment read")
 			}
 		}
 
-		var reader io.Reader
-		reader, err := result.Fragment.ReaderFromOffset(result.Offset, h.cfs)
+		reader, err := fragmentReader(r.Context(), h.cache, h.cfs, result.Fragment, result.Offset)
 		if err != nil {
 			log.WithFields(log.Fields{"err": err, "ReadOp": op, "ReadIter": iter}).
 				Warn("failed to get a fragment reader")
@@ -95,6 +110,8 @@ func (h *ReadAPI) Read(w http.ResponseWriter, r *http.Request) {
 		}
 
 		delta, err := io.Copy(w, reader)
+		reader.Close()
+
 		if err != nil {
 			log.WithFields(log.Fields{"err": err, "ReadOp": op, "ReadIter": iter}).
 				Warn("failed to copy to client")
@@ -115,16 +132,22 @@ func (h *ReadAPI) initialRead(w http.ResponseWriter, r *http.Request) (journal.R
 	journal.ReadResult) {
 
 	var schema struct {
-		Offset  int64 // Required.
-		Block   bool
-		BlockMS int64
+		Offset    int64 // Required.
+		Timestamp string
+		Block     bool
+		BlockMS   int64
 	}
 	var op journal.ReadOp
 	var result journal.ReadResult
 
+	var timestamp time.Time
+
 	if result.Error = r.ParseForm(); result.Error == nil {
 		result.Error = h.decoder.Decode(&schema, r.Form)
 	}
+	if result.Error == nil && schema.Timestamp != "" {
+		timestamp, result.Error = time.Parse(time.RFC3339Nano, schema.Timestamp)
+	}
 	if result.Error != nil {
 		if tr, ok := trace.FromContext(r.Context()); ok {
 			tr.LazyPrintf("parsing request: %v", result.Error)
@@ -134,6 +157,12 @@ func (h *ReadAPI) initialRead(w http.ResponseWriter, r *http.Request) (journal.R
 		return op, result
 	}
 
+	var name = journal.Name(r.URL.Path[1:])
+	if result.Error = authorize(h.authorizer, r, OpRead, name); result.Error != nil {
+		http.Error(w, result.Error.Error(), journal.StatusCodeForError(result.Error))
+		return op, result
+	}
+
 	var deadline time.Time
 	if schema.BlockMS != 0 {
 		deadline = time.Now().Add(time.Duration(schema.BlockMS) * time.Millisecond)
@@ -142,10 +171,11 @@ func (h *ReadAPI) initialRead(w http.ResponseWriter, r *http.Request) (journal.R
 
 	op = journal.ReadOp{
 		ReadArgs: journal.ReadArgs{
-			Journal:  journal.Name(r.URL.Path[1:]),
-			Offset:   schema.Offset,
-			Blocking: false,
-			Context:  r.Context(),
+			Journal:   name,
+			Offset:    schema.Offset,
+			Timestamp: timestamp,
+			Blocking:  false,
+			Context:   r.Context(),
 		},
 		Result: make(chan journal.ReadResult, 1),
 	}
@@ -153,6 +183,11 @@ func (h *ReadAPI) initialRead(w http.ResponseWriter, r *http.Request) (journal.R
 	h.handler.Read(op)
 	result = <-op.Result
 
+	if !op.Timestamp.IsZero() && result.Error != journal.ErrNotReplica {
+		// The initial read resolved |timestamp| to an offset. Continue from it.
+		op.Offset, op.Timestamp = result.Offset, time.Time{}
+	}
+
 	if result.Error == journal.ErrNotYetAvailable || result.WriteHead != 0 {
 		// Informational: Add the current write head.
 		w.Header().Add(WriteHeadHeader, strconv.FormatInt(result.WriteHead, 10))
@@ -195,7 +230,7 @@ func (h *ReadAPI) initialRead(w http.ResponseWriter, r *http.Request) (journal.R
 			if err == nil {
 				w.Header().Add(FragmentLocationHeader, url.String())
 				w.Header().Add(FragmentLastModifiedHeader, result.Fragment.RemoteModTime.Format(http.TimeFormat))
-			} else {
+			} else if err != cloudstore.ErrURLNotSupported {
 				log.WithFields(log.Fields{"err": err, "fragment": result.Fragment}).
 					Warn("failed to generate remote URL")
 			}
@@ -302,6 +302,49 @@ func (s *ReadAPISuite) TestInvalidArguments(c *gc.C) {
 		"schema: error converting value for \"offset\"\n")
 }
 
+func (s *ReadAPISuite) TestBlockingReadFromTimestamp(c *gc.C) {
+	req, _ := http.NewRequest("HEAD", "/journal/name?timestamp=2018-01-02T09:00:00Z&block=true", nil)
+	w := httptest.NewRecorder()
+
+	s.readCallbacks = []func(journal.ReadOp){
+		func(op journal.ReadOp) {
+			c.Check(op.Timestamp.Equal(time.Date(2018, 1, 2, 9, 0, 0, 0, time.UTC)), gc.Equals, true)
+
+			// Content committed since the timestamp isn't yet available.
+			op.Result <- journal.ReadResult{
+				Error:     journal.ErrNotYetAvailable,
+				Offset:    12371,
+				WriteHead: 12371,
+			}
+		},
+		func(op journal.ReadOp) {
+			// Expect the blocking read continues from the resolved offset.
+			c.Check(op.Timestamp.IsZero(), gc.Equals, true)
+			c.Check(op.Offset, gc.Equals, int64(12371))
+			c.Check(op.Blocking, gc.Equals, true)
+
+			op.Result <- journal.ReadResult{
+				Offset:    12371,
+				WriteHead: 12400,
+				Fragment:  journal.Fragment{Journal: "journal/name", Begin: 12371, End: 12400},
+			}
+		},
+	}
+	s.mux.ServeHTTP(w, req)
+
+	c.Check(w.Code, gc.Equals, http.StatusPartialContent)
+	c.Check(w.Header().Get("Content-Range"), gc.Equals,
+		fmt.Sprintf("bytes 12371-%v/%v", int64(math.MaxInt64), int64(math.MaxInt64)))
+}
+
+func (s *ReadAPISuite) TestInvalidTimestamp(c *gc.C) {
+	req, _ := http.NewRequest("GET", "/journal/name?timestamp=yesterday", nil)
+	w := httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusBadRequest)
+}
+
 func (s *ReadAPISuite) TestNotReplica(c *gc.C) {
 	req, _ := http.NewRequest("GET", "/journal/name?offset=12350", nil)
 	w := httptest.NewRecorder()
@@ -356,6 +399,31 @@ func (s *ReadAPISuite) TestInternalError(c *gc.C) {
 	c.Check(w.Body.String(), gc.Equals, "some error\n")
 }
 
+func (s *ReadAPISuite) TestAuthorization(c *gc.C) {
+	var m = mux.NewRouter()
+	NewReadAPI(s, s.cfs).UseAuthorizer(staticAuthorizer("secret")).Register(m)
+
+	// A request without the expected token is rejected, without a read.
+	req, _ := http.NewRequest("GET", "/journal/name?offset=12350", nil)
+	w := httptest.NewRecorder()
+
+	m.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusForbidden)
+
+	// A request presenting the token is read.
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+
+	s.readCallbacks = []func(journal.ReadOp){
+		func(op journal.ReadOp) {
+			c.Check(op.Journal, gc.Equals, journal.Name("journal/name"))
+			op.Result <- journal.ReadResult{Error: journal.ErrNotYetAvailable, WriteHead: 12350}
+		},
+	}
+	m.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusRequestedRangeNotSatisfiable)
+}
+
 // Implementation of ReadOpHandler.
 func (s *ReadAPISuite) Read(op journal.ReadOp) {
 	s.readCallbacks[0](op)
@@ -15,8 +15,9 @@ import (
 )
 
 type ReplicateAPI struct {
-	handler ReplicateOpHandler
-	decoder *schema.Decoder
+	handler    ReplicateOpHandler
+	decoder    *schema.Decoder
+	authorizer Authorizer
 }
 
 func NewReplicateAPI(handler ReplicateOpHandler) *ReplicateAPI {
@@ -27,6 +28,12 @@ func NewReplicateAPI(handler ReplicateOpHandler) *ReplicateAPI {
 	return &ReplicateAPI{handler: handler, decoder: decoder}
 }
 
+// UseAuthorizer authorizes replications with |authorizer|.
+func (h *ReplicateAPI) UseAuthorizer(authorizer Authorizer) *ReplicateAPI {
+	h.authorizer = authorizer
+	return h
+}
+
 func (h *ReplicateAPI) Register(router *mux.Router) {
 	router.NewRoute().Methods("REPLICATE").HandlerFunc(h.Replicate)
 }
@@ -35,6 +42,12 @@ func (h *ReplicateAPI) Replicate(w http.ResponseWriter, r *http.Request) {
 	r = maybeTrace(r, "ReplicateAPI.Replicate")
 	defer finishTrace(r)
 
+	var name = journal.Name(r.URL.Path[1:])
+	if err := authorize(h.authorizer, r, OpReplicate, name); err != nil {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+
 	var schema struct {
 		WriteHead  int64
 		RouteToken string
@@ -56,7 +69,7 @@ func (h *ReplicateAPI) Replicate(w http.ResponseWriter, r *http.Request) {
 
 	var op = journal.ReplicateOp{
 		ReplicateArgs: journal.ReplicateArgs{
-			Journal:    journal.Name(r.URL.Path[1:]),
+			Journal:    name,
 			RouteToken: journal.RouteToken(schema.RouteToken),
 			WriteHead:  schema.WriteHead,
 			NewSpool:   schema.NewSpool,
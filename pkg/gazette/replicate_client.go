@@ -3,6 +3,7 @@ package gazette
 import (
 	"bufio"
 	"bytes"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -24,9 +25,13 @@ import (
 
 const (
 	CommitDeltaHeader          = "X-Commit-Delta"
+	ExpectWriteHeadHeader      = "X-Expect-Write-Head"
 	FragmentLastModifiedHeader = "X-Fragment-Last-Modified"
 	FragmentLocationHeader     = "X-Fragment-Location"
 	FragmentNameHeader         = "X-Fragment-Name"
+	ProducerHeader             = "X-Producer-Id"
+	ProducerSequenceHeader     = "X-Producer-Sequence"
+	RetryAfterHeader           = "Retry-After"
 	RouteTokenHeader           = "X-Route-Token"
 	WriteHeadHeader            = "X-Write-Head"
 
@@ -36,6 +41,9 @@ const (
 type ReplicateClient struct {
 	endpoint *CachedURL
 	idlePool chan replicaClientConn
+	// TLS configuration used with https:// endpoints. If nil, the host's root
+	// CAs are used and no client certificate is presented.
+	tlsConfig *tls.Config
 }
 
 type replicaClientConn struct {
@@ -68,6 +76,15 @@ func NewReplicateClient(ep *CachedURL) ReplicateClient {
 	}
 }
 
+// NewReplicateClientWithTLS returns a ReplicateClient which uses TLS |config|
+// with https:// endpoints, presenting its certificate (if any) to replicas
+// which require mutual authentication.
+func NewReplicateClientWithTLS(ep *CachedURL, config *tls.Config) ReplicateClient {
+	var c = NewReplicateClient(ep)
+	c.tlsConfig = config
+	return c
+}
+
 func (c ReplicateClient) Replicate(op journal.ReplicateOp) {
 	transaction := replicaClientTransaction{client: c}
 	go transaction.start(op)
@@ -157,10 +174,26 @@ func (t *replicaClientTransaction) takeConn() (replicaClientConn, error) {
 		t.client.endpoint.InvalidateResolution()
 		return replicaClientConn{}, err
 	}
+	if url.Scheme == "https" {
+		raw = tls.Client(raw, t.client.tlsClientConfig())
+	}
 	return replicaClientConn{raw,
 		bufio.NewReadWriter(bufio.NewReader(raw), bufio.NewWriter(raw))}, nil
 }
 
+// Returns the TLS configuration of the client, with a ServerName of the
+// endpoint's (unresolved) host.
+func (c ReplicateClient) tlsClientConfig() *tls.Config {
+	var config = new(tls.Config)
+	if c.tlsConfig != nil {
+		config = c.tlsConfig.Clone()
+	}
+	if url, err := c.endpoint.URL(); err == nil {
+		config.ServerName = url.Hostname()
+	}
+	return config
+}
+
 func (t *replicaClientTransaction) putConn(conn replicaClientConn) {
 	conn.raw.SetReadDeadline(time.Time{}) // Clear timeout.
 	select {
@@ -3,12 +3,16 @@ package gazette
 import (
 	"bytes"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"strings"
 
 	gc "github.com/go-check/check"
 
 	"github.com/LiveRamp/gazette/pkg/journal"
+	"github.com/LiveRamp/gazette/pkg/tlsconfig"
 )
 
 type ReplicateClientSuite struct {
@@ -154,4 +158,41 @@ func (s *ReplicateClientSuite) TestOpParallelism(c *gc.C) {
 	c.Check(result1.Writer.Commit(2345), gc.IsNil)
 }
 
+func (s *ReplicateClientSuite) TestMutualTLS(c *gc.C) {
+	var dir, err = ioutil.TempDir("", "replicate-client-suite")
+	c.Assert(err, gc.IsNil)
+	defer os.RemoveAll(dir)
+
+	files, err := tlsconfig.WriteTestCertificates(dir)
+	c.Assert(err, gc.IsNil)
+	serverConfig, err := tlsconfig.Load(files.ServerCert, files.ServerKey, files.CA)
+	c.Assert(err, gc.IsNil)
+
+	var server = httptest.NewUnstartedServer(s)
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	// Brokers present their server certificate as a client certificate.
+	var client = NewReplicateClientWithTLS(&CachedURL{Base: server.URL}, serverConfig)
+	var op = s.opFixture()
+	client.Replicate(op)
+
+	var result = <-op.Result
+	c.Assert(result.Error, gc.IsNil)
+	result.Writer.Write([]byte("expected write body"))
+	c.Check(result.Writer.Commit(2345), gc.IsNil)
+
+	// A client which presents no certificate is rejected. Use a distinct URL
+	// of the server, as idle connections are pooled on endpoint URL.
+	noCertConfig, err := tlsconfig.Load("", "", files.CA)
+	c.Assert(err, gc.IsNil)
+
+	client = NewReplicateClientWithTLS(&CachedURL{
+		Base: strings.Replace(server.URL, "127.0.0.1", "localhost", 1)}, noCertConfig)
+	op = s.opFixture()
+	client.Replicate(op)
+	c.Check((<-op.Result).Error, gc.NotNil)
+}
+
 var _ = gc.Suite(&ReplicateClientSuite{})
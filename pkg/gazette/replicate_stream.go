@@ -0,0 +1,326 @@
+package gazette
+
+import (
+	"crypto/tls"
+	"errors"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/net/context"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
+	"github.com/LiveRamp/gazette/pkg/keepalive"
+)
+
+var (
+	errReplicateStreamClosed   = errors.New("replicate stream closed")
+	errUnexpectedReplicateResp = errors.New("unexpected replicate response")
+)
+
+// ReplicateStream is a journal.Replicator which replicates transactions to a
+// remote replica over a long-lived Journal Replicate gRPC stream, rather than
+// a new request per transaction (as does ReplicateClient). Transactions are
+// pipelined: a transaction's header and content are streamed without awaiting
+// the replica's verification, which is instead awaited by the broker prior to
+// commit (see journal.PendingWriteCommitter). Replica responses are
+// acknowledged in stream order. The stream is opened on first use and is
+// re-opened after a failure. Streams of the same endpoint and TLS configuration
+// share a connection, which is closed once all such ReplicateStreams are closed.
+type ReplicateStream struct {
+	// Endpoint of the replica's Journal gRPC service, in "host:port" format.
+	endpoint string
+	// TLS configuration of the stream, or nil if TLS is not used.
+	tlsConfig *tls.Config
+
+	// Client of the shared connection to |endpoint|, or nil if the connection
+	// has not yet been acquired.
+	client journal.JournalClient
+	// Current stream, or nil if a stream must be opened.
+	conn   *replicateStreamConn
+	closed bool
+	mu     sync.Mutex
+}
+
+// replicateStreamConn is an opened stream of a ReplicateStream.
+type replicateStreamConn struct {
+	stream journal.Journal_ReplicateClient
+	cancel context.CancelFunc
+
+	// Transactions awaiting replica responses, in stream order. Guarded by
+	// the |mu| of the ReplicateStream.
+	pending []*replicateStreamTxn
+
+	// Serializes stream sends.
+	sendMu sync.Mutex
+}
+
+// replicateStreamTxn is a transaction of a replicateStreamConn. It implements
+// journal.PendingWriteCommitter.
+type replicateStreamTxn struct {
+	conn *replicateStreamConn
+
+	verified  chan journal.ReplicateResult
+	committed chan error
+
+	// Verification of the transaction, valid once |awaited|.
+	result  journal.ReplicateResult
+	awaited bool
+	// Whether |verified| has been sent. Accessed only by serveResponses.
+	wasVerified bool
+}
+
+// NewReplicateStream returns a ReplicateStream to the Journal gRPC service
+// at |endpoint|, in "host:port" format. If |tlsConfig| is non-nil, the stream
+// uses TLS. No stream is opened until a transaction is replicated.
+func NewReplicateStream(endpoint string, tlsConfig *tls.Config) *ReplicateStream {
+	return &ReplicateStream{endpoint: endpoint, tlsConfig: tlsConfig}
+}
+
+func (s *ReplicateStream) Replicate(op journal.ReplicateOp) {
+	// As with other Replicators, |op| is resolved asynchronously.
+	go func() {
+		if txn, err := s.begin(op.ReplicateArgs); err != nil {
+			op.Result <- journal.ReplicateResult{Error: err}
+		} else {
+			op.Result <- journal.ReplicateResult{Writer: txn}
+		}
+	}()
+}
+
+// Close closes the ReplicateStream. Pending transactions fail, as does
+// further use of the ReplicateStream.
+func (s *ReplicateStream) Close() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return
+	}
+	s.closed = true
+	if s.conn != nil {
+		s.conn.cancel()
+		s.conn = nil
+	}
+	if s.client != nil {
+		releaseStreamConn(s.endpoint, s.tlsConfig)
+		s.client = nil
+	}
+}
+
+// begin sends the header of a transaction described by |args|, opening a
+// stream if required.
+func (s *ReplicateStream) begin(args journal.ReplicateArgs) (*replicateStreamTxn, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return nil, errReplicateStreamClosed
+	}
+	if s.conn == nil {
+		var conn, err = s.open()
+		if err != nil {
+			return nil, err
+		}
+		s.conn = conn
+		go s.serveResponses(conn)
+	}
+	var txn = &replicateStreamTxn{
+		conn:      s.conn,
+		verified:  make(chan journal.ReplicateResult, 1),
+		committed: make(chan error, 1),
+	}
+
+	if err := txn.send(&journal.ReplicateRequest{
+		Journal:    args.Journal,
+		WriteHead:  args.WriteHead,
+		RouteToken: args.RouteToken,
+		NewSpool:   args.NewSpool,
+	}); err != nil {
+		// The stream has failed. serveResponses will observe this, and the next
+		// transaction will open a new stream.
+		return nil, err
+	}
+	s.conn.pending = append(s.conn.pending, txn)
+	return txn, nil
+}
+
+func (s *ReplicateStream) open() (*replicateStreamConn, error) {
+	if s.client == nil {
+		var conn, err = acquireStreamConn(s.endpoint, s.tlsConfig)
+		if err != nil {
+			return nil, err
+		}
+		s.client = journal.NewJournalClient(conn)
+	}
+	var ctx, cancel = context.WithCancel(context.Background())
+
+	var stream, err = s.client.Replicate(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return &replicateStreamConn{stream: stream, cancel: cancel}, nil
+}
+
+// serveResponses reads responses of |conn| and dispatches them to pending
+// transactions, until the stream fails.
+func (s *ReplicateStream) serveResponses(conn *replicateStreamConn) {
+	for {
+		var resp, err = conn.stream.Recv()
+
+		s.mu.Lock()
+		if err == nil && len(conn.pending) == 0 {
+			err = errUnexpectedReplicateResp
+		}
+		if err != nil {
+			var pending, closed = conn.pending, s.closed
+			conn.pending = nil
+
+			if s.conn == conn {
+				s.conn = nil
+			}
+			s.mu.Unlock()
+
+			if !closed {
+				log.WithFields(log.Fields{"err": err, "endpoint": s.endpoint}).
+					Warn("replicate stream failed")
+			}
+			conn.cancel()
+
+			for _, txn := range pending {
+				txn.fail(err)
+			}
+			return
+		}
+
+		var txn = conn.pending[0]
+		var status = journal.ErrorForStatus(resp.Status)
+
+		if !txn.wasVerified {
+			txn.wasVerified = true
+			txn.verified <- journal.ReplicateResult{Error: status, ErrorWriteHead: resp.WriteHead}
+
+			if status != nil {
+				// No further response is sent for a rejected transaction.
+				conn.pending = conn.pending[1:]
+			}
+		} else {
+			txn.committed <- status
+			conn.pending = conn.pending[1:]
+		}
+		s.mu.Unlock()
+	}
+}
+
+func (t *replicateStreamTxn) Write(p []byte) (int, error) {
+	// Send marshals |p| before returning, allowing the caller to re-use it.
+	if err := t.send(&journal.ReplicateRequest{Content: p}); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (t *replicateStreamTxn) Await() journal.ReplicateResult {
+	if !t.awaited {
+		t.result, t.awaited = <-t.verified, true
+	}
+	return t.result
+}
+
+func (t *replicateStreamTxn) Commit(count int64) error {
+	// The commit request delimits the transaction on the stream, and is sent
+	// even if the replica rejected the transaction.
+	var err = t.send(&journal.ReplicateRequest{Commit: true, CommitDelta: count})
+
+	if result := t.Await(); result.Error != nil {
+		return result.Error
+	} else if err != nil {
+		return err
+	}
+	return <-t.committed
+}
+
+func (t *replicateStreamTxn) send(req *journal.ReplicateRequest) error {
+	t.conn.sendMu.Lock()
+	defer t.conn.sendMu.Unlock()
+
+	return t.conn.stream.Send(req)
+}
+
+// fail resolves the transaction with stream error |err|.
+func (t *replicateStreamTxn) fail(err error) {
+	if !t.wasVerified {
+		t.wasVerified = true
+		t.verified <- journal.ReplicateResult{Error: err}
+	}
+	t.committed <- err
+}
+
+// acquireStreamConn returns a gRPC connection to |endpoint| using |tlsConfig|,
+// which is shared by all ReplicateStreams of the endpoint and configuration.
+// Each acquisition must be released with releaseStreamConn.
+func acquireStreamConn(endpoint string, tlsConfig *tls.Config) (*grpc.ClientConn, error) {
+	streamConnsMu.Lock()
+	defer streamConnsMu.Unlock()
+
+	var key = streamConnKey{endpoint: endpoint, tlsConfig: tlsConfig}
+	var shared, ok = streamConns[key]
+	if !ok {
+		var security = grpc.WithInsecure()
+		if tlsConfig != nil {
+			security = grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig))
+		}
+
+		var conn, err = grpc.Dial(endpoint,
+			grpc.WithDialer(keepalive.DialerFunc),
+			security)
+		if err != nil {
+			return nil, err
+		}
+		shared = &sharedStreamConn{conn: conn}
+		streamConns[key] = shared
+	}
+	shared.refs++
+	return shared.conn, nil
+}
+
+// releaseStreamConn releases an acquired connection to |endpoint| using
+// |tlsConfig|, closing it if it has no further references.
+func releaseStreamConn(endpoint string, tlsConfig *tls.Config) {
+	streamConnsMu.Lock()
+	defer streamConnsMu.Unlock()
+
+	var key = streamConnKey{endpoint: endpoint, tlsConfig: tlsConfig}
+	var shared = streamConns[key]
+	if shared.refs--; shared.refs != 0 {
+		return
+	}
+	delete(streamConns, key)
+
+	if err := shared.conn.Close(); err != nil {
+		log.WithFields(log.Fields{"err": err, "endpoint": endpoint}).
+			Warn("failed to close replicate stream connection")
+	}
+}
+
+// streamConnKey identifies a shared connection of ReplicateStreams. TLS
+// configurations are compared by identity: a connection is shared only by
+// ReplicateStreams using the very same *tls.Config.
+type streamConnKey struct {
+	endpoint  string
+	tlsConfig *tls.Config
+}
+
+// sharedStreamConn is a reference-counted connection of ReplicateStreams.
+type sharedStreamConn struct {
+	conn *grpc.ClientConn
+	refs int
+}
+
+var (
+	// Connections of ReplicateStreams.
+	streamConns   = make(map[streamConnKey]*sharedStreamConn)
+	streamConnsMu sync.Mutex
+)
@@ -1,19 +1,28 @@
 package gazette
 
 import (
+	"bytes"
+	"context"
+	"crypto/tls"
 	"encoding/json"
 	"expvar"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"path"
+	"reflect"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/trace"
 
+	"github.com/LiveRamp/gazette/pkg/consensus"
 	"github.com/LiveRamp/gazette/pkg/journal"
 )
 
@@ -24,11 +33,22 @@ type ReplicaFactory func(journal.Name) JournalReplica
 // collection of responsible JournalReplicas.
 type Router struct {
 	replicaFactory ReplicaFactory
+	// Port of the Journal gRPC service of peers, to which brokered transactions
+	// are replicated over ReplicateStreams. If empty, transactions are instead
+	// replicated via ReplicateClient.
+	streamPort string
+	// TLS configuration used to replicate to peers having https:// routes.
+	peerTLS *tls.Config
+	// Limiter of brokered appends, or nil if appends are not limited.
+	limiter *AppendLimiter
 
 	routes map[journal.Name]*journalRoute
+	// Number of local replica shutdowns in progress, by journal.
+	shutdowns  map[journal.Name]int
+	shutdownWG sync.WaitGroup
 
 	// This mutex guards any read or write operation on |routes| *and* its
-	// underlying |*journalRoute| values.
+	// underlying |*journalRoute| values, as well as |shutdowns|.
 	routesMu sync.Mutex
 }
 
@@ -36,6 +56,7 @@ func NewRouter(factory ReplicaFactory) *Router {
 	var r = &Router{
 		replicaFactory: factory,
 		routes:         make(map[journal.Name]*journalRoute),
+		shutdowns:      make(map[journal.Name]int),
 	}
 
 	gazetteMap.Set("brokers", journalStringer(r.BrokeredJournals))
@@ -43,6 +64,28 @@ func NewRouter(factory ReplicaFactory) *Router {
 	return r
 }
 
+// ReplicateOverStreams configures the Router to replicate brokered
+// transactions over ReplicateStreams, to the Journal gRPC service at |port|
+// of each peer. It must be called before journals are routed.
+func (r *Router) ReplicateOverStreams(port int) {
+	r.streamPort = strconv.Itoa(port)
+}
+
+// UsePeerTLS configures the Router to replicate brokered transactions to
+// peers having https:// routes using TLS |config|, which typically presents
+// a client certificate for mutual authentication. It must be called before
+// journals are routed.
+func (r *Router) UsePeerTLS(config *tls.Config) {
+	r.peerTLS = config
+}
+
+// LimitAppends configures the Router to admit appends of brokered journals
+// only within the AppendQuotas of |limiter|. Other appends fail with
+// journal.ErrRateLimited. It must be called before journals are routed.
+func (r *Router) LimitAppends(limiter *AppendLimiter) {
+	r.limiter = limiter
+}
+
 func (r *Router) Read(op journal.ReadOp) {
 	if tr, ok := trace.FromContext(op.Context); ok {
 		tr.LazyPrintf("Read request: %s", op.ReadArgs)
@@ -93,6 +136,12 @@ func (r *Router) Read(op journal.ReadOp) {
 }
 
 func (r *Router) Append(op journal.AppendOp) {
+	r.append(op, true)
+}
+
+// append dispatches |op| to the journal's broker. If |limit|, the append is
+// subject to (and charged against) the AppendQuotas of the journal.
+func (r *Router) append(op journal.AppendOp, limit bool) {
 	if tr, ok := trace.FromContext(op.Context); ok {
 		tr.LazyPrintf("Append request: %s", op.AppendArgs)
 	}
@@ -112,6 +161,15 @@ func (r *Router) Append(op journal.AppendOp) {
 			Error:      journal.ErrReplicationFailed,
 			RouteToken: route.token,
 		}
+	} else if limit && r.limiter != nil {
+		if retryAfter := r.limiter.Admit(op.Journal); retryAfter != 0 {
+			// We are the broker, but the append exceeds a quota of the journal.
+			result = journal.AppendResult{
+				Error:      journal.ErrRateLimited,
+				RouteToken: route.token,
+				RetryAfter: retryAfter,
+			}
+		}
 	}
 
 	if result.Error != nil {
@@ -128,10 +186,24 @@ func (r *Router) Append(op journal.AppendOp) {
 	var forward = op.Result
 	op.Result = make(chan journal.AppendResult, 1)
 
+	// Count appended content, to be charged against quotas of the journal
+	// and tracked by its append rate.
+	var content = new(countingReader)
+	if op.Content != nil {
+		content.Reader, op.Content = op.Content, content
+	}
+
 	go func(token, lastAppendToken journal.RouteToken) {
 		var result = <-op.Result
 		result.RouteToken = token
 
+		if limit && r.limiter != nil {
+			r.limiter.Charge(op.Journal, content.n)
+		}
+		if result.Error == nil {
+			route.appendRate.Add(float64(content.n), time.Now())
+		}
+
 		if tr, ok := trace.FromContext(op.Context); ok {
 			tr.LazyPrintf("Append result: %s", result)
 			if result.Error != nil {
@@ -145,9 +217,11 @@ func (r *Router) Append(op journal.AppendOp) {
 			// rather than its present value under |r.routes|.
 			// Similarly |route.lastAppendToken| may have been updated by a raced
 			// append: in this case we don't care, as it will still converge to
-			// the correct value.
+			// the correct value. The route may also have been removed.
 			r.routesMu.Lock()
-			r.routes[op.Journal].lastAppendToken = token
+			if route, ok := r.routes[op.Journal]; ok {
+				route.lastAppendToken = token
+			}
 			r.routesMu.Unlock()
 		}
 
@@ -210,6 +284,57 @@ func (r *Router) HasServedAppend(name journal.Name) bool {
 	return ok && route.brokerReady && route.token == route.lastAppendToken
 }
 
+// Returns whether the Router has a route for journal |name|.
+func (r *Router) HasRoute(name journal.Name) bool {
+	var _, ok = r.readRoute(name)
+	return ok
+}
+
+// Returns whether a local replica of journal |name| is shutting down, and
+// has yet to hand its spool to the FragmentPersister.
+func (r *Router) IsReplicaShuttingDown(name journal.Name) bool {
+	r.routesMu.Lock()
+	defer r.routesMu.Unlock()
+
+	return r.shutdowns[name] != 0
+}
+
+// AppendRate returns the recent rate of bytes per second appended to journal
+// |name| through this Router, or zero if the journal is unknown.
+func (r *Router) AppendRate(name journal.Name) float64 {
+	if route, ok := r.readRoute(name); ok {
+		return route.appendRate.Rate(time.Now())
+	}
+	return 0
+}
+
+// PulseBrokeredJournals issues a no-op Append to each journal brokered by
+// this Router, and waits for their completion. A journal can be handed off
+// only after its broker has served an Append under the current route
+// topology (see HasServedAppend), and pulses ensure that it does so even
+// if clients append to the journal infrequently.
+func (r *Router) PulseBrokeredJournals() {
+	var emptyBuffer bytes.Buffer
+	var journals = r.BrokeredJournals()
+	var resultCh = make(chan journal.AppendResult, len(journals))
+
+	// Pulses are not subject to AppendQuotas: they're issued by the broker
+	// itself, and must proceed even when a journal's quota is exhausted.
+	for _, name := range journals {
+		r.append(journal.AppendOp{
+			AppendArgs: journal.AppendArgs{
+				Journal: name,
+				Content: &emptyBuffer,
+				Context: context.Background(),
+			},
+			Result: resultCh,
+		}, false)
+	}
+	for _ = range journals {
+		<-resultCh
+	}
+}
+
 // Returns the set of Journals which are brokered by this Router.
 func (r *Router) BrokeredJournals() []journal.Name {
 	r.routesMu.Lock()
@@ -251,20 +376,26 @@ type journalRoute struct {
 	// Current topology |token| of journal, and the token of the most-recent
 	// Append operation which we successfully brokered.
 	token, lastAppendToken journal.RouteToken
+	// Current specification of the journal.
+	spec journal.JournalSpec
+	// ReplicateStreams to peers of a locally brokered journal, keyed on the
+	// peer's route token entry.
+	streams map[string]*ReplicateStream
+	// Rate of content appended to the journal through this Router.
+	appendRate *consensus.DecayingRate
 }
 
 // Updates |routes| with new information about the journal. Creates a route if
 // it does not already exist, or updates the existing one otherwise. Holds the
 // lock to update both |routes| and underlying |journalRoute| objects.
-// Once created, a route cannot be removed from |routes| without restarting the
-// process.
+// Once created, a route is removed from |routes| only by remove().
 func (r *Router) transition(name journal.Name, rt journal.RouteToken,
-	index, requiredReplicas int) {
+	index int, spec journal.JournalSpec) {
 
 	// We are a replica if our index is within the range of required replicas.
-	// Note the broker is a replica, and |requiredReplicas| is zero-indexed
-	// (eg |requiredReplicas| of 2 implies one master and two replicas).
-	var replica = index != -1 && index <= requiredReplicas
+	// Note the broker is a replica, and |spec.Replication| is zero-indexed
+	// (eg |spec.Replication| of 2 implies one master and two replicas).
+	var replica = index != -1 && index <= spec.Replication
 
 	r.routesMu.Lock()
 	defer r.routesMu.Unlock()
@@ -272,24 +403,29 @@ func (r *Router) transition(name journal.Name, rt journal.RouteToken,
 	var route, ok = r.routes[name]
 	if !ok {
 		// Journal |name| is being tracked for the first time.
-		route = new(journalRoute)
+		route = &journalRoute{appendRate: new(consensus.DecayingRate)}
 		r.routes[name] = route
 	}
 
 	if route.replica == nil && replica {
 		// The replica doesn't exist, but should.
 		route.replica = r.replicaFactory(name)
+		route.replica.UpdateSpec(spec)
 	} else if route.replica != nil && !replica {
 		// The replica exists, but should not.
-		route.replica.Shutdown()
+		r.shutdownReplica(name, route.replica)
 		route.replica = nil
+	} else if route.replica != nil && !reflect.DeepEqual(route.spec, spec) {
+		// The replica exists, and its spec has changed.
+		route.replica.UpdateSpec(spec)
 	}
 
-	if route.token == rt {
-		// This Journal's route is unchanged. No further work.
+	if route.token == rt && reflect.DeepEqual(route.spec, spec) {
+		// This Journal's route and spec are unchanged. No further work.
 		return
 	}
 	route.token = rt
+	route.spec = spec
 
 	// We serve as the broker iff we hold the master item lock, and a sufficent
 	// number of replication peers are present in the route topology.
@@ -299,13 +435,21 @@ func (r *Router) transition(name journal.Name, rt journal.RouteToken,
 	if index == 0 {
 		broker = true
 
-		if peers = routePeers(rt); len(peers) >= requiredReplicas {
+		if peers = r.routePeers(route, rt); len(peers) >= spec.Replication {
 			brokerReady = true
+			// The route topology may include additional members beyond those
+			// required by the journal's spec. They're not replicas, and are not
+			// involved in brokered transactions.
+			peers = peers[:spec.Replication]
 		}
 	}
 	route.broker = broker
 	route.brokerReady = brokerReady
 
+	if !broker {
+		route.closeStreams()
+	}
+
 	if route.broker {
 		route.replica.StartBrokeringWithPeers(rt, peers)
 	} else if route.replica != nil {
@@ -313,6 +457,70 @@ func (r *Router) transition(name journal.Name, rt journal.RouteToken,
 	}
 }
 
+// Removes the route of journal |name|, shutting down its local replica (if
+// any). Spooled content of the replica is handed to its FragmentPersister as
+// the replica shuts down. Subsequent operations of the journal fail with
+// ErrNotFound until the journal is again transitioned.
+func (r *Router) remove(name journal.Name) {
+	r.routesMu.Lock()
+	defer r.routesMu.Unlock()
+
+	var route, ok = r.routes[name]
+	if !ok {
+		return
+	}
+	if route.replica != nil {
+		r.shutdownReplica(name, route.replica)
+	}
+	route.closeStreams()
+	delete(r.routes, name)
+
+	log.WithField("journal", name).Info("removed journal route")
+}
+
+// Shutdown removes all routes of the Router, and blocks until each local
+// replica has shut down. Replicas hand their spooled content to their
+// FragmentPersister as they shut down, so a Persister may be stopped only
+// after Shutdown returns.
+func (r *Router) Shutdown() {
+	r.routesMu.Lock()
+	var names = make([]journal.Name, 0, len(r.routes))
+	for name := range r.routes {
+		names = append(names, name)
+	}
+	r.routesMu.Unlock()
+
+	for _, name := range names {
+		r.remove(name)
+	}
+	r.shutdownWG.Wait()
+}
+
+// Begins a shutdown of |replica| of journal |name|, which is tracked until it
+// completes. |routesMu| must be held.
+func (r *Router) shutdownReplica(name journal.Name, replica JournalReplica) {
+	var done = replica.Shutdown()
+
+	select {
+	case <-done:
+		return // Already complete.
+	default:
+	}
+	r.shutdowns[name]++
+	r.shutdownWG.Add(1)
+
+	go func() {
+		<-done
+
+		r.routesMu.Lock()
+		if r.shutdowns[name]--; r.shutdowns[name] == 0 {
+			delete(r.shutdowns, name)
+		}
+		r.routesMu.Unlock()
+		r.shutdownWG.Done()
+	}()
+}
+
 func (r *Router) readRoute(name journal.Name) (journalRoute, bool) {
 	r.routesMu.Lock()
 	defer r.routesMu.Unlock()
@@ -323,8 +531,64 @@ func (r *Router) readRoute(name journal.Name) (journalRoute, bool) {
 	return journalRoute{}, false
 }
 
-// Builds a Replicator for each non-master replica of |route|.
-func routePeers(rt journal.RouteToken) []journal.Replicator {
+// Builds a Replicator for each non-master replica of |rt|. If the Router
+// replicates over streams, ReplicateStreams of |route| are re-used for peers
+// which remain in the topology, and closed for those which don't.
+func (r *Router) routePeers(route *journalRoute, rt journal.RouteToken) []journal.Replicator {
+	if r.streamPort == "" {
+		return routePeers(rt, r.peerTLS)
+	}
+	var peers []journal.Replicator
+	var streams = make(map[string]*ReplicateStream)
+
+	for i, entry := range strings.Split(string(rt), "|") {
+		if i == 0 {
+			// Skip local token.
+			continue
+		}
+		var stream, ok = route.streams[entry]
+		if !ok {
+			var u, err = url.Parse(entry)
+			if err != nil {
+				log.WithFields(log.Fields{"err": err, "peer": entry}).Error("failed to parse URL")
+				continue
+			}
+			var tlsConfig *tls.Config
+			if u.Scheme == "https" {
+				if tlsConfig = r.peerTLS; tlsConfig == nil {
+					tlsConfig = defaultPeerTLS
+				}
+			}
+			stream = NewReplicateStream(net.JoinHostPort(u.Hostname(), r.streamPort), tlsConfig)
+		}
+		streams[entry] = stream
+		peers = append(peers, stream)
+	}
+	for entry, stream := range route.streams {
+		if _, ok := streams[entry]; !ok {
+			stream.Close()
+		}
+	}
+	route.streams = streams
+	return peers
+}
+
+// TLS configuration of ReplicateStreams to https:// peers, if the Router has
+// no peer TLS configuration. It's shared so that such streams may share
+// connections (see streamConnKey).
+var defaultPeerTLS = new(tls.Config)
+
+// Closes ReplicateStreams of the route, if any.
+func (route *journalRoute) closeStreams() {
+	for _, stream := range route.streams {
+		stream.Close()
+	}
+	route.streams = nil
+}
+
+// Builds a Replicator for each non-master replica of |route|, using TLS
+// |config| with https:// replicas.
+func routePeers(rt journal.RouteToken, config *tls.Config) []journal.Replicator {
 	var peers []journal.Replicator
 
 	for i, url := range strings.Split(string(rt), "|") {
@@ -333,7 +597,7 @@ func routePeers(rt journal.RouteToken) []journal.Replicator {
 			continue
 		}
 		var ep = &CachedURL{Base: url}
-		peers = append(peers, NewReplicateClient(ep))
+		peers = append(peers, NewReplicateClientWithTLS(ep, config))
 	}
 	return peers
 }
@@ -381,3 +645,15 @@ var gazetteMap *expvar.Map
 func init() {
 	gazetteMap = expvar.NewMap("gazette")
 }
+
+// countingReader is an io.Reader which counts bytes read.
+type countingReader struct {
+	io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	var n, err = r.Reader.Read(p)
+	r.n += int64(n)
+	return n, err
+}
@@ -3,10 +3,14 @@ package gazette
 import (
 	"context"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
 	"strings"
+	"time"
 
+	etcd "github.com/coreos/etcd/client"
 	gc "github.com/go-check/check"
 
 	"github.com/LiveRamp/gazette/pkg/journal"
@@ -17,6 +21,7 @@ type RouterSuite struct{}
 func (s *RouterSuite) TestReadConditions(c *gc.C) {
 	var recorder routerRecorder
 	var router = NewRouter(recorder.NewReplica)
+	var spec = journal.JournalSpec{Replication: 1}
 
 	var resultCh = make(chan journal.ReadResult, 1)
 	var op = journal.ReadOp{
@@ -32,7 +37,7 @@ func (s *RouterSuite) TestReadConditions(c *gc.C) {
 	c.Check(<-resultCh, gc.DeepEquals, journal.ReadResult{Error: journal.ErrNotFound})
 
 	// Journal is now known, but non-local.
-	router.transition("foo/bar", "http://server-one|http://server-two", -1, 1)
+	router.transition("foo/bar", "http://server-one|http://server-two", -1, spec)
 	recorder.verify(c) // Expect no replica was created.
 
 	router.Read(op)
@@ -42,8 +47,8 @@ func (s *RouterSuite) TestReadConditions(c *gc.C) {
 	})
 
 	// Journal is now a local replica.
-	router.transition("foo/bar", "http://server|http://local", 1, 1)
-	recorder.verify(c, "created replica foo/bar",
+	router.transition("foo/bar", "http://server|http://local", 1, spec)
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec",
 		"foo/bar => replica http://server|http://local")
 
 	router.Read(op)
@@ -53,7 +58,7 @@ func (s *RouterSuite) TestReadConditions(c *gc.C) {
 	})
 
 	// Journal is no longer local.
-	router.transition("foo/bar", "http://server-fin", -1, 1)
+	router.transition("foo/bar", "http://server-fin", -1, spec)
 	recorder.verify(c, "foo/bar => shutdown")
 
 	router.Read(op)
@@ -66,6 +71,7 @@ func (s *RouterSuite) TestReadConditions(c *gc.C) {
 func (s *RouterSuite) TestAppendConditions(c *gc.C) {
 	var recorder routerRecorder
 	var router = NewRouter(recorder.NewReplica)
+	var spec = journal.JournalSpec{Replication: 1}
 
 	var resultCh = make(chan journal.AppendResult, 1)
 	var op = journal.AppendOp{
@@ -81,7 +87,7 @@ func (s *RouterSuite) TestAppendConditions(c *gc.C) {
 	c.Check(<-resultCh, gc.DeepEquals, journal.AppendResult{Error: journal.ErrNotFound})
 
 	// Journal is now known, but non-local.
-	router.transition("foo/bar", "http://server-one|http://server-two", 2, 1)
+	router.transition("foo/bar", "http://server-one|http://server-two", 2, spec)
 	recorder.verify(c)
 
 	router.Append(op)
@@ -91,8 +97,8 @@ func (s *RouterSuite) TestAppendConditions(c *gc.C) {
 	})
 
 	// Journal is now a local replica.
-	router.transition("foo/bar", "http://server-one|http://server-two", 1, 1)
-	recorder.verify(c, "created replica foo/bar")
+	router.transition("foo/bar", "http://server-one|http://server-two", 1, spec)
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec")
 
 	router.Append(op)
 	c.Check(<-resultCh, gc.DeepEquals, journal.AppendResult{
@@ -101,7 +107,7 @@ func (s *RouterSuite) TestAppendConditions(c *gc.C) {
 	})
 
 	// Journal is now a local broker.
-	router.transition("foo/bar", "http://local|http://remote", 0, 1)
+	router.transition("foo/bar", "http://local|http://remote", 0, spec)
 	recorder.verify(c, "foo/bar => broker http://local|http://remote ([remote])")
 	c.Check(router.HasServedAppend("foo/bar"), gc.Equals, false)
 
@@ -113,7 +119,7 @@ func (s *RouterSuite) TestAppendConditions(c *gc.C) {
 	c.Check(router.HasServedAppend("foo/bar"), gc.Equals, true)
 
 	// Change topology. Expect HasServedAppend changes accordingly.
-	router.transition("foo/bar", "http://local|http://remote-two", 0, 1)
+	router.transition("foo/bar", "http://local|http://remote-two", 0, spec)
 	recorder.verify(c, "foo/bar => broker http://local|http://remote-two ([remote-two])")
 	c.Check(router.HasServedAppend("foo/bar"), gc.Equals, false)
 
@@ -125,7 +131,7 @@ func (s *RouterSuite) TestAppendConditions(c *gc.C) {
 	c.Check(router.HasServedAppend("foo/bar"), gc.Equals, true)
 
 	// A replica is removed, and we are no longer able to broker.
-	router.transition("foo/bar", "http://local", 0, 1)
+	router.transition("foo/bar", "http://local", 0, spec)
 	recorder.verify(c, "foo/bar => broker http://local ([])")
 
 	router.Append(op)
@@ -136,9 +142,116 @@ func (s *RouterSuite) TestAppendConditions(c *gc.C) {
 	c.Check(router.HasServedAppend("foo/bar"), gc.Equals, false)
 }
 
+func (s *RouterSuite) TestAppendRateLimiting(c *gc.C) {
+	var recorder routerRecorder
+	var router = NewRouter(recorder.NewReplica)
+	var spec = journal.JournalSpec{Replication: 1}
+
+	var now = time.Unix(1500000000, 0)
+	var limiter = NewAppendLimiter(nil)
+	limiter.timeNow = func() time.Time { return now }
+	limiter.update(&etcd.Node{Nodes: etcd.Nodes{
+		{Value: `{"prefix": "foo/", "bytesPerSecond": 10}`}}})
+	router.LimitAppends(limiter)
+
+	router.transition("foo/bar", "http://local|http://remote", 0, spec)
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://remote ([remote])")
+
+	var resultCh = make(chan journal.AppendResult, 1)
+	var appendContent = func(content string) journal.AppendResult {
+		router.Append(journal.AppendOp{
+			AppendArgs: journal.AppendArgs{
+				Journal: "foo/bar",
+				Content: strings.NewReader(content),
+				Context: context.Background(),
+			},
+			Result: resultCh,
+		})
+		return <-resultCh
+	}
+
+	// An append which overdraws the quota is admitted.
+	c.Check(appendContent("0123456789abcde"), gc.DeepEquals, journal.AppendResult{
+		WriteHead:  1234,
+		RouteToken: "http://local|http://remote",
+	})
+	// Further appends are limited until the overdraft is repaid.
+	c.Check(appendContent("f"), gc.DeepEquals, journal.AppendResult{
+		Error:      journal.ErrRateLimited,
+		RouteToken: "http://local|http://remote",
+		RetryAfter: 500 * time.Millisecond,
+	})
+
+	now = now.Add(500 * time.Millisecond)
+	c.Check(appendContent("f"), gc.DeepEquals, journal.AppendResult{
+		WriteHead:  1234,
+		RouteToken: "http://local|http://remote",
+	})
+}
+
+func (s *RouterSuite) TestPulsesAreExemptFromRateLimiting(c *gc.C) {
+	var recorder routerRecorder
+	var router = NewRouter(recorder.NewReplica)
+	var spec = journal.JournalSpec{Replication: 1}
+
+	var now = time.Unix(1500000000, 0)
+	var limiter = NewAppendLimiter(nil)
+	limiter.timeNow = func() time.Time { return now }
+	limiter.update(&etcd.Node{Nodes: etcd.Nodes{
+		{Value: `{"prefix": "foo/", "appendsPerSecond": 1}`}}})
+	router.LimitAppends(limiter)
+
+	router.transition("foo/bar", "http://local|http://remote", 0, spec)
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://remote ([remote])")
+
+	// Exhaust the quota of the journal.
+	c.Check(limiter.Admit("foo/bar"), gc.Equals, time.Duration(0))
+	c.Check(limiter.Admit("foo/bar"), gc.Equals, time.Second)
+
+	// A pulse is brokered regardless, such that the journal may be handed off.
+	c.Check(router.HasServedAppend("foo/bar"), gc.Equals, false)
+	router.PulseBrokeredJournals()
+	c.Check(router.HasServedAppend("foo/bar"), gc.Equals, true)
+
+	// The pulse wasn't debited from the quota.
+	now = now.Add(time.Second)
+	c.Check(limiter.Admit("foo/bar"), gc.Equals, time.Duration(0))
+}
+
+func (s *RouterSuite) TestAppendRate(c *gc.C) {
+	var recorder routerRecorder
+	var router = NewRouter(recorder.NewReplica)
+	var spec = journal.JournalSpec{Replication: 1}
+
+	router.transition("foo/bar", "http://local|http://remote", 0, spec)
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://remote ([remote])")
+
+	c.Check(router.AppendRate("foo/bar"), gc.Equals, 0.0)
+	c.Check(router.AppendRate("foo/unknown"), gc.Equals, 0.0)
+
+	var resultCh = make(chan journal.AppendResult, 1)
+	router.Append(journal.AppendOp{
+		AppendArgs: journal.AppendArgs{
+			Journal: "foo/bar",
+			Content: strings.NewReader("0123456789"),
+			Context: context.Background(),
+		},
+		Result: resultCh,
+	})
+	c.Check((<-resultCh).Error, gc.IsNil)
+
+	// Appended content is reflected in the journal's rate.
+	var rate = router.AppendRate("foo/bar")
+	c.Check(rate > 0 && rate <= 10, gc.Equals, true, gc.Commentf("rate %v", rate))
+}
+
 func (s *RouterSuite) TestReplicateConditions(c *gc.C) {
 	var recorder routerRecorder
 	var router = NewRouter(recorder.NewReplica)
+	var spec = journal.JournalSpec{Replication: 1}
 
 	var resultCh = make(chan journal.ReplicateResult, 1)
 	var op = journal.ReplicateOp{
@@ -155,7 +268,7 @@ func (s *RouterSuite) TestReplicateConditions(c *gc.C) {
 	})
 
 	// Journal is now known, but non-local.
-	router.transition("foo/bar", "http://server-one|http://server-two", -1, 1)
+	router.transition("foo/bar", "http://server-one|http://server-two", -1, spec)
 	recorder.verify(c) // Expect no replica was created.
 
 	router.Replicate(op)
@@ -164,8 +277,8 @@ func (s *RouterSuite) TestReplicateConditions(c *gc.C) {
 	})
 
 	// Journal is local.
-	router.transition("foo/bar", "http://server|http://local", 1, 1)
-	recorder.verify(c, "created replica foo/bar",
+	router.transition("foo/bar", "http://server|http://local", 1, spec)
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec",
 		"foo/bar => replica http://server|http://local")
 
 	// Expect RouteToken is verified.
@@ -182,6 +295,119 @@ func (s *RouterSuite) TestReplicateConditions(c *gc.C) {
 	})
 }
 
+func (s *RouterSuite) TestSpecUpdates(c *gc.C) {
+	var recorder routerRecorder
+	var router = NewRouter(recorder.NewReplica)
+	var spec = journal.JournalSpec{Replication: 1}
+
+	// Journal is a local broker. The topology includes an extra member beyond
+	// the spec'd replication, which is not used as a peer.
+	router.transition("foo/bar", "http://local|http://remote|http://extra", 0, spec)
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://remote|http://extra ([remote])")
+	c.Check(router.BrokeredJournals(), gc.DeepEquals, []journal.Name{"foo/bar"})
+
+	// Update the spec fragment size. Expect the broker is restarted.
+	spec.FragmentSize = 1 << 20
+	router.transition("foo/bar", "http://local|http://remote|http://extra", 0, spec)
+	recorder.verify(c, "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://remote|http://extra ([remote])")
+
+	// Repeated transitions with an unchanged spec and route are no-ops.
+	router.transition("foo/bar", "http://local|http://remote|http://extra", 0, spec)
+	c.Check(recorder, gc.HasLen, 0)
+
+	// Increase replication. The extra member now becomes a peer.
+	spec.Replication = 2
+	router.transition("foo/bar", "http://local|http://remote|http://extra", 0, spec)
+	recorder.verify(c, "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://remote|http://extra ([remote,extra])")
+
+	// Increase replication again. The broker is no longer able to broker.
+	spec.Replication = 3
+	router.transition("foo/bar", "http://local|http://remote|http://extra", 0, spec)
+	recorder.verify(c, "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://remote|http://extra ([remote,extra])")
+
+	var resultCh = make(chan journal.AppendResult, 1)
+	router.Append(journal.AppendOp{
+		AppendArgs: journal.AppendArgs{
+			Journal: "foo/bar",
+			Context: context.Background(),
+		},
+		Result: resultCh,
+	})
+	c.Check(<-resultCh, gc.DeepEquals, journal.AppendResult{
+		Error:      journal.ErrReplicationFailed,
+		RouteToken: "http://local|http://remote|http://extra",
+	})
+}
+
+func (s *RouterSuite) TestRemove(c *gc.C) {
+	var recorder routerRecorder
+	var router = NewRouter(recorder.NewReplica)
+
+	router.transition("foo/bar", "http://local|http://remote", 0, journal.JournalSpec{Replication: 1})
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://remote ([remote])")
+	c.Check(router.HasRoute("foo/bar"), gc.Equals, true)
+
+	// Expect the replica is shut down, and the route removed.
+	router.remove("foo/bar")
+	recorder.verify(c, "foo/bar => shutdown")
+	c.Check(router.HasRoute("foo/bar"), gc.Equals, false)
+	c.Check(router.BrokeredJournals(), gc.HasLen, 0)
+
+	// Removal of an unknown route is a no-op.
+	router.remove("foo/bar")
+	c.Check(recorder, gc.HasLen, 0)
+
+	// Appends of the removed journal fail.
+	var resultCh = make(chan journal.AppendResult, 1)
+	router.Append(journal.AppendOp{
+		AppendArgs: journal.AppendArgs{
+			Journal: "foo/bar",
+			Context: context.Background(),
+		},
+		Result: resultCh,
+	})
+	c.Check(<-resultCh, gc.DeepEquals, journal.AppendResult{Error: journal.ErrNotFound})
+}
+
+func (s *RouterSuite) TestReplicateOverStreams(c *gc.C) {
+	var recorder routerRecorder
+	var router = NewRouter(recorder.NewReplica)
+	router.ReplicateOverStreams(8082)
+	var spec = journal.JournalSpec{Replication: 2}
+
+	router.transition("foo/bar", "http://local|http://one:8081|http://two:8081", 0, spec)
+	recorder.verify(c, "created replica foo/bar", "foo/bar => updated spec",
+		"foo/bar => broker http://local|http://one:8081|http://two:8081 ([one:8082,two:8082])")
+
+	var route, _ = router.readRoute("foo/bar")
+	var one, two = route.streams["http://one:8081"], route.streams["http://two:8081"]
+
+	// Peer "two" is replaced by "three". The stream of "one" is retained,
+	// and that of "two" is closed.
+	router.transition("foo/bar", "http://local|http://one:8081|http://three:8081", 0, spec)
+	recorder.verify(c,
+		"foo/bar => broker http://local|http://one:8081|http://three:8081 ([one:8082,three:8082])")
+
+	route, _ = router.readRoute("foo/bar")
+	c.Check(route.streams["http://one:8081"], gc.Equals, one)
+	c.Check(route.streams, gc.HasLen, 2)
+	c.Check(one.closed, gc.Equals, false)
+	c.Check(two.closed, gc.Equals, true)
+
+	// We're no longer the broker. All streams are closed.
+	router.transition("foo/bar", "http://one:8081|http://local|http://three:8081", 1, spec)
+	recorder.verify(c, "foo/bar => replica http://one:8081|http://local|http://three:8081")
+
+	route, _ = router.readRoute("foo/bar")
+	c.Check(route.streams, gc.HasLen, 0)
+	c.Check(one.closed, gc.Equals, true)
+}
+
 func (s *RouterSuite) TestBrokerRedirect(c *gc.C) {
 	req, _ := http.NewRequest("GET", "/foo/bar?baz", nil)
 
@@ -235,13 +461,24 @@ func (r replicaRecorder) StartReplicating(token journal.RouteToken) {
 		fmt.Sprintf("%s => replica %s", r.Name, token))
 }
 
-func (r replicaRecorder) Shutdown() {
+func (r replicaRecorder) UpdateSpec(spec journal.JournalSpec) {
+	*r.recorder = append(*r.recorder, fmt.Sprintf("%s => updated spec", r.Name))
+}
+
+func (r replicaRecorder) Shutdown() <-chan struct{} {
 	*r.recorder = append(*r.recorder, fmt.Sprintf("%s => shutdown", r.Name))
+
+	var done = make(chan struct{})
+	close(done)
+	return done
 }
 
 // Trivial implementations of each operation handler,
 // which pass back a distinguishing WriteHead.
 func (r replicaRecorder) Append(op journal.AppendOp) {
+	if op.Content != nil {
+		io.Copy(ioutil.Discard, op.Content)
+	}
 	op.Result <- journal.AppendResult{WriteHead: 1234}
 }
 func (r replicaRecorder) Read(op journal.ReadOp) {
@@ -254,6 +491,10 @@ func (r replicaRecorder) Replicate(op journal.ReplicateOp) {
 func flatPaths(peers []journal.Replicator) string {
 	var tmp []string
 	for _, peer := range peers {
+		if stream, ok := peer.(*ReplicateStream); ok {
+			tmp = append(tmp, stream.endpoint)
+			continue
+		}
 		url, _ := peer.(ReplicateClient).endpoint.URL()
 		tmp = append(tmp, url.Host)
 	}
@@ -2,10 +2,20 @@ package gazette
 
 import (
 	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
 	"net/url"
+	"os"
+	"os/signal"
+	"path"
+	"sync"
+	"syscall"
 	"time"
 
 	etcd "github.com/coreos/etcd/client"
+	"github.com/gorilla/mux"
 	log "github.com/sirupsen/logrus"
 
 	"github.com/LiveRamp/gazette/pkg/consensus"
@@ -13,37 +23,209 @@ import (
 	"github.com/LiveRamp/gazette/pkg/metrics"
 )
 
-const ServiceRoot = "/gazette/cluster"
+const (
+	ServiceRoot = "/gazette/cluster"
+
+	// JournalSpecs are stored under SpecsPrefix, keyed on the journal's
+	// allocator item name. Note they're not stored under the item itself,
+	// as children of the item are interpreted as route entries.
+	SpecsPrefix = "specs"
+	// Journals being deleted are marked by a tombstone under DeletedPrefix,
+	// keyed on the journal's allocator item name. The tombstone value is the
+	// JSON-encoded journal.DeleteArgs of the deletion.
+	DeletedPrefix = "deleted"
+	// ProducerStates of journals are stored under ProducersPrefix, keyed on
+	// the journal's allocator item name. See ProducerStore.
+	ProducersPrefix = "producers"
+	// ACLRules consulted by ACLAuthorizer are stored under ACLsPrefix, each
+	// as a JSON-encoded value under an arbitrary key.
+	ACLsPrefix = "acls"
+	// AppendQuotas enforced by AppendLimiter are stored under QuotasPrefix,
+	// each as a JSON-encoded value under an arbitrary key.
+	QuotasPrefix = "quotas"
+	// RetentionPolicies are stored under RetentionPrefix, each as a
+	// JSON-encoded value keyed on the escaped policy prefix (see
+	// RetentionPolicyPath).
+	RetentionPrefix = "retention"
+
+	// ItemStates of journal items. An item is "deleting" by a Runner once it
+	// has observed the item's tombstone and begun to tear down its local
+	// replica, and "deleted" once the replica is torn down and its spools are
+	// persisted (or dropped, as the tombstone directs).
+	itemStateReady    = "ready"
+	itemStateDeleting = "deleting"
+	itemStateDeleted  = "deleted"
+
+	// DrainPath is the path of the broker HTTP API at which a Runner serves
+	// HandleDrain. It's reserved, and can't be used as a journal name.
+	DrainPath = "/_gazette/drain"
+)
 
 type Runner struct {
-	client        etcd.Client
+	keysAPI       etcd.KeysAPI
 	localRouteKey string
 	replicaCount  int
 	router        *Router
+	persister     *Persister
+	zone          string
+	authorizer    Authorizer
+
+	// Closed upon Drain.
+	drainCh chan struct{}
+	// Closed to Cancel allocation of the draining Runner.
+	cancelCh  chan struct{}
+	drainOnce sync.Once
 }
 
-func NewRunner(client etcd.Client, localRouteKey string, replicaCount int, router *Router) *Runner {
+// NewRunner returns a Runner which allocates journals to |router| through
+// |keysAPI|, which is typically an Etcd v2 KeysAPI (see also package
+// consensus/etcdv3).
+func NewRunner(keysAPI etcd.KeysAPI, localRouteKey string, replicaCount int, router *Router) *Runner {
 	var runner = Runner{
-		client:        client,
+		keysAPI:       keysAPI,
 		localRouteKey: localRouteKey,
 		replicaCount:  replicaCount,
 		router:        router,
+		drainCh:       make(chan struct{}),
+		cancelCh:      make(chan struct{}),
 	}
 
 	return &runner
 }
 
+// UseZone places the Runner within failure domain |zone| (eg, an availability
+// zone or rack). Replicas of journals are spread across the zones of Runners.
+func (r *Runner) UseZone(zone string) *Runner {
+	r.zone = zone
+	return r
+}
+
+// UsePersister configures the Runner to report a deleted journal as "deleted"
+// only once |persister| has handled the spools of its torn-down replica.
+func (r *Runner) UsePersister(persister *Persister) *Runner {
+	r.persister = persister
+	return r
+}
+
+// UseAuthorizer authorizes HTTP requests to begin a Drain with |authorizer|,
+// which must grant OpAdmin on all journals (the empty journal name).
+func (r *Runner) UseAuthorizer(authorizer Authorizer) *Runner {
+	r.authorizer = authorizer
+	return r
+}
+
+// Register HandleDrain at DrainPath of |router|. It must be registered before
+// journal APIs, which match all paths.
+func (r *Runner) Register(router *mux.Router) {
+	router.Path(DrainPath).Methods("GET", "POST").HandlerFunc(r.HandleDrain)
+}
+
+// Run the Runner until it has drained. A SIGTERM or SIGINT begins a Drain.
+// Run returns only after local replicas have shut down, and have handed their
+// spools to the Persister.
 func (r *Runner) Run() error {
-	return consensus.CreateAndAllocateWithSignalHandling(r)
+	var signalCh = make(chan os.Signal, 1)
+	signal.Notify(signalCh, syscall.SIGTERM, syscall.SIGINT)
+	defer signal.Stop(signalCh)
+
+	go func() {
+		select {
+		case sig := <-signalCh:
+			log.WithField("signal", sig).Info("caught signal")
+			r.Drain()
+		case <-r.drainCh:
+		}
+	}()
+
+	var err = consensus.CreateAndAllocate(r, r.cancelCh)
+	r.router.Shutdown()
+	return err
+}
+
+// Drain begins a graceful drain of the Runner, and returns immediately. A
+// draining Runner releases its member lock, and thereafter acquires no items.
+// Each journal it brokers is handed off to a ready replica, after which held
+// replicas are released. Run returns once all items have been released.
+func (r *Runner) Drain() {
+	r.drainOnce.Do(func() {
+		log.Info("draining broker")
+		close(r.drainCh)
+
+		go func() {
+			// Brokered journals may be handed off only once they've served an
+			// Append under their current route. Pulse each now, rather than
+			// waiting for the next regular pulse.
+			r.router.PulseBrokeredJournals()
+			close(r.cancelCh)
+		}()
+	})
+}
+
+// IsDraining returns whether Drain has been called.
+func (r *Runner) IsDraining() bool {
+	select {
+	case <-r.drainCh:
+		return true
+	default:
+		return false
+	}
+}
+
+// HandleDrain is an administrative http.HandlerFunc. A POST request begins a
+// Drain of the Runner, if authorized for OpAdmin, and a GET request reports
+// whether the Runner is draining.
+func (r *Runner) HandleDrain(w http.ResponseWriter, req *http.Request) {
+	switch req.Method {
+	case "POST":
+		if err := authorize(r.authorizer, req, OpAdmin, ""); err != nil {
+			http.Error(w, err.Error(), journal.StatusCodeForError(err))
+			return
+		}
+		log.WithField("remote", req.RemoteAddr).Info("drain requested")
+		r.Drain()
+		w.WriteHeader(http.StatusAccepted)
+		fmt.Fprintln(w, "draining")
+	case "GET":
+		if r.IsDraining() {
+			fmt.Fprintln(w, "draining")
+		} else {
+			fmt.Fprintln(w, "serving")
+		}
+	default:
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+	}
 }
 
 // consumer.Allocator implementation.
-func (r *Runner) FixedItems() []string         { return nil }
-func (r *Runner) InstanceKey() string          { return r.localRouteKey }
-func (r *Runner) KeysAPI() etcd.KeysAPI        { return etcd.NewKeysAPI(r.client) }
-func (r *Runner) PathRoot() string             { return ServiceRoot }
-func (r *Runner) Replicas() int                { return r.replicaCount }
-func (r *Runner) ItemState(item string) string { return "ready" }
+func (r *Runner) FixedItems() []string  { return nil }
+func (r *Runner) InstanceKey() string   { return r.localRouteKey }
+func (r *Runner) KeysAPI() etcd.KeysAPI { return r.keysAPI }
+func (r *Runner) PathRoot() string      { return ServiceRoot }
+func (r *Runner) Replicas() int         { return r.replicaCount }
+func (r *Runner) Zone() string          { return r.zone }
+
+func (r *Runner) ItemState(item string) string {
+	name, err := itemToJournal(item)
+	if err != nil {
+		log.WithFields(log.Fields{"err": err, "item": item}).
+			Error("failed to decode journal")
+		return itemStateReady
+	}
+
+	// ItemState is only polled for items we hold, and ItemRoute will have
+	// already been called for |item| in this allocator pass. A route is
+	// therefore missing only if it was removed due to the item's tombstone.
+	if r.router.HasRoute(name) {
+		return itemStateReady
+	}
+	// The journal is deleted only once its replica has shut down, and the
+	// spools it handed off are no longer queued for persisting.
+	if r.router.IsReplicaShuttingDown(name) ||
+		(r.persister != nil && r.persister.IsPersisting(name)) {
+		return itemStateDeleting
+	}
+	return itemStateDeleted
+}
 
 func (r *Runner) ItemIsReadyForPromotion(item, state string) bool {
 	name, err := itemToJournal(item)
@@ -59,6 +241,16 @@ func (r *Runner) ItemIsReadyForPromotion(item, state string) bool {
 	return r.router.HasServedAppend(name)
 }
 
+// ItemWeight is the recent rate of bytes appended to the journal of |item|,
+// which balances brokered journals by their throughput.
+func (r *Runner) ItemWeight(item string) float64 {
+	name, err := itemToJournal(item)
+	if err != nil {
+		return 0
+	}
+	return r.router.AppendRate(name)
+}
+
 func (r *Runner) ItemRoute(item string, route consensus.Route, index int, tree *etcd.Node) {
 	defer func(start time.Time) {
 		var s = time.Since(start).Seconds()
@@ -78,7 +270,111 @@ func (r *Runner) ItemRoute(item string, route consensus.Route, index int, tree *
 		return
 	}
 
-	r.router.transition(name, token, index, r.replicaCount)
+	if consensus.Child(tree, DeletedPrefix, item) != nil {
+		// The journal is being deleted. Tear down (and don't re-create) its route.
+		r.router.remove(name)
+		return
+	}
+	r.router.transition(name, token, index, r.journalSpec(item, tree))
+}
+
+// Returns the JournalSpec of |item| from |tree|, with RetentionPolicies and
+// cluster defaults applied.
+func (r *Runner) journalSpec(item string, tree *etcd.Node) journal.JournalSpec {
+	var spec = decodeJournalSpec(item, tree)
+
+	if name, err := itemToJournal(item); err == nil {
+		spec = applyRetentionPolicies(spec, name, consensus.Child(tree, RetentionPrefix))
+	}
+
+	// The allocator provides at most |r.replicaCount| replicas of an item,
+	// which also bounds the replication of the journal.
+	if spec.Replication == 0 || spec.Replication > r.replicaCount {
+		spec.Replication = r.replicaCount
+	}
+	return spec
+}
+
+// Returns the JournalSpec of |item| stored in |tree|, rooted at ServiceRoot.
+// A missing or malformed spec decodes as the zero-valued JournalSpec.
+func decodeJournalSpec(item string, tree *etcd.Node) journal.JournalSpec {
+	var spec journal.JournalSpec
+
+	if node := consensus.Child(tree, SpecsPrefix, item); node != nil {
+		if err := json.Unmarshal([]byte(node.Value), &spec); err != nil {
+			log.WithFields(log.Fields{"err": err, "item": item}).
+				Warn("failed to decode journal spec")
+		}
+	}
+	return spec
+}
+
+// RetentionPolicy is a default retention of journals having a name prefix,
+// including journals created after the policy. A journal's own JournalSpec
+// Retention or RetentionBytes takes precedence over that of a policy. Where
+// multiple policies match a journal, the one having the longest prefix
+// applies.
+type RetentionPolicy struct {
+	// Prefix of journal names to which the policy applies. As with ACLRules,
+	// the prefix matches only on path segment boundaries.
+	Prefix journal.Name `json:"prefix"`
+	// Retention of matched journals, or zero if not set by the policy.
+	Retention time.Duration `json:"retention,omitempty"`
+	// RetentionBytes of matched journals, or zero if not set by the policy.
+	RetentionBytes int64 `json:"retention_bytes,omitempty"`
+}
+
+// Validate returns an error if the RetentionPolicy is malformed.
+func (p RetentionPolicy) Validate() error {
+	if p.Prefix == "" {
+		return errors.New("retention policy prefix is empty")
+	}
+	return journal.JournalSpec{
+		Retention:      p.Retention,
+		RetentionBytes: p.RetentionBytes,
+	}.Validate()
+}
+
+// RetentionPolicyPath returns the Etcd path of the RetentionPolicy of |prefix|.
+func RetentionPolicyPath(prefix journal.Name) string {
+	return path.Join(ServiceRoot, RetentionPrefix, url.QueryEscape(string(prefix)))
+}
+
+// Returns |spec| of journal |name|, with Retention and RetentionBytes not
+// set by |spec| taken from the matching RetentionPolicies of |policies|.
+func applyRetentionPolicies(spec journal.JournalSpec, name journal.Name,
+	policies *etcd.Node) journal.JournalSpec {
+
+	if policies == nil {
+		return spec
+	}
+	// Lengths of the prefixes of applied policies.
+	var retentionLen, bytesLen = -1, -1
+	var out = spec
+
+	for _, node := range policies.Nodes {
+		var policy RetentionPolicy
+
+		if err := json.Unmarshal([]byte(node.Value), &policy); err != nil {
+			log.WithFields(log.Fields{"err": err, "key": node.Key}).
+				Warn("failed to decode retention policy")
+			continue
+		} else if err = policy.Validate(); err != nil {
+			log.WithFields(log.Fields{"err": err, "key": node.Key}).
+				Warn("invalid retention policy")
+			continue
+		} else if !hasPathPrefix(string(name), string(policy.Prefix)) {
+			continue
+		}
+
+		if spec.Retention == 0 && policy.Retention != 0 && len(policy.Prefix) > retentionLen {
+			out.Retention, retentionLen = policy.Retention, len(policy.Prefix)
+		}
+		if spec.RetentionBytes == 0 && policy.RetentionBytes != 0 && len(policy.Prefix) > bytesLen {
+			out.RetentionBytes, bytesLen = policy.RetentionBytes, len(policy.Prefix)
+		}
+	}
+	return out
 }
 
 func itemToJournal(s string) (journal.Name, error) {
@@ -90,6 +386,21 @@ func journalToItem(j journal.Name) string {
 	return url.QueryEscape(string(j))
 }
 
+// Returns the Etcd path of the JournalSpec of journal |j|.
+func journalSpecPath(j journal.Name) string {
+	return path.Join(ServiceRoot, SpecsPrefix, journalToItem(j))
+}
+
+// Returns the Etcd path of the ProducerStates of journal |j|.
+func journalProducersPath(j journal.Name) string {
+	return path.Join(ServiceRoot, ProducersPrefix, journalToItem(j))
+}
+
+// Returns the Etcd path of the deletion tombstone of journal |j|.
+func journalTombstonePath(j journal.Name) string {
+	return path.Join(ServiceRoot, DeletedPrefix, journalToItem(j))
+}
+
 // Converts a unique consensus.Route into a correponding journal.RouteToken.
 // In particular, given a route of parent `/path/to/item` and ordered Entries
 // `/path/to/item/http%3A%2F%2Ffoo` & `/path/to/item/http%3A%2F%2Fbar`, returns
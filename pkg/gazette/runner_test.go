@@ -0,0 +1,321 @@
+package gazette
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+	"github.com/gorilla/mux"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+type RunnerSuite struct{}
+
+func (s *RunnerSuite) TestJournalSpecExtraction(c *gc.C) {
+	var runner = &Runner{replicaCount: 2}
+
+	var tree = &etcd.Node{
+		Key: ServiceRoot,
+		Dir: true,
+		Nodes: etcd.Nodes{
+			{
+				Key: ServiceRoot + "/specs",
+				Dir: true,
+				Nodes: etcd.Nodes{
+					{Key: ServiceRoot + "/specs/a%2Fjournal", Value: `{"replication":1,"fragment_size":1024}`},
+					{Key: ServiceRoot + "/specs/b%2Fjournal", Value: `{"replication":5}`},
+					{Key: ServiceRoot + "/specs/c%2Fjournal", Value: `malformed`},
+					{Key: ServiceRoot + "/specs/e%2Fone%2Fjournal", Value: `{"retention":60000000000}`},
+				},
+			},
+		},
+	}
+
+	// Expect a spec'd journal takes its spec.
+	c.Check(runner.journalSpec("a%2Fjournal", tree), gc.DeepEquals,
+		journal.JournalSpec{Replication: 1, FragmentSize: 1024})
+	// Replication is bounded by the cluster replica count.
+	c.Check(runner.journalSpec("b%2Fjournal", tree), gc.DeepEquals,
+		journal.JournalSpec{Replication: 2})
+	// Malformed and missing specs take cluster defaults.
+	c.Check(runner.journalSpec("c%2Fjournal", tree), gc.DeepEquals,
+		journal.JournalSpec{Replication: 2})
+	c.Check(runner.journalSpec("d%2Fjournal", tree), gc.DeepEquals,
+		journal.JournalSpec{Replication: 2})
+
+	// Add retention policies of prefixes "e/" and "e/one/". Nodes are sorted.
+	tree.Nodes = append(etcd.Nodes{{
+		Key: ServiceRoot + "/retention",
+		Dir: true,
+		Nodes: etcd.Nodes{
+			{Key: ServiceRoot + "/retention/e%2F", Value: `{"prefix":"e/","retention":3600000000000,"retention_bytes":1024}`},
+			{Key: ServiceRoot + "/retention/e%2Fone%2F", Value: `{"prefix":"e/one/","retention":7200000000000}`},
+			{Key: ServiceRoot + "/retention/malformed", Value: `{"retention":-1}`},
+		},
+	}}, tree.Nodes...)
+
+	// Expect journals created later take the retention of their longest
+	// matching policy, unless their own spec sets it.
+	c.Check(runner.journalSpec("e%2Fjournal", tree), gc.DeepEquals,
+		journal.JournalSpec{Replication: 2, Retention: time.Hour, RetentionBytes: 1024})
+	c.Check(runner.journalSpec("e%2Fone%2Fother", tree), gc.DeepEquals,
+		journal.JournalSpec{Replication: 2, Retention: 2 * time.Hour, RetentionBytes: 1024})
+	c.Check(runner.journalSpec("e%2Fone%2Fjournal", tree), gc.DeepEquals,
+		journal.JournalSpec{Replication: 2, Retention: time.Minute, RetentionBytes: 1024})
+	// Policies apply only on path segment boundaries.
+	c.Check(runner.journalSpec("eee%2Fjournal", tree), gc.DeepEquals,
+		journal.JournalSpec{Replication: 2})
+}
+
+func (s *RunnerSuite) TestDeletedJournalRouteIsRemoved(c *gc.C) {
+	var recorder routerRecorder
+	var runner = &Runner{replicaCount: 1, router: NewRouter(recorder.NewReplica)}
+
+	var item = &etcd.Node{
+		Key: ServiceRoot + "/items/a%2Fjournal",
+		Dir: true,
+		Nodes: etcd.Nodes{
+			{Key: ServiceRoot + "/items/a%2Fjournal/http%3A%2F%2Flocal", Value: "ready"},
+		},
+	}
+	var tree = &etcd.Node{
+		Key: ServiceRoot,
+		Dir: true,
+		Nodes: etcd.Nodes{
+			{Key: ServiceRoot + "/items", Dir: true, Nodes: etcd.Nodes{item}},
+		},
+	}
+	var route = consensus.Route{Item: item, Entries: item.Nodes}
+
+	runner.ItemRoute("a%2Fjournal", route, 0, tree)
+	recorder.verify(c, "created replica a/journal", "a/journal => updated spec",
+		"a/journal => broker http://local ([])")
+	c.Check(runner.ItemState("a%2Fjournal"), gc.Equals, "ready")
+
+	// Add a tombstone for the journal. Expect its replica is shut down and
+	// not re-created, and that it reports a "deleted" ItemState.
+	tree.Nodes = append(etcd.Nodes{{
+		Key: ServiceRoot + "/deleted",
+		Dir: true,
+		Nodes: etcd.Nodes{
+			{Key: ServiceRoot + "/deleted/a%2Fjournal", Value: `{"fragments":"delete"}`},
+		},
+	}}, tree.Nodes...)
+
+	runner.ItemRoute("a%2Fjournal", route, 0, tree)
+	recorder.verify(c, "a/journal => shutdown")
+	c.Check(runner.ItemState("a%2Fjournal"), gc.Equals, "deleted")
+
+	runner.ItemRoute("a%2Fjournal", route, 0, tree)
+	c.Check(recorder, gc.HasLen, 0)
+	c.Check(runner.ItemState("a%2Fjournal"), gc.Equals, "deleted")
+}
+
+func (s *RunnerSuite) TestDeletedJournalAwaitsTeardown(c *gc.C) {
+	var recorder routerRecorder
+	var shutdownCh = make(chan struct{})
+
+	var router = NewRouter(func(name journal.Name) JournalReplica {
+		return blockingShutdownReplica{recorder.NewReplica(name).(replicaRecorder), shutdownCh}
+	})
+	var persister = NewPersister("base/directory", nil, nil, "route-key")
+	var runner = NewRunner(nil, "http%3A%2F%2Flocal", 1, router).UsePersister(persister)
+
+	router.transition("a/journal", "http://local", 0, journal.JournalSpec{Replication: 1})
+	c.Check(runner.ItemState("a%2Fjournal"), gc.Equals, "ready")
+
+	// The route is removed, but the replica has yet to complete its shutdown.
+	router.remove("a/journal")
+	c.Check(runner.ItemState("a%2Fjournal"), gc.Equals, "deleting")
+
+	// The replica completes shutdown, handing its spool to the Persister.
+	var fragment = journal.Fragment{Journal: "a/journal", Begin: 0, End: 10}
+	persister.Persist(fragment)
+	close(shutdownCh)
+
+	for router.IsReplicaShuttingDown("a/journal") {
+		time.Sleep(time.Millisecond)
+	}
+	c.Check(runner.ItemState("a%2Fjournal"), gc.Equals, "deleting")
+
+	// Once the spool is no longer queued, the journal is deleted.
+	persister.mu.Lock()
+	delete(persister.queue, fragment.ContentName())
+	persister.mu.Unlock()
+
+	c.Check(runner.ItemState("a%2Fjournal"), gc.Equals, "deleted")
+}
+
+func (s *RunnerSuite) TestDrain(c *gc.C) {
+	var recorder routerRecorder
+	var router = NewRouter(recorder.NewReplica)
+	var runner = NewRunner(nil, "http%3A%2F%2Flocal", 1, router).
+		UseAuthorizer(staticAuthorizer("secret"))
+
+	var m = mux.NewRouter()
+	runner.Register(m)
+
+	router.transition("a/journal", "http://local|http://remote", 0,
+		journal.JournalSpec{Replication: 1})
+	c.Check(router.HasServedAppend("a/journal"), gc.Equals, false)
+
+	var request = func(method, token string) *httptest.ResponseRecorder {
+		var req = httptest.NewRequest(method, DrainPath, nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		var w = httptest.NewRecorder()
+		m.ServeHTTP(w, req)
+		return w
+	}
+
+	var w = request("GET", "")
+	c.Check(w.Code, gc.Equals, http.StatusOK)
+	c.Check(w.Body.String(), gc.Equals, "serving\n")
+	c.Check(runner.IsDraining(), gc.Equals, false)
+
+	// Unauthorized callers may not begin a drain.
+	for _, token := range []string{"", "other"} {
+		w = request("POST", token)
+		c.Check(w.Code, gc.Equals, http.StatusForbidden)
+		c.Check(runner.IsDraining(), gc.Equals, false)
+	}
+
+	w = request("POST", "secret")
+	c.Check(w.Code, gc.Equals, http.StatusAccepted)
+	c.Check(w.Body.String(), gc.Equals, "draining\n")
+	c.Check(runner.IsDraining(), gc.Equals, true)
+
+	// Expect the brokered journal is pulsed, readying it for hand-off, before
+	// allocation is cancelled.
+	select {
+	case <-runner.cancelCh:
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout awaiting cancel")
+	}
+	c.Check(router.HasServedAppend("a/journal"), gc.Equals, true)
+
+	// Drain is idempotent.
+	runner.Drain()
+	w = request("GET", "")
+	c.Check(w.Body.String(), gc.Equals, "draining\n")
+}
+
+func (s *RunnerSuite) TestRunAllocatesAndDrains(c *gc.C) {
+	var ctx = context.Background()
+	var keysAPI = consensus.NewMemoryKeysAPI()
+	var itemPath = ServiceRoot + "/items/a%2Fjournal"
+
+	var _, err = keysAPI.Set(ctx, itemPath, "", &etcd.SetOptions{Dir: true})
+	c.Assert(err, gc.IsNil)
+
+	var recorder routerRecorder
+	var router = NewRouter(recorder.NewReplica)
+	var runner = NewRunner(keysAPI, "http%3A%2F%2Flocal", 0, router)
+
+	var doneCh = make(chan error)
+	go func() { doneCh <- runner.Run() }()
+
+	// Expect the Runner acquires and brokers the journal.
+	for deadline := time.Now().Add(5 * time.Second); !router.HasRoute("a/journal"); {
+		c.Assert(time.Now().Before(deadline), gc.Equals, true)
+		time.Sleep(time.Millisecond)
+	}
+	c.Check(router.BrokeredJournals(), gc.DeepEquals, []journal.Name{"a/journal"})
+
+	// Drain the Runner. It releases the journal and exits.
+	runner.Drain()
+
+	select {
+	case err = <-doneCh:
+		c.Check(err, gc.IsNil)
+	case <-time.After(5 * time.Second):
+		c.Fatal("timeout awaiting Run")
+	}
+	c.Check(router.HasRoute("a/journal"), gc.Equals, false)
+
+	resp, err := keysAPI.Get(ctx, itemPath, nil)
+	c.Assert(err, gc.IsNil)
+	c.Check(resp.Node.Nodes, gc.HasLen, 0)
+
+	_, err = keysAPI.Get(ctx, ServiceRoot+"/members/http%3A%2F%2Flocal", nil)
+	c.Check(isKeyNotFound(err), gc.Equals, true)
+}
+
+func (s *RunnerSuite) TestDrainAwaitsSpooledContent(c *gc.C) {
+	var localDir, err = ioutil.TempDir("", "runner-suite")
+	c.Assert(err, gc.IsNil)
+	defer os.RemoveAll(localDir)
+
+	var cfs = cloudstore.NewTmpFileSystem()
+	defer cfs.Close()
+	c.Assert(cfs.MkdirAll("a/journal", 0750), gc.IsNil)
+
+	var persisted = make(chan journal.Fragment, 1)
+	var persister = fragmentPersisterFunc(func(f journal.Fragment) { persisted <- f })
+
+	var router = NewRouter(func(name journal.Name) JournalReplica {
+		return journal.NewReplica(name, localDir, persister, nil, cfs)
+	})
+	router.transition("a/journal", "http://remote|http://local", 1,
+		journal.JournalSpec{Replication: 1})
+
+	// Replicate and commit content into the spool of the local replica.
+	var op = journal.ReplicateOp{
+		ReplicateArgs: journal.ReplicateArgs{
+			Journal:    "a/journal",
+			RouteToken: "http://remote|http://local",
+			NewSpool:   true,
+			Context:    context.Background(),
+		},
+		Result: make(chan journal.ReplicateResult, 1),
+	}
+	router.Replicate(op)
+
+	var result = <-op.Result
+	c.Assert(result.Error, gc.IsNil)
+	result.Writer.Write([]byte("spooled content"))
+	c.Check(result.Writer.Commit(15), gc.IsNil)
+
+	// As the drained Runner exits, it shuts down the Router. Expect the
+	// spool was handed off by the time Shutdown returns.
+	router.Shutdown()
+
+	select {
+	case fragment := <-persisted:
+		c.Check(fragment.Journal, gc.Equals, journal.Name("a/journal"))
+		c.Check(fragment.Begin, gc.Equals, int64(0))
+		c.Check(fragment.End, gc.Equals, int64(15))
+	default:
+		c.Error("expected spool to be persisted")
+	}
+	c.Check(router.HasRoute("a/journal"), gc.Equals, false)
+	c.Check(router.IsReplicaShuttingDown("a/journal"), gc.Equals, false)
+}
+
+var _ = gc.Suite(&RunnerSuite{})
+
+// fragmentPersisterFunc adapts a function to journal.FragmentPersister.
+type fragmentPersisterFunc func(journal.Fragment)
+
+func (f fragmentPersisterFunc) Persist(fragment journal.Fragment) { f(fragment) }
+
+// blockingShutdownReplica is a replicaRecorder which completes Shutdown only
+// once |shutdownCh| is closed.
+type blockingShutdownReplica struct {
+	replicaRecorder
+	shutdownCh chan struct{}
+}
+
+func (r blockingShutdownReplica) Shutdown() <-chan struct{} {
+	r.replicaRecorder.Shutdown()
+	return r.shutdownCh
+}
@@ -0,0 +1,127 @@
+package gazette
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path"
+
+	etcd "github.com/coreos/etcd/client"
+	"github.com/gorilla/mux"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+	"github.com/LiveRamp/gazette/pkg/journal"
+)
+
+// API for updating the journal.JournalSpec of an existing Journal. Fields
+// present in the JSON-encoded request body are merged into the Journal's
+// current spec, which is then validated and stored to Etcd. Runners apply
+// the updated spec as it's observed. Labels are merged individually, and a
+// label updated to the empty value is removed.
+type SpecAPI struct {
+	keysAPI          etcd.KeysAPI
+	requiredReplicas int
+	authorizer       Authorizer
+}
+
+func NewSpecAPI(keysAPI etcd.KeysAPI, requiredReplicas int) *SpecAPI {
+	return &SpecAPI{
+		keysAPI:          keysAPI,
+		requiredReplicas: requiredReplicas,
+	}
+}
+
+// UseAuthorizer authorizes JournalSpec updates with |authorizer|.
+func (h *SpecAPI) UseAuthorizer(authorizer Authorizer) *SpecAPI {
+	h.authorizer = authorizer
+	return h
+}
+
+func (h *SpecAPI) Register(router *mux.Router) {
+	router.NewRoute().Methods("PATCH").HandlerFunc(h.Update)
+}
+
+func (h *SpecAPI) Update(w http.ResponseWriter, r *http.Request) {
+	var name = journal.Name(path.Clean(r.URL.Path[1:]))
+
+	if err := authorize(h.authorizer, r, OpAdmin, name); err != nil {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+
+	// Verify the Journal exists.
+	var itemPath = path.Join(ServiceRoot, consensus.ItemsPrefix, journalToItem(name))
+	if _, err := h.keysAPI.Get(context.Background(), itemPath, nil); err != nil {
+		if etcdErr, _ := err.(etcd.Error); etcdErr.Code == etcd.ErrorCodeKeyNotFound {
+			err = journal.ErrNotFound
+		}
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+
+	// Fetch the current spec, if there is one. The updated spec is written
+	// only if the current one is unchanged in the meantime.
+	var spec journal.JournalSpec
+	var setOptions = &etcd.SetOptions{PrevExist: etcd.PrevNoExist}
+
+	if response, err := h.keysAPI.Get(context.Background(), journalSpecPath(name), nil); err == nil {
+		if err = json.Unmarshal([]byte(response.Node.Value), &spec); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		setOptions = &etcd.SetOptions{PrevIndex: response.Node.ModifiedIndex}
+	} else if etcdErr, _ := err.(etcd.Error); etcdErr.Code != etcd.ErrorCodeKeyNotFound {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+
+	if err := json.NewDecoder(r.Body).Decode(&spec); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for key, value := range spec.Labels {
+		if value == "" {
+			delete(spec.Labels, key)
+		}
+	}
+
+	if err := validateSpec(spec, h.requiredReplicas); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if value, err := json.Marshal(spec); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	} else if _, err = h.keysAPI.Set(context.Background(), journalSpecPath(name),
+		string(value), setOptions); err != nil {
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+
+	log.WithFields(log.Fields{"name": name, "spec": spec}).Info("updated journal spec")
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Validates |spec|, which must also not require more than |maxReplicas|.
+func validateSpec(spec journal.JournalSpec, maxReplicas int) error {
+	if err := spec.Validate(); err != nil {
+		return err
+	} else if spec.Replication > maxReplicas {
+		return fmt.Errorf("%s (%d exceeds cluster replica count %d)",
+			journal.ErrInvalidReplication, spec.Replication, maxReplicas)
+	}
+	return nil
+}
+
+// Stores |spec| as the JournalSpec of journal |name|.
+func putJournalSpec(keysAPI etcd.KeysAPI, name journal.Name, spec journal.JournalSpec) error {
+	if value, err := json.Marshal(spec); err != nil {
+		return err
+	} else {
+		_, err = keysAPI.Set(context.Background(), journalSpecPath(name), string(value), nil)
+		return err
+	}
+}
@@ -0,0 +1,144 @@
+package gazette
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+
+	etcd "github.com/coreos/etcd/client"
+	gc "github.com/go-check/check"
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/mock"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+)
+
+type SpecAPISuite struct {
+	keys *consensus.MockKeysAPI
+	mux  *mux.Router
+}
+
+func (s *SpecAPISuite) SetUpTest(c *gc.C) {
+	s.keys = new(consensus.MockKeysAPI)
+	s.mux = mux.NewRouter()
+	NewSpecAPI(s.keys, 2).Register(s.mux)
+}
+
+func (s *SpecAPISuite) TestUpdateMergesCurrentSpec(c *gc.C) {
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{}, nil)
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{
+		Node: &etcd.Node{
+			Value:         `{"replication":1,"fragment_size":1024}`,
+			ModifiedIndex: 1234,
+		},
+	}, nil)
+
+	// Expect the fragment size is updated, the replication is retained, and the
+	// spec is stored only if unchanged since it was read.
+	s.keys.On("Set", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		`{"replication":1,"fragment_size":2048}`, &etcd.SetOptions{PrevIndex: 1234}).
+		Return(&etcd.Response{Index: 1235}, nil)
+
+	var req, _ = http.NewRequest("PATCH", "/journal/name",
+		strings.NewReader(`{"fragment_size": 2048}`))
+	var w = httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusNoContent)
+	s.keys.AssertExpectations(c)
+}
+
+func (s *SpecAPISuite) TestUpdateMergesLabels(c *gc.C) {
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{}, nil)
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{
+		Node: &etcd.Node{
+			Value:         `{"labels":{"env":"prod","owner":"team-a","topic":"foo"}}`,
+			ModifiedIndex: 1234,
+		},
+	}, nil)
+
+	// Expect labels are individually updated, added, and removed.
+	s.keys.On("Set", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		`{"labels":{"env":"prod","owner":"team-b","tier":"gold"}}`,
+		&etcd.SetOptions{PrevIndex: 1234}).Return(&etcd.Response{Index: 1235}, nil)
+
+	var req, _ = http.NewRequest("PATCH", "/journal/name", strings.NewReader(
+		`{"labels": {"owner": "team-b", "tier": "gold", "topic": ""}}`))
+	var w = httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusNoContent)
+	s.keys.AssertExpectations(c)
+}
+
+func (s *SpecAPISuite) TestUpdateCreatesSpec(c *gc.C) {
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{}, nil)
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+
+	// Expect the spec is stored only if it still doesn't exist.
+	s.keys.On("Set", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		`{"retention":3600000000000}`, &etcd.SetOptions{PrevExist: etcd.PrevNoExist}).
+		Return(&etcd.Response{Index: 1235}, nil)
+
+	var req, _ = http.NewRequest("PATCH", "/journal/name",
+		strings.NewReader(`{"retention": 3600000000000}`))
+	var w = httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusNoContent)
+	s.keys.AssertExpectations(c)
+}
+
+func (s *SpecAPISuite) TestUpdateValidation(c *gc.C) {
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(&etcd.Response{}, nil)
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/specs/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+
+	var req, _ = http.NewRequest("PATCH", "/journal/name",
+		strings.NewReader(`{"fragment_size": -1}`))
+	var w = httptest.NewRecorder()
+
+	// Expect the spec is not stored.
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusBadRequest)
+	c.Check(w.Body.String(), gc.Equals, "invalid journal fragment size\n")
+	s.keys.AssertExpectations(c)
+}
+
+func (s *SpecAPISuite) TestUpdateOfMissingJournal(c *gc.C) {
+	s.keys.On("Get", mock.Anything, ServiceRoot+"/items/journal%2Fname",
+		(*etcd.GetOptions)(nil)).Return(nil, etcd.Error{Code: etcd.ErrorCodeKeyNotFound})
+
+	var req, _ = http.NewRequest("PATCH", "/journal/name",
+		strings.NewReader(`{"fragment_size": 2048}`))
+	var w = httptest.NewRecorder()
+
+	s.mux.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusNotFound)
+	s.keys.AssertExpectations(c)
+}
+
+func (s *SpecAPISuite) TestNotAuthorized(c *gc.C) {
+	var m = mux.NewRouter()
+	NewSpecAPI(s.keys, 2).UseAuthorizer(staticAuthorizer("secret")).Register(m)
+
+	var req, _ = http.NewRequest("PATCH", "/journal/name",
+		strings.NewReader(`{"fragment_size": 2048}`))
+	req.Header.Set("Authorization", "Bearer other")
+	var w = httptest.NewRecorder()
+
+	m.ServeHTTP(w, req)
+	c.Check(w.Code, gc.Equals, http.StatusForbidden)
+
+	// Expect no Etcd requests were made.
+	s.keys.AssertExpectations(c)
+}
+
+var _ = gc.Suite(&SpecAPISuite{})
@@ -0,0 +1,76 @@
+package gazette
+
+import (
+	"context"
+	"time"
+
+	etcd "github.com/coreos/etcd/client"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/consensus"
+)
+
+// Cool-off interval after a failed watch of a tree.
+const treeWatchErrInterval = 5 * time.Second
+
+// watchTree loads the Etcd directory |dir|, creating it if it doesn't exist,
+// and passes the loaded tree to |update|. It then watches |dir| and passes
+// each updated tree to |update|, until the returned CancelFunc is called.
+// Watch failures are logged and retried. An error is returned if |dir| could
+// not be initially loaded.
+func watchTree(keysAPI etcd.KeysAPI, dir string,
+	update func(tree *etcd.Node)) (context.CancelFunc, error) {
+
+	// Create the (otherwise empty) directory, so that it may be watched.
+	if _, err := keysAPI.Set(context.Background(), dir, "", &etcd.SetOptions{
+		Dir:       true,
+		PrevExist: etcd.PrevNoExist,
+	}); err != nil && !isNodeExist(err) {
+		return nil, err
+	}
+
+	// Full refreshes of the tree occur only on watch errors.
+	var watcher = consensus.RetryWatcher(keysAPI, dir,
+		&etcd.GetOptions{Recursive: true, Sort: true},
+		&etcd.WatcherOptions{Recursive: true}, nil)
+
+	var ctx, cancel = context.WithCancel(context.Background())
+
+	var response, err = watcher.Next(ctx)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	var tree = response.Node
+	update(tree)
+
+	go func() {
+		for {
+			var response, err = watcher.Next(ctx)
+
+			if ctx.Err() != nil {
+				return
+			} else if err != nil {
+				log.WithFields(log.Fields{"err": err, "dir": dir}).Warn("tree watch")
+
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(treeWatchErrInterval):
+				}
+				continue
+			}
+
+			if tree, err = consensus.PatchTree(tree, response); err != nil {
+				log.WithFields(log.Fields{"err": err, "resp": response}).Error("patch failed")
+			}
+			update(tree)
+		}
+	}()
+	return cancel, nil
+}
+
+func isNodeExist(err error) bool {
+	var etcdErr, _ = err.(etcd.Error)
+	return etcdErr.Code == etcd.ErrorCodeNodeExist
+}
@@ -3,6 +3,7 @@ package gazette
 import (
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gorilla/mux"
 
@@ -10,13 +11,20 @@ import (
 )
 
 type WriteAPI struct {
-	handler AppendOpHandler
+	handler    AppendOpHandler
+	authorizer Authorizer
 }
 
 func NewWriteAPI(handler AppendOpHandler) *WriteAPI {
 	return &WriteAPI{handler: handler}
 }
 
+// UseAuthorizer authorizes appends with |authorizer|.
+func (h *WriteAPI) UseAuthorizer(authorizer Authorizer) *WriteAPI {
+	h.authorizer = authorizer
+	return h
+}
+
 func (h *WriteAPI) Register(router *mux.Router) {
 	router.NewRoute().Methods("PUT").HandlerFunc(h.Write)
 }
@@ -25,23 +33,53 @@ func (h *WriteAPI) Write(w http.ResponseWriter, r *http.Request) {
 	r = maybeTrace(r, "WriteAPI.Write")
 	defer finishTrace(r)
 
+	var name = journal.Name(r.URL.Path[1:])
+	if err := authorize(h.authorizer, r, OpAppend, name); err != nil {
+		r.Body.Close()
+		http.Error(w, err.Error(), journal.StatusCodeForError(err))
+		return
+	}
+
 	var op = journal.AppendOp{
 		AppendArgs: journal.AppendArgs{
-			Journal: journal.Name(r.URL.Path[1:]),
+			Journal: name,
 			Content: r.Body,
 			Context: r.Context(),
 		},
 		Result: make(chan journal.AppendResult, 1),
 	}
+	if expect := r.Header.Get(ExpectWriteHeadHeader); expect != "" {
+		var writeHead, err = strconv.ParseInt(expect, 10, 64)
+		if err != nil {
+			r.Body.Close()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		op.ExpectWriteHead = &writeHead
+	}
+	if producer := r.Header.Get(ProducerHeader); producer != "" {
+		var seq, err = strconv.ParseInt(r.Header.Get(ProducerSequenceHeader), 10, 64)
+		if err != nil {
+			r.Body.Close()
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		op.Producer, op.Sequence = journal.ProducerID(producer), seq
+	}
 	h.handler.Append(op)
 	result := <-op.Result
 
-	if result.WriteHead != 0 {
+	if result.WriteHead != 0 || result.Error == journal.ErrWrongWriteHead {
 		w.Header().Set(WriteHeadHeader, strconv.FormatInt(result.WriteHead, 10))
 	}
 	if result.RouteToken != "" {
 		w.Header().Set(RouteTokenHeader, string(result.RouteToken))
 	}
+	if result.Error == journal.ErrRateLimited {
+		// Retry-After is in whole seconds. Round up.
+		var seconds = (result.RetryAfter + time.Second - 1) / time.Second
+		w.Header().Set(RetryAfterHeader, strconv.FormatInt(int64(seconds), 10))
+	}
 	r.Body.Close()
 
 	if result.Error == journal.ErrNotBroker {
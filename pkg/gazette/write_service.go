@@ -38,6 +38,10 @@ type pendingWrite struct {
 	offset  int64
 	started time.Time
 	result  *journal.AsyncAppend
+	// Producer and sequence number of the write, if the WriteService has a
+	// producer ID.
+	producer journal.ProducerID
+	sequence int64
 }
 
 var pendingWritePool = sync.Pool{
@@ -77,7 +81,9 @@ func writeAllOrNone(write *pendingWrite, r io.Reader) error {
 // of writes to Gazette journals. Writes to each journal are spooled to local
 // disk (and never memory), so back-pressure from slow or down brokers does not
 // affect busy writers (at least, until disk runs out). Writes are retried
-// indefinitely, until aknowledged by a broker.
+// indefinitely, until aknowledged by a broker. If the WriteService has a
+// producer ID (see SetProducerID), retries of a write which was committed
+// but not acknowledged are not appended again.
 type WriteService struct {
 	client  *Client
 	stopped chan struct{} // Coordinates exit of service loops.
@@ -88,6 +94,11 @@ type WriteService struct {
 	// Indexes pendingWrite's which are in |writeQueue|, and still append-able.
 	writeIndex   map[journal.Name]*pendingWrite
 	writeIndexMu sync.Mutex
+
+	// Producer ID of writes, and the last sequence number of each journal.
+	// Also guarded by |writeIndexMu|.
+	producer  journal.ProducerID
+	sequences map[journal.Name]int64
 }
 
 func NewWriteService(client *Client) *WriteService {
@@ -96,6 +107,7 @@ func NewWriteService(client *Client) *WriteService {
 		stopped:    make(chan struct{}),
 		writeQueue: nil,
 		writeIndex: make(map[journal.Name]*pendingWrite),
+		sequences:  make(map[journal.Name]int64),
 	}
 
 	writeService.SetConcurrency(*writeConcurrency)
@@ -111,6 +123,14 @@ func (c *WriteService) SetConcurrency(concurrency int) {
 	}
 }
 
+// Sets the journal.ProducerID of writes, which makes them idempotent. |id|
+// must be unique to this WriteService, and should be set before Start.
+func (c *WriteService) SetProducerID(id journal.ProducerID) {
+	c.writeIndexMu.Lock()
+	c.producer = id
+	c.writeIndexMu.Unlock()
+}
+
 // Begins the write service loop. Be sure to invoke Stop() prior to process
 // exit, to ensure that all pending writes have been flushed.
 func (c *WriteService) Start() {
@@ -151,6 +171,10 @@ func (c *WriteService) obtainWrite(name journal.Name) (*pendingWrite, bool, erro
 			Ready: make(chan struct{}),
 		}
 		write.started = time.Now()
+		if c.producer != "" {
+			c.sequences[name]++
+			write.producer, write.sequence = c.producer, c.sequences[name]
+		}
 		c.writeIndex[name] = write
 		return write, true, nil
 	}
@@ -222,8 +246,10 @@ func (c *WriteService) onWrite(write *pendingWrite) error {
 			return err // Not recoverable
 		}
 		result := c.client.Put(journal.AppendArgs{
-			Journal: write.journal,
-			Content: io.NewSectionReader(write.file, 0, write.offset),
+			Journal:  write.journal,
+			Content:  io.NewSectionReader(write.file, 0, write.offset),
+			Producer: write.producer,
+			Sequence: write.sequence,
 		})
 
 		switch result.Error {
@@ -246,6 +272,17 @@ func (c *WriteService) onWrite(write *pendingWrite) error {
 			}
 			continue
 
+		case journal.ErrRateLimited:
+			// The broker is throttling appends of the journal. Retry once its
+			// hinted interval has elapsed.
+			metrics.GazetteWriteFailureTotal.Inc()
+			if result.RetryAfter != 0 {
+				time.Sleep(result.RetryAfter)
+			} else {
+				time.Sleep(writeServiceCoolOffTimeout)
+			}
+			continue
+
 		default:
 			metrics.GazetteWriteFailureTotal.Inc()
 			time.Sleep(writeServiceCoolOffTimeout)
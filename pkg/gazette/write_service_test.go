@@ -204,4 +204,50 @@ func (s *WriteServiceSuite) TestWriteLifecycle(c *gc.C) {
 	mockClient.AssertExpectations(c)
 }
 
+func (s *WriteServiceSuite) TestRateLimitedWriteHonorsRetryAfter(c *gc.C) {
+	// Lengthen the write error cool-off interval, which must not be used.
+	actualTimeout := writeServiceCoolOffTimeout
+	writeServiceCoolOffTimeout = time.Hour
+	defer func() { writeServiceCoolOffTimeout = actualTimeout }()
+
+	var mockClient mockHttpClient
+
+	client, _ := NewClient("http://server")
+	client.httpClient = &mockClient
+	client.locationCache.Add("/a/journal", newURL("http://server/a/journal"))
+
+	writer := NewWriteService(client)
+	writer.SetConcurrency(1)
+
+	promise, err := writer.Write("a/journal", []byte("foo"))
+	c.Check(err, gc.IsNil)
+
+	// First PUT is rate-limited, with a retry-after hint.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "PUT" && request.URL.Path == "/a/journal"
+	})).Return(&http.Response{
+		StatusCode: http.StatusTooManyRequests,
+		Header:     http.Header{RetryAfterHeader: []string{"1"}},
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil).Once()
+
+	// It's retried after the hinted interval, and succeeds.
+	mockClient.On("Do", mock.MatchedBy(func(request *http.Request) bool {
+		return request.Method == "PUT" && request.URL.Path == "/a/journal"
+	})).Return(&http.Response{
+		StatusCode: http.StatusNoContent,
+		Body:       ioutil.NopCloser(strings.NewReader("")),
+	}, nil).Once()
+
+	var start = time.Now()
+	writer.Start()
+	<-promise.Ready
+
+	c.Check(promise.Error, gc.IsNil)
+	c.Check(time.Since(start) >= time.Second, gc.Equals, true)
+
+	writer.Stop()
+	mockClient.AssertExpectations(c)
+}
+
 var _ = gc.Suite(&WriteServiceSuite{})
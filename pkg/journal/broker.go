@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"io"
+	"time"
 
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/net/trace"
@@ -14,6 +15,9 @@ import (
 const (
 	kSpoolRollSize   = 1 << 30
 	kCommitThreshold = 1 << 20
+	// Maximum number of transactions awaiting commit results of replicas. See
+	// Broker.commits.
+	kMaxPendingCommits = 8
 
 	AppendOpBufferSize = 100
 )
@@ -32,8 +36,15 @@ type BrokerConfig struct {
 	// replication requests and verifed for consensus by each remote replica:
 	// for a transaction to succeed, all replicas must agree on the |WriteHead|.
 	WriteHead int64
+	// Specification of the journal. Spools are rolled by the Broker once
+	// |Spec.FragmentSize| bytes have been written to them, or once the
+	// |Spec.FlushInterval| in which they were begun has elapsed.
+	Spec JournalSpec
 	// Number of bytes written since the last spool roll.
 	writtenSinceRoll int64
+	// Time at which the current spool should be rolled. Zero if the spool
+	// should be rolled only on |writtenSinceRoll|.
+	flushDeadline time.Time
 }
 
 // Broker is responsible for scattering journal writes to each replica, i.e.,
@@ -46,7 +57,25 @@ type Broker struct {
 	configUpdates chan BrokerConfig
 	config        BrokerConfig
 
+	// Durable store of ProducerStates, or nil if ProducerStates are held only
+	// in memory.
+	producers ProducerStore
+	// ProducerStates of the journal, or nil if not yet loaded (and resolved)
+	// from |producers|. |producerRevision| is the revision of stored states.
+	producerStates   *ProducerStates
+	producerRevision uint64
+
+	// Transactions whose commits were sent to replicas, but whose commit
+	// results have not yet been gathered, in transaction order. The broker
+	// pipelines the next transaction with these commits, which is safe as
+	// a replica verifies the next transaction only after applying the prior
+	// commit. The configured WriteHead presumes these transactions commit.
+	commits []*pendingCommit
+
 	stop chan struct{}
+
+	// Effective constants, which are swappable for testing.
+	timeNow func() time.Time
 }
 
 func NewBroker(journal Name) *Broker {
@@ -55,6 +84,7 @@ func NewBroker(journal Name) *Broker {
 		appendOps:     make(chan AppendOp, AppendOpBufferSize),
 		configUpdates: make(chan BrokerConfig, 16),
 		stop:          make(chan struct{}),
+		timeNow:       time.Now,
 	}
 	return b
 }
@@ -96,6 +126,11 @@ func (b *Broker) loop() {
 			}
 		default:
 		}
+		// Resolve pending commits once there's no further append to pipeline.
+		if len(b.commits) != 0 && len(b.appendOps) == 0 {
+			b.resolveCommit()
+			continue
+		}
 		select {
 		case config, ok := <-b.configUpdates:
 			if !ok {
@@ -109,28 +144,44 @@ func (b *Broker) loop() {
 				b.appendOps = nil
 				continue
 			}
-			if b.config.writtenSinceRoll > kSpoolRollSize {
+			if err := b.syncProducers(op.Context); err != nil {
+				op.Result <- AppendResult{Error: ErrReplicationFailed}
+
+				log.WithFields(log.Fields{"err": err, "journal": b.journal}).
+					Warn("failed to sync producers")
+				continue
+			}
+			if !b.admit(op, b.config.WriteHead, nil) {
+				continue
+			}
+			if b.shouldRoll() {
 				b.config.writtenSinceRoll = 0
 			}
 			if writers, err := b.phaseOne(op.Context); err != nil {
 				op.Result <- AppendResult{Error: ErrReplicationFailed}
 
 				log.WithField("err", err).Warn("transaction failed (phase one)")
-			} else if err = b.phaseTwo(writers, op); err != nil {
-				log.WithField("err", err).Warn("transaction failed (phase two)")
+			} else {
+				b.phaseTwo(writers, op)
 			}
 		}
 	}
+	b.resolveCommits()
+
 	log.WithField("journal", b.journal).Debug("broker exiting")
 	close(b.stop)
 }
 
 func (b *Broker) onConfigUpdate(config BrokerConfig) {
+	// Pending commits are resolved against the replicas they were sent to.
+	b.resolveCommits()
+
 	log.WithFields(log.Fields{"config": config, "journal": b.journal}).
 		Debug("updated config")
 
 	b.config.RouteToken = config.RouteToken
 	b.config.Replicas = config.Replicas
+	b.config.Spec = config.Spec
 
 	if config.WriteHead > b.config.WriteHead {
 		b.config.WriteHead = config.WriteHead
@@ -138,6 +189,21 @@ func (b *Broker) onConfigUpdate(config BrokerConfig) {
 	// We zero writtenSinceRoll so that replicas begin new spools after
 	// the route configuration changes.
 	b.config.writtenSinceRoll = 0
+
+	// Another broker may have committed producer appends since ProducerStates
+	// were loaded. Re-load them on next use.
+	if b.producers != nil {
+		b.producerStates = nil
+	}
+}
+
+// Returns whether the current spool has reached the journal's FragmentSize,
+// or its flush deadline has passed.
+func (b *Broker) shouldRoll() bool {
+	if b.config.writtenSinceRoll > b.config.Spec.fragmentSize() {
+		return true
+	}
+	return !b.config.flushDeadline.IsZero() && !b.timeNow().Before(b.config.flushDeadline)
 }
 
 // Opens a write-stream with each replica for this transaction.
@@ -159,6 +225,9 @@ func (b *Broker) phaseOne(ctx context.Context) ([]WriteCommitter, error) {
 		// TODO(johnny): The Broker should have its own cancel-able Context, used here.
 		Context: context.TODO(),
 	}
+	if args.NewSpool {
+		b.config.flushDeadline = b.config.Spec.flushDeadline(b.timeNow())
+	}
 	if tr, ok := trace.FromContext(ctx); ok {
 		tr.LazyPrintf("Broker.phaseOne request: %v", args)
 	}
@@ -199,11 +268,14 @@ func (b *Broker) phaseOne(ctx context.Context) ([]WriteCommitter, error) {
 	}
 }
 
-func (b *Broker) phaseTwo(writers []WriteCommitter, op AppendOp) error {
+func (b *Broker) phaseTwo(writers []WriteCommitter, op AppendOp) {
 	var pending []AppendOp
 
 	var commitDelta int64
 	var readErr, writeErr error
+	var ok bool
+	// Sequence numbers of producer appends in this transaction.
+	var sequences = make(map[ProducerID]int64)
 	var buf = make([]byte, 32*1024) // io.Copy's buffer size.
 
 	// Consume waiting AppendOps, streaming them to writers.
@@ -217,6 +289,10 @@ func (b *Broker) phaseTwo(writers []WriteCommitter, op AppendOp) error {
 			// Only commit a complete read from a client.
 			commitDelta += readSize
 			pending = append(pending, op)
+
+			if op.Producer != "" {
+				sequences[op.Producer] = op.Sequence
+			}
 		}
 
 		if tr, ok := trace.FromContext(op.Context); ok {
@@ -229,18 +305,33 @@ func (b *Broker) phaseTwo(writers []WriteCommitter, op AppendOp) error {
 			break
 		}
 
-		// Pop another append. Break if the channel blocks or closes.
-		var ok bool
-		select {
-		case op, ok = <-b.appendOps:
-		default:
-			ok = false
-		}
-		if !ok {
+		// Pop another append which may be coalesced into this transaction.
+		// Break if the channel blocks or closes.
+		if op, ok = b.popCoalescedAppend(b.config.WriteHead+commitDelta, sequences); !ok {
 			break
 		}
 	}
 
+	// Pipelined writers may not yet have been verified by their replicas.
+	// Require that all replicas accepted the transaction.
+	if writeErr == nil {
+		writeErr = b.awaitVerified(writers)
+	}
+	// Producer appends must be durably stored as Pending before they commit.
+	// If they can't be, the transaction is rolled back. As the failed store
+	// may nonetheless have been applied, ProducerStates are then re-loaded
+	// and resolved before the next transaction.
+	var pendingStored bool
+	if writeErr == nil && len(sequences) != 0 {
+		if writeErr = b.storePending(commitDelta, sequences); writeErr != nil {
+			log.WithFields(log.Fields{"err": writeErr, "journal": b.journal}).
+				Warn("failed to store pending producer appends")
+			b.producerStates = nil
+		} else {
+			pendingStored = true
+		}
+	}
+
 	// If a write error occurred to any replica, roll back this transaction.
 	if writeErr != nil {
 		log.WithFields(log.Fields{"err": writeErr, "delta": commitDelta}).
@@ -248,18 +339,45 @@ func (b *Broker) phaseTwo(writers []WriteCommitter, op AppendOp) error {
 		commitDelta = 0
 	}
 
-	// Scatter / gather to close each writer in parallel.
+	// Scatter commits to each writer in parallel. The write head moves forward
+	// presuming at least one replica commits.
+	b.commits = append(b.commits, &pendingCommit{
+		commitErrs:    scatterCommit(writers, commitDelta),
+		writers:       len(writers),
+		delta:         commitDelta,
+		writeHead:     b.config.WriteHead + commitDelta,
+		appends:       pending,
+		writeErr:      writeErr,
+		sequences:     sequences,
+		pendingStored: pendingStored,
+	})
+	b.config.WriteHead += commitDelta
+	b.config.writtenSinceRoll += commitDelta
+
+	// Transactions having producer appends are resolved before any further
+	// append is admitted, as are those having replicas which don't verify
+	// transactions prior to their commit.
+	if len(sequences) != 0 || !allPending(writers) || len(b.commits) > kMaxPendingCommits {
+		b.resolveCommits()
+	}
+}
+
+// Gathers commit results of the oldest pending commit, and notifies its
+// appends of the transaction outcome.
+func (b *Broker) resolveCommit() {
+	var commit = b.commits[0]
+	b.commits = b.commits[1:]
+
 	// Retain a replica write error, if any occur.
-	var sawError = writeErr
+	var sawError = commit.writeErr
 	var sawSuccess bool
-	var commitErrs = scatterCommit(writers, commitDelta)
 
-	for range writers {
-		if err := <-commitErrs; err != nil {
+	for i := 0; i != commit.writers; i++ {
+		if err := <-commit.commitErrs; err != nil {
 			if sawError == nil {
 				sawError = err
 			}
-			log.WithFields(log.Fields{"err": err, "delta": commitDelta}).
+			log.WithFields(log.Fields{"err": err, "delta": commit.delta}).
 				Warn("reporting failure due to replica commit error")
 		} else {
 			sawSuccess = true
@@ -267,29 +385,207 @@ func (b *Broker) phaseTwo(writers []WriteCommitter, op AppendOp) error {
 	}
 	// The write head moves forward if at least one replica committed.
 	if sawSuccess {
-		b.config.WriteHead += commitDelta
-		b.config.writtenSinceRoll += int64(commitDelta)
-
-		metrics.CommittedBytesTotal.Add(float64(commitDelta))
-		metrics.CoalescedAppendsTotal.Add(float64(len(pending)))
+		metrics.CommittedBytesTotal.Add(float64(commit.delta))
+		metrics.CoalescedAppendsTotal.Add(float64(len(commit.appends)))
+
+		// Producer appends of the transaction are now part of the journal (even
+		// if some replicas failed), and retries must not append them again.
+		// Stored ProducerStates needn't be updated, as their Pending transaction
+		// resolves as committed.
+		if commit.pendingStored {
+			b.producerStates.apply(commit.sequences, b.timeNow())
+		}
+	} else {
+		b.config.WriteHead -= commit.delta
+		b.config.writtenSinceRoll -= commit.delta
+
+		if commit.pendingStored && b.producers != nil {
+			// Replicas may have committed the Pending transaction despite
+			// reporting errors. Re-load and resolve ProducerStates before the
+			// next transaction.
+			b.producerStates = nil
+		}
 	}
 
 	if sawError != nil {
 		// At least one replica failed. The client must retry.
-		for _, p := range pending {
+		for _, p := range commit.appends {
 			if tr, ok := trace.FromContext(p.Context); ok {
 				tr.LazyPrintf("Broker.phaseTwo abort: %v", sawError)
 			}
 			p.Result <- AppendResult{Error: ErrReplicationFailed}
 		}
-		return sawError
+		log.WithField("err", sawError).Warn("transaction failed (phase two)")
+		return
 	}
 
 	// The transaction was fully replicated. Notify client(s) of success and
 	// new write-head.
-	for _, p := range pending {
-		p.Result <- AppendResult{Error: nil, WriteHead: b.config.WriteHead}
+	for _, p := range commit.appends {
+		p.Result <- AppendResult{Error: nil, WriteHead: commit.writeHead}
+	}
+}
+
+// Resolves all pending commits.
+func (b *Broker) resolveCommits() {
+	for len(b.commits) != 0 {
+		b.resolveCommit()
+	}
+}
+
+// Awaits verification of each PendingWriteCommitter of |writers|, returning
+// an error if any replica rejected the transaction. As in phaseOne, the write
+// head is stepped forward to that of a replica which is ahead of it.
+func (b *Broker) awaitVerified(writers []WriteCommitter) error {
+	var err error
+
+	for _, w := range writers {
+		var pending, ok = w.(PendingWriteCommitter)
+		if !ok {
+			continue
+		}
+		if result := pending.Await(); result.Error != nil {
+			if result.ErrorWriteHead > b.config.WriteHead {
+				b.config.WriteHead = result.ErrorWriteHead
+			}
+			err = result.Error
+		}
+	}
+	return err
+}
+
+// Returns whether |op| may be appended at |writeHead|, in a transaction which
+// already includes producer appends |sequences|. If not, |op| is resolved:
+// either as a successful no-op (if it's a duplicate of a committed producer
+// append), or with an error.
+func (b *Broker) admit(op AppendOp, writeHead int64, sequences map[ProducerID]int64) bool {
+	if op.Producer != "" {
+		if op.Sequence <= b.producerStates.Producers[op.Producer].Sequence {
+			if tr, ok := trace.FromContext(op.Context); ok {
+				tr.LazyPrintf("Broker: duplicate of committed producer append")
+			}
+			metrics.DuplicateAppendsTotal.Inc()
+			op.Result <- AppendResult{WriteHead: b.config.WriteHead}
+			return false
+		} else if seq, ok := sequences[op.Producer]; ok && op.Sequence <= seq {
+			// |op| duplicates an append of the current transaction, which hasn't
+			// yet committed. The producer must retry.
+			op.Result <- AppendResult{Error: ErrReplicationFailed}
+			return false
+		}
+	}
+
+	if op.ExpectWriteHead != nil && *op.ExpectWriteHead != writeHead {
+		if tr, ok := trace.FromContext(op.Context); ok {
+			tr.LazyPrintf("Broker: expected write head %d, but is %d", *op.ExpectWriteHead, writeHead)
+		}
+		op.Result <- AppendResult{Error: ErrWrongWriteHead, WriteHead: writeHead}
+		return false
+	}
+	return true
+}
+
+// Pops a ready AppendOp to be appended at |writeHead|, in a transaction which
+// includes producer appends |sequences|. Ops which aren't admitted are skipped.
+// Returns false if no op is ready.
+func (b *Broker) popCoalescedAppend(writeHead int64, sequences map[ProducerID]int64) (AppendOp, bool) {
+	for {
+		select {
+		case op, ok := <-b.appendOps:
+			if !ok {
+				return AppendOp{}, false
+			} else if b.admit(op, writeHead, sequences) {
+				return op, true
+			}
+		default:
+			return AppendOp{}, false
+		}
+	}
+}
+
+// Readies ProducerStates of the journal for a transaction, loading them if
+// not already loaded. A Pending transaction of loaded ProducerStates is
+// resolved against the write head agreed by replicas. If it rolled back, the
+// resolution is stored before any further transaction may commit content at
+// its offsets.
+func (b *Broker) syncProducers(ctx context.Context) error {
+	if b.producerStates != nil {
+		return nil
+	} else if b.producers == nil {
+		b.producerStates = &ProducerStates{Producers: make(map[ProducerID]ProducerState)}
+		return nil
+	}
+
+	// Pending commits may include producer appends of the loaded states.
+	b.resolveCommits()
+
+	var states, revision, err = b.producers.LoadProducers(b.journal)
+	if err != nil {
+		return err
+	} else if states.Producers == nil {
+		states.Producers = make(map[ProducerID]ProducerState)
 	}
+	b.producerRevision = revision
+
+	if states.Pending != nil {
+		// Our write head may lag that of replicas. Broker an empty transaction
+		// to agree upon it.
+		if err = b.agreeWriteHead(ctx); err != nil {
+			return err
+		}
+		if states.resolve(b.config.WriteHead) {
+			if err = b.storeProducers(states); err != nil {
+				return err
+			}
+		}
+	}
+	b.producerStates = &states
+	return nil
+}
+
+// Brokers an empty transaction, which succeeds only if all replicas agree
+// upon the write head. On failure, the write head is stepped forward to that
+// of a replica which is ahead of it.
+func (b *Broker) agreeWriteHead(ctx context.Context) error {
+	var writers, err = b.phaseOne(ctx)
+	if err != nil {
+		return err
+	}
+	var commitErrs = scatterCommit(writers, 0)
+
+	for range writers {
+		if commitErr := <-commitErrs; commitErr != nil {
+			err = commitErr
+		}
+	}
+	return err
+}
+
+// Stores ProducerStates having a Pending transaction of producer appends
+// |sequences|, which spans |delta| bytes from the write head.
+func (b *Broker) storePending(delta int64, sequences map[ProducerID]int64) error {
+	if b.producers == nil {
+		return nil
+	}
+	return b.storeProducers(ProducerStates{
+		Producers: b.producerStates.Producers,
+		Pending: &PendingAppends{
+			Begin:     b.config.WriteHead,
+			End:       b.config.WriteHead + delta,
+			Sequences: sequences,
+			Updated:   b.timeNow(),
+		},
+	})
+}
+
+// Stores |states| as the ProducerStates of the journal, iff stored
+// ProducerStates are of the revision last loaded or stored by the Broker.
+func (b *Broker) storeProducers(states ProducerStates) error {
+	var revision, err = b.producers.StoreProducers(b.journal, states, b.producerRevision)
+	if err != nil {
+		return err
+	}
+	b.producerRevision = revision
 	return nil
 }
 
@@ -318,6 +614,16 @@ func streamToWriters(dst []WriteCommitter, src io.Reader,
 	}
 }
 
+// Returns whether each of |writers| is a PendingWriteCommitter.
+func allPending(writers []WriteCommitter) bool {
+	for _, w := range writers {
+		if _, ok := w.(PendingWriteCommitter); !ok {
+			return false
+		}
+	}
+	return true
+}
+
 func scatterCommit(writers []WriteCommitter, delta int64) chan error {
 	// Buffer result channel to the number of writers, so goroutines
 	// will exit if caller never inspects results.
@@ -329,3 +635,20 @@ func scatterCommit(writers []WriteCommitter, delta int64) chan error {
 	}
 	return closeResults
 }
+
+// pendingCommit is a transaction awaiting commit results of its replicas.
+type pendingCommit struct {
+	commitErrs <-chan error
+	writers    int
+	delta      int64
+	// Write head of the journal following the transaction.
+	writeHead int64
+	// Appends of the transaction, notified upon its resolution.
+	appends []AppendOp
+	// Write error of the transaction, if it was rolled back.
+	writeErr error
+	// Producer appends of the transaction, and whether they were stored as
+	// Pending ProducerStates.
+	sequences     map[ProducerID]int64
+	pendingStored bool
+}
@@ -5,6 +5,7 @@ import (
 	"context"
 	"errors"
 	"testing/iotest"
+	"time"
 
 	gc "github.com/go-check/check"
 )
@@ -215,6 +216,102 @@ func (s *BrokerSuite) TestBrokenReadHandling(c *gc.C) {
 	c.Check(s.broker.config.writtenSinceRoll, gc.Equals, int64(29))
 }
 
+func (s *BrokerSuite) TestSpoolRollsAtFragmentSize(c *gc.C) {
+	// Update the config with a JournalSpec having a small FragmentSize.
+	var config = BrokerConfig{
+		RouteToken: "a-route-token",
+		Spec:       JournalSpec{FragmentSize: 15},
+	}
+	for _, r := range s.replicator {
+		config.Replicas = append(config.Replicas, r)
+	}
+	s.broker.UpdateConfig(config)
+	s.broker.StartServingOps(12345)
+
+	// The first transaction begins a new spool, and writes 20 bytes.
+	s.serveReplicaWriters(c)
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: int64(12365)})
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: int64(12365)})
+
+	s.broker.Append(AppendOp{
+		AppendArgs: AppendArgs{
+			Content: bytes.NewBufferString("write three "),
+			Context: context.Background(),
+		},
+		Result: s.appendResults,
+	})
+
+	// The next transaction again begins a new spool, as the former spool
+	// exceeds the FragmentSize.
+	var ops = [...]ReplicateOp{
+		<-s.replicateOps, <-s.replicateOps, <-s.replicateOps}
+	for i, op := range ops {
+		c.Check(op.NewSpool, gc.Equals, true)
+		c.Check(op.WriteHead, gc.Equals, int64(12365))
+		op.Result <- ReplicateResult{Writer: s.replicator[i]}
+	}
+	for range s.replicator {
+		<-s.committed
+	}
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: int64(12377)})
+}
+
+func (s *BrokerSuite) TestSpoolRollsAtFlushInterval(c *gc.C) {
+	var config = BrokerConfig{
+		RouteToken: "a-route-token",
+		Spec:       JournalSpec{FlushInterval: time.Hour},
+	}
+	for _, r := range s.replicator {
+		config.Replicas = append(config.Replicas, r)
+	}
+	s.broker.UpdateConfig(config)
+
+	var now = time.Date(2018, 1, 1, 10, 30, 0, 0, time.UTC)
+	s.broker.timeNow = func() time.Time { return now }
+	s.broker.StartServingOps(12345)
+
+	// The first transaction begins a new spool, with a flush deadline of 11:00.
+	s.serveReplicaWriters(c)
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: int64(12365)})
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: int64(12365)})
+	c.Check(s.broker.config.flushDeadline, gc.Equals,
+		time.Date(2018, 1, 1, 11, 0, 0, 0, time.UTC))
+
+	var appendAt = func(t time.Time) {
+		now = t
+		s.broker.Append(AppendOp{
+			AppendArgs: AppendArgs{
+				Content: bytes.NewBufferString("more"),
+				Context: context.Background(),
+			},
+			Result: s.appendResults,
+		})
+	}
+
+	// Prior to the deadline, the spool is not rolled.
+	appendAt(time.Date(2018, 1, 1, 10, 59, 59, 0, time.UTC))
+	s.serveReplicaWriters(c)
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: int64(12369)})
+	c.Check(s.broker.config.writtenSinceRoll, gc.Equals, int64(24))
+
+	// At the deadline, the spool is rolled. The next deadline is 12:00.
+	appendAt(time.Date(2018, 1, 1, 11, 0, 0, 0, time.UTC))
+
+	var ops = [...]ReplicateOp{
+		<-s.replicateOps, <-s.replicateOps, <-s.replicateOps}
+	for i, op := range ops {
+		c.Check(op.NewSpool, gc.Equals, true)
+		op.Result <- ReplicateResult{Writer: s.replicator[i]}
+	}
+	for range s.replicator {
+		<-s.committed
+	}
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: int64(12373)})
+	c.Check(s.broker.config.writtenSinceRoll, gc.Equals, int64(4))
+	c.Check(s.broker.config.flushDeadline, gc.Equals,
+		time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC))
+}
+
 func (s *BrokerSuite) TestWrongWriteHeadErrorHandling(c *gc.C) {
 	s.broker.StartServingOps(12345)
 
@@ -260,6 +357,291 @@ func (s *BrokerSuite) TestWrongWriteHeadErrorHandling(c *gc.C) {
 	c.Check(s.broker.config.writtenSinceRoll, gc.Equals, int64(10))
 }
 
+func (s *BrokerSuite) TestPipelinedVerificationErrorHandling(c *gc.C) {
+	s.broker.StartServingOps(12345)
+
+	// The first replica returns a pipelined writer, which is later rejected.
+	var pending = &testPendingWriter{
+		testReplicator: s.replicator[0],
+		verification: ReplicateResult{
+			Error:          ErrWrongWriteHead,
+			ErrorWriteHead: 234567,
+		},
+	}
+	var ops = [...]ReplicateOp{
+		<-s.replicateOps, <-s.replicateOps, <-s.replicateOps}
+	for i, op := range ops {
+		if i == 0 {
+			op.Result <- ReplicateResult{Writer: pending}
+		} else {
+			op.Result <- ReplicateResult{Writer: s.replicator[i]}
+		}
+	}
+	// Content was streamed to all replicas, but each received an abort-commit.
+	for _ = range s.replicator {
+		<-s.committed
+	}
+	for _, r := range s.replicator {
+		c.Check(r.commitDelta, gc.Equals, int64(0))
+		c.Check(r.buffer.String(), gc.Equals, "write one write two ")
+	}
+	// Both append ops were notified of failure.
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{Error: ErrReplicationFailed})
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{Error: ErrReplicationFailed})
+
+	// Write head was stepped forward.
+	c.Check(s.broker.config.WriteHead, gc.Equals, int64(234567))
+	c.Check(s.broker.config.writtenSinceRoll, gc.Equals, int64(0))
+}
+
+func (s *BrokerSuite) TestCommitsArePipelined(c *gc.C) {
+	var results = make(chan AppendResult, 2)
+	var large = bytes.Repeat([]byte("x"), kCommitThreshold)
+
+	// A large append closes the first transaction at the commit threshold.
+	// The next append is brokered in a second transaction.
+	for _, content := range [][]byte{large, []byte("write four ")} {
+		s.broker.Append(AppendOp{
+			AppendArgs: AppendArgs{
+				Content: bytes.NewReader(content),
+				Context: context.Background(),
+			},
+			Result: results,
+		})
+	}
+	s.broker.StartServingOps(12345)
+
+	// Serves a transaction with pipelined writers, which verify successfully.
+	var serve = func(writeHead int64) (writers [3]*testReplicator) {
+		var ops = [...]ReplicateOp{
+			<-s.replicateOps, <-s.replicateOps, <-s.replicateOps}
+		for i, op := range ops {
+			c.Check(op.WriteHead, gc.Equals, writeHead)
+
+			writers[i] = &testReplicator{committed: s.committed}
+			op.Result <- ReplicateResult{Writer: &testPendingWriter{testReplicator: writers[i]}}
+		}
+		return
+	}
+	var first = serve(12345)
+	// The second transaction begins at the write head presumed by the first,
+	// before any replica has acknowledged the first's commit.
+	var second = serve(12365 + kCommitThreshold)
+
+	for i := 0; i != 2*len(s.replicator); i++ {
+		<-s.committed
+	}
+	for i := range s.replicator {
+		c.Check(first[i].commitDelta, gc.Equals, int64(20+kCommitThreshold))
+		c.Check(second[i].buffer.String(), gc.Equals, "write four ")
+		c.Check(second[i].commitDelta, gc.Equals, int64(11))
+	}
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: 12365 + kCommitThreshold})
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: 12365 + kCommitThreshold})
+	c.Check(<-results, gc.DeepEquals, AppendResult{WriteHead: 12365 + kCommitThreshold})
+	c.Check(<-results, gc.DeepEquals, AppendResult{WriteHead: 12376 + kCommitThreshold})
+}
+
+func (s *BrokerSuite) TestExpectedWriteHead(c *gc.C) {
+	var results = make(chan AppendResult, 3)
+	var expect = func(head int64) *int64 { return &head }
+
+	// Queue appends behind the fixture appends. The first expects the write
+	// head at which it will be coalesced, and the second expects a stale head.
+	s.broker.Append(AppendOp{
+		AppendArgs: AppendArgs{
+			Content:         bytes.NewBufferString("write three "),
+			ExpectWriteHead: expect(12365),
+			Context:         context.Background(),
+		},
+		Result: results,
+	})
+	s.broker.Append(AppendOp{
+		AppendArgs: AppendArgs{
+			Content:         bytes.NewBufferString("write four "),
+			ExpectWriteHead: expect(12345),
+			Context:         context.Background(),
+		},
+		Result: results,
+	})
+	s.broker.StartServingOps(12345)
+	s.serveReplicaWriters(c)
+
+	for _, r := range s.replicator {
+		c.Check(r.commitDelta, gc.Equals, int64(32))
+		c.Check(r.buffer.String(), gc.Equals, "write one write two write three ")
+	}
+	// The stale append fails with the write head at which it would have been
+	// appended, without being written.
+	c.Check(<-results, gc.DeepEquals, AppendResult{Error: ErrWrongWriteHead, WriteHead: 12377})
+
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: 12377})
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: 12377})
+	c.Check(<-results, gc.DeepEquals, AppendResult{WriteHead: 12377})
+
+	// An append expecting a stale head fails without starting a transaction.
+	s.broker.Append(AppendOp{
+		AppendArgs: AppendArgs{
+			Content:         bytes.NewBufferString("write five "),
+			ExpectWriteHead: expect(0),
+			Context:         context.Background(),
+		},
+		Result: results,
+	})
+	c.Check(<-results, gc.DeepEquals, AppendResult{Error: ErrWrongWriteHead, WriteHead: 12377})
+	c.Check(s.broker.config.WriteHead, gc.Equals, int64(12377))
+}
+
+func (s *BrokerSuite) TestProducerAppendsAreIdempotent(c *gc.C) {
+	var results = make(chan AppendResult, 3)
+	var store = &testProducerStore{
+		states: ProducerStates{
+			Producers: map[ProducerID]ProducerState{"producer": {Sequence: 3}},
+		},
+		revision: 1,
+	}
+	var now = time.Unix(1500000000, 0)
+
+	s.broker.producers = store
+	s.broker.timeNow = func() time.Time { return now }
+
+	var producerAppend = func(content string, seq int64) {
+		s.broker.Append(AppendOp{
+			AppendArgs: AppendArgs{
+				Content:  bytes.NewBufferString(content),
+				Producer: "producer",
+				Sequence: seq,
+				Context:  context.Background(),
+			},
+			Result: results,
+		})
+	}
+	// Queue a retry of an already-committed append, a new append, and a
+	// retry of the new append within the same transaction.
+	producerAppend("write three ", 3)
+	producerAppend("write four ", 4)
+	producerAppend("write five ", 4)
+
+	s.broker.StartServingOps(12345)
+	s.serveReplicaWriters(c)
+
+	for _, r := range s.replicator {
+		c.Check(r.commitDelta, gc.Equals, int64(31))
+		c.Check(r.buffer.String(), gc.Equals, "write one write two write four ")
+	}
+	// The committed duplicate succeeds as a no-op, at the committed write head.
+	c.Check(<-results, gc.DeepEquals, AppendResult{WriteHead: 12345})
+	// The uncommitted duplicate must be retried.
+	c.Check(<-results, gc.DeepEquals, AppendResult{Error: ErrReplicationFailed})
+
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: 12376})
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: 12376})
+	c.Check(<-results, gc.DeepEquals, AppendResult{WriteHead: 12376})
+
+	// The producer append was stored as Pending before the transaction
+	// committed, with compare-and-swap against the loaded revision.
+	c.Check(store.stored, gc.DeepEquals, []ProducerStates{{
+		Producers: map[ProducerID]ProducerState{"producer": {Sequence: 3}},
+		Pending: &PendingAppends{
+			Begin:     12345,
+			End:       12376,
+			Sequences: map[ProducerID]int64{"producer": 4},
+			Updated:   now,
+		},
+	}})
+	c.Check(store.storedRevisions, gc.DeepEquals, []uint64{1})
+
+	// A retry of the append now resolves as a no-op.
+	producerAppend("write four ", 4)
+	c.Check(<-results, gc.DeepEquals, AppendResult{WriteHead: 12376})
+	c.Check(s.broker.config.WriteHead, gc.Equals, int64(12376))
+}
+
+func (s *BrokerSuite) TestProducerStoreErrorRollsBackTransaction(c *gc.C) {
+	var results = make(chan AppendResult, 1)
+	var store = &testProducerStore{storeErr: errors.New("store error")}
+	s.broker.producers = store
+
+	s.broker.Append(AppendOp{
+		AppendArgs: AppendArgs{
+			Content:  bytes.NewBufferString("write three "),
+			Producer: "producer",
+			Sequence: 1,
+			Context:  context.Background(),
+		},
+		Result: results,
+	})
+
+	s.broker.StartServingOps(12345)
+	s.serveReplicaWriters(c)
+
+	// Appends were written, but the transaction was rolled back.
+	for _, r := range s.replicator {
+		c.Check(r.commitDelta, gc.Equals, int64(0))
+		c.Check(r.buffer.String(), gc.Equals, "write one write two write three ")
+	}
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{Error: ErrReplicationFailed})
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{Error: ErrReplicationFailed})
+	c.Check(<-results, gc.DeepEquals, AppendResult{Error: ErrReplicationFailed})
+
+	c.Check(s.broker.config.WriteHead, gc.Equals, int64(12345))
+	// ProducerStates must be re-loaded before the next transaction.
+	c.Check(s.broker.producerStates, gc.IsNil)
+}
+
+func (s *BrokerSuite) TestRolledBackPendingAppendsAreResolved(c *gc.C) {
+	var store = s.startWithPendingAppends(c, 12345)
+
+	// The resolution is stored before other content is committed at offsets
+	// of the Pending transaction.
+	c.Check(store.stored, gc.DeepEquals, []ProducerStates{{
+		Producers: map[ProducerID]ProducerState{"producer": {Sequence: 3}},
+	}})
+	c.Check(store.storedRevisions, gc.DeepEquals, []uint64{5})
+	c.Check(s.broker.producerStates.Producers, gc.DeepEquals,
+		map[ProducerID]ProducerState{"producer": {Sequence: 3}})
+}
+
+func (s *BrokerSuite) TestCommittedPendingAppendsAreResolved(c *gc.C) {
+	var store = s.startWithPendingAppends(c, 12350)
+
+	c.Check(store.stored, gc.HasLen, 0)
+	c.Check(s.broker.producerStates.Producers, gc.DeepEquals,
+		map[ProducerID]ProducerState{"producer": {Sequence: 4, Updated: time.Unix(1500000000, 0)}})
+}
+
+// Starts the Broker at |writeHead|, with stored ProducerStates having a
+// Pending transaction spanning [12345, 12350), and serves the fixture
+// transaction.
+func (s *BrokerSuite) startWithPendingAppends(c *gc.C, writeHead int64) *testProducerStore {
+	var store = &testProducerStore{
+		states: ProducerStates{
+			Producers: map[ProducerID]ProducerState{"producer": {Sequence: 3}},
+			Pending: &PendingAppends{
+				Begin:     12345,
+				End:       12350,
+				Sequences: map[ProducerID]int64{"producer": 4},
+				Updated:   time.Unix(1500000000, 0),
+			},
+		},
+		revision: 5,
+	}
+	s.broker.producers = store
+	s.broker.StartServingOps(writeHead)
+
+	// An empty transaction first agrees upon the write head.
+	s.serveReplicaWriters(c)
+	for _, r := range s.replicator {
+		c.Check(r.commitDelta, gc.Equals, int64(0))
+	}
+	// Followed by the fixture transaction.
+	s.serveReplicaWriters(c)
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: writeHead + 20})
+	c.Check(<-s.appendResults, gc.DeepEquals, AppendResult{WriteHead: writeHead + 20})
+
+	return store
+}
+
 type testReplicator struct {
 	ops chan ReplicateOp
 
@@ -284,4 +666,43 @@ func (c *testReplicator) Commit(delta int64) error {
 	return c.commitErr
 }
 
+// testPendingWriter is a testReplicator writer which is verified only when
+// awaited.
+type testPendingWriter struct {
+	*testReplicator
+	verification ReplicateResult
+}
+
+func (w *testPendingWriter) Await() ReplicateResult { return w.verification }
+
+type testProducerStore struct {
+	states   ProducerStates
+	revision uint64
+	storeErr error
+
+	stored          []ProducerStates
+	storedRevisions []uint64
+}
+
+func (s *testProducerStore) LoadProducers(name Name) (ProducerStates, uint64, error) {
+	return s.states, s.revision, nil
+}
+
+func (s *testProducerStore) StoreProducers(name Name, states ProducerStates,
+	revision uint64) (uint64, error) {
+	if s.storeErr != nil {
+		return 0, s.storeErr
+	}
+	// Copy |states|, as the Broker continues to mutate its Producers.
+	var producers = make(map[ProducerID]ProducerState)
+	for id, state := range states.Producers {
+		producers[id] = state
+	}
+	states.Producers = producers
+
+	s.stored = append(s.stored, states)
+	s.storedRevisions = append(s.storedRevisions, revision)
+	return revision + 1, nil
+}
+
 var _ = gc.Suite(&BrokerSuite{})
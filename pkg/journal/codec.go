@@ -0,0 +1,128 @@
+package journal
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+
+	"github.com/DataDog/zstd"
+	"github.com/golang/snappy"
+)
+
+// CompressionCodec defines the encoding of persisted fragment content. Local
+// fragments (spools) are always uncompressed, while persisted fragments are
+// compressed with the CompressionCodec of the journal's JournalSpec at the
+// time of persistence. The codec of a persisted fragment is encoded as an
+// extension of its content name, making fragments self-describing.
+type CompressionCodec string
+
+const (
+	CodecNone   CompressionCodec = "none"
+	CodecGzip   CompressionCodec = "gzip"
+	CodecSnappy CompressionCodec = "snappy"
+	CodecZstd   CompressionCodec = "zstd"
+)
+
+var ErrInvalidCodec = errors.New("invalid compression codec")
+
+// Validate returns an error if the CompressionCodec is not known. The
+// zero-valued CompressionCodec is valid.
+func (c CompressionCodec) Validate() error {
+	switch c {
+	case "", CodecNone, CodecGzip, CodecSnappy, CodecZstd:
+		return nil
+	default:
+		return ErrInvalidCodec
+	}
+}
+
+// IsCompressed returns whether content encoded with the CompressionCodec is
+// compressed. The zero-valued CompressionCodec is equivalent to CodecNone.
+func (c CompressionCodec) IsCompressed() bool {
+	return c != "" && c != CodecNone
+}
+
+// Returns the content name extension of the CompressionCodec.
+func (c CompressionCodec) extension() string {
+	switch c {
+	case CodecGzip:
+		return ".gz"
+	case CodecSnappy:
+		return ".sz"
+	case CodecZstd:
+		return ".zst"
+	default:
+		return ""
+	}
+}
+
+// Returns the CompressionCodec of content name extension |ext|.
+func codecForExtension(ext string) (CompressionCodec, error) {
+	for _, codec := range []CompressionCodec{CodecGzip, CodecSnappy, CodecZstd} {
+		if ext == codec.extension() {
+			return codec, nil
+		}
+	}
+	return "", ErrInvalidCodec
+}
+
+// NewCompressor returns a WriteCloser which compresses content written to it
+// using |codec|, and writes the result to |w|. Close must be called to flush
+// compressed content to |w|, but does not close |w|.
+func NewCompressor(w io.Writer, codec CompressionCodec) (io.WriteCloser, error) {
+	switch codec {
+	case "", CodecNone:
+		return nopWriteCloser{w}, nil
+	case CodecGzip:
+		return gzip.NewWriter(w), nil
+	case CodecSnappy:
+		return snappy.NewBufferedWriter(w), nil
+	case CodecZstd:
+		return zstd.NewWriter(w), nil
+	default:
+		return nil, ErrInvalidCodec
+	}
+}
+
+// NewDecompressor returns a ReadCloser which reads and decompresses content
+// of |r| using |codec|. Closing the returned ReadCloser also closes |r|.
+func NewDecompressor(r io.ReadCloser, codec CompressionCodec) (io.ReadCloser, error) {
+	switch codec {
+	case "", CodecNone:
+		return r, nil
+	case CodecGzip:
+		if gz, err := gzip.NewReader(r); err != nil {
+			return nil, err
+		} else {
+			return decompressor{Reader: gz, closers: []io.Closer{gz, r}}, nil
+		}
+	case CodecSnappy:
+		return decompressor{Reader: snappy.NewReader(r), closers: []io.Closer{r}}, nil
+	case CodecZstd:
+		var zr = zstd.NewReader(r)
+		return decompressor{Reader: zr, closers: []io.Closer{zr, r}}, nil
+	default:
+		return nil, ErrInvalidCodec
+	}
+}
+
+type nopWriteCloser struct{ io.Writer }
+
+func (nopWriteCloser) Close() error { return nil }
+
+// decompressor composes a decompressing Reader with Closers of the Reader
+// and its underlying source.
+type decompressor struct {
+	io.Reader
+	closers []io.Closer
+}
+
+func (d decompressor) Close() error {
+	var err error
+	for _, c := range d.closers {
+		if cErr := c.Close(); cErr != nil && err == nil {
+			err = cErr
+		}
+	}
+	return err
+}
@@ -25,9 +25,15 @@ type Fragment struct {
 	Journal    Name
 	Begin, End int64
 	Sum        [sha1.Size]byte
+	// Codec with which persisted fragment content is compressed. Local
+	// fragments are never compressed.
+	Codec CompressionCodec
 
 	// Backing file of the fragment, if present locally.
 	File FragmentFile
+	// Commit times of fragment content. Set for fragments spooled by this
+	// process: the index of a persisted fragment is loaded with LoadTimeIndex.
+	TimeIndex TimeIndex
 	// If fragment is remote, the time of last modification.
 	// NOTE(joshk): Does not get set in Client use.
 	// TODO(johnny): Is this the appropriate factoring?
@@ -35,12 +41,17 @@ type Fragment struct {
 }
 
 func (f Fragment) ContentName() string {
-	return fmt.Sprintf("%016x-%016x-%x", f.Begin, f.End, f.Sum)
+	return fmt.Sprintf("%016x-%016x-%x%s", f.Begin, f.End, f.Sum, f.Codec.extension())
 }
 func (f *Fragment) ContentPath() string {
 	return f.Journal.String() + "/" + f.ContentName()
 }
 
+// TimeIndexPath is the path of the fragment's persisted TimeIndex.
+func (f *Fragment) TimeIndexPath() string {
+	return f.ContentPath() + TimeIndexExtension
+}
+
 func (f Fragment) Size() int64 {
 	return f.End - f.Begin
 }
@@ -56,8 +67,21 @@ func (f Fragment) ReaderFromOffset(offset int64,
 	if err != nil {
 		return nil, err
 	}
-	_, err = file.Seek(offset-f.Begin, 0)
-	return file, err
+	if !f.Codec.IsCompressed() {
+		_, err = file.Seek(offset-f.Begin, 0)
+		return file, err
+	}
+	// Compressed content can't be seeked. Decompress and discard through |offset|.
+	rc, err := NewDecompressor(file, f.Codec)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+	if _, err = io.CopyN(ioutil.Discard, rc, offset-f.Begin); err != nil {
+		rc.Close()
+		return nil, err
+	}
+	return rc, nil
 }
 
 func (f Fragment) IsLocal() bool {
@@ -77,6 +101,14 @@ func ParseFragment(journal Name, contentName string) (Fragment, error) {
 	var err error
 
 	r.Journal = journal
+
+	// A content name extension denotes the fragment's compression codec.
+	if ext := path.Ext(contentName); ext != "" {
+		if r.Codec, err = codecForExtension(ext); err != nil {
+			return r, err
+		}
+		contentName = strings.TrimSuffix(contentName, ext)
+	}
 	fields := strings.Split(contentName, "-")
 
 	if len(fields) != 3 {
@@ -106,8 +138,8 @@ func NewWalkFuncAdapter(callback func(Fragment) error, rewrites ...string) filep
 	return func(fpath string, finfo os.FileInfo, err error) error {
 		if err != nil {
 			return err
-		} else if finfo.IsDir() {
-			return nil
+		} else if finfo.IsDir() || strings.HasSuffix(fpath, TimeIndexExtension) {
+			return nil // Not a fragment.
 		}
 
 		for i := 0; i != len(rewrites); i += 2 {
@@ -3,11 +3,14 @@ package journal
 import (
 	"crypto/sha1"
 	"errors"
+	"io/ioutil"
 	"math"
 	"os"
 	"time"
 
 	gc "github.com/go-check/check"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
 )
 
 type FragmentSuite struct {
@@ -23,6 +26,20 @@ func (s *FragmentSuite) TestContentName(c *gc.C) {
 	}
 	c.Assert(fragment.ContentName(), gc.Equals,
 		"00000000499602d2-7fffffffffffffff-0102030405060708090a0b0c0d0e0f1011121314")
+
+	// Compressed fragments have a content name extension of their codec.
+	fragment.Codec = CodecNone
+	c.Check(fragment.ContentName(), gc.Equals,
+		"00000000499602d2-7fffffffffffffff-0102030405060708090a0b0c0d0e0f1011121314")
+	fragment.Codec = CodecGzip
+	c.Check(fragment.ContentName(), gc.Equals,
+		"00000000499602d2-7fffffffffffffff-0102030405060708090a0b0c0d0e0f1011121314.gz")
+	fragment.Codec = CodecSnappy
+	c.Check(fragment.ContentName(), gc.Equals,
+		"00000000499602d2-7fffffffffffffff-0102030405060708090a0b0c0d0e0f1011121314.sz")
+	fragment.Codec = CodecZstd
+	c.Check(fragment.ContentName(), gc.Equals,
+		"00000000499602d2-7fffffffffffffff-0102030405060708090a0b0c0d0e0f1011121314.zst")
 }
 
 func (s *SpoolSuite) TestContentPath(c *gc.C) {
@@ -60,10 +77,27 @@ func (s *FragmentSuite) TestParsing(c *gc.C) {
 		Sum:     [sha1.Size]byte{},
 	})
 
+	// Compressed fragment.
+	fragment, err = ParseFragment("a/journal",
+		"00000000499602d2-7fffffffffffffff-0102030405060708090a0b0c0d0e0f1011121314.zst")
+	c.Assert(err, gc.IsNil)
+	c.Assert(fragment, gc.DeepEquals, Fragment{
+		Journal: "a/journal",
+		Begin:   1234567890,
+		End:     math.MaxInt64,
+		Sum: [...]byte{1, 2, 3, 4, 5, 6, 7, 8, 9, 10,
+			11, 12, 13, 14, 15, 16, 17, 18, 19, 20},
+		Codec: CodecZstd,
+	})
+
 	_, err = ParseFragment("a/journal",
 		"00000000499602d2-7fffffffffffffff-010203040506")
 	c.Assert(err, gc.ErrorMatches, "invalid checksum")
 
+	_, err = ParseFragment("a/journal",
+		"00000000499602d2-7fffffffffffffff-0102030405060708090a0b0c0d0e0f1011121314.bz2")
+	c.Assert(err, gc.Equals, ErrInvalidCodec)
+
 	_, err = ParseFragment("a/journal",
 		"2-1-0102030405060708090a0b0c0d0e0f1011121314")
 	c.Assert(err, gc.ErrorMatches, "invalid content range")
@@ -106,6 +140,35 @@ func (s *FragmentSuite) TestPathWalkFuncAdapater(c *gc.C) {
 	c.Check(out, gc.DeepEquals, []Fragment{}) // Verify ignored fragments were in fact ignored.
 }
 
+func (s *FragmentSuite) TestReaderFromOffsetOfCompressedFragment(c *gc.C) {
+	var cfs = cloudstore.NewTmpFileSystem()
+	defer func() { c.Check(cfs.Close(), gc.IsNil) }()
+
+	for _, codec := range []CompressionCodec{CodecNone, CodecGzip, CodecSnappy, CodecZstd} {
+		var fragment = Fragment{Journal: "a/journal", Begin: 100, End: 111, Codec: codec}
+
+		c.Check(cfs.MkdirAll(fragment.Journal.String(), 0750), gc.IsNil)
+		var w, err = cfs.OpenFile(fragment.ContentPath(), os.O_WRONLY|os.O_CREATE, 0640)
+		c.Assert(err, gc.IsNil)
+
+		cw, err := NewCompressor(w, codec)
+		c.Assert(err, gc.IsNil)
+		_, err = cw.Write([]byte("hello world"))
+		c.Check(err, gc.IsNil)
+		c.Check(cw.Close(), gc.IsNil)
+		c.Check(w.Close(), gc.IsNil)
+
+		// Expect content is decompressed, and read from the requested offset.
+		r, err := fragment.ReaderFromOffset(106, cfs)
+		c.Assert(err, gc.IsNil)
+
+		b, err := ioutil.ReadAll(r)
+		c.Check(err, gc.IsNil)
+		c.Check(string(b), gc.Equals, "world")
+		c.Check(r.Close(), gc.IsNil)
+	}
+}
+
 type mockFinfo struct {
 	isDir bool
 	size  int64
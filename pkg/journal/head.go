@@ -1,6 +1,9 @@
 package journal
 
 import (
+	"sync"
+	"time"
+
 	log "github.com/sirupsen/logrus"
 )
 
@@ -20,8 +23,18 @@ type Head struct {
 	persister FragmentPersister
 	// Notification channel on which committed fragments are sent.
 	updates chan<- Fragment
+	// Time after which |spool| is rolled, even if not requested by the broker.
+	// Zero if |spool| is not subject to a flush interval.
+	spoolDeadline time.Time
+
+	// Current specification of the journal, guarded by |specMu|.
+	spec   JournalSpec
+	specMu sync.Mutex
 
 	stop chan struct{}
+
+	// Effective constants, which are swappable for testing.
+	timeNow func() time.Time
 }
 
 func NewHead(journal Name, directory string, persister FragmentPersister,
@@ -35,6 +48,7 @@ func NewHead(journal Name, directory string, persister FragmentPersister,
 		persister:    persister,
 		updates:      updates,
 		stop:         make(chan struct{}),
+		timeNow:      time.Now,
 	}
 	return h
 }
@@ -49,6 +63,13 @@ func (h *Head) Replicate(op ReplicateOp) {
 	h.replicateOps <- op
 }
 
+// UpdateSpec updates the JournalSpec applied by the Head to future writes.
+func (h *Head) UpdateSpec(spec JournalSpec) {
+	h.specMu.Lock()
+	h.spec = spec
+	h.specMu.Unlock()
+}
+
 func (h *Head) Stop() {
 	close(h.replicateOps)
 	<-h.stop // Blocks until loop() exits.
@@ -93,7 +114,19 @@ func (h *Head) onWrite(write ReplicateOp) ReplicateResult {
 	//  * The Spool encountered an error.
 	//  * The Spool's End isn't our current write head.
 	//  * The broker requested a new spool.
-	if h.spool == nil || h.spool.err != nil || h.spool.End != h.writeHead || write.NewSpool {
+	//  * The Spool has grown beyond the journal's FragmentSize, or a full
+	//    FlushInterval has passed since its flush deadline. Typically the
+	//    broker will have already requested a new spool, but it may be working
+	//    from a different JournalSpec.
+	h.specMu.Lock()
+	var spec = h.spec
+	h.specMu.Unlock()
+
+	var now = h.timeNow()
+
+	if h.spool == nil || h.spool.err != nil || h.spool.End != h.writeHead || write.NewSpool ||
+		h.spool.Size() > spec.fragmentSize() ||
+		(!h.spoolDeadline.IsZero() && !now.Before(h.spoolDeadline)) {
 
 		if h.spool != nil {
 			if h.spool.End != h.writeHead {
@@ -108,6 +141,13 @@ func (h *Head) onWrite(write ReplicateOp) ReplicateResult {
 			return ReplicateResult{Error: err}
 		}
 		h.spool = spool
+
+		// Allow the broker a full FlushInterval beyond the spool's flush deadline
+		// to request a roll, so that modest clock skew doesn't produce spurious
+		// fragments.
+		if h.spoolDeadline = spec.flushDeadline(now); !h.spoolDeadline.IsZero() {
+			h.spoolDeadline = h.spoolDeadline.Add(spec.FlushInterval)
+		}
 	}
 	return ReplicateResult{Writer: headTransaction{h}}
 }
@@ -120,7 +160,12 @@ func (t headTransaction) Write(buf []byte) (n int, err error) {
 }
 
 func (t headTransaction) Commit(delta int64) error {
+	var begin = t.spool.End
+
 	err := t.spool.Commit(delta)
+	if err == nil && delta != 0 {
+		t.spool.TimeIndex = t.spool.TimeIndex.record(begin, t.timeNow())
+	}
 	t.writeHead = t.spool.End
 	t.updates <- t.spool.Fragment
 	t.committed <- struct{}{}
@@ -3,6 +3,7 @@ package journal
 import (
 	"io/ioutil"
 	"os"
+	"time"
 
 	gc "github.com/go-check/check"
 )
@@ -68,6 +69,9 @@ func (s *HeadSuite) TestBasicWrite(c *gc.C) {
 	c.Check(fragment.Begin, gc.Equals, int64(123456))
 	c.Check(fragment.End, gc.Equals, int64(123466))
 	c.Check(fragment.File, gc.NotNil)
+	// The commit time of written content was indexed.
+	c.Check(fragment.TimeIndex, gc.HasLen, 1)
+	c.Check(fragment.TimeIndex[0].Offset, gc.Equals, int64(123456))
 }
 
 func (s *HeadSuite) TestNoWrite(c *gc.C) {
@@ -110,6 +114,73 @@ func (s *HeadSuite) TestSequentialWrite(c *gc.C) {
 	c.Check(fragment.File, gc.NotNil)
 }
 
+func (s *HeadSuite) TestSequentialWriteBeyondFragmentSize(c *gc.C) {
+	s.head.UpdateSpec(JournalSpec{FragmentSize: 5})
+	s.TestBasicWrite(c)
+
+	// Perform another sequenced write after the first, which doesn't request
+	// a new spool.
+	var op = s.opFixture()
+	op.WriteHead = 123466
+	s.head.Replicate(op)
+
+	// The former spool was rolled anyway, as it exceeds the FragmentSize.
+	spool := <-s.rolled
+	c.Check(spool.End, gc.Equals, int64(123466))
+
+	result := <-op.Result
+	c.Check(result.Error, gc.IsNil)
+
+	result.Writer.Write([]byte("another write body"))
+	c.Check(result.Writer.Commit(18), gc.IsNil)
+
+	// A new fragment was produced from the new spool.
+	fragment := <-s.updates
+	c.Check(fragment.Begin, gc.Equals, int64(123466))
+	c.Check(fragment.End, gc.Equals, int64(123484))
+}
+
+func (s *HeadSuite) TestSequentialWriteBeyondFlushDeadline(c *gc.C) {
+	var now = time.Date(2018, 1, 1, 10, 30, 0, 0, time.UTC)
+	s.head.timeNow = func() time.Time { return now }
+	s.head.UpdateSpec(JournalSpec{FlushInterval: time.Hour})
+
+	s.TestBasicWrite(c)
+
+	// Perform sequenced writes after the first, which don't request a new spool.
+	var writeAt = func(t time.Time, writeHead int64) ReplicateResult {
+		now = t
+
+		var op = s.opFixture()
+		op.WriteHead = writeHead
+		s.head.Replicate(op)
+
+		var result = <-op.Result
+		c.Check(result.Error, gc.IsNil)
+		result.Writer.Write([]byte("body"))
+		c.Check(result.Writer.Commit(4), gc.IsNil)
+		return result
+	}
+
+	// The spool's flush deadline is 11:00, and the Head allows the broker a
+	// further hour to request a roll. Expect it's not rolled before 12:00.
+	writeAt(time.Date(2018, 1, 1, 11, 59, 0, 0, time.UTC), 123466)
+
+	fragment := <-s.updates
+	c.Check(fragment.Begin, gc.Equals, int64(123456))
+	c.Check(fragment.End, gc.Equals, int64(123470))
+
+	// At 12:00, the spool is rolled.
+	writeAt(time.Date(2018, 1, 1, 12, 0, 0, 0, time.UTC), 123470)
+
+	spool := <-s.rolled
+	c.Check(spool.End, gc.Equals, int64(123470))
+
+	fragment = <-s.updates
+	c.Check(fragment.Begin, gc.Equals, int64(123470))
+	c.Check(fragment.End, gc.Equals, int64(123474))
+}
+
 func (s *HeadSuite) TestWriteHeadIncreaseHandling(c *gc.C) {
 	s.TestBasicWrite(c)
 
@@ -10,6 +10,7 @@ import (
 //go:generate mockery -inpkg -name=FragmentFile
 //go:generate mockery -inpkg -name=Getter
 //go:generate mockery -inpkg -name=Header
+//go:generate mockery -inpkg -name=Lister
 //go:generate mockery -inpkg -name=Writer
 
 // A typed journal name. By convention, journals are named using a forward-
@@ -60,6 +61,16 @@ type Creator interface {
 	Create(journal Name) error
 }
 
+// Performs a Gazette DELETE operation.
+type Deleter interface {
+	Delete(args DeleteArgs) error
+}
+
+// Performs a Gazette LIST operation.
+type Lister interface {
+	List(args ListArgs) ([]ListedJournal, error)
+}
+
 // Provides low-level routing and access to a Gazette service, suitable for
 // proxying requests and modeled on http.Client. The client will perform
 // journal-based routing to the appropriate Gazette instance. See gazette.Client.
@@ -88,6 +99,17 @@ type WriteCommitter interface {
 	Commit(count int64) error
 }
 
+// A PendingWriteCommitter is a WriteCommitter which a Replicator returns
+// before the replica has verified the transaction, allowing transaction
+// content to be pipelined with the replica's verification. Brokers must Await
+// verification by all replicas before committing a non-zero |count|.
+type PendingWriteCommitter interface {
+	WriteCommitter
+	// Blocks until the replica has verified the transaction, and returns its
+	// verification. Only |Error| and |ErrorWriteHead| are set.
+	Await() ReplicateResult
+}
+
 // FragmentPersister accepts completed local fragment spools, which should
 // be persisted to long-term storage. See |gazette.Persister|.
 type FragmentPersister interface {
@@ -0,0 +1,32 @@
+// Code generated by mockery v1.0.0
+package journal
+
+import mock "github.com/stretchr/testify/mock"
+
+// MockLister is an autogenerated mock type for the Lister type
+type MockLister struct {
+	mock.Mock
+}
+
+// List provides a mock function with given fields: args
+func (_m *MockLister) List(args ListArgs) ([]ListedJournal, error) {
+	ret := _m.Called(args)
+
+	var r0 []ListedJournal
+	if rf, ok := ret.Get(0).(func(ListArgs) []ListedJournal); ok {
+		r0 = rf(args)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]ListedJournal)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(ListArgs) error); ok {
+		r1 = rf(args)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
@@ -0,0 +1,106 @@
+package journal
+
+import (
+	"errors"
+	"time"
+)
+
+// Duration after which the state of an idle producer may be discarded. A
+// retried append of a producer which has been idle for longer is no longer
+// recognized as a duplicate.
+const kProducerIdleTTL = 7 * 24 * time.Hour
+
+// ErrProducersConflict is returned by a ProducerStore if stored ProducerStates
+// were modified since the revision being updated.
+var ErrProducersConflict = errors.New("producer states were concurrently modified")
+
+// A ProducerID uniquely identifies a writer of idempotent appends. Each append
+// of a producer to a journal carries a sequence number, which increases with
+// each new append (but may have gaps). Retries of an append must use the same
+// sequence number. Brokers track the last committed sequence number of each
+// producer, and resolve retries of already-committed appends as successful
+// no-ops, without appending their content again.
+type ProducerID string
+
+// ProducerState is the state of a producer's appends to a journal.
+type ProducerState struct {
+	// Sequence number of the producer's last committed append.
+	Sequence int64 `json:"sequence"`
+	// Time at which the producer's last append was committed.
+	Updated time.Time `json:"updated"`
+}
+
+// ProducerStates are the durably stored producer states of a journal.
+//
+// A broker stores ProducerStates having a Pending transaction *before* it
+// commits a transaction which includes producer appends. Should the broker
+// fail before it's known whether the transaction committed, the next broker
+// of the journal resolves the Pending transaction against the journal's write
+// head: it committed iff the write head has reached its End. A broker which
+// learns the Pending transaction rolled back stores the resolution before
+// brokering further transactions, such that other content is never committed
+// at the offsets of a Pending transaction.
+type ProducerStates struct {
+	// States of producers having committed appends.
+	Producers map[ProducerID]ProducerState `json:"producers"`
+	// Transaction of producer appends which may or may not have committed.
+	Pending *PendingAppends `json:"pending,omitempty"`
+}
+
+// PendingAppends are producer appends of a transaction spanning journal
+// offsets [Begin, End).
+type PendingAppends struct {
+	Begin     int64                `json:"begin"`
+	End       int64                `json:"end"`
+	Sequences map[ProducerID]int64 `json:"sequences"`
+	// Time at which the transaction was begun.
+	Updated time.Time `json:"updated"`
+}
+
+// ProducerStore durably stores ProducerStates of journal producers, such that
+// they survive a hand-off of the journal to another broker. See
+// |gazette.ProducerStore|.
+type ProducerStore interface {
+	// Loads the ProducerStates of journal |name|, and their revision. The
+	// revision is zero if no ProducerStates are stored.
+	LoadProducers(name Name) (ProducerStates, uint64, error)
+	// Stores |states| as the ProducerStates of journal |name|, iff stored
+	// ProducerStates are of |revision|. Returns the revision of |states|, or
+	// ErrProducersConflict if stored ProducerStates are of another revision.
+	StoreProducers(name Name, states ProducerStates, revision uint64) (uint64, error)
+}
+
+// Resolves a Pending transaction of the ProducerStates against the journal
+// |writeHead|, applying its appends iff it committed. Returns whether the
+// Pending transaction rolled back.
+func (s *ProducerStates) resolve(writeHead int64) bool {
+	var pending = s.Pending
+	if pending == nil {
+		return false
+	}
+	s.Pending = nil
+
+	if writeHead < pending.End {
+		return true
+	}
+	s.apply(pending.Sequences, pending.Updated)
+	return false
+}
+
+// Applies committed producer appends |sequences|, updated at |now|, and prunes
+// producers idle since before |now| less kProducerIdleTTL.
+func (s *ProducerStates) apply(sequences map[ProducerID]int64, now time.Time) {
+	for id, seq := range sequences {
+		s.Producers[id] = ProducerState{Sequence: seq, Updated: now}
+	}
+	pruneProducers(s.Producers, now.Add(-kProducerIdleTTL))
+}
+
+// Removes states of |producers| which were last updated before |horizon|.
+func pruneProducers(producers map[ProducerID]ProducerState, horizon time.Time) {
+	for id, state := range producers {
+		if state.Updated.Before(horizon) {
+			delete(producers, id)
+		}
+	}
+}
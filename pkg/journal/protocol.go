@@ -7,15 +7,19 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"path"
+	"strconv"
 	"time"
 )
 
 var (
 	ErrExists            = errors.New("journal exists")
 	ErrNotBroker         = errors.New("not journal broker")
+	ErrNotAuthorized     = errors.New("not authorized")
 	ErrNotFound          = errors.New("journal not found")
 	ErrNotReplica        = errors.New("not journal replica")
 	ErrNotYetAvailable   = errors.New("offset not yet available")
+	ErrRateLimited       = errors.New("append rate limited")
 	ErrReplicationFailed = errors.New("replication failed")
 	ErrWrongRouteToken   = errors.New("wrong route token")
 	ErrWrongWriteHead    = errors.New("wrong write head")
@@ -23,9 +27,11 @@ var (
 	protocolErrors = []error{
 		ErrExists,
 		ErrNotBroker,
+		ErrNotAuthorized,
 		ErrNotFound,
 		ErrNotReplica,
 		ErrNotYetAvailable,
+		ErrRateLimited,
 		ErrReplicationFailed,
 		ErrWrongRouteToken,
 		ErrWrongWriteHead,
@@ -92,6 +98,10 @@ type ReadArgs struct {
 	// been permantently deleted), the broker will return the next available
 	// offset. Callers should therefore always inspect the ReadResult Offset.
 	Offset int64
+	// If non-zero, the read begins from the first offset committed at or after
+	// Timestamp (to within one second), and Offset is ignored. Callers should
+	// inspect the ReadResult Offset for the resolved offset.
+	Timestamp time.Time
 	// Whether the operation should block until content becomes available.
 	// ErrNotYetAvailable is returned if a non-blocking read has no ready content.
 	Blocking bool
@@ -106,11 +116,12 @@ type ReadArgs struct {
 
 func (a ReadArgs) String() string {
 	return fmt.Sprintf("%+v", struct {
-		Journal  Name
-		Offset   int64
-		Blocking bool
-		Deadline time.Time
-	}{a.Journal, a.Offset, a.Blocking, a.Deadline})
+		Journal   Name
+		Offset    int64
+		Timestamp time.Time
+		Blocking  bool
+		Deadline  time.Time
+	}{a.Journal, a.Offset, a.Timestamp, a.Blocking, a.Deadline})
 }
 
 type ReadResult struct {
@@ -150,23 +161,43 @@ type AppendArgs struct {
 	// until io.EOF, and abort the append (without committing any content)
 	// if any other error is returned by |Content.Read()|.
 	Content io.Reader
+	// Optional write head which the journal is expected to have. If set, and
+	// the journal's write head differs, the append fails with ErrWrongWriteHead
+	// (and the current write head is returned). Writers may use this to fence
+	// a journal against concurrent appends of other writers.
+	ExpectWriteHead *int64
+	// Optional producer of the append, and its sequence number. If set, and
+	// the producer has already committed an append of |Sequence| (or greater),
+	// the append is a duplicate and succeeds without appending |Content|.
+	Producer ProducerID
+	Sequence int64
 	// Context which may trace, cancel or supply a deadline for the operation.
 	Context context.Context
 }
 
 func (a AppendArgs) String() string {
+	var expect = "none"
+	if a.ExpectWriteHead != nil {
+		expect = strconv.FormatInt(*a.ExpectWriteHead, 10)
+	}
 	return fmt.Sprintf("%+v", struct {
-		Journal Name
-	}{a.Journal})
+		Journal         Name
+		ExpectWriteHead string
+		Producer        ProducerID
+		Sequence        int64
+	}{a.Journal, expect, a.Producer, a.Sequence})
 }
 
 type AppendResult struct {
 	// Any error that occurred during the append operation (PUT request.)
 	Error error
-	// Write head at the completion of the operation.
+	// Write head at the completion of the operation. On ErrWrongWriteHead,
+	// the current write head of the journal.
 	WriteHead int64
 	// RouteToken of the Journal. Set on ErrNotBroker.
 	RouteToken
+	// On ErrRateLimited, the duration after which the append may be retried.
+	RetryAfter time.Duration
 }
 
 func (a AppendResult) String() string {
@@ -174,7 +205,8 @@ func (a AppendResult) String() string {
 		Error     error
 		WriteHead int64
 		RouteToken
-	}{a.Error, a.WriteHead, a.RouteToken})
+		RetryAfter time.Duration
+	}{a.Error, a.WriteHead, a.RouteToken, a.RetryAfter})
 }
 
 type AppendOp struct {
@@ -184,6 +216,74 @@ type AppendOp struct {
 	Result chan AppendResult `json:"-"`
 }
 
+type ListArgs struct {
+	// Prefix of listed journal names. If empty, all journals are listed.
+	Prefix Name
+	// Labels which listed journals must have, with equal values.
+	Labels map[string]string
+}
+
+// ListedJournal describes a journal returned by a list operation.
+type ListedJournal struct {
+	Name Name `json:"name"`
+	// Current RouteToken of the journal. Empty if the journal has no broker.
+	RouteToken RouteToken `json:"route_token"`
+	// Current write head of the journal, or -1 if it couldn't be determined.
+	WriteHead int64 `json:"write_head"`
+	// Labels of the journal's JournalSpec.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// FragmentDisposition determines the handling of a deleted journal's
+// persisted fragments.
+type FragmentDisposition string
+
+const (
+	// Fragments are left in place.
+	KeepFragments FragmentDisposition = "keep"
+	// Fragments are removed.
+	DeleteFragments FragmentDisposition = "delete"
+	// Fragments are moved beneath DeleteArgs.Destination.
+	MoveFragments FragmentDisposition = "move"
+)
+
+type DeleteArgs struct {
+	Journal Name `json:"-"`
+	// Disposition of the journal's persisted fragments. If empty, fragments
+	// are kept.
+	Fragments FragmentDisposition `json:"fragments,omitempty"`
+	// Iff |Fragments| is MoveFragments, the prefix under which fragments are
+	// moved. A fragment of journal "a/b" is moved to journal "<Destination>/a/b",
+	// and may be read by creating that journal.
+	Destination Name `json:"destination,omitempty"`
+}
+
+// Validate returns an error if the DeleteArgs are malformed.
+func (a DeleteArgs) Validate() error {
+	switch a.Fragments {
+	case "", KeepFragments, DeleteFragments:
+		if a.Destination != "" {
+			return fmt.Errorf("destination requires %q fragments (got %q)", MoveFragments, a.Fragments)
+		}
+	case MoveFragments:
+		if a.Destination == "" {
+			return fmt.Errorf("%q fragments requires a destination", MoveFragments)
+		}
+	default:
+		return fmt.Errorf("invalid fragment disposition: %q", a.Fragments)
+	}
+	return nil
+}
+
+// Returns the journal to which fragments of |name| are moved, or |name| itself
+// if fragments are not moved.
+func (a DeleteArgs) FragmentJournal(name Name) Name {
+	if a.Fragments != MoveFragments {
+		return name
+	}
+	return Name(path.Join(a.Destination.String(), name.String()))
+}
+
 // Represents an AppendOp which is being asynchronously executed.
 type AsyncAppend struct {
 	// Read-only, and valid only after Ready is signaled.
@@ -200,12 +300,16 @@ func StatusCodeForError(err error) int {
 		return http.StatusConflict // 409.
 	case ErrNotBroker:
 		return http.StatusGone // 410.
+	case ErrNotAuthorized:
+		return http.StatusForbidden // 403.
 	case ErrNotFound:
 		return http.StatusNotFound // 404.
 	case ErrNotReplica:
 		return http.StatusTemporaryRedirect // 307.
 	case ErrNotYetAvailable:
 		return http.StatusRequestedRangeNotSatisfiable // 416.
+	case ErrRateLimited:
+		return http.StatusTooManyRequests // 429.
 	case ErrReplicationFailed:
 		return http.StatusServiceUnavailable // 503.
 	case ErrWrongRouteToken:
@@ -239,12 +343,16 @@ func ErrorFromResponse(response *http.Response) error {
 		return ErrExists
 	case http.StatusGone: // 410.
 		return ErrNotBroker
+	case http.StatusForbidden: // 403.
+		return ErrNotAuthorized
 	case http.StatusNotFound: // 404.
 		return ErrNotFound
 	case http.StatusTemporaryRedirect: // 307.
 		return ErrNotReplica
 	case http.StatusRequestedRangeNotSatisfiable: // 416.
 		return ErrNotYetAvailable
+	case http.StatusTooManyRequests: // 429.
+		return ErrRateLimited
 	case http.StatusServiceUnavailable: // 503.
 		return ErrReplicationFailed
 	case http.StatusProxyAuthRequired: // 407.
@@ -259,3 +367,65 @@ func ErrorFromResponse(response *http.Response) error {
 		}
 	}
 }
+
+// Maps Journal protocol errors into a corresponding Status of the Journal gRPC
+// service. |ok| is false if |err| is not a protocol error.
+func StatusForError(err error) (status Status, ok bool) {
+	switch err {
+	case nil:
+		return Status_OK, true
+	case ErrExists:
+		return Status_EXISTS, true
+	case ErrNotBroker:
+		return Status_NOT_BROKER, true
+	case ErrNotAuthorized:
+		return Status_NOT_AUTHORIZED, true
+	case ErrNotFound:
+		return Status_NOT_FOUND, true
+	case ErrNotReplica:
+		return Status_NOT_REPLICA, true
+	case ErrNotYetAvailable:
+		return Status_NOT_YET_AVAILABLE, true
+	case ErrRateLimited:
+		return Status_RATE_LIMITED, true
+	case ErrReplicationFailed:
+		return Status_REPLICATION_FAILED, true
+	case ErrWrongRouteToken:
+		return Status_WRONG_ROUTE_TOKEN, true
+	case ErrWrongWriteHead:
+		return Status_WRONG_WRITE_HEAD, true
+	default:
+		return Status_OK, false
+	}
+}
+
+// Maps a Status of the Journal gRPC service into a corresponding Journal
+// protocol error, or nil. Unknown statuses are converted into an error.
+func ErrorForStatus(status Status) error {
+	switch status {
+	case Status_OK:
+		return nil
+	case Status_EXISTS:
+		return ErrExists
+	case Status_NOT_BROKER:
+		return ErrNotBroker
+	case Status_NOT_AUTHORIZED:
+		return ErrNotAuthorized
+	case Status_NOT_FOUND:
+		return ErrNotFound
+	case Status_NOT_REPLICA:
+		return ErrNotReplica
+	case Status_NOT_YET_AVAILABLE:
+		return ErrNotYetAvailable
+	case Status_RATE_LIMITED:
+		return ErrRateLimited
+	case Status_REPLICATION_FAILED:
+		return ErrReplicationFailed
+	case Status_WRONG_ROUTE_TOKEN:
+		return ErrWrongRouteToken
+	case Status_WRONG_WRITE_HEAD:
+		return ErrWrongWriteHead
+	default:
+		return fmt.Errorf("unknown status (%s)", status)
+	}
+}
@@ -45,4 +45,26 @@ func (s *ProtocolSuite) TestErrorsAsStatusCodes(c *gc.C) {
 	c.Check(ErrorFromResponse(&response), gc.ErrorMatches, `error! \(body\)`)
 }
 
+func (s *ProtocolSuite) TestErrorsAsStatuses(c *gc.C) {
+	// Round-trip each protocol error.
+	for _, err := range protocolErrors {
+		var status, ok = StatusForError(err)
+		c.Check(ok, gc.Equals, true)
+		c.Check(status, gc.Not(gc.Equals), Status_OK)
+		c.Check(ErrorForStatus(status), gc.Equals, err)
+	}
+
+	var status, ok = StatusForError(nil)
+	c.Check(status, gc.Equals, Status_OK)
+	c.Check(ok, gc.Equals, true)
+	c.Check(ErrorForStatus(Status_OK), gc.IsNil)
+
+	// A novel error.
+	_, ok = StatusForError(errors.New("error!"))
+	c.Check(ok, gc.Equals, false)
+
+	// A novel, unknown status.
+	c.Check(ErrorForStatus(Status(1234)), gc.ErrorMatches, `unknown status \(1234\)`)
+}
+
 var _ = gc.Suite(&ProtocolSuite{})
@@ -1,6 +1,8 @@
 package journal
 
 import (
+	"time"
+
 	log "github.com/sirupsen/logrus"
 
 	"github.com/LiveRamp/gazette/pkg/cloudstore"
@@ -12,6 +14,7 @@ import (
 // replication requests only, to a broker of the journal.
 type Replica struct {
 	journal Name
+	cfs     cloudstore.FileSystem
 	// Fragment updates are written into |updates| by |index| and |head|,
 	// and are consumed by |tail|.
 	updates chan Fragment
@@ -19,24 +22,33 @@ type Replica struct {
 	index *IndexWatcher
 	// Serves fragment reads.
 	tail *Tail
+	// Expires and removes fragments which are no longer retained.
+	retainer *Retainer
 	// Serves replicated writes.
 	head *Head
 	// Brokers transactions which result in replicated writes to the journal.
 	broker *Broker
+	// Current specification of the journal.
+	spec JournalSpec
+	// Whether the Replica is currently the journal broker.
+	isBroker bool
 }
 
 func NewReplica(journal Name, localDir string, persister FragmentPersister,
-	cfs cloudstore.FileSystem) *Replica {
+	producers ProducerStore, cfs cloudstore.FileSystem) *Replica {
 
 	updates := make(chan Fragment, 1)
 	r := &Replica{
 		journal: journal,
+		cfs:     cfs,
 		updates: updates,
 		index:   NewIndexWatcher(journal, cfs, updates).StartWatchingIndex(),
 		tail:    NewTail(journal, updates).StartServingOps(),
 		head:    NewHead(journal, localDir, persister, updates),
 		broker:  NewBroker(journal),
 	}
+	r.broker.producers = producers
+	r.retainer = NewRetainer(journal, cfs, r.tail).StartRetaining()
 
 	// Defer writes until local fragments & the remote index are fully loaded.
 	go func() {
@@ -64,13 +76,39 @@ func (r *Replica) Replicate(op ReplicateOp) {
 
 func (r *Replica) Read(op ReadOp) {
 	r.index.WaitForInitialLoad()
+
+	if !op.Timestamp.IsZero() {
+		var offset, err = ResolveTimestamp(r.tail.Fragments(), op.Timestamp, r.cfs)
+		if err != nil {
+			log.WithFields(log.Fields{"journal": r.journal, "timestamp": op.Timestamp, "err": err}).
+				Warn("failed to resolve read timestamp")
+			op.Result <- ReadResult{Error: err}
+			return
+		}
+		op.Offset, op.Timestamp = offset, time.Time{}
+	}
 	r.tail.Read(op)
 }
 
+// Update the JournalSpec of the Replica. The spec is applied immediately to
+// replicated writes, and to brokered writes with the next call to
+// StartBrokeringWithPeers.
+func (r *Replica) UpdateSpec(spec JournalSpec) {
+	log.WithFields(log.Fields{"journal": r.journal, "spec": spec}).
+		Debug("updated spec")
+
+	r.spec = spec
+	r.head.UpdateSpec(spec)
+	r.retainer.UpdateConfig(RetainerConfig{Spec: spec, IsBroker: r.isBroker})
+}
+
 // Switch the Replica into pure-replica mode.
 func (r *Replica) StartReplicating(routeToken RouteToken) {
 	log.WithFields(log.Fields{"journal": r.journal, "route": routeToken}).
 		Debug("now replicating")
+
+	r.isBroker = false
+	r.retainer.UpdateConfig(RetainerConfig{Spec: r.spec, IsBroker: false})
 }
 
 // Switch the Replica into broker mode. Appends will be brokered to |peers| with
@@ -83,18 +121,30 @@ func (r *Replica) StartBrokeringWithPeers(routeToken RouteToken, peers []Replica
 	config.RouteToken = routeToken
 	config.WriteHead = r.tail.EndOffset()
 	config.Replicas = append(peers, r.head)
+	config.Spec = r.spec
 
 	r.broker.UpdateConfig(config)
+
+	r.isBroker = true
+	r.retainer.UpdateConfig(RetainerConfig{Spec: r.spec, IsBroker: true})
 }
 
-func (r *Replica) Shutdown() {
+// Shutdown begins an asynchronous shutdown of the Replica, and returns a
+// channel which is closed once shutdown completes. By then, the spool of the
+// Replica (if any) has been handed to its FragmentPersister.
+func (r *Replica) Shutdown() <-chan struct{} {
 	log.WithField("journal", r.journal).Debug("beginning journal shutdown")
+
+	var done = make(chan struct{})
 	go func() {
 		r.broker.Stop()
 		r.head.Stop()
 		r.index.Stop()
+		r.retainer.Stop()
 		close(r.updates)
 		r.tail.Stop()
 		log.WithField("journal", r.journal).Debug("completed journal shutdown")
+		close(done)
 	}()
+	return done
 }
@@ -0,0 +1,120 @@
+package journal
+
+import (
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/metrics"
+)
+
+const retainerPeriod = 5 * time.Minute
+
+// Retainer enforces the retention policy of a journal's JournalSpec. Each
+// period, it expires fragments which are no longer retained from the journal
+// Tail, such that reads of expired content skip ahead rather than fail. If the
+// Retainer's replica is the journal broker, fragments expired by the previous
+// period are then removed from the cloud filesystem. Removals lag expiry by a
+// period so that other replicas, which apply the same policy, have also
+// expired the fragments from their own Tails.
+type Retainer struct {
+	journal Name
+
+	cfs  cloudstore.FileSystem
+	tail *Tail
+
+	config  RetainerConfig
+	configs chan RetainerConfig
+	// Fragments expired by the last period, which are yet to be removed.
+	expired []Fragment
+
+	stop chan struct{}
+
+	// Effective constants, which are swappable for testing.
+	timeNow func() time.Time
+}
+
+// RetainerConfig is the configuration of a Retainer.
+type RetainerConfig struct {
+	// Current specification of the journal.
+	Spec JournalSpec
+	// Whether the Retainer's replica is the journal broker.
+	IsBroker bool
+}
+
+func NewRetainer(journal Name, cfs cloudstore.FileSystem, tail *Tail) *Retainer {
+	return &Retainer{
+		journal: journal,
+		cfs:     cfs,
+		tail:    tail,
+		configs: make(chan RetainerConfig, 16),
+		stop:    make(chan struct{}),
+		timeNow: time.Now,
+	}
+}
+
+func (r *Retainer) StartRetaining() *Retainer {
+	go r.loop()
+	return r
+}
+
+func (r *Retainer) UpdateConfig(config RetainerConfig) {
+	r.configs <- config
+}
+
+func (r *Retainer) Stop() {
+	close(r.configs)
+	<-r.stop // Blocks until loop() exits.
+}
+
+func (r *Retainer) loop() {
+	var ticker = time.NewTicker(retainerPeriod)
+
+	for done := false; !done; {
+		select {
+		case config, ok := <-r.configs:
+			if !ok {
+				done = true
+			} else {
+				r.config = config
+			}
+		case <-ticker.C:
+			r.onTick()
+		}
+	}
+	ticker.Stop()
+	close(r.stop)
+}
+
+func (r *Retainer) onTick() {
+	if r.config.IsBroker {
+		for _, fragment := range r.expired {
+			r.remove(fragment)
+		}
+	}
+	r.expired = r.tail.Expire(r.config.Spec, r.timeNow())
+}
+
+func (r *Retainer) remove(fragment Fragment) {
+	// Remove the fragment's TimeIndex first, such that it's not orphaned if
+	// the fragment itself was already removed.
+	if err := r.cfs.Remove(fragment.TimeIndexPath()); err != nil && !os.IsNotExist(err) {
+		log.WithFields(log.Fields{"path": fragment.TimeIndexPath(), "err": err}).
+			Warn("failed to remove expired fragment time index")
+	}
+
+	var err = r.cfs.Remove(fragment.ContentPath())
+	if os.IsNotExist(err) {
+		return // Already removed (eg, by a previous broker).
+	} else if err != nil {
+		log.WithFields(log.Fields{"path": fragment.ContentPath(), "err": err}).
+			Warn("failed to remove expired fragment")
+		return
+	}
+	log.WithField("path", fragment.ContentPath()).Info("removed expired fragment")
+
+	metrics.RetentionRemovedFragmentsTotal.Inc()
+	metrics.RetentionRemovedBytesTotal.Add(float64(fragment.Size()))
+}
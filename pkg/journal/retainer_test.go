@@ -0,0 +1,65 @@
+package journal
+
+import (
+	"os"
+	"time"
+
+	gc "github.com/go-check/check"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+)
+
+type RetainerSuite struct{}
+
+func (s *RetainerSuite) TestExpiredFragmentsAreRemovedByBroker(c *gc.C) {
+	var cfs = cloudstore.NewTmpFileSystem()
+	defer cfs.Close()
+
+	var updates = make(chan Fragment)
+	var tail = NewTail("a/journal", updates).StartServingOps()
+	var now = time.Unix(1500000000, 0)
+
+	var fragments = []Fragment{
+		{Journal: "a/journal", Begin: 0, End: 100, RemoteModTime: now.Add(-2 * time.Hour)},
+		{Journal: "a/journal", Begin: 100, End: 200, RemoteModTime: now},
+	}
+	c.Assert(cfs.MkdirAll("a/journal", 0750), gc.IsNil)
+	for _, f := range fragments {
+		var file, err = cfs.OpenFile(f.ContentPath(), os.O_WRONLY|os.O_CREATE, 0640)
+		c.Assert(err, gc.IsNil)
+		c.Assert(file.Close(), gc.IsNil)
+
+		updates <- f
+	}
+
+	var retainer = NewRetainer("a/journal", cfs, tail)
+	retainer.timeNow = func() time.Time { return now }
+	retainer.config = RetainerConfig{Spec: JournalSpec{Retention: time.Hour}}
+
+	// As a replica, the expired fragment is dropped from the Tail but not removed.
+	retainer.onTick()
+	c.Check(retainer.expired, gc.DeepEquals, fragments[:1])
+	c.Check(tail.EndOffset(), gc.Equals, int64(200))
+	c.Check(s.exists(cfs, fragments[0]), gc.Equals, true)
+
+	// As broker, fragments expired by the previous period are removed.
+	retainer.config.IsBroker = true
+	retainer.onTick()
+	c.Check(retainer.expired, gc.HasLen, 0)
+	c.Check(s.exists(cfs, fragments[0]), gc.Equals, false)
+	c.Check(s.exists(cfs, fragments[1]), gc.Equals, true)
+
+	close(updates)
+	tail.Stop()
+}
+
+func (s *RetainerSuite) exists(cfs cloudstore.FileSystem, fragment Fragment) bool {
+	var file, err = cfs.Open(fragment.ContentPath())
+	if err != nil {
+		return false
+	}
+	file.Close()
+	return true
+}
+
+var _ = gc.Suite(&RetainerSuite{})
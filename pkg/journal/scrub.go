@@ -0,0 +1,265 @@
+package journal
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+	"github.com/LiveRamp/gazette/pkg/metrics"
+)
+
+// FindingKind is the kind of a scrub Finding.
+type FindingKind string
+
+const (
+	// Fragment content could not be read or decompressed.
+	FindingUnreadable FindingKind = "unreadable"
+	// Fragment content is shorter than its offset range (eg, a partial upload).
+	FindingTruncated FindingKind = "truncated"
+	// Fragment content is longer than its offset range.
+	FindingOversized FindingKind = "oversized"
+	// Fragment content doesn't match the SHA1 sum of its content name.
+	FindingChecksumMismatch FindingKind = "checksum_mismatch"
+	// Journal offsets between fragments are not covered by any fragment.
+	FindingOffsetGap FindingKind = "offset_gap"
+)
+
+// Finding describes an integrity issue of persisted journal fragments.
+type Finding struct {
+	Kind    FindingKind `json:"kind"`
+	Journal Name        `json:"journal"`
+	// Content name of the fragment having the issue. For gaps, the fragment
+	// which follows the gap.
+	Fragment string `json:"fragment"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// ScrubStats summarizes a scrub of persisted fragments.
+type ScrubStats struct {
+	Journals  int `json:"journals"`
+	Fragments int `json:"fragments"`
+	// Number of journal bytes verified (before any decompression).
+	Bytes int64 `json:"bytes"`
+	// Number of fragments not verified, as a prior scrub verified them.
+	Skipped  int `json:"skipped"`
+	Findings int `json:"findings"`
+}
+
+// VerifyFragment streams persisted |fragment| from |cfs|, and verifies its
+// content length and SHA1 sum against those of its content name. A Finding is
+// returned if verification fails, or nil if the fragment is intact.
+func VerifyFragment(cfs cloudstore.FileSystem, fragment Fragment) *Finding {
+	var finding = &Finding{Journal: fragment.Journal, Fragment: fragment.ContentName()}
+
+	var rc, err = fragment.ReaderFromOffset(fragment.Begin, cfs)
+	if err != nil {
+		finding.Kind, finding.Detail = FindingUnreadable, err.Error()
+		return finding
+	}
+	defer rc.Close()
+
+	// Read up to one byte past the fragment's End, to detect oversized content.
+	var summer = sha1.New()
+	var n int64
+
+	if n, err = io.CopyN(summer, rc, fragment.Size()+1); err != nil && err != io.EOF {
+		finding.Kind, finding.Detail = FindingUnreadable, err.Error()
+	} else if n < fragment.Size() {
+		finding.Kind = FindingTruncated
+		finding.Detail = fmt.Sprintf("read %d of %d bytes", n, fragment.Size())
+	} else if n > fragment.Size() {
+		finding.Kind = FindingOversized
+	} else if sum := summer.Sum(nil); string(sum) != string(fragment.Sum[:]) {
+		finding.Kind = FindingChecksumMismatch
+		finding.Detail = fmt.Sprintf("content sum is %x", sum)
+	} else {
+		return nil
+	}
+	return finding
+}
+
+// CheckContinuity returns Findings for journal offsets not covered by any of
+// |fragments|, which must be ordered on Begin and End. Overlapping fragments are
+// expected (eg, replicas may persist spools which begin at different offsets)
+// and are not reported.
+func CheckContinuity(fragments []Fragment) []Finding {
+	if len(fragments) == 0 {
+		return nil
+	}
+	var findings []Finding
+	// Maximum End of fragments examined thus far. Offsets below it are covered.
+	var covered = fragments[0].End
+
+	for _, next := range fragments[1:] {
+		if next.Begin > covered {
+			findings = append(findings, Finding{
+				Kind:     FindingOffsetGap,
+				Journal:  next.Journal,
+				Fragment: next.ContentName(),
+				Detail:   fmt.Sprintf("offsets [%d, %d) are missing", covered, next.Begin),
+			})
+		}
+		if next.End > covered {
+			covered = next.End
+		}
+	}
+	return findings
+}
+
+// Scrub walks all persisted fragments of |cfs| under |prefix|. The fragments
+// of each journal are checked for continuity, and each fragment is verified.
+// Findings are passed to |onFinding|.
+func Scrub(cfs cloudstore.FileSystem, prefix string, onFinding func(Finding)) (ScrubStats, error) {
+	return (&Scrubber{cfs: cfs}).scrub(prefix, func(Name) bool { return true }, onFinding)
+}
+
+// ScrubJournal scrubs the persisted fragments of journal |name|, excluding
+// those of other journals nested beneath it. See Scrub.
+func ScrubJournal(cfs cloudstore.FileSystem, name Name, onFinding func(Finding)) (ScrubStats, error) {
+	return (&Scrubber{cfs: cfs}).ScrubJournal(name, onFinding)
+}
+
+// Scrubber repeatedly scrubs persisted fragments of journals. Unlike
+// ScrubJournal, a Scrubber verifies each intact fragment only once: later
+// scrubs check continuity of all fragments, but verify only fragments which
+// are new or had findings. Verification is throttled to a maximum rate of
+// fragment bytes per second.
+type Scrubber struct {
+	cfs cloudstore.FileSystem
+	// Maximum verified bytes per second, or zero if unlimited.
+	bytesPerSecond int64
+	// Content names of intact fragments, indexed on journal.
+	verified map[Name]map[string]struct{}
+
+	timeNow func() time.Time
+	sleep   func(time.Duration)
+}
+
+func NewScrubber(cfs cloudstore.FileSystem, bytesPerSecond int64) *Scrubber {
+	return &Scrubber{
+		cfs:            cfs,
+		bytesPerSecond: bytesPerSecond,
+		verified:       make(map[Name]map[string]struct{}),
+		timeNow:        time.Now,
+		sleep:          time.Sleep,
+	}
+}
+
+// ScrubJournal scrubs the persisted fragments of journal |name| not already
+// verified by the Scrubber. See ScrubJournal.
+func (s *Scrubber) ScrubJournal(name Name, onFinding func(Finding)) (ScrubStats, error) {
+	return s.scrub(name.String()+"/", func(n Name) bool { return n == name }, onFinding)
+}
+
+// Retain discards the state of journals other than |names|, such that the
+// Scrubber doesn't accumulate state of journals it no longer scrubs.
+func (s *Scrubber) Retain(names []Name) {
+	var retain = make(map[Name]struct{}, len(names))
+	for _, name := range names {
+		retain[name] = struct{}{}
+	}
+	for name := range s.verified {
+		if _, ok := retain[name]; !ok {
+			delete(s.verified, name)
+		}
+	}
+}
+
+func (s *Scrubber) scrub(prefix string, filter func(Name) bool,
+	onFinding func(Finding)) (ScrubStats, error) {
+
+	var stats ScrubStats
+	var journals = make(map[Name][]Fragment)
+
+	// Walk fragments directly, rather than with NewWalkFuncAdapter, which skips
+	// zero-length fragments that we'd like to report as truncated.
+	if err := s.cfs.Walk(prefix, func(fpath string, finfo os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		} else if finfo.IsDir() || strings.HasSuffix(fpath, TimeIndexExtension) {
+			return nil
+		}
+		var fragment, parseErr = ParseFragment(Name(path.Dir(fpath)), path.Base(fpath))
+		if parseErr != nil {
+			log.WithFields(log.Fields{"path": fpath, "err": parseErr}).Warn("parsing fragment")
+		} else if filter(fragment.Journal) {
+			journals[fragment.Journal] = append(journals[fragment.Journal], fragment)
+		}
+		return nil
+	}); err != nil && !os.IsNotExist(err) {
+		return stats, err
+	}
+
+	var report = func(finding Finding) {
+		stats.Findings++
+		metrics.ScrubFindingsTotal.WithLabelValues(string(finding.Kind)).Inc()
+		onFinding(finding)
+	}
+
+	for name, fragments := range journals {
+		sort.Slice(fragments, func(i, j int) bool {
+			if fragments[i].Begin != fragments[j].Begin {
+				return fragments[i].Begin < fragments[j].Begin
+			}
+			return fragments[i].End < fragments[j].End
+		})
+		for _, finding := range CheckContinuity(fragments) {
+			report(finding)
+		}
+
+		// Intact fragments of this scrub. Fragments verified by a prior scrub
+		// but since removed (eg, by retention) are dropped.
+		var verified = make(map[string]struct{})
+
+		for _, fragment := range fragments {
+			var contentName = fragment.ContentName()
+
+			if _, ok := s.verified[name][contentName]; ok {
+				verified[contentName] = struct{}{}
+				stats.Skipped++
+				continue
+			}
+
+			var started time.Time
+			if s.bytesPerSecond != 0 {
+				started = s.timeNow()
+			}
+			if finding := VerifyFragment(s.cfs, fragment); finding != nil {
+				report(*finding)
+			} else {
+				verified[contentName] = struct{}{}
+			}
+			stats.Fragments++
+			stats.Bytes += fragment.Size()
+
+			metrics.ScrubbedFragmentsTotal.Inc()
+			metrics.ScrubbedBytesTotal.Add(float64(fragment.Size()))
+
+			if s.bytesPerSecond != 0 {
+				s.throttle(fragment.Size(), s.timeNow().Sub(started))
+			}
+		}
+		if s.verified != nil {
+			s.verified[name] = verified
+		}
+		stats.Journals++
+	}
+	return stats, nil
+}
+
+// throttle sleeps for the remainder of the time which verifying |size| bytes
+// should take at the Scrubber's rate, given verification took |elapsed|.
+func (s *Scrubber) throttle(size int64, elapsed time.Duration) {
+	var budget = time.Duration(float64(size) / float64(s.bytesPerSecond) * float64(time.Second))
+	if budget > elapsed {
+		s.sleep(budget - elapsed)
+	}
+}
@@ -0,0 +1,166 @@
+package journal
+
+import (
+	"crypto/sha1"
+	"os"
+	"time"
+
+	gc "github.com/go-check/check"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+)
+
+type ScrubSuite struct {
+	cfs cloudstore.FileSystem
+}
+
+func (s *ScrubSuite) SetUpTest(c *gc.C) {
+	s.cfs = cloudstore.NewTmpFileSystem()
+}
+
+func (s *ScrubSuite) TearDownTest(c *gc.C) {
+	c.Check(s.cfs.Close(), gc.IsNil)
+}
+
+func (s *ScrubSuite) TestVerifyFragment(c *gc.C) {
+	var intact = s.write(c, "a/journal", 0, "hello, world", "hello, world")
+	c.Check(VerifyFragment(s.cfs, intact), gc.IsNil)
+
+	var truncated = s.write(c, "a/journal", 100, "hello, world", "hello")
+	c.Check(VerifyFragment(s.cfs, truncated), gc.DeepEquals, &Finding{
+		Kind:     FindingTruncated,
+		Journal:  "a/journal",
+		Fragment: truncated.ContentName(),
+		Detail:   "read 5 of 12 bytes",
+	})
+
+	var oversized = s.write(c, "a/journal", 200, "hello", "hello, world")
+	c.Check(VerifyFragment(s.cfs, oversized).Kind, gc.Equals, FindingOversized)
+
+	var corrupt = s.write(c, "a/journal", 300, "hello, world", "jello, world")
+	c.Check(VerifyFragment(s.cfs, corrupt).Kind, gc.Equals, FindingChecksumMismatch)
+
+	var missing = Fragment{Journal: "a/journal", Begin: 400, End: 410}
+	c.Check(VerifyFragment(s.cfs, missing).Kind, gc.Equals, FindingUnreadable)
+}
+
+func (s *ScrubSuite) TestCheckContinuity(c *gc.C) {
+	var fragments = []Fragment{
+		{Journal: "a/journal", Begin: 0, End: 100},
+		{Journal: "a/journal", Begin: 100, End: 200},
+		{Journal: "a/journal", Begin: 250, End: 300},
+		{Journal: "a/journal", Begin: 280, End: 400},
+	}
+	c.Check(CheckContinuity(fragments[:2]), gc.HasLen, 0)
+	// Overlapping fragments are not reported.
+	c.Check(CheckContinuity(fragments), gc.DeepEquals, []Finding{
+		{
+			Kind:     FindingOffsetGap,
+			Journal:  "a/journal",
+			Fragment: fragments[2].ContentName(),
+			Detail:   "offsets [200, 250) are missing",
+		},
+	})
+	c.Check(CheckContinuity(nil), gc.HasLen, 0)
+}
+
+func (s *ScrubSuite) TestCheckContinuityOfNestedFragments(c *gc.C) {
+	// [10, 20) is nested within [0, 100), which also covers offsets through
+	// the next fragment's Begin.
+	var fragments = []Fragment{
+		{Journal: "a/journal", Begin: 0, End: 100},
+		{Journal: "a/journal", Begin: 10, End: 20},
+		{Journal: "a/journal", Begin: 100, End: 200},
+	}
+	c.Check(CheckContinuity(fragments), gc.HasLen, 0)
+
+	fragments = append(fragments, Fragment{Journal: "a/journal", Begin: 150, End: 160},
+		Fragment{Journal: "a/journal", Begin: 210, End: 220})
+	c.Check(CheckContinuity(fragments), gc.DeepEquals, []Finding{
+		{
+			Kind:     FindingOffsetGap,
+			Journal:  "a/journal",
+			Fragment: fragments[4].ContentName(),
+			Detail:   "offsets [200, 210) are missing",
+		},
+	})
+}
+
+func (s *ScrubSuite) TestScrubJournal(c *gc.C) {
+	s.write(c, "a/journal", 0, "hello, ", "hello, ")
+	s.write(c, "a/journal", 7, "world", "word")
+	s.write(c, "a/journal", 20, "!", "!")
+	s.write(c, "a/journal", 21, "?", "") // Zero-length partial upload.
+	// Fragments of a nested journal are not scrubbed with their parent.
+	s.write(c, "a/journal/nested", 0, "hello", "jello")
+
+	var findings []Finding
+	var stats, err = ScrubJournal(s.cfs, "a/journal", func(f Finding) {
+		findings = append(findings, f)
+	})
+	c.Check(err, gc.IsNil)
+	c.Check(stats, gc.Equals, ScrubStats{Journals: 1, Fragments: 4, Bytes: 14, Findings: 3})
+
+	c.Assert(findings, gc.HasLen, 3)
+	c.Check(findings[0].Kind, gc.Equals, FindingOffsetGap)
+	c.Check(findings[1].Kind, gc.Equals, FindingTruncated)
+	c.Check(findings[2].Kind, gc.Equals, FindingTruncated)
+
+	// Scrubbing by prefix includes the nested journal.
+	stats, err = Scrub(s.cfs, "a/", func(Finding) {})
+	c.Check(err, gc.IsNil)
+	c.Check(stats, gc.Equals, ScrubStats{Journals: 2, Fragments: 5, Bytes: 19, Findings: 4})
+}
+
+func (s *ScrubSuite) TestScrubberVerifiesFragmentsOnce(c *gc.C) {
+	var slept time.Duration
+	var scrubber = NewScrubber(s.cfs, 10)
+	scrubber.timeNow = func() time.Time { return time.Unix(1500000000, 0) }
+	scrubber.sleep = func(d time.Duration) { slept += d }
+
+	s.write(c, "a/journal", 0, "hello, ", "hello, ")
+	s.write(c, "a/journal", 7, "world", "word")
+
+	var stats, err = scrubber.ScrubJournal("a/journal", func(Finding) {})
+	c.Check(err, gc.IsNil)
+	c.Check(stats, gc.Equals, ScrubStats{Journals: 1, Fragments: 2, Bytes: 12, Findings: 1})
+	// Verification was throttled to 10 bytes per second.
+	c.Check(slept, gc.Equals, 1200*time.Millisecond)
+
+	// A new fragment is persisted. Only it, and the truncated fragment, are
+	// verified by the next scrub.
+	s.write(c, "a/journal", 12, "!", "!")
+
+	stats, err = scrubber.ScrubJournal("a/journal", func(Finding) {})
+	c.Check(err, gc.IsNil)
+	c.Check(stats, gc.Equals, ScrubStats{Journals: 1, Fragments: 2, Bytes: 6, Skipped: 1, Findings: 1})
+
+	// Retaining other journals discards verifications of "a/journal".
+	scrubber.Retain([]Name{"other/journal"})
+
+	stats, err = scrubber.ScrubJournal("a/journal", func(Finding) {})
+	c.Check(err, gc.IsNil)
+	c.Check(stats, gc.Equals, ScrubStats{Journals: 1, Fragments: 3, Bytes: 13, Findings: 1})
+}
+
+// write persists a fragment of |journal| at |begin|, named for |expect| but
+// having |content|.
+func (s *ScrubSuite) write(c *gc.C, journal Name, begin int64, expect, content string) Fragment {
+	var fragment = Fragment{
+		Journal: journal,
+		Begin:   begin,
+		End:     begin + int64(len(expect)),
+		Sum:     sha1.Sum([]byte(expect)),
+	}
+	c.Assert(s.cfs.MkdirAll(journal.String(), 0750), gc.IsNil)
+
+	var f, err = s.cfs.OpenFile(fragment.ContentPath(), os.O_WRONLY|os.O_CREATE, 0640)
+	c.Assert(err, gc.IsNil)
+	_, err = f.Write([]byte(content))
+	c.Assert(err, gc.IsNil)
+	c.Assert(f.Close(), gc.IsNil)
+
+	return fragment
+}
+
+var _ = gc.Suite(&ScrubSuite{})
@@ -0,0 +1,134 @@
+package journal
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// JournalSpec describes the desired configuration of a journal. Specs are
+// encoded as JSON and stored in Etcd alongside the journal's allocator item.
+// They may be provided when the journal is created and updated at any time
+// thereafter: brokers and replicas apply an updated spec as it's observed.
+// Zero-valued fields take a cluster or package default.
+type JournalSpec struct {
+	// Number of required journal replicas, in addition to the broker. If zero,
+	// the cluster-wide replica count is used.
+	Replication int `json:"replication,omitempty"`
+	// Target size of journal fragments, in bytes. Brokers begin a new spool
+	// once this many bytes have been written to the current one. If zero,
+	// DefaultFragmentSize is used.
+	FragmentSize int64 `json:"fragment_size,omitempty"`
+	// Interval after which brokers begin a new spool, regardless of its size.
+	// Spools are rolled at multiples of FlushInterval since the zero time, such
+	// that an interval of one hour rolls spools at the top of each hour. If
+	// zero, spools are rolled only on FragmentSize.
+	FlushInterval time.Duration `json:"flush_interval,omitempty"`
+	// Duration for which written journal content must be retained. Fragments
+	// older than Retention need not be persisted, and persisted fragments older
+	// than Retention are removed by the journal's broker. If zero, content is
+	// retained without regard to its age.
+	Retention time.Duration `json:"retention,omitempty"`
+	// Number of trailing journal bytes which must be retained. Persisted
+	// fragments which end this many bytes or more before the journal write
+	// head are removed by the journal's broker. If zero, content is retained
+	// without regard to its offset.
+	RetentionBytes int64 `json:"retention_bytes,omitempty"`
+	// Codec with which fragments are compressed as they're persisted. If zero,
+	// fragments are persisted uncompressed.
+	CompressionCodec CompressionCodec `json:"compression_codec,omitempty"`
+	// Labels are arbitrary key/value pairs which describe the journal, and by
+	// which journals may be selected when listed. Keys must be non-empty, and
+	// may not contain '='.
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// DefaultFragmentSize is the FragmentSize of a JournalSpec which doesn't
+// specify one.
+const DefaultFragmentSize = kSpoolRollSize
+
+var (
+	ErrInvalidReplication    = errors.New("invalid journal replication")
+	ErrInvalidFragmentSize   = errors.New("invalid journal fragment size")
+	ErrInvalidFlushInterval  = errors.New("invalid journal flush interval")
+	ErrInvalidRetention      = errors.New("invalid journal retention")
+	ErrInvalidRetentionBytes = errors.New("invalid journal retention bytes")
+	ErrInvalidLabel          = errors.New("invalid journal label")
+)
+
+// Validate returns an error if the JournalSpec is malformed.
+func (s JournalSpec) Validate() error {
+	if s.Replication < 0 {
+		return ErrInvalidReplication
+	} else if s.FragmentSize < 0 {
+		return ErrInvalidFragmentSize
+	} else if s.FlushInterval < 0 {
+		return ErrInvalidFlushInterval
+	} else if s.Retention < 0 {
+		return ErrInvalidRetention
+	} else if s.RetentionBytes < 0 {
+		return ErrInvalidRetentionBytes
+	} else if err := s.CompressionCodec.Validate(); err != nil {
+		return err
+	}
+	for key := range s.Labels {
+		if key == "" || strings.IndexByte(key, '=') != -1 {
+			return ErrInvalidLabel
+		}
+	}
+	return nil
+}
+
+// MatchesLabels returns whether the JournalSpec has each of |labels|, with
+// equal values.
+func (s JournalSpec) MatchesLabels(labels map[string]string) bool {
+	for key, value := range labels {
+		if v, ok := s.Labels[key]; !ok || v != value {
+			return false
+		}
+	}
+	return true
+}
+
+// fragmentSize returns the effective FragmentSize of the JournalSpec.
+func (s JournalSpec) fragmentSize() int64 {
+	if s.FragmentSize == 0 {
+		return DefaultFragmentSize
+	}
+	return s.FragmentSize
+}
+
+// flushDeadline returns the time at which a spool begun at |began| should be
+// rolled, or the zero Time if the JournalSpec has no FlushInterval.
+func (s JournalSpec) flushDeadline(began time.Time) time.Time {
+	if s.FlushInterval == 0 {
+		return time.Time{}
+	}
+	return began.Truncate(s.FlushInterval).Add(s.FlushInterval)
+}
+
+// expiredFragments returns the number of leading fragments of |set| which are
+// no longer retained under the JournalSpec as of |now|. Only persisted
+// fragments expire, and a fragment expires only if all fragments before it
+// have also expired, such that retained content is always a suffix of the
+// journal. The final fragment is always retained, as it marks the journal's
+// write head.
+func (s JournalSpec) expiredFragments(set FragmentSet, now time.Time) int {
+	if s.Retention == 0 && s.RetentionBytes == 0 {
+		return 0
+	}
+	var n int
+	for ; n < len(set)-1; n++ {
+		var f = set[n]
+
+		if f.RemoteModTime.IsZero() {
+			break // Not yet persisted.
+		} else if s.Retention != 0 && f.RemoteModTime.Before(now.Add(-s.Retention)) {
+			continue
+		} else if s.RetentionBytes != 0 && f.End <= set.EndOffset()-s.RetentionBytes {
+			continue
+		}
+		break
+	}
+	return n
+}
@@ -0,0 +1,89 @@
+package journal
+
+import (
+	"time"
+
+	gc "github.com/go-check/check"
+)
+
+type SpecSuite struct{}
+
+func (s *SpecSuite) TestValidation(c *gc.C) {
+	c.Check(JournalSpec{}.Validate(), gc.IsNil)
+	c.Check(JournalSpec{Replication: 2, FragmentSize: 1024, FlushInterval: time.Hour,
+		Retention: 24 * time.Hour, CompressionCodec: CodecSnappy}.Validate(), gc.IsNil)
+
+	c.Check(JournalSpec{Replication: -1}.Validate(), gc.Equals, ErrInvalidReplication)
+	c.Check(JournalSpec{FragmentSize: -1}.Validate(), gc.Equals, ErrInvalidFragmentSize)
+	c.Check(JournalSpec{FlushInterval: -1}.Validate(), gc.Equals, ErrInvalidFlushInterval)
+	c.Check(JournalSpec{Retention: -1}.Validate(), gc.Equals, ErrInvalidRetention)
+	c.Check(JournalSpec{RetentionBytes: -1}.Validate(), gc.Equals, ErrInvalidRetentionBytes)
+	c.Check(JournalSpec{CompressionCodec: "lzma"}.Validate(), gc.Equals, ErrInvalidCodec)
+	c.Check(JournalSpec{Labels: map[string]string{"": "value"}}.Validate(),
+		gc.Equals, ErrInvalidLabel)
+	c.Check(JournalSpec{Labels: map[string]string{"a=b": "value"}}.Validate(),
+		gc.Equals, ErrInvalidLabel)
+	c.Check(JournalSpec{Labels: map[string]string{"topic": "a=b"}}.Validate(), gc.IsNil)
+	c.Check(JournalSpec{CompressionCodec: CodecGzip,
+		Labels: map[string]string{"a=b": "value"}}.Validate(), gc.Equals, ErrInvalidLabel)
+}
+
+func (s *SpecSuite) TestMatchesLabels(c *gc.C) {
+	var spec = JournalSpec{Labels: map[string]string{"topic": "foo", "env": "prod"}}
+
+	c.Check(spec.MatchesLabels(nil), gc.Equals, true)
+	c.Check(spec.MatchesLabels(map[string]string{"topic": "foo"}), gc.Equals, true)
+	c.Check(spec.MatchesLabels(map[string]string{"topic": "foo", "env": "prod"}), gc.Equals, true)
+	c.Check(spec.MatchesLabels(map[string]string{"topic": "bar"}), gc.Equals, false)
+	c.Check(spec.MatchesLabels(map[string]string{"topic": ""}), gc.Equals, false)
+	c.Check(spec.MatchesLabels(map[string]string{"other": "foo"}), gc.Equals, false)
+
+	c.Check(JournalSpec{}.MatchesLabels(nil), gc.Equals, true)
+	c.Check(JournalSpec{}.MatchesLabels(map[string]string{"topic": "foo"}), gc.Equals, false)
+}
+
+func (s *SpecSuite) TestFlushDeadlineAlignment(c *gc.C) {
+	var began = time.Date(2018, 1, 1, 10, 31, 15, 0, time.UTC)
+
+	c.Check(JournalSpec{}.flushDeadline(began).IsZero(), gc.Equals, true)
+
+	c.Check(JournalSpec{FlushInterval: time.Hour}.flushDeadline(began), gc.Equals,
+		time.Date(2018, 1, 1, 11, 0, 0, 0, time.UTC))
+	c.Check(JournalSpec{FlushInterval: 15 * time.Minute}.flushDeadline(began), gc.Equals,
+		time.Date(2018, 1, 1, 10, 45, 0, 0, time.UTC))
+	c.Check(JournalSpec{FlushInterval: 24 * time.Hour}.flushDeadline(began), gc.Equals,
+		time.Date(2018, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	// A spool begun exactly on a boundary is flushed at the next one.
+	c.Check(JournalSpec{FlushInterval: time.Hour}.flushDeadline(
+		time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)), gc.Equals,
+		time.Date(2018, 1, 1, 11, 0, 0, 0, time.UTC))
+}
+
+func (s *SpecSuite) TestExpiredFragments(c *gc.C) {
+	var now = time.Date(2018, 1, 10, 0, 0, 0, 0, time.UTC)
+	var set = FragmentSet{
+		{Begin: 0, End: 100, RemoteModTime: now.Add(-72 * time.Hour)},
+		{Begin: 100, End: 200, RemoteModTime: now.Add(-48 * time.Hour)},
+		{Begin: 200, End: 300, RemoteModTime: now.Add(-24 * time.Hour)},
+		{Begin: 300, End: 400}, // Not yet persisted.
+		{Begin: 400, End: 500, RemoteModTime: now.Add(-96 * time.Hour)},
+	}
+	c.Check(JournalSpec{}.expiredFragments(set, now), gc.Equals, 0)
+
+	// Expire on age.
+	c.Check(JournalSpec{Retention: 36 * time.Hour}.expiredFragments(set, now), gc.Equals, 2)
+	c.Check(JournalSpec{Retention: time.Hour}.expiredFragments(set, now), gc.Equals, 3)
+	// Expire on bytes.
+	c.Check(JournalSpec{RetentionBytes: 350}.expiredFragments(set, now), gc.Equals, 1)
+	c.Check(JournalSpec{RetentionBytes: 1000}.expiredFragments(set, now), gc.Equals, 0)
+	// Fragments expire under either limit.
+	c.Check(JournalSpec{Retention: 60 * time.Hour, RetentionBytes: 300}.
+		expiredFragments(set, now), gc.Equals, 2)
+	// Unpersisted fragments and those after them never expire.
+	c.Check(JournalSpec{RetentionBytes: 1}.expiredFragments(set, now), gc.Equals, 3)
+	// The final fragment is always retained.
+	c.Check(JournalSpec{Retention: time.Hour}.expiredFragments(set[:3], now), gc.Equals, 2)
+}
+
+var _ = gc.Suite(&SpecSuite{})
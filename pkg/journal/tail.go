@@ -23,6 +23,12 @@ type Tail struct {
 	readOps   chan ReadOp
 	updates   <-chan Fragment
 	endOffset chan int64
+	expireOps chan expireOp
+	snapshots chan chan FragmentSet
+
+	// Offset below which journal content has expired. Reads of lesser offsets
+	// skip to |expiredOffset|, and fragments ending at or before it are ignored.
+	expiredOffset int64
 
 	// Reads which can't (yet) be satisfied by a fragment in |fragments|.
 	blockedReads []ReadOp
@@ -34,12 +40,22 @@ type Tail struct {
 	stop chan struct{}
 }
 
+// expireOp requests that the Tail expire fragments not retained under |spec|
+// as of |now|. Expired fragments are sent to |result|.
+type expireOp struct {
+	spec   JournalSpec
+	now    time.Time
+	result chan []Fragment
+}
+
 func NewTail(journal Name, updates <-chan Fragment) *Tail {
 	t := &Tail{
 		journal:   journal,
 		updates:   updates,
 		readOps:   make(chan ReadOp, kReadOpBufferSize),
 		endOffset: make(chan int64),
+		expireOps: make(chan expireOp),
+		snapshots: make(chan chan FragmentSet),
 		stop:      make(chan struct{}),
 	}
 	t.deadline.timer = time.NewTimer(0)
@@ -64,6 +80,22 @@ func (t *Tail) EndOffset() int64 {
 	return <-t.endOffset
 }
 
+// Fragments returns a copy of the Tail's current FragmentSet.
+func (t *Tail) Fragments() FragmentSet {
+	var result = make(chan FragmentSet, 1)
+	t.snapshots <- result
+	return <-result
+}
+
+// Expire removes fragments which are no longer retained under |spec| as of
+// |now| (see JournalSpec.Retention and RetentionBytes), and returns them.
+// Reads of expired offsets thereafter skip to the first retained offset.
+func (t *Tail) Expire(spec JournalSpec, now time.Time) []Fragment {
+	var op = expireOp{spec: spec, now: now, result: make(chan []Fragment, 1)}
+	t.expireOps <- op
+	return <-op.result
+}
+
 func (t *Tail) loop() {
 	for t.updates != nil || t.readOps != nil {
 		// Consume available fragment updates prior to serving reads.
@@ -98,6 +130,10 @@ func (t *Tail) loop() {
 			t.deadline.next = time.Time{}
 			t.wakeBlockedReads(done)
 		case t.endOffset <- t.fragments.EndOffset():
+		case op := <-t.expireOps:
+			op.result <- t.onExpire(op.spec, op.now)
+		case result := <-t.snapshots:
+			result <- append(FragmentSet(nil), t.fragments...)
 		}
 	}
 	close(t.endOffset) // After close(), EndOffset() will thereafter return 0.
@@ -111,10 +147,28 @@ func (t *Tail) onUpdate(fragment Fragment) {
 			"tail.journal": t.journal}).Error("unexpected fragment journal")
 		return
 	}
+	if fragment.End <= t.expiredOffset {
+		return // Content of |fragment| has expired.
+	}
 	t.fragments.Add(fragment)
 	t.wakeBlockedReads(time.Time{})
 }
 
+func (t *Tail) onExpire(spec JournalSpec, now time.Time) []Fragment {
+	var n = spec.expiredFragments(t.fragments, now)
+	if n == 0 {
+		return nil
+	}
+	var expired = append([]Fragment(nil), t.fragments[:n]...)
+
+	t.expiredOffset = expired[n-1].End
+	t.fragments = append(FragmentSet(nil), t.fragments[n:]...)
+
+	log.WithFields(log.Fields{"journal": t.journal, "offset": t.expiredOffset,
+		"fragments": n}).Info("expired journal fragments")
+	return expired
+}
+
 func (t *Tail) onRead(op ReadOp) {
 	if op.Journal != t.journal {
 		panic("wrong journal")
@@ -124,6 +178,10 @@ func (t *Tail) onRead(op ReadOp) {
 	if op.Offset == -1 {
 		op.Offset = t.fragments.EndOffset()
 	}
+	// Reads of expired content skip to the first retained offset.
+	if op.Offset < t.expiredOffset {
+		op.Offset = t.expiredOffset
+	}
 
 	// Attempt to find a covering fragment for the read.
 	ind := t.fragments.LongestOverlappingFragment(op.Offset)
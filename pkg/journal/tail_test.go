@@ -324,6 +324,42 @@ func (s *TailSuite) TestReadFromMiddleSkipsToFirstAvailable(c *gc.C) {
 	}
 }
 
+func (s *TailSuite) TestExpiredReadsSkipToRetainedOffset(c *gc.C) {
+	var now = time.Unix(1500000000, 0)
+	var fragments = []Fragment{
+		{Journal: "a/journal", Begin: 100, End: 200, RemoteModTime: now.Add(-2 * time.Hour)},
+		{Journal: "a/journal", Begin: 200, End: 300, RemoteModTime: now.Add(-2 * time.Hour)},
+		{Journal: "a/journal", Begin: 300, End: 400, RemoteModTime: now},
+	}
+	for _, f := range fragments {
+		s.updates <- f
+	}
+
+	c.Check(s.tail.Expire(JournalSpec{}, now), gc.HasLen, 0)
+	c.Check(s.tail.Expire(JournalSpec{Retention: time.Hour}, now), gc.DeepEquals, fragments[:2])
+
+	// An expired fragment which is re-discovered (eg, by a racing IndexWatcher
+	// listing) is ignored.
+	s.updates <- fragments[1]
+
+	var results = make(chan ReadResult)
+	s.tail.Read(ReadOp{
+		ReadArgs: ReadArgs{
+			Journal: "a/journal",
+			Offset:  150,
+			Context: context.Background(),
+		},
+		Result: results,
+	})
+	// Expect the read skips to the first retained offset, even though the
+	// retained fragment is new.
+	c.Check(<-results, gc.DeepEquals, ReadResult{
+		Offset:    300,
+		WriteHead: 400,
+		Fragment:  fragments[2],
+	})
+}
+
 func (s *TailSuite) TestEndOffsetGenerator(c *gc.C) {
 	c.Check(s.tail.EndOffset(), gc.Equals, int64(0))
 	s.updates <- Fragment{Journal: "a/journal", Begin: 100, End: 200}
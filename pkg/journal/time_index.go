@@ -0,0 +1,121 @@
+package journal
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+)
+
+// Resolution of TimeIndex entries. A commit is indexed only if it occurs at
+// least this long after the last indexed commit.
+const kTimeIndexResolution = time.Second
+
+// TimeIndexExtension is the extension of a persisted fragment's TimeIndex,
+// which is stored alongside the fragment's content (see Fragment.TimeIndexPath).
+const TimeIndexExtension = ".times"
+
+// CommitTime records that journal content beginning at Offset was committed
+// at Time.
+type CommitTime struct {
+	Offset int64     `json:"offset"`
+	Time   time.Time `json:"time"`
+}
+
+// TimeIndex is a sparse index of the times at which fragment content was
+// committed, ordered on Offset. Commits occurring within kTimeIndexResolution
+// of the last indexed commit are not themselves indexed.
+type TimeIndex []CommitTime
+
+// record indexes a commit of content beginning at |offset|, at time |t|.
+func (x TimeIndex) record(offset int64, t time.Time) TimeIndex {
+	if l := len(x); l != 0 && t.Before(x[l-1].Time.Add(kTimeIndexResolution)) {
+		return x
+	}
+	return append(x, CommitTime{Offset: offset, Time: t})
+}
+
+// offsetOf returns the first offset indexed by the TimeIndex which was
+// committed at or after |t|, or false if all indexed content was committed
+// before |t|. The returned offset may precede content committed at |t| by up
+// to kTimeIndexResolution, but never follows it.
+func (x TimeIndex) offsetOf(t time.Time) (int64, bool) {
+	var i = sort.Search(len(x), func(i int) bool { return !x[i].Time.Before(t) })
+
+	// Commits following entry |i-1| which weren't indexed occurred within
+	// kTimeIndexResolution of it, and may be at or after |t|.
+	if i != 0 && t.Before(x[i-1].Time.Add(kTimeIndexResolution)) {
+		return x[i-1].Offset, true
+	} else if i == len(x) {
+		return 0, false
+	}
+	return x[i].Offset, true
+}
+
+// LoadTimeIndex loads the persisted TimeIndex of remote |fragment| from |cfs|.
+// A nil TimeIndex is returned if |fragment| has no persisted TimeIndex.
+func LoadTimeIndex(cfs cloudstore.FileSystem, fragment Fragment) (TimeIndex, error) {
+	var file, err = cfs.Open(fragment.TimeIndexPath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var index TimeIndex
+	if b, err := ioutil.ReadAll(file); err != nil {
+		return nil, err
+	} else if err = json.Unmarshal(b, &index); err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// StoreTimeIndex persists |index| as the TimeIndex of |fragment| to |cfs|.
+func StoreTimeIndex(cfs cloudstore.FileSystem, fragment Fragment, index TimeIndex) error {
+	var b, err = json.Marshal(index)
+	if err != nil {
+		return err
+	}
+	w, err := cfs.OpenFile(fragment.TimeIndexPath(), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0640)
+	if err != nil {
+		return err
+	}
+	_, err = cfs.CopyAtomic(w, bytes.NewReader(b))
+	return err
+}
+
+// ResolveTimestamp returns the first offset of fragments |set| committed at or
+// after |t|, to within kTimeIndexResolution. Content committed at or after |t|
+// is never skipped. The TimeIndex of a remote fragment is loaded from |cfs| as
+// required. A fragment having no TimeIndex is conservatively resolved to its
+// Begin, unless it was persisted before |t|. If all content of |set| was
+// committed before |t|, its EndOffset is returned.
+func ResolveTimestamp(set FragmentSet, t time.Time, cfs cloudstore.FileSystem) (int64, error) {
+	for _, fragment := range set {
+		// Persisted fragments are modified only after all of their content is
+		// committed. Skip fragments which were persisted before |t|.
+		if !fragment.RemoteModTime.IsZero() && fragment.RemoteModTime.Before(t) {
+			continue
+		}
+		var index = fragment.TimeIndex
+
+		if index == nil && !fragment.IsLocal() {
+			var err error
+			if index, err = LoadTimeIndex(cfs, fragment); err != nil {
+				return 0, err
+			}
+		}
+		if index == nil {
+			return fragment.Begin, nil
+		} else if offset, ok := index.offsetOf(t); ok {
+			return offset, nil
+		}
+	}
+	return set.EndOffset(), nil
+}
@@ -0,0 +1,101 @@
+package journal
+
+import (
+	"os"
+	"time"
+
+	gc "github.com/go-check/check"
+
+	"github.com/LiveRamp/gazette/pkg/cloudstore"
+)
+
+type TimeIndexSuite struct{}
+
+func (s *TimeIndexSuite) TestRecordIsSparse(c *gc.C) {
+	var t0 = time.Unix(1500000000, 0)
+	var index TimeIndex
+
+	index = index.record(100, t0)
+	index = index.record(110, t0.Add(500*time.Millisecond)) // Within resolution.
+	index = index.record(120, t0.Add(time.Second))
+	index = index.record(130, t0.Add(5*time.Second))
+
+	c.Check(index, gc.DeepEquals, TimeIndex{
+		{Offset: 100, Time: t0},
+		{Offset: 120, Time: t0.Add(time.Second)},
+		{Offset: 130, Time: t0.Add(5 * time.Second)},
+	})
+}
+
+func (s *TimeIndexSuite) TestOffsetOf(c *gc.C) {
+	var t0 = time.Unix(1500000000, 0)
+	var index = TimeIndex{
+		{Offset: 100, Time: t0},
+		{Offset: 200, Time: t0.Add(10 * time.Second)},
+	}
+	var cases = []struct {
+		t      time.Time
+		offset int64
+		ok     bool
+	}{
+		{t0.Add(-time.Second), 100, true},
+		{t0, 100, true},
+		// Un-indexed commits within resolution of an entry may follow |t|.
+		{t0.Add(500 * time.Millisecond), 100, true},
+		{t0.Add(2 * time.Second), 200, true},
+		{t0.Add(10 * time.Second), 200, true},
+		{t0.Add(10*time.Second + 500*time.Millisecond), 200, true},
+		{t0.Add(12 * time.Second), 0, false},
+	}
+	for _, tc := range cases {
+		var offset, ok = index.offsetOf(tc.t)
+		c.Check(offset, gc.Equals, tc.offset)
+		c.Check(ok, gc.Equals, tc.ok)
+	}
+}
+
+func (s *TimeIndexSuite) TestResolveTimestamp(c *gc.C) {
+	var cfs = cloudstore.NewTmpFileSystem()
+	defer cfs.Close()
+
+	var t0 = time.Unix(1500000000, 0)
+	var set = FragmentSet{
+		// Persisted, with a stored TimeIndex.
+		{Journal: "a/journal", Begin: 0, End: 100, RemoteModTime: t0.Add(20 * time.Second)},
+		// Persisted, without a TimeIndex.
+		{Journal: "a/journal", Begin: 100, End: 200, RemoteModTime: t0.Add(40 * time.Second)},
+		// Local, with an in-memory TimeIndex.
+		{Journal: "a/journal", Begin: 200, End: 300, File: new(os.File), TimeIndex: TimeIndex{
+			{Offset: 200, Time: t0.Add(50 * time.Second)},
+			{Offset: 250, Time: t0.Add(60 * time.Second)},
+		}},
+	}
+	c.Assert(cfs.MkdirAll("a/journal", 0750), gc.IsNil)
+	c.Assert(StoreTimeIndex(cfs, set[0], TimeIndex{
+		{Offset: 0, Time: t0},
+		{Offset: 50, Time: t0.Add(10 * time.Second)},
+	}), gc.IsNil)
+
+	var cases = []struct {
+		t      time.Time
+		offset int64
+	}{
+		{t0.Add(-time.Second), 0},
+		{t0.Add(5 * time.Second), 50},
+		// Fragment [100, 200) has no TimeIndex, and is conservatively resolved.
+		{t0.Add(15 * time.Second), 100},
+		{t0.Add(30 * time.Second), 100},
+		// Fragment [100, 200) was persisted before these times.
+		{t0.Add(45 * time.Second), 200},
+		{t0.Add(55 * time.Second), 250},
+		// All content was committed before |t|.
+		{t0.Add(time.Minute + 5*time.Second), 300},
+	}
+	for _, tc := range cases {
+		var offset, err = ResolveTimestamp(set, tc.t, cfs)
+		c.Check(err, gc.IsNil)
+		c.Check(offset, gc.Equals, tc.offset)
+	}
+}
+
+var _ = gc.Suite(&TimeIndexSuite{})
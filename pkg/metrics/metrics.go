@@ -9,9 +9,22 @@ import "github.com/prometheus/client_golang/prometheus"
 const (
 	CoalescedAppendsTotalKey          = "gazette_coalesced_appends_total"
 	CommittedBytesTotalKey            = "gazette_committed_bytes_total"
+	DuplicateAppendsTotalKey          = "gazette_duplicate_appends_total"
 	FailedCommitsTotalKey             = "gazette_failed_commits_total"
+	FragmentCacheBytesKey             = "gazette_fragment_cache_bytes"
+	FragmentCacheEvictionsTotalKey    = "gazette_fragment_cache_evictions_total"
+	FragmentCacheHitsTotalKey         = "gazette_fragment_cache_hits_total"
+	FragmentCacheMissesTotalKey       = "gazette_fragment_cache_misses_total"
 	ItemRouteDurationSecondsKey       = "gazette_item_route_duration_seconds"
+	PersistDurationSecondsTotalKey    = "gazette_persist_duration_seconds_total"
+	PersistedBytesTotalKey            = "gazette_persisted_bytes_total"
+	PersistedFragmentsTotalKey        = "gazette_persisted_fragments_total"
 	RecoveryLogRecoveredBytesTotalKey = "gazette_recoverylog_recovered_bytes_total"
+	RetentionRemovedBytesTotalKey     = "gazette_retention_removed_bytes_total"
+	RetentionRemovedFragmentsTotalKey = "gazette_retention_removed_fragments_total"
+	ScrubFindingsTotalKey             = "gazette_scrub_findings_total"
+	ScrubbedBytesTotalKey             = "gazette_scrubbed_bytes_total"
+	ScrubbedFragmentsTotalKey         = "gazette_scrubbed_fragments_total"
 )
 
 // Collectors for gazette metrics.
@@ -24,27 +37,92 @@ var (
 		Name: CommittedBytesTotalKey,
 		Help: "Cumulative number of bytes committed.",
 	})
+	DuplicateAppendsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: DuplicateAppendsTotalKey,
+		Help: "Cumulative number of retried producer appends which were already committed.",
+	})
 	FailedCommitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: FailedCommitsTotalKey,
 		Help: "Cumulative number of failed commits.",
 	})
+	FragmentCacheBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: FragmentCacheBytesKey,
+		Help: "Number of remote fragment bytes held by the local fragment cache.",
+	})
+	FragmentCacheEvictionsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: FragmentCacheEvictionsTotalKey,
+		Help: "Cumulative number of fragments evicted from the local fragment cache.",
+	})
+	FragmentCacheHitsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: FragmentCacheHitsTotalKey,
+		Help: "Cumulative number of remote fragment reads served by the local fragment cache.",
+	})
+	FragmentCacheMissesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: FragmentCacheMissesTotalKey,
+		Help: "Cumulative number of remote fragment reads which filled the local fragment cache.",
+	})
 	ItemRouteDurationSeconds = prometheus.NewHistogram(prometheus.HistogramOpts{
 		Name: ItemRouteDurationSecondsKey,
 		Help: "Benchmarking of Runner.ItemRoute calls.",
 	})
+	PersistDurationSecondsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: PersistDurationSecondsTotalKey,
+		Help: "Cumulative number of seconds spent uploading persisted fragments.",
+	})
+	PersistedBytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: PersistedBytesTotalKey,
+		Help: "Cumulative number of bytes uploaded by the fragment persister.",
+	})
+	PersistedFragmentsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: PersistedFragmentsTotalKey,
+		Help: "Cumulative number of fragments uploaded by the fragment persister.",
+	})
 	RecoveryLogRecoveredBytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
 		Name: RecoveryLogRecoveredBytesTotalKey,
 		Help: "Cumulative number of bytes recovered.",
 	})
+	RetentionRemovedBytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: RetentionRemovedBytesTotalKey,
+		Help: "Cumulative number of expired fragment bytes removed.",
+	})
+	RetentionRemovedFragmentsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: RetentionRemovedFragmentsTotalKey,
+		Help: "Cumulative number of expired fragments removed.",
+	})
+	ScrubFindingsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: ScrubFindingsTotalKey,
+		Help: "Cumulative number of fragment integrity issues found by scrubs.",
+	}, []string{"kind"})
+	ScrubbedBytesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: ScrubbedBytesTotalKey,
+		Help: "Cumulative number of fragment bytes verified by scrubs.",
+	})
+	ScrubbedFragmentsTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: ScrubbedFragmentsTotalKey,
+		Help: "Cumulative number of fragments verified by scrubs.",
+	})
 )
 
 func GazetteCollectors() []prometheus.Collector {
 	return []prometheus.Collector{
 		CoalescedAppendsTotal,
 		CommittedBytesTotal,
+		DuplicateAppendsTotal,
 		FailedCommitsTotal,
+		FragmentCacheBytes,
+		FragmentCacheEvictionsTotal,
+		FragmentCacheHitsTotal,
+		FragmentCacheMissesTotal,
 		ItemRouteDurationSeconds,
+		PersistDurationSecondsTotal,
+		PersistedBytesTotal,
+		PersistedFragmentsTotal,
 		RecoveryLogRecoveredBytesTotal,
+		RetentionRemovedBytesTotal,
+		RetentionRemovedFragmentsTotal,
+		ScrubFindingsTotal,
+		ScrubbedBytesTotal,
+		ScrubbedFragmentsTotal,
 	}
 }
 
@@ -65,43 +143,6 @@ func GazconsumerCollectors() []prometheus.Collector {
 	return []prometheus.Collector{GazconsumerLagBytes}
 }
 
-// Keys for gazretention metrics.
-const (
-	GazretentionDeletedBytesTotalKey      = "gazretention_deleted_bytes_total"
-	GazretentionDeletedFragmentsTotalKey  = "gazretention_deleted_fragments_total"
-	GazretentionRetainedBytesTotalKey     = "gazretention_retained_bytes_total"
-	GazretentionRetainedFragmentsTotalKey = "gazretention_retained_fragments_total"
-)
-
-// Collectors for gazretention metrics.
-var (
-	GazretentionDeletedBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: GazretentionDeletedBytesTotalKey,
-		Help: "Cumulative number of bytes deleted.",
-	}, []string{"prefix"})
-	GazretentionDeletedFragmentsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: GazretentionDeletedFragmentsTotalKey,
-		Help: "Cumulative number of fragments deleted.",
-	}, []string{"prefix"})
-	GazretentionRetainedBytesTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: GazretentionRetainedBytesTotalKey,
-		Help: "Cumulative number of bytes retained.",
-	}, []string{"prefix"})
-	GazretentionRetainedFragmentsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
-		Name: GazretentionRetainedFragmentsTotalKey,
-		Help: "Cumulative number of fragments retained.",
-	}, []string{"prefix"})
-)
-
-func GazretentionCollectors() []prometheus.Collector {
-	return []prometheus.Collector{
-		GazretentionDeletedBytesTotal,
-		GazretentionDeletedFragmentsTotal,
-		GazretentionRetainedBytesTotal,
-		GazretentionRetainedFragmentsTotal,
-	}
-}
-
 // Keys for gazette.Client and gazette.WriteService metrics.
 const (
 	GazetteDiscardBytesTotalKey         = "gazette_discard_bytes_total"
@@ -0,0 +1,114 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"path/filepath"
+	"time"
+)
+
+// TestFiles are paths of certificates and keys written by
+// WriteTestCertificates.
+type TestFiles struct {
+	// CA certificate, which signed all other certificates.
+	CA string
+	// Certificate and key of a server at "localhost" and 127.0.0.1. The
+	// certificate may also be used as a client certificate.
+	ServerCert, ServerKey string
+	// Certificate and key of a client.
+	ClientCert, ClientKey string
+}
+
+// WriteTestCertificates generates a CA and server and client certificates
+// signed by it, and writes them to |dir|. It's intended for use in tests.
+func WriteTestCertificates(dir string) (TestFiles, error) {
+	var files = TestFiles{
+		CA:         filepath.Join(dir, "ca.crt"),
+		ServerCert: filepath.Join(dir, "server.crt"),
+		ServerKey:  filepath.Join(dir, "server.key"),
+		ClientCert: filepath.Join(dir, "client.crt"),
+		ClientKey:  filepath.Join(dir, "client.key"),
+	}
+	var notBefore = time.Now().Add(-time.Hour)
+	var notAfter = notBefore.Add(24 * time.Hour)
+
+	var caKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return files, err
+	}
+	var caTemplate = &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             notBefore,
+		NotAfter:              notAfter,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	if err != nil {
+		return files, err
+	} else if err = writePEM(files.CA, "CERTIFICATE", caDER); err != nil {
+		return files, err
+	}
+
+	var leaves = []struct {
+		template          *x509.Certificate
+		certFile, keyFile string
+	}{
+		{
+			template: &x509.Certificate{
+				SerialNumber: big.NewInt(2),
+				Subject:      pkix.Name{CommonName: "localhost"},
+				DNSNames:     []string{"localhost"},
+				IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+				ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+			},
+			certFile: files.ServerCert,
+			keyFile:  files.ServerKey,
+		},
+		{
+			template: &x509.Certificate{
+				SerialNumber: big.NewInt(3),
+				Subject:      pkix.Name{CommonName: "client"},
+				ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+			},
+			certFile: files.ClientCert,
+			keyFile:  files.ClientKey,
+		},
+	}
+	for _, leaf := range leaves {
+		leaf.template.NotBefore, leaf.template.NotAfter = notBefore, notAfter
+		leaf.template.KeyUsage = x509.KeyUsageDigitalSignature
+
+		key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		if err != nil {
+			return files, err
+		}
+		der, err := x509.CreateCertificate(rand.Reader, leaf.template, caTemplate, &key.PublicKey, caKey)
+		if err != nil {
+			return files, err
+		}
+		keyDER, err := x509.MarshalECPrivateKey(key)
+		if err != nil {
+			return files, err
+		}
+		if err = writePEM(leaf.certFile, "CERTIFICATE", der); err != nil {
+			return files, err
+		} else if err = writePEM(leaf.keyFile, "EC PRIVATE KEY", keyDER); err != nil {
+			return files, err
+		}
+	}
+	return files, nil
+}
+
+func writePEM(path, blockType string, der []byte) error {
+	return ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0600)
+}
@@ -0,0 +1,46 @@
+// Package tlsconfig builds tls.Configs for servers and clients of Gazette
+// services, with optional mutual authentication of peers.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// Load returns a tls.Config suitable for use by both servers and clients.
+// If |certFile| and |keyFile| are non-empty, the config presents their
+// PEM-encoded certificate chain and private key: to clients when used by a
+// server, and to servers which request a client certificate when used by a
+// client. If |caFile| is non-empty, its PEM-encoded CA certificates are used
+// to verify servers, and the config requires and verifies client
+// certificates against them (mutual TLS). Otherwise, servers are verified
+// against the host's root CAs and client certificates are not required.
+func Load(certFile, keyFile, caFile string) (*tls.Config, error) {
+	var config = &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if certFile != "" || keyFile != "" {
+		var cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading certificate: %s", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	if caFile != "" {
+		var pem, err = ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA: %s", err)
+		}
+		var pool = x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.New("no CA certificates found in " + caFile)
+		}
+		config.RootCAs = pool
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return config, nil
+}
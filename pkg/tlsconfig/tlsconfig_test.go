@@ -0,0 +1,96 @@
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"io"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	gc "github.com/go-check/check"
+)
+
+type TLSConfigSuite struct {
+	dir   string
+	files TestFiles
+}
+
+func (s *TLSConfigSuite) SetUpSuite(c *gc.C) {
+	var err error
+	s.dir, err = ioutil.TempDir("", "tlsconfig-suite")
+	c.Assert(err, gc.IsNil)
+
+	s.files, err = WriteTestCertificates(s.dir)
+	c.Assert(err, gc.IsNil)
+}
+
+func (s *TLSConfigSuite) TearDownSuite(c *gc.C) {
+	os.RemoveAll(s.dir)
+}
+
+func (s *TLSConfigSuite) TestMutualAuthentication(c *gc.C) {
+	var server, err = Load(s.files.ServerCert, s.files.ServerKey, s.files.CA)
+	c.Assert(err, gc.IsNil)
+	c.Check(server.ClientAuth, gc.Equals, tls.RequireAndVerifyClientCert)
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", server)
+	c.Assert(err, gc.IsNil)
+	defer l.Close()
+
+	go func() {
+		for {
+			var conn, err = l.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	var handshake = func(config *tls.Config) error {
+		var conn, err = tls.Dial("tcp", l.Addr().String(), config)
+		if err == nil {
+			// The server's verification of our certificate completes on read.
+			_, err = conn.Read(make([]byte, 1))
+			conn.Close()
+		}
+		if err == io.EOF {
+			err = nil
+		}
+		return err
+	}
+
+	// A client presenting a certificate signed by the CA is authenticated.
+	client, err := Load(s.files.ClientCert, s.files.ClientKey, s.files.CA)
+	c.Assert(err, gc.IsNil)
+	c.Check(handshake(client), gc.IsNil)
+
+	// Server certificates may also be used as client certificates.
+	c.Check(handshake(server), gc.IsNil)
+
+	// A client without a certificate is rejected.
+	client, err = Load("", "", s.files.CA)
+	c.Assert(err, gc.IsNil)
+	c.Check(handshake(client), gc.NotNil)
+
+	// As is a client which doesn't trust the server's CA.
+	client, err = Load(s.files.ClientCert, s.files.ClientKey, "")
+	c.Assert(err, gc.IsNil)
+	c.Check(handshake(client), gc.ErrorMatches, ".*certificate signed by unknown authority")
+}
+
+func (s *TLSConfigSuite) TestLoadErrors(c *gc.C) {
+	var _, err = Load(s.files.ServerCert, "", "")
+	c.Check(err, gc.ErrorMatches, "loading certificate: .*")
+
+	_, err = Load("", "", s.dir+"/does-not-exist")
+	c.Check(err, gc.ErrorMatches, "reading CA: .*")
+
+	_, err = Load("", "", s.files.ServerKey)
+	c.Check(err, gc.ErrorMatches, "no CA certificates found in .*")
+}
+
+var _ = gc.Suite(&TLSConfigSuite{})
+
+func Test(t *testing.T) { gc.TestingT(t) }
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"fmt"
 	"hash/fnv"
+	"sort"
 
 	"github.com/LiveRamp/gazette/pkg/journal"
 )
@@ -98,6 +99,29 @@ func EnumeratePartitions(name string, partitions int) func() []journal.Name {
 	}
 }
 
+// ListPartitions returns a closure suitable for use as Description.Partitions,
+// which returns the sorted journals listed by |lister| under |args|. Journals
+// are listed once, when ListPartitions is called. It's an error if no journals
+// are listed.
+func ListPartitions(lister journal.Lister, args journal.ListArgs) (func() []journal.Name, error) {
+	var listed, err = lister.List(args)
+	if err != nil {
+		return nil, err
+	} else if len(listed) == 0 {
+		return nil, fmt.Errorf("no journals listed for %+v", args)
+	}
+
+	var result []journal.Name
+	for _, l := range listed {
+		result = append(result, l.Name)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+
+	return func() []journal.Name {
+		return result
+	}, nil
+}
+
 // ModuloPartitionMapping returns a closure which maps a Message into a stable
 // member of |partitions| using modulo arithmetic. It requires a |routingKey|
 // function, which extracts and encodes a key from Message,
@@ -1,6 +1,7 @@
 package topic
 
 import (
+	"errors"
 	"fmt"
 	"math/rand"
 	"strconv"
@@ -8,6 +9,8 @@ import (
 	"time"
 
 	gc "github.com/go-check/check"
+
+	"github.com/LiveRamp/gazette/pkg/journal"
 )
 
 type TopicSuite struct{}
@@ -39,6 +42,34 @@ func (s *TopicSuite) TestRoutingRegressionFixtures(c *gc.C) {
 	}
 }
 
+func (s *TopicSuite) TestListPartitions(c *gc.C) {
+	var lister journal.MockLister
+	var args = journal.ListArgs{Labels: map[string]string{"topic": "a/topic"}}
+
+	lister.On("List", args).Return([]journal.ListedJournal{
+		{Name: "a/topic/part-002"},
+		{Name: "a/topic/part-000"},
+		{Name: "a/topic/part-001"},
+	}, nil).Once()
+
+	var partitions, err = ListPartitions(&lister, args)
+	c.Check(err, gc.IsNil)
+	c.Check(partitions(), gc.DeepEquals, []journal.Name{
+		"a/topic/part-000", "a/topic/part-001", "a/topic/part-002"})
+
+	// Expect an empty listing is an error.
+	lister.On("List", args).Return(nil, nil).Once()
+	_, err = ListPartitions(&lister, args)
+	c.Check(err, gc.ErrorMatches, "no journals listed for .*")
+
+	// As is a List error.
+	lister.On("List", args).Return(nil, errors.New("error!")).Once()
+	_, err = ListPartitions(&lister, args)
+	c.Check(err, gc.ErrorMatches, "error!")
+
+	lister.AssertExpectations(c)
+}
+
 func (s *TopicSuite) TestModuloRoutingWithCommonFactors(c *gc.C) {
 	var seed = time.Now().UnixNano()
 	c.Log("seed:", seed)